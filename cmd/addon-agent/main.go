@@ -17,7 +17,9 @@ import (
 
 	"open-cluster-management.io/addon-framework/pkg/lease"
 	addonutils "open-cluster-management.io/addon-framework/pkg/utils"
+	addonclient "open-cluster-management.io/api/client/addon/clientset/versioned"
 	"open-cluster-management.io/cluster-proxy/pkg/common"
+	"open-cluster-management.io/cluster-proxy/pkg/config"
 	"open-cluster-management.io/cluster-proxy/pkg/util"
 
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -29,7 +31,9 @@ var (
 	clusterName                 string
 	proxyServerNamespace        string
 	enablePortForwardProxy      bool
+	proxyServerAgentPort        int
 	enableProxyAgentHealthCheck bool
+	agentDeploymentName         string
 )
 
 // envKeyPodNamespace represents the environment variable key for the addon agent namespace.
@@ -75,8 +79,12 @@ func main() {
 		"The namespace where proxy-server pod lives")
 	flag.BoolVar(&enablePortForwardProxy, "enable-port-forward-proxy", false,
 		"If true, running a local server forwarding tunnel shakes to proxy-server pods")
+	flag.IntVar(&proxyServerAgentPort, "proxy-server-agent-port", int(config.DefaultAgentServerPort),
+		"The port of the proxy-server pods serving tunnel handshakes, also the listening port of the local port-forward proxy")
 	flag.BoolVar(&enableProxyAgentHealthCheck, "enable-proxy-agent-health-check", true,
 		"If true, check proxy-agent connection status before updating lease")
+	flag.StringVar(&agentDeploymentName, "agent-deployment-name", "cluster-proxy-proxy-agent",
+		"The name of the deployment running the proxy-agent, whose rollout is reported to the hub")
 	flag.Parse()
 
 	// pipe controller-runtime logs to klog
@@ -121,7 +129,7 @@ func main() {
 			readiness,
 			proxyServerNamespace,
 			common.LabelKeyComponentName+"="+common.ComponentNameProxyServer,
-			8091,
+			int32(proxyServerAgentPort), //nolint:gosec // a port number
 		)
 		_, err := rr.Listen(ctx)
 		if err != nil {
@@ -146,6 +154,15 @@ func main() {
 		},
 	})
 
+	// the hub waits for the agents to be rolled out with the new CA bundle and service-proxy
+	// certificate along the rotation of the signer CA.
+	hubAddonClient, err := addonclient.NewForConfig(cfg)
+	if err != nil {
+		panic(fmt.Errorf("failed to create hub addon client, err: %w", err))
+	}
+	util.NewAgentRolloutReporter(spokeClient, hubAddonClient, clusterName, addonAgentNamespace, agentDeploymentName).
+		Start(ctx, time.Minute)
+
 	klog.Infof("Starting lease updater")
 	leaseUpdater.Start(ctx)
 	<-ctx.Done()
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"os"
 	"strings"
@@ -42,18 +43,22 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
 	addonclient "open-cluster-management.io/api/client/addon/clientset/versioned"
 	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
 	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	proxyv1beta1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1beta1"
 	"open-cluster-management.io/cluster-proxy/pkg/config"
 	"open-cluster-management.io/cluster-proxy/pkg/features"
 	proxyclient "open-cluster-management.io/cluster-proxy/pkg/generated/clientset/versioned"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyagent/agent"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/controllers"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/certmanager"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+	proxywebhook "open-cluster-management.io/cluster-proxy/pkg/proxyserver/webhook"
 	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
 	//+kubebuilder:scaffold:imports
 )
@@ -68,6 +73,7 @@ func init() {
 
 	utilruntime.Must(addonv1beta1.Install(scheme))
 	utilruntime.Must(proxyv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(proxyv1beta1.AddToScheme(scheme))
 	utilruntime.Must(clusterv1beta2.Install(scheme))
 	utilruntime.Must(cpv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
@@ -82,6 +88,8 @@ func main() {
 	var enableServiceProxy bool
 	var enableNetworkPolicies bool
 	var imagePullPolicy string
+	var webhookPort int
+	var webhookServiceName string
 	var featureGates map[string]bool
 
 	logger := textlogger.NewLogger(textlogger.NewConfig())
@@ -106,6 +114,10 @@ func main() {
 	flag.StringVar(&config.DefaultAddonInstallNamespace, "agent-install-namespace", config.DefaultAddonInstallNamespace,
 		"The default namespace to install the addon agents.")
 	flag.StringVar(&imagePullPolicy, "image-pull-policy", string(corev1.PullIfNotPresent), "The image pull policy for the addon manager")
+	flag.IntVar(&webhookPort, "webhook-port", 0,
+		"The port serving the admission and conversion webhooks of ManagedProxyConfiguration, 0 disables the webhooks")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", "cluster-proxy-addon-manager-webhook",
+		"The name of the service in the pod namespace routing to the webhooks")
 	flag.Var(cliflag.NewMapStringBool(&featureGates), "feature-gates",
 		"A set of key=value pairs that describe feature gates for alpha/experimental features. "+
 			"Options are:\n"+strings.Join(features.FeatureGates.KnownFeatures(), "\n"))
@@ -121,9 +133,41 @@ func main() {
 	// pipe controller-runtime logs to klog
 	ctrl.SetLogger(logger)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	podNamespace := os.Getenv("POD_NAMESPACE")
+	if len(podNamespace) == 0 {
+		klog.Fatalf("Pod namespace is empty, please set the ENV for POD_NAMESPACE")
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	nativeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to set up kubernetes native client")
+		os.Exit(1)
+	}
+
+	// the TLS profile is loaded below, before the webhook server is started by the manager.
+	var sdkTLSConfig *sdktls.TLSConfig
+	var webhookServingCert *proxywebhook.ServingCertificate
+	webhookOptions := webhook.Options{}
+	if webhookPort > 0 {
+		webhookServingCert, err = proxywebhook.NewServingCertificate(nativeClient, podNamespace, webhookServiceName)
+		if err != nil {
+			setupLog.Error(err, "unable to set up webhook serving certificate")
+			os.Exit(1)
+		}
+		webhookOptions = webhook.Options{
+			Port: webhookPort,
+			TLSOpts: []func(*tls.Config){
+				webhookServingCert.ConfigureTLS,
+				func(config *tls.Config) { sdktls.ConfigToFunc(sdkTLSConfig)(config) },
+			},
+		}
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		WebhookServer:          webhook.NewServer(webhookOptions),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "cluster-proxy-addon-manager",
@@ -133,12 +177,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	nativeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
-	if err != nil {
-		setupLog.Error(err, "unable to set up kubernetes native client")
-		os.Exit(1)
-	}
-
 	addonClient, err := addonclient.NewForConfig(mgr.GetConfig())
 	if err != nil {
 		setupLog.Error(err, "unable to set up ocm addon client")
@@ -161,28 +199,64 @@ func main() {
 	}
 	ownerRef := selfsigned.NewOwnerReferenceFromConfig(proxyConfig)
 
-	// loading self-signer
-	selfSigner, err := selfsigned.NewSelfSignerFromSecretOrGenerate(
-		nativeClient, signerSecretNamespace, signerSecretName, ownerRef)
-	if err != nil {
-		setupLog.Error(err, "failed loading self-signer")
-		os.Exit(1)
+	// loading the signer prescribed in the ManagedProxyConfiguration
+	var selfSigner selfsigned.SelfSigner
+	var certManagerSigner *certmanager.Signer
+	signerConfig := proxyConfig.Spec.Authentication.Signer
+	switch signerConfig.Type {
+	case proxyv1alpha1.Provided:
+		if signerConfig.Provided == nil {
+			setupLog.Error(nil, "provided is required for the Provided signer")
+			os.Exit(1)
+		}
+		selfSigner, err = selfsigned.NewSelfSignerFromProvidedSecret(
+			nativeClient, signerConfig.Provided.SecretNamespace, signerConfig.Provided.SecretName)
+		if err != nil {
+			setupLog.Error(err, "failed loading provided signer")
+			os.Exit(1)
+		}
+	case proxyv1alpha1.CertManager:
+		if signerConfig.CertManager == nil {
+			setupLog.Error(nil, "certManager is required for the CertManager signer")
+			os.Exit(1)
+		}
+		certManagerSigner, err = certmanager.NewSigner(mgr.GetClient(), nativeClient.CoreV1(),
+			proxyConfig.Spec.ProxyServer.Namespace,
+			signerConfig.CertManager.IssuerRef,
+			signerConfig.CertManager.CABundle)
+		if err != nil {
+			setupLog.Error(err, "failed loading cert-manager ca bundle")
+			os.Exit(1)
+		}
+		// the CA of the issuer may be renewed, so the agents are handed the current bundle.
+		if err := certManagerSigner.ReloadOnChange(nativeInformer.Core().V1().Secrets().Informer()); err != nil {
+			setupLog.Error(err, "failed watching cert-manager ca bundle")
+			os.Exit(1)
+		}
+	default:
+		rotatingSigner, err := selfsigned.NewRotatingSignerFromSecretOrGenerate(
+			nativeClient, signerSecretNamespace, signerSecretName, ownerRef)
+		if err != nil {
+			setupLog.Error(err, "failed loading self-signer")
+			os.Exit(1)
+		}
+		// the CA rotation is advanced by the leader, so the followers reload the signer from the secret.
+		if err := rotatingSigner.ReloadOnChange(nativeInformer.Core().V1().Secrets().Informer()); err != nil {
+			setupLog.Error(err, "failed watching self-signer")
+			os.Exit(1)
+		}
+		selfSigner = rotatingSigner
 	}
 
 	ctx, cancel := context.WithCancel(ctrl.SetupSignalHandler())
 	defer cancel()
 
-	podNamespace := os.Getenv("POD_NAMESPACE")
-	if len(podNamespace) == 0 {
-		klog.Fatalf("Pod namespace is empty, please set the ENV for POD_NAMESPACE")
-	}
-
 	// Using common code to watch for the TLS Profile configmap and restart.
-	// Note that technically this addon-manager does not serve any thing with tls directly and only
+	// Note that the addon-manager only serves the webhooks with tls directly and otherwise
 	// updates the configuration in the proxyServer via the RegisterClusterManagementAddonReconciler.
 	// So we could alternatively add a watch there on the configmap and simply reconcile.
 	// This way keeps it simpler with common code, but does restart when the configmap changes
-	sdkTLSConfig, err := sdktls.StartTLSConfigMapWatcher(ctx, nativeClient, podNamespace, func() {
+	sdkTLSConfig, err = sdktls.StartTLSConfigMapWatcher(ctx, nativeClient, podNamespace, func() {
 		klog.Info("TLS ConfigMap changed, shutting down gracefully for restart")
 		cancel()
 	})
@@ -201,6 +275,7 @@ func main() {
 		imagePullPolicy,
 		ownerRef,
 		sdkTLSConfig,
+		signerSecretNamespace,
 	); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClusterManagementAddonReconciler")
 		os.Exit(1)
@@ -214,6 +289,23 @@ func main() {
 		}
 	}
 
+	if webhookPort > 0 {
+		if err := proxywebhook.SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ManagedProxyConfiguration")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&proxywebhook.CABundleInjector{
+			NativeClient: nativeClient,
+			Client:       mgr.GetClient(),
+			CAData:       webhookServingCert.CAData(),
+			Namespace:    podNamespace,
+			ServiceName:  webhookServiceName,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up webhook ca bundle injector")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -232,8 +324,10 @@ func main() {
 
 	clusterProxyAddon, err := agent.NewAgentAddon(
 		selfSigner,
+		certManagerSigner,
 		signerSecretNamespace,
 		mgr.GetClient(),
+		mgr.GetCache(),
 		nativeClient,
 		enableKubeAPIProxy,
 		enableServiceProxy,
@@ -17,6 +17,7 @@ import (
 
 	"open-cluster-management.io/cluster-proxy/pkg/controllers"
 	"open-cluster-management.io/cluster-proxy/pkg/serviceproxy"
+	"open-cluster-management.io/cluster-proxy/pkg/tcptunnel"
 	"open-cluster-management.io/cluster-proxy/pkg/userserver"
 	"open-cluster-management.io/cluster-proxy/pkg/version"
 )
@@ -70,6 +71,7 @@ func newClusterProxyCommand() *cobra.Command {
 	cmd.AddCommand(userserver.NewUserServerCommand())
 	cmd.AddCommand(serviceproxy.NewServiceProxyCommand())
 	cmd.AddCommand(controllers.NewControllersCommand())
+	cmd.AddCommand(tcptunnel.NewTunnelCommand())
 
 	return cmd
 }
@@ -0,0 +1,5 @@
+package v1alpha1
+
+// Hub marks v1alpha1 as the storage version which the other versions of
+// ManagedProxyConfiguration are converted from and to.
+func (*ManagedProxyConfiguration) Hub() {}
@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // ManagedProxyConfigurationSpec is the prescription of ManagedProxyConfiguration
@@ -49,14 +50,122 @@ type ManagedProxyConfigurationStatus struct {
 	LastObservedGeneration int64 `json:"lastObservedGeneration,omitempty"`
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// `entrypoint` is the addresses resolved for the entrypoint of the proxy servers.
+	// +optional
+	Entrypoint *ManagedProxyConfigurationEntrypointStatus `json:"entrypoint,omitempty"`
+	// `certificates` lists the certificates signed for the proxy servers and their clients.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Certificates []ManagedProxyConfigurationCertificateStatus `json:"certificates,omitempty"`
+	// `proxyServers` lists the proxy agents connected to each of the proxy-server replicas.
+	// +optional
+	// +listType=map
+	// +listMapKey=podName
+	ProxyServers []ManagedProxyConfigurationProxyServerStatus `json:"proxyServers,omitempty"`
+	// `caRotation` reports the progress of the latest rotation of the self-signed CA.
+	// +optional
+	CARotation *ManagedProxyConfigurationCARotationStatus `json:"caRotation,omitempty"`
+}
+
+// CARotationPhase is a step of the self-signed CA rotation.
+type CARotationPhase string
+
+const (
+	// CARotationPhaseTrusting adds the new CA to the trust bundles of the proxy servers, the
+	// user-server and the proxy agents, while the certificates are still signed by the
+	// previous CA.
+	CARotationPhaseTrusting CARotationPhase = "Trusting"
+	// CARotationPhaseReissuing signs the certificates of the proxy servers, their clients and
+	// the proxy agents by the new CA, while the previous CA is still trusted.
+	CARotationPhaseReissuing CARotationPhase = "Reissuing"
+	// CARotationPhaseCompleted retires the previous CA from the trust bundles.
+	CARotationPhaseCompleted CARotationPhase = "Completed"
+)
+
+// ManagedProxyConfigurationCARotationStatus reports the progress of a CA rotation. A phase
+// advances after the proxy servers are rolled out and every managed cluster acknowledges it.
+type ManagedProxyConfigurationCARotationStatus struct {
+	// `trigger` is the "caRotationTrigger" the rotation is started for.
+	// +optional
+	Trigger string `json:"trigger,omitempty"`
+	// `phase` is the current step of the rotation, one of "Trusting", "Reissuing" and
+	// "Completed".
+	// +required
+	Phase CARotationPhase `json:"phase"`
+	// `startTime` is when the rotation was started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// `lastTransitionTime` is when the rotation entered the current phase.
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// `totalClusters` is the number of managed clusters the current phase waits for.
+	// +optional
+	TotalClusters int32 `json:"totalClusters,omitempty"`
+	// `pendingClusters` lists the managed clusters yet to acknowledge the current phase, i.e.
+	// to apply the proxy agents trusting the new CA in the "Trusting" phase, or to renew the
+	// client certificates of the proxy agents from the new CA in the "Reissuing" phase.
+	// Removing the addon from a managed cluster stops waiting for it.
+	// +optional
+	// +listType=set
+	PendingClusters []string `json:"pendingClusters,omitempty"`
+}
+
+// ManagedProxyConfigurationCertificateStatus describes a certificate dumped into a secret
+// by the addon-manager.
+type ManagedProxyConfigurationCertificateStatus struct {
+	// `name` is the component the certificate is signed for, one of "proxy-server",
+	// "agent-server", "proxy-client", "user-server" and "service-proxy".
+	// +required
+	Name string `json:"name"`
+	// `secretNamespace` is the namespace of the secret.
+	// +required
+	SecretNamespace string `json:"secretNamespace"`
+	// `secretName` is the name of the secret.
+	// +required
+	SecretName string `json:"secretName"`
+	// `notAfter` is the expiry of the certificate.
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+	// `lastRotationTime` is when the certificate was last signed.
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
+// ManagedProxyConfigurationProxyServerStatus reports the connections served by a proxy-server
+// replica, as scraped from its metrics endpoint.
+type ManagedProxyConfigurationProxyServerStatus struct {
+	// `podName` is the name of the proxy-server pod.
+	// +required
+	PodName string `json:"podName"`
+	// `connectedAgents` is the number of proxy agents connected to the replica.
+	// +optional
+	ConnectedAgents int32 `json:"connectedAgents,omitempty"`
+}
+
+// ManagedProxyConfigurationEntrypointStatus lists the addresses through which the
+// proxy agents reach the proxy servers.
+type ManagedProxyConfigurationEntrypointStatus struct {
+	// `hostnames` are the DNS names published for the entrypoint.
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+	// `ips` are the IPv4 or IPv6 addresses published for the entrypoint.
+	// +optional
+	IPs []string `json:"ips,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Entrypoint",type=string,JSONPath=`.spec.proxyServer.entrypoint.type`
+//+kubebuilder:printcolumn:name="Hostname",type=string,JSONPath=`.status.entrypoint.hostnames[0]`
+//+kubebuilder:printcolumn:name="IP",type=string,JSONPath=`.status.entrypoint.ips[0]`
+//+kubebuilder:printcolumn:name="Deployed",type=string,JSONPath=`.status.conditions[?(@.type=="ProxyServerDeployed")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // +genclient
 // +genclient:nonNamespaced
+// +kubebuilder:storageversion
 // ManagedProxyConfiguration is the Schema for the managedproxyconfigurations API
 type ManagedProxyConfiguration struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -88,8 +197,12 @@ var (
 	// Note that the namespace and name can be configured via "--signer-secret-name"
 	// and "signer-secret-namespace" at the addon-manager.
 	SelfSigned AuthenticationSignerType = "SelfSigned"
-	//Provided    AuthenticationType = "Provided"
-	//CertManager AuthenticationType = "CertManager"
+	// `Provided` prescribes the CA certificate and key should be read from an
+	// externally provided secret, e.g. an intermediate CA of the corporate PKI.
+	Provided AuthenticationSignerType = "Provided"
+	// `CertManager` prescribes the certificates should be issued by a cert-manager
+	// Issuer or ClusterIssuer.
+	CertManager AuthenticationSignerType = "CertManager"
 )
 
 // ManagedProxyConfigurationAuthentication prescribes how we manage the authentication
@@ -104,18 +217,70 @@ type ManagedProxyConfigurationAuthentication struct {
 	// +optional
 	// `dump` is where we store the signed certificates from signers.
 	Dump ManagedProxyConfigurationCertificateDump `json:"dump"`
+	// +optional
+	// `lifetimes` prescribes the validity and the renewal of the signed certificates.
+	Lifetimes ManagedProxyConfigurationCertificateLifetimes `json:"lifetimes,omitempty"`
+}
+
+// ManagedProxyConfigurationCertificateLifetimes prescribes the lifetimes of the signed
+// certificates by their classes. None of the certificates can outlive the signer CA.
+type ManagedProxyConfigurationCertificateLifetimes struct {
+	// +optional
+	// `server` applies to the serving certificates of the proxy servers, the user-server
+	// and the service-proxy.
+	Server CertificateLifetime `json:"server,omitempty"`
+	// +optional
+	// `proxyClient` applies to the client certificate presented to the proxy servers by
+	// their clients, e.g. the user-server.
+	ProxyClient CertificateLifetime `json:"proxyClient,omitempty"`
+	// +optional
+	// `agent` applies to the client certificates signed for the proxy agents.
+	Agent AgentCertificateLifetime `json:"agent,omitempty"`
+}
+
+// CertificateLifetime prescribes the lifetime of the certificates rotated by the addon-manager.
+type CertificateLifetime struct {
+	// +optional
+	// `validity` is how long the certificates are valid, "4320h" (180 days) by default. The
+	// default is shortened to the remaining lifetime of the signer CA.
+	Validity *metav1.Duration `json:"validity,omitempty"`
+	// +optional
+	// `refreshBefore` is how long before the expiry the certificates are renewed, a fifth
+	// of the validity by default. It must be shorter than the validity. The certificates
+	// signed by the addon-manager are renewed once a fifth of the validity is left anyway,
+	// so the longer of the two applies.
+	RefreshBefore *metav1.Duration `json:"refreshBefore,omitempty"`
+}
+
+// AgentCertificateLifetime prescribes the lifetime of the client certificates of the agents.
+type AgentCertificateLifetime struct {
+	// +optional
+	// `validity` is how long the certificates are valid, "4320h" (180 days) by default. The
+	// default is shortened to the remaining lifetime of the signer CA. The agents renew their
+	// certificates on their own once most of the validity has passed.
+	Validity *metav1.Duration `json:"validity,omitempty"`
 }
 
 // ManagedProxyConfigurationCertificateSigner prescribes how to sign certificates
 // for proxy servers and agents.
+// +kubebuilder:validation:XValidation:rule="!has(self.type) || self.type != 'Provided' || has(self.provided)",message="provided is required for the Provided signer"
+// +kubebuilder:validation:XValidation:rule="!has(self.type) || self.type != 'CertManager' || has(self.certManager)",message="certManager is required for the CertManager signer"
 type ManagedProxyConfigurationCertificateSigner struct {
-	// `type` is the supported type of signer. Currently only "SelfSign" supported.
+	// `type` is the supported type of signer, one of "SelfSigned", "Provided" and
+	// "CertManager". Note that the addon-manager needs restarting to pick up a new signer.
 	// +optional
 	// +kubebuilder:default=SelfSigned
 	Type AuthenticationSignerType `json:"type"`
 	// `selfSigned` prescribes the detail of how we self-sign the certificates.
 	// +optional
 	SelfSigned *AuthenticationSelfSigned `json:"selfSigned,omitempty"`
+	// `provided` prescribes the CA secret signing the certificates upon "Provided" type.
+	// +optional
+	Provided *AuthenticationProvided `json:"provided,omitempty"`
+	// `certManager` prescribes the cert-manager issuer signing the certificates upon
+	// "CertManager" type.
+	// +optional
+	CertManager *AuthenticationCertManager `json:"certManager,omitempty"`
 }
 
 // ManagedProxyConfigurationCertificateDump prescribes how to dump the signed
@@ -131,6 +296,73 @@ type AuthenticationSelfSigned struct {
 	// +optional
 	// `additionalSANs` adds a few custom hostnames or IPs to the signing certificates.
 	AdditionalSANs []string `json:"additionalSANs,omitempty"`
+	// +optional
+	// `caRotationTrigger` starts a rotation of the self-signed CA whenever it's changed to a
+	// new non-empty value, e.g. the current date. The new CA is trusted everywhere before it
+	// signs any certificate, and the previous CA is retired only after every managed cluster
+	// renews its certificates, so that no connection is interrupted. The progress is reported
+	// in status.caRotation.
+	CARotationTrigger string `json:"caRotationTrigger,omitempty"`
+}
+
+// AuthenticationProvided prescribes the externally provided CA signing the certificates.
+type AuthenticationProvided struct {
+	// `secretNamespace` is the namespace of the CA secret.
+	// +required
+	SecretNamespace string `json:"secretNamespace"`
+	// `secretName` is the name of the CA secret. The secret is expected to be of the
+	// "kubernetes.io/tls" type, holding the CA certificate in "tls.crt" and its RSA
+	// private key in "tls.key".
+	// +required
+	SecretName string `json:"secretName"`
+	// +optional
+	// `additionalSANs` adds a few custom hostnames or IPs to the signing certificates.
+	AdditionalSANs []string `json:"additionalSANs,omitempty"`
+}
+
+// AuthenticationCertManager prescribes the cert-manager issuer signing the certificates.
+type AuthenticationCertManager struct {
+	// `issuerRef` is the cert-manager issuer signing the certificates. An "Issuer" is
+	// expected to be in the namespace of the proxy servers.
+	// +required
+	IssuerRef CertManagerIssuerReference `json:"issuerRef"`
+	// `caBundle` is the secret holding the CA certificates the issuer chains to, which
+	// are trusted by the proxy servers and agents.
+	// +required
+	CABundle CertManagerCABundle `json:"caBundle"`
+	// +optional
+	// `additionalSANs` adds a few custom hostnames or IPs to the signing certificates.
+	AdditionalSANs []string `json:"additionalSANs,omitempty"`
+}
+
+// CertManagerIssuerReference refers to a cert-manager issuer.
+type CertManagerIssuerReference struct {
+	// `name` is the name of the issuer.
+	// +required
+	Name string `json:"name"`
+	// `kind` is the kind of the issuer, either "Issuer" or "ClusterIssuer".
+	// +optional
+	// +kubebuilder:default=Issuer
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+	// `group` is the API group of the issuer.
+	// +optional
+	// +kubebuilder:default=cert-manager.io
+	Group string `json:"group,omitempty"`
+}
+
+// CertManagerCABundle refers to the PEM-encoded CA certificates in a secret.
+type CertManagerCABundle struct {
+	// `secretNamespace` is the namespace of the secret.
+	// +required
+	SecretNamespace string `json:"secretNamespace"`
+	// `secretName` is the name of the secret.
+	// +required
+	SecretName string `json:"secretName"`
+	// `key` is the data key of the CA certificates in the secret.
+	// +optional
+	// +kubebuilder:default=ca.crt
+	Key string `json:"key,omitempty"`
 }
 
 // CertificateSigningSecrets enumerates the target names of the secrets to be mounted
@@ -215,7 +447,8 @@ type ManagedProxyConfigurationProxyServer struct {
 	// +optional
 	// +kubebuilder:default=open-cluster-management-cluster-proxy
 	Namespace string `json:"namespace,omitempty"`
-	// `entrypoint` defines how will the proxy agents connecting the servers.
+	// `entrypoint` defines how will the proxy agents connecting the servers. It's
+	// required by the admission webhook of the addon-manager.
 	// +optional
 	Entrypoint *ManagedProxyConfigurationProxyServerEntrypoint `json:"entrypoint"`
 
@@ -226,13 +459,84 @@ type ManagedProxyConfigurationProxyServer struct {
 	// NodePlacement defines which Nodes the proxy server are scheduled on. The default is an empty list.
 	// +optional
 	NodePlacement NodePlacement `json:"nodePlacement,omitempty"`
+
+	// `maxUnavailable` is the number of proxy servers that may be down during a rolling
+	// update or a voluntary disruption. Rolling updates never surge beyond `replicas`, so
+	// the running proxy servers stay within `--server-count`. A value resolving to 0, such
+	// as "0%" or a percentage rounding down to 0 proxy servers, is treated as 1. The
+	// PodDisruptionBudget enforcing it is only created for more than one replica.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// `affinity` replaces the default preferred pod anti-affinity spreading the proxy
+	// servers across nodes.
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+
+	// `topologySpreadConstraints` replaces the default best-effort spreading of the proxy
+	// servers across zones.
+	// +optional
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// `imagePullPolicy` is the pull policy of the proxy server image. Empty uses the
+	// `--image-pull-policy` flag of the addon-manager.
+	// +optional
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// `imagePullSecrets` names the secrets in `namespace` used to pull the proxy server image.
+	// +optional
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+
+	// `resources` is the compute resources of the proxy server container.
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// `priorityClassName` is the priority class of the proxy server pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// `podSecurityContext` replaces the default pod security context, which runs as
+	// non-root with the RuntimeDefault seccomp profile.
+	// +optional
+	PodSecurityContext *v1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// `securityContext` replaces the default container security context, which runs as
+	// non-root without privileges or capabilities on a read-only root filesystem.
+	// +optional
+	SecurityContext *v1.SecurityContext `json:"securityContext,omitempty"`
+
+	// `env` adds environment variables to the proxy server container.
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// `volumes` adds volumes to the proxy server pods. The names proxy-server-ca-certs,
+	// proxy-server-certs and proxy-agent-certs are reserved.
+	// +optional
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+
+	// `volumeMounts` mounts `volumes` into the proxy server container.
+	// +optional
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// `podAnnotations` adds annotations to the proxy server pods.
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
 }
 
 // ManagedProxyConfigurationProxyServerEntrypoint prescribes the ingress for serving
 // tunneling handshakes from proxy agents.
+// +kubebuilder:validation:XValidation:rule="self.type != 'Hostname' || (has(self.hostname) && size(self.hostname.value) > 0)",message="hostname is required for the Hostname entrypoint"
+// +kubebuilder:validation:XValidation:rule="self.type != 'LoadBalancerService' || has(self.loadBalancerService)",message="loadBalancerService is required for the LoadBalancerService entrypoint"
+// +kubebuilder:validation:XValidation:rule="self.type != 'GatewayRoute' || has(self.gatewayRoute)",message="gatewayRoute is required for the GatewayRoute entrypoint"
+// +kubebuilder:validation:XValidation:rule="self.type != 'Ingress' || has(self.ingress)",message="ingress is required for the Ingress entrypoint"
+// +kubebuilder:validation:XValidation:rule="self.type != 'Route' || has(self.route)",message="route is required for the Route entrypoint"
 type ManagedProxyConfigurationProxyServerEntrypoint struct {
 	// `type` is the type of the entrypoint of the proxy servers.
-	// Currently supports "Hostname", "LoadBalancerService"
+	// Currently supports "Hostname", "LoadBalancerService", "PortForward",
+	// "GatewayRoute", "Ingress" and "Route"
 	// +required
 	Type EntryPointType `json:"type"`
 	// `loadBalancerService` points to a load-balancer typed service in the hub cluster.
@@ -241,16 +545,27 @@ type ManagedProxyConfigurationProxyServerEntrypoint struct {
 	// `hostname` points to a fixed hostname for serving agents' handshakes.
 	// +optional
 	Hostname *EntryPointHostname `json:"hostname,omitempty"`
+	// `gatewayRoute` attaches a Gateway API route for the proxy servers to existing gateways.
+	// +optional
+	GatewayRoute *EntryPointGatewayRoute `json:"gatewayRoute,omitempty"`
+	// `ingress` exposes the proxy servers through an Ingress with SSL passthrough.
+	// +optional
+	Ingress *EntryPointIngress `json:"ingress,omitempty"`
+	// `route` exposes the proxy servers through an OpenShift passthrough Route.
+	// +optional
+	Route *EntryPointRoute `json:"route,omitempty"`
 
-	// `port` is the target port to access proxy servers
+	// `port` is the target port to access proxy servers. For the "GatewayRoute",
+	// "Ingress" and "Route" types it is the port of the gateway listener or the
+	// ingress controller and defaults to 443; the other types default to the
+	// `agentServer` port.
 	// +optional
-	// +kubebuilder:default=8091
 	// +kubebuilder:validation:Minimum=1
 	Port int32 `json:"port,omitempty"`
 }
 
 // EntryPointType is the type of the entrypoint.
-// +kubebuilder:validation:Enum=Hostname;LoadBalancerService;PortForward
+// +kubebuilder:validation:Enum=Hostname;LoadBalancerService;PortForward;GatewayRoute;Ingress;Route
 type EntryPointType string
 
 var (
@@ -263,6 +578,15 @@ var (
 	// addon-agent which proxies tunnel connection to the proxy-servers via pod
 	// port-forwarding.
 	EntryPointTypePortForward EntryPointType = "PortForward"
+	// GatewayRoute prescribes the proxy agents to connect the addresses of the gateways
+	// which a Gateway API TLSRoute or TCPRoute to the proxy servers is attached to.
+	EntryPointTypeGatewayRoute EntryPointType = "GatewayRoute"
+	// Ingress prescribes the proxy agents to connect the host of an Ingress which passes
+	// TLS through to the proxy servers.
+	EntryPointTypeIngress EntryPointType = "Ingress"
+	// Route prescribes the proxy agents to connect the host of an OpenShift Route which
+	// passes TLS through to the proxy servers.
+	EntryPointTypeRoute EntryPointType = "Route"
 )
 
 // EntryPointLoadBalancerService is the reference to a load-balancer service.
@@ -279,6 +603,26 @@ type EntryPointLoadBalancerService struct {
 	// - service.beta.kubernetes.io/azure-load-balancer-internal: true
 	// +optional
 	Annotations []AnnotationVar `json:"annotations,omitempty"`
+
+	// `loadBalancerClass` is the class of the load-balancer implementation. It can't be
+	// changed once the service is created.
+	// +optional
+	LoadBalancerClass string `json:"loadBalancerClass,omitempty"`
+
+	// `loadBalancerSourceRanges` restricts the client IP ranges allowed through the
+	// load-balancer.
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// `externalTrafficPolicy` is the external traffic policy of the load-balancer service.
+	// +optional
+	// +kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy v1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+
+	// `ipFamilyPolicy` is the IP family policy of the load-balancer service.
+	// +optional
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	IPFamilyPolicy *v1.IPFamilyPolicy `json:"ipFamilyPolicy,omitempty"`
 }
 
 // AnnotationVar list of annotation variables to set in the LB Service.
@@ -299,6 +643,84 @@ type EntryPointHostname struct {
 	Value string `json:"value"`
 }
 
+// GatewayRouteKind is the kind of the Gateway API route.
+// +kubebuilder:validation:Enum=TLSRoute;TCPRoute
+type GatewayRouteKind string
+
+var (
+	// GatewayRouteKindTLSRoute routes by SNI to the proxy servers, which terminate TLS.
+	GatewayRouteKindTLSRoute GatewayRouteKind = "TLSRoute"
+	// GatewayRouteKindTCPRoute forwards all connections of the listener to the proxy servers.
+	GatewayRouteKindTCPRoute GatewayRouteKind = "TCPRoute"
+)
+
+// EntryPointGatewayRoute prescribes the Gateway API route for the proxy servers.
+// +kubebuilder:validation:XValidation:rule="(has(self.kind) && self.kind == 'TCPRoute') || (has(self.hostname) && size(self.hostname) > 0)",message="hostname is required for the TLSRoute entrypoint"
+type EntryPointGatewayRoute struct {
+	// `name` is the name of the route. And the namespace will align to where the
+	// proxy-servers are deployed.
+	// +optional
+	// +kubebuilder:default=proxy-agent-entrypoint
+	Name string `json:"name"`
+	// `kind` is the kind of the route.
+	// +optional
+	// +kubebuilder:default=TLSRoute
+	Kind GatewayRouteKind `json:"kind"`
+	// `hostname` is the SNI hostname matched by a TLSRoute and dialed by the proxy agents.
+	// Required for the TLSRoute kind.
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+	// `parentRefs` are the gateway listeners the route is attached to.
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	ParentRefs []EntryPointGatewayParentRef `json:"parentRefs"`
+}
+
+// EntryPointGatewayParentRef references a gateway listener.
+type EntryPointGatewayParentRef struct {
+	// `name` is the name of the gateway.
+	// +required
+	Name string `json:"name"`
+	// `namespace` is the namespace of the gateway. Defaults to the namespace of the
+	// proxy-servers.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// `sectionName` is the name of the listener in the gateway.
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// EntryPointIngress prescribes the SSL passthrough Ingress for the proxy servers.
+type EntryPointIngress struct {
+	// `name` is the name of the ingress. And the namespace will align to where the
+	// proxy-servers are deployed.
+	// +optional
+	// +kubebuilder:default=proxy-agent-entrypoint
+	Name string `json:"name"`
+	// `hostname` is the host of the ingress rule dialed by the proxy agents.
+	// +required
+	Hostname string `json:"hostname"`
+	// `ingressClassName` is the class of the ingress controller serving the ingress.
+	// +optional
+	IngressClassName string `json:"ingressClassName,omitempty"`
+	// `annotations` is the annotations of the ingress. Defaults to the annotation
+	// enabling SSL passthrough on the NGINX ingress controller.
+	// +optional
+	Annotations []AnnotationVar `json:"annotations,omitempty"`
+}
+
+// EntryPointRoute prescribes the OpenShift passthrough Route for the proxy servers.
+type EntryPointRoute struct {
+	// `name` is the name of the route. And the namespace will align to where the
+	// proxy-servers are deployed.
+	// +optional
+	// +kubebuilder:default=proxy-agent-entrypoint
+	Name string `json:"name"`
+	// `hostname` is the host of the route. The router generates one when empty.
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+}
+
 // ManagedProxyConfigurationProxyAgent prescribes how to deploy agents to the managed
 // cluster.
 type ManagedProxyConfigurationProxyAgent struct {
@@ -8,8 +8,29 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentCertificateLifetime) DeepCopyInto(out *AgentCertificateLifetime) {
+	*out = *in
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentCertificateLifetime.
+func (in *AgentCertificateLifetime) DeepCopy() *AgentCertificateLifetime {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentCertificateLifetime)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AnnotationVar) DeepCopyInto(out *AnnotationVar) {
 	*out = *in
@@ -25,6 +46,48 @@ func (in *AnnotationVar) DeepCopy() *AnnotationVar {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthenticationCertManager) DeepCopyInto(out *AuthenticationCertManager) {
+	*out = *in
+	out.IssuerRef = in.IssuerRef
+	out.CABundle = in.CABundle
+	if in.AdditionalSANs != nil {
+		in, out := &in.AdditionalSANs, &out.AdditionalSANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthenticationCertManager.
+func (in *AuthenticationCertManager) DeepCopy() *AuthenticationCertManager {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthenticationCertManager)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthenticationProvided) DeepCopyInto(out *AuthenticationProvided) {
+	*out = *in
+	if in.AdditionalSANs != nil {
+		in, out := &in.AdditionalSANs, &out.AdditionalSANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthenticationProvided.
+func (in *AuthenticationProvided) DeepCopy() *AuthenticationProvided {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthenticationProvided)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthenticationSelfSigned) DeepCopyInto(out *AuthenticationSelfSigned) {
 	*out = *in
@@ -45,6 +108,61 @@ func (in *AuthenticationSelfSigned) DeepCopy() *AuthenticationSelfSigned {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertManagerCABundle) DeepCopyInto(out *CertManagerCABundle) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerCABundle.
+func (in *CertManagerCABundle) DeepCopy() *CertManagerCABundle {
+	if in == nil {
+		return nil
+	}
+	out := new(CertManagerCABundle)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertManagerIssuerReference) DeepCopyInto(out *CertManagerIssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerIssuerReference.
+func (in *CertManagerIssuerReference) DeepCopy() *CertManagerIssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CertManagerIssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateLifetime) DeepCopyInto(out *CertificateLifetime) {
+	*out = *in
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RefreshBefore != nil {
+		in, out := &in.RefreshBefore, &out.RefreshBefore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateLifetime.
+func (in *CertificateLifetime) DeepCopy() *CertificateLifetime {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateLifetime)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateSigningSecrets) DeepCopyInto(out *CertificateSigningSecrets) {
 	*out = *in
@@ -60,6 +178,41 @@ func (in *CertificateSigningSecrets) DeepCopy() *CertificateSigningSecrets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntryPointGatewayParentRef) DeepCopyInto(out *EntryPointGatewayParentRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntryPointGatewayParentRef.
+func (in *EntryPointGatewayParentRef) DeepCopy() *EntryPointGatewayParentRef {
+	if in == nil {
+		return nil
+	}
+	out := new(EntryPointGatewayParentRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntryPointGatewayRoute) DeepCopyInto(out *EntryPointGatewayRoute) {
+	*out = *in
+	if in.ParentRefs != nil {
+		in, out := &in.ParentRefs, &out.ParentRefs
+		*out = make([]EntryPointGatewayParentRef, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntryPointGatewayRoute.
+func (in *EntryPointGatewayRoute) DeepCopy() *EntryPointGatewayRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(EntryPointGatewayRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EntryPointHostname) DeepCopyInto(out *EntryPointHostname) {
 	*out = *in
@@ -75,6 +228,26 @@ func (in *EntryPointHostname) DeepCopy() *EntryPointHostname {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntryPointIngress) DeepCopyInto(out *EntryPointIngress) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]AnnotationVar, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntryPointIngress.
+func (in *EntryPointIngress) DeepCopy() *EntryPointIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(EntryPointIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EntryPointLoadBalancerService) DeepCopyInto(out *EntryPointLoadBalancerService) {
 	*out = *in
@@ -83,6 +256,16 @@ func (in *EntryPointLoadBalancerService) DeepCopyInto(out *EntryPointLoadBalance
 		*out = make([]AnnotationVar, len(*in))
 		copy(*out, *in)
 	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPFamilyPolicy != nil {
+		in, out := &in.IPFamilyPolicy, &out.IPFamilyPolicy
+		*out = new(corev1.IPFamilyPolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntryPointLoadBalancerService.
@@ -95,6 +278,21 @@ func (in *EntryPointLoadBalancerService) DeepCopy() *EntryPointLoadBalancerServi
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntryPointRoute) DeepCopyInto(out *EntryPointRoute) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntryPointRoute.
+func (in *EntryPointRoute) DeepCopy() *EntryPointRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(EntryPointRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedProxyConfiguration) DeepCopyInto(out *ManagedProxyConfiguration) {
 	*out = *in
@@ -127,6 +325,7 @@ func (in *ManagedProxyConfigurationAuthentication) DeepCopyInto(out *ManagedProx
 	*out = *in
 	in.Signer.DeepCopyInto(&out.Signer)
 	out.Dump = in.Dump
+	in.Lifetimes.DeepCopyInto(&out.Lifetimes)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationAuthentication.
@@ -139,6 +338,34 @@ func (in *ManagedProxyConfigurationAuthentication) DeepCopy() *ManagedProxyConfi
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedProxyConfigurationCARotationStatus) DeepCopyInto(out *ManagedProxyConfigurationCARotationStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PendingClusters != nil {
+		in, out := &in.PendingClusters, &out.PendingClusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationCARotationStatus.
+func (in *ManagedProxyConfigurationCARotationStatus) DeepCopy() *ManagedProxyConfigurationCARotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedProxyConfigurationCARotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedProxyConfigurationCertificateDump) DeepCopyInto(out *ManagedProxyConfigurationCertificateDump) {
 	*out = *in
@@ -155,6 +382,24 @@ func (in *ManagedProxyConfigurationCertificateDump) DeepCopy() *ManagedProxyConf
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedProxyConfigurationCertificateLifetimes) DeepCopyInto(out *ManagedProxyConfigurationCertificateLifetimes) {
+	*out = *in
+	in.Server.DeepCopyInto(&out.Server)
+	in.ProxyClient.DeepCopyInto(&out.ProxyClient)
+	in.Agent.DeepCopyInto(&out.Agent)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationCertificateLifetimes.
+func (in *ManagedProxyConfigurationCertificateLifetimes) DeepCopy() *ManagedProxyConfigurationCertificateLifetimes {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedProxyConfigurationCertificateLifetimes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedProxyConfigurationCertificateSigner) DeepCopyInto(out *ManagedProxyConfigurationCertificateSigner) {
 	*out = *in
@@ -163,6 +408,16 @@ func (in *ManagedProxyConfigurationCertificateSigner) DeepCopyInto(out *ManagedP
 		*out = new(AuthenticationSelfSigned)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Provided != nil {
+		in, out := &in.Provided, &out.Provided
+		*out = new(AuthenticationProvided)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CertManager != nil {
+		in, out := &in.CertManager, &out.CertManager
+		*out = new(AuthenticationCertManager)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationCertificateSigner.
@@ -175,6 +430,29 @@ func (in *ManagedProxyConfigurationCertificateSigner) DeepCopy() *ManagedProxyCo
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedProxyConfigurationCertificateStatus) DeepCopyInto(out *ManagedProxyConfigurationCertificateStatus) {
+	*out = *in
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationCertificateStatus.
+func (in *ManagedProxyConfigurationCertificateStatus) DeepCopy() *ManagedProxyConfigurationCertificateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedProxyConfigurationCertificateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedProxyConfigurationDeploy) DeepCopyInto(out *ManagedProxyConfigurationDeploy) {
 	*out = *in
@@ -206,6 +484,31 @@ func (in *ManagedProxyConfigurationDeployPorts) DeepCopy() *ManagedProxyConfigur
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedProxyConfigurationEntrypointStatus) DeepCopyInto(out *ManagedProxyConfigurationEntrypointStatus) {
+	*out = *in
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPs != nil {
+		in, out := &in.IPs, &out.IPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationEntrypointStatus.
+func (in *ManagedProxyConfigurationEntrypointStatus) DeepCopy() *ManagedProxyConfigurationEntrypointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedProxyConfigurationEntrypointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedProxyConfigurationList) DeepCopyInto(out *ManagedProxyConfigurationList) {
 	*out = *in
@@ -282,6 +585,71 @@ func (in *ManagedProxyConfigurationProxyServer) DeepCopyInto(out *ManagedProxyCo
 		copy(*out, *in)
 	}
 	in.NodePlacement.DeepCopyInto(&out.NodePlacement)
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]corev1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationProxyServer.
@@ -307,6 +675,21 @@ func (in *ManagedProxyConfigurationProxyServerEntrypoint) DeepCopyInto(out *Mana
 		*out = new(EntryPointHostname)
 		**out = **in
 	}
+	if in.GatewayRoute != nil {
+		in, out := &in.GatewayRoute, &out.GatewayRoute
+		*out = new(EntryPointGatewayRoute)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(EntryPointIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Route != nil {
+		in, out := &in.Route, &out.Route
+		*out = new(EntryPointRoute)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationProxyServerEntrypoint.
@@ -319,6 +702,21 @@ func (in *ManagedProxyConfigurationProxyServerEntrypoint) DeepCopy() *ManagedPro
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedProxyConfigurationProxyServerStatus) DeepCopyInto(out *ManagedProxyConfigurationProxyServerStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationProxyServerStatus.
+func (in *ManagedProxyConfigurationProxyServerStatus) DeepCopy() *ManagedProxyConfigurationProxyServerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedProxyConfigurationProxyServerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedProxyConfigurationSpec) DeepCopyInto(out *ManagedProxyConfigurationSpec) {
 	*out = *in
@@ -357,6 +755,28 @@ func (in *ManagedProxyConfigurationStatus) DeepCopyInto(out *ManagedProxyConfigu
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Entrypoint != nil {
+		in, out := &in.Entrypoint, &out.Entrypoint
+		*out = new(ManagedProxyConfigurationEntrypointStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Certificates != nil {
+		in, out := &in.Certificates, &out.Certificates
+		*out = make([]ManagedProxyConfigurationCertificateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProxyServers != nil {
+		in, out := &in.ProxyServers, &out.ProxyServers
+		*out = make([]ManagedProxyConfigurationProxyServerStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.CARotation != nil {
+		in, out := &in.CARotation, &out.CARotation
+		*out = new(ManagedProxyConfigurationCARotationStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedProxyConfigurationStatus.
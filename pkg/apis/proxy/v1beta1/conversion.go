@@ -0,0 +1,139 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+// AnnotationKeyV1alpha1Args keeps the v1alpha1 args which are not plain flags, e.g. flag
+// values passed as separate args, so that they survive a round trip through v1beta1.
+const AnnotationKeyV1alpha1Args = "proxy.open-cluster-management.io/v1alpha1-args"
+
+// v1alpha1Args is the value of the AnnotationKeyV1alpha1Args annotation.
+type v1alpha1Args struct {
+	ProxyServer  []string `json:"proxyServer,omitempty"`
+	ProxyAgent   []string `json:"proxyAgent,omitempty"`
+	ServiceProxy []string `json:"serviceProxy,omitempty"`
+}
+
+var _ conversion.Convertible = &ManagedProxyConfiguration{}
+
+// ConvertTo converts the ManagedProxyConfiguration to the v1alpha1 hub version.
+func (src *ManagedProxyConfiguration) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*proxyv1alpha1.ManagedProxyConfiguration)
+	*dst = proxyv1alpha1.ManagedProxyConfiguration{TypeMeta: dst.TypeMeta}
+	// the other fields are identical between the versions.
+	if err := convertByJSON(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertByJSON(&src.Status, &dst.Status); err != nil {
+		return err
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec.Authentication.Dump.Secrets = proxyv1alpha1.CertificateSigningSecrets(src.Spec.Authentication.Secrets)
+
+	var preserved v1alpha1Args
+	if raw, ok := dst.Annotations[AnnotationKeyV1alpha1Args]; ok {
+		// the args are rendered as flags if the annotation is corrupted.
+		_ = json.Unmarshal([]byte(raw), &preserved)
+		delete(dst.Annotations, AnnotationKeyV1alpha1Args)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+	dst.Spec.ProxyServer.AdditionalArgs = toV1alpha1Args(src.Spec.ProxyServer.Args, preserved.ProxyServer)
+	dst.Spec.ProxyAgent.AdditionalArgs = toV1alpha1Args(src.Spec.ProxyAgent.Args, preserved.ProxyAgent)
+	dst.Spec.ProxyAgent.AdditionalServiceProxyArgs = toV1alpha1Args(src.Spec.ProxyAgent.ServiceProxyArgs, preserved.ServiceProxy)
+	return nil
+}
+
+// ConvertFrom converts the v1alpha1 hub version to the ManagedProxyConfiguration.
+func (dst *ManagedProxyConfiguration) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*proxyv1alpha1.ManagedProxyConfiguration)
+	*dst = ManagedProxyConfiguration{TypeMeta: dst.TypeMeta}
+	if err := convertByJSON(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertByJSON(&src.Status, &dst.Status); err != nil {
+		return err
+	}
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec.Authentication.Secrets = CertificateSigningSecrets(src.Spec.Authentication.Dump.Secrets)
+
+	delete(dst.Annotations, AnnotationKeyV1alpha1Args)
+
+	var preserved v1alpha1Args
+	dst.Spec.ProxyServer.Args, preserved.ProxyServer = fromV1alpha1Args(src.Spec.ProxyServer.AdditionalArgs)
+	dst.Spec.ProxyAgent.Args, preserved.ProxyAgent = fromV1alpha1Args(src.Spec.ProxyAgent.AdditionalArgs)
+	dst.Spec.ProxyAgent.ServiceProxyArgs, preserved.ServiceProxy = fromV1alpha1Args(src.Spec.ProxyAgent.AdditionalServiceProxyArgs)
+	if preserved.ProxyServer == nil && preserved.ProxyAgent == nil && preserved.ServiceProxy == nil {
+		return nil
+	}
+	raw, err := json.Marshal(preserved)
+	if err != nil {
+		return err
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[AnnotationKeyV1alpha1Args] = string(raw)
+	return nil
+}
+
+// convertByJSON copies the fields sharing the same json name between the versions.
+func convertByJSON(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// fromV1alpha1Args parses the args of the "--name=value" and "--name" forms into flags.
+// The args are also returned to be preserved unless the flags render them unchanged.
+func fromV1alpha1Args(args []string) ([]Arg, []string) {
+	var flags []Arg
+	for _, arg := range args {
+		name, value, ok := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") || len(name) == 0 {
+			continue
+		}
+		flag := Arg{Name: name}
+		if ok {
+			flag.Value = value
+		}
+		flags = append(flags, flag)
+	}
+	if slices.Equal(renderArgs(flags), args) {
+		return flags, nil
+	}
+	return flags, args
+}
+
+// toV1alpha1Args renders the flags as v1alpha1 args, restoring the preserved args if the
+// flags parsed from them are not changed.
+func toV1alpha1Args(flags []Arg, preserved []string) []string {
+	if preserved != nil {
+		if parsed, _ := fromV1alpha1Args(preserved); slices.Equal(parsed, flags) {
+			return preserved
+		}
+	}
+	return renderArgs(flags)
+}
+
+func renderArgs(flags []Arg) []string {
+	var args []string
+	for _, flag := range flags {
+		if len(flag.Value) == 0 {
+			args = append(args, "--"+flag.Name)
+			continue
+		}
+		args = append(args, "--"+flag.Name+"="+flag.Value)
+	}
+	return args
+}
@@ -0,0 +1,66 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func newHubConfig(proxyServerArgs ...string) *proxyv1alpha1.ManagedProxyConfiguration {
+	config := &proxyv1alpha1.ManagedProxyConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-proxy"},
+	}
+	config.Spec.Authentication.Dump.Secrets.SigningProxyServerSecretName = "proxy-server"
+	config.Spec.ProxyServer.Image = "proxy-server:latest"
+	config.Spec.ProxyServer.Entrypoint = &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type:     proxyv1alpha1.EntryPointTypeHostname,
+		Hostname: &proxyv1alpha1.EntryPointHostname{Value: "proxy.example.com"},
+	}
+	config.Spec.ProxyServer.AdditionalArgs = proxyServerArgs
+	config.Spec.ProxyAgent.AdditionalServiceProxyArgs = []string{"--oidc-issuer-url=https://issuer.example.com"}
+	config.Status.ProxyServers = []proxyv1alpha1.ManagedProxyConfigurationProxyServerStatus{
+		{PodName: "cluster-proxy-abc-1", ConnectedAgents: 2},
+	}
+	return config
+}
+
+func TestConvertFrom(t *testing.T) {
+	config := &ManagedProxyConfiguration{}
+	if err := config.ConvertFrom(newHubConfig("--v=4", "--enable-profiling")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "proxy-server", config.Spec.Authentication.Secrets.SigningProxyServerSecretName)
+	assert.Equal(t, "proxy.example.com", config.Spec.ProxyServer.Entrypoint.Hostname.Value)
+	assert.Equal(t, []Arg{{Name: "v", Value: "4"}, {Name: "enable-profiling"}}, config.Spec.ProxyServer.Args)
+	assert.Equal(t, []Arg{{Name: "oidc-issuer-url", Value: "https://issuer.example.com"}}, config.Spec.ProxyAgent.ServiceProxyArgs)
+	assert.Equal(t, []ManagedProxyConfigurationProxyServerStatus{{PodName: "cluster-proxy-abc-1", ConnectedAgents: 2}},
+		config.Status.ProxyServers)
+	assert.NotContains(t, config.Annotations, AnnotationKeyV1alpha1Args)
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	hub := newHubConfig("--keepalive-time", "1h", "--v=4")
+	config := &ManagedProxyConfiguration{}
+	if err := config.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Arg{{Name: "keepalive-time"}, {Name: "v", Value: "4"}}, config.Spec.ProxyServer.Args)
+	assert.Contains(t, config.Annotations, AnnotationKeyV1alpha1Args)
+
+	converted := &proxyv1alpha1.ManagedProxyConfiguration{}
+	if err := config.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hub, converted)
+
+	// the args are rendered from the flags once they are changed in v1beta1.
+	config.Spec.ProxyServer.Args = []Arg{{Name: "keepalive-time", Value: "1h"}}
+	if err := config.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"--keepalive-time=1h"}, converted.Spec.ProxyServer.AdditionalArgs)
+	assert.Nil(t, converted.Annotations)
+}
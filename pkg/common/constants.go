@@ -10,6 +10,8 @@ const (
 	ComponentNameProxyServer      = "proxy-server"
 	ComponentNameProxyAgent       = "proxy-agent"
 	ComponentNameProxyClient      = "proxy-client"
+	// ComponentNameProxyEntrypoint labels the load-balancer services exposing the proxy-servers.
+	ComponentNameProxyEntrypoint = "proxy-entrypoint"
 )
 
 var (
@@ -33,6 +35,15 @@ const (
 	AnnotationKeyConfigurationGeneration = "proxy.open-cluster-management.io/configuration-generation"
 	AnnotationKeyTLSConfigHash           = "proxy.open-cluster-management.io/tls-config-hash"
 	AnnotationKeyRenderedHash            = "proxy.open-cluster-management.io/rendered-hash"
+	AnnotationKeyCABundleHash            = "proxy.open-cluster-management.io/ca-bundle-hash"
+	AnnotationKeyServiceProxyCertHash    = "proxy.open-cluster-management.io/service-proxy-cert-hash"
+)
+
+const (
+	// AddonConditionTypeProxyAgentRolledOut is reported on the ManagedClusterAddOn by the
+	// addon-agents, telling whether the proxy agents are rolled out. Its message carries the
+	// rollout hashes of the pod template, see FormatRolloutHashes.
+	AddonConditionTypeProxyAgentRolledOut = "ProxyAgentRolledOut"
 )
 
 const (
@@ -2,9 +2,12 @@ package common
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"reflect"
+	"strings"
 
 	certutil "k8s.io/client-go/util/cert"
 )
@@ -47,3 +50,43 @@ func MergeCertificateData(caBundles ...[]byte) ([]byte, error) {
 	}
 	return b.Bytes(), nil
 }
+
+// CABundleHash is a short digest of the CA bundle, which is annotated on the pods loading
+// the bundle on startup so that they're rolled out upon changes.
+func CABundleHash(caBundle []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(caBundle))[:16]
+}
+
+// CertificateHash is a short digest of a serving certificate, which is annotated on the pods
+// serving it so that the renewals can be told from the rollouts.
+func CertificateHash(cert []byte) string {
+	return CABundleHash(cert)
+}
+
+// rolloutHashAnnotationKeys are the pod template annotations of the proxy agents reported
+// back to the hub once the agents are rolled out.
+var rolloutHashAnnotationKeys = []string{AnnotationKeyCABundleHash, AnnotationKeyServiceProxyCertHash}
+
+// FormatRolloutHashes formats the rollout hashes among the pod template annotations into the
+// message of the AddonConditionTypeProxyAgentRolledOut condition.
+func FormatRolloutHashes(annotations map[string]string) string {
+	var pairs []string
+	for _, key := range rolloutHashAnnotationKeys {
+		if value, ok := annotations[key]; ok {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseRolloutHashes parses the message of the AddonConditionTypeProxyAgentRolledOut condition
+// formatted by FormatRolloutHashes.
+func ParseRolloutHashes(message string) map[string]string {
+	hashes := map[string]string{}
+	for _, pair := range strings.Split(message, ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			hashes[key] = value
+		}
+	}
+	return hashes
+}
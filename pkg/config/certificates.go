@@ -0,0 +1,76 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+// DefaultCertificateValidity is the validity of the signed certificates unless prescribed
+// in `spec.authentication.lifetimes`.
+const DefaultCertificateValidity = time.Hour * 24 * 180
+
+// GetCertificateLifetimes returns the lifetimes from `spec.authentication.lifetimes`,
+// falling back to the defaults for the fields that are not set. The default validity is
+// shortened to the remaining lifetime of the CA signing the certificates if it's known, and
+// the certificates are refreshed when a fifth of the validity is left by default.
+func GetCertificateLifetimes(config *proxyv1alpha1.ManagedProxyConfiguration, caCert *x509.Certificate) proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes {
+	defaultValidity := DefaultCertificateValidity
+	if caCert != nil {
+		if remaining := time.Until(caCert.NotAfter); remaining > 0 && remaining < defaultValidity {
+			defaultValidity = remaining
+		}
+	}
+	lifetimes := *config.Spec.Authentication.Lifetimes.DeepCopy()
+	for _, lifetime := range []*proxyv1alpha1.CertificateLifetime{&lifetimes.Server, &lifetimes.ProxyClient} {
+		if lifetime.Validity == nil {
+			lifetime.Validity = &metav1.Duration{Duration: defaultValidity}
+		}
+		if lifetime.RefreshBefore == nil {
+			lifetime.RefreshBefore = &metav1.Duration{Duration: lifetime.Validity.Duration / 5}
+		}
+	}
+	if lifetimes.Agent.Validity == nil {
+		lifetimes.Agent.Validity = &metav1.Duration{Duration: defaultValidity}
+	}
+	return lifetimes
+}
+
+// ValidateCertificateLifetimes checks the lifetimes returned by GetCertificateLifetimes. None
+// of the validities set in `spec.authentication.lifetimes` is allowed to outlive the CA
+// signing the certificates if it's known, while the defaults are shortened to fit in.
+func ValidateCertificateLifetimes(config *proxyv1alpha1.ManagedProxyConfiguration, caCert *x509.Certificate) error {
+	lifetimes := GetCertificateLifetimes(config, caCert)
+	prescribed := config.Spec.Authentication.Lifetimes
+	classes := []struct {
+		name          string
+		validity      time.Duration
+		refreshBefore *metav1.Duration
+		isPrescribed  bool
+	}{
+		{"server", lifetimes.Server.Validity.Duration, lifetimes.Server.RefreshBefore, prescribed.Server.Validity != nil},
+		{"proxyClient", lifetimes.ProxyClient.Validity.Duration, lifetimes.ProxyClient.RefreshBefore, prescribed.ProxyClient.Validity != nil},
+		{"agent", lifetimes.Agent.Validity.Duration, nil, prescribed.Agent.Validity != nil},
+	}
+	for _, class := range classes {
+		if class.validity <= 0 {
+			return fmt.Errorf("the validity %v of the %s certificates must be positive", class.validity, class.name)
+		}
+		if class.refreshBefore != nil && (class.refreshBefore.Duration <= 0 || class.refreshBefore.Duration >= class.validity) {
+			return fmt.Errorf("the refreshBefore %v of the %s certificates must be positive and shorter than the validity %v",
+				class.refreshBefore.Duration, class.name, class.validity)
+		}
+		if caCert == nil || !class.isPrescribed {
+			continue
+		}
+		if caValidity := caCert.NotAfter.Sub(caCert.NotBefore); class.validity > caValidity {
+			return fmt.Errorf("the validity %v of the %s certificates exceeds the validity %v of the signer ca",
+				class.validity, class.name, caValidity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func TestGetCertificateLifetimes(t *testing.T) {
+	config := &proxyv1alpha1.ManagedProxyConfiguration{}
+	config.Spec.Authentication.Lifetimes.Server.Validity = &metav1.Duration{Duration: 30 * 24 * time.Hour}
+	config.Spec.Authentication.Lifetimes.ProxyClient.RefreshBefore = &metav1.Duration{Duration: 7 * 24 * time.Hour}
+
+	lifetimes := GetCertificateLifetimes(config, nil)
+	if lifetimes.Server.Validity.Duration != 30*24*time.Hour || lifetimes.Server.RefreshBefore.Duration != 6*24*time.Hour {
+		t.Errorf("unexpected server lifetime %v/%v", lifetimes.Server.Validity, lifetimes.Server.RefreshBefore)
+	}
+	if lifetimes.ProxyClient.Validity.Duration != DefaultCertificateValidity || lifetimes.ProxyClient.RefreshBefore.Duration != 7*24*time.Hour {
+		t.Errorf("unexpected proxy client lifetime %v/%v", lifetimes.ProxyClient.Validity, lifetimes.ProxyClient.RefreshBefore)
+	}
+	if lifetimes.Agent.Validity.Duration != DefaultCertificateValidity {
+		t.Errorf("unexpected agent validity %v", lifetimes.Agent.Validity)
+	}
+	if config.Spec.Authentication.Lifetimes.Server.RefreshBefore != nil {
+		t.Errorf("expected the config not to be defaulted in place")
+	}
+}
+
+func TestGetCertificateLifetimesWithinCA(t *testing.T) {
+	now := time.Now()
+	caCert := &x509.Certificate{NotBefore: now.Add(-300 * 24 * time.Hour), NotAfter: now.Add(65 * 24 * time.Hour)}
+	config := &proxyv1alpha1.ManagedProxyConfiguration{}
+	config.Spec.Authentication.Lifetimes.Server.Validity = &metav1.Duration{Duration: 90 * 24 * time.Hour}
+
+	lifetimes := GetCertificateLifetimes(config, caCert)
+	if lifetimes.Server.Validity.Duration != 90*24*time.Hour {
+		t.Errorf("expected the prescribed server validity to be kept, got %v", lifetimes.Server.Validity)
+	}
+	for name, validity := range map[string]time.Duration{
+		"proxyClient": lifetimes.ProxyClient.Validity.Duration,
+		"agent":       lifetimes.Agent.Validity.Duration,
+	} {
+		if validity > 65*24*time.Hour || validity < 64*24*time.Hour {
+			t.Errorf("expected the default %s validity to be shortened to the ca, got %v", name, validity)
+		}
+	}
+	if lifetimes.ProxyClient.RefreshBefore.Duration != lifetimes.ProxyClient.Validity.Duration/5 {
+		t.Errorf("unexpected proxy client refreshBefore %v", lifetimes.ProxyClient.RefreshBefore)
+	}
+}
+
+func TestValidateCertificateLifetimes(t *testing.T) {
+	now := time.Now()
+	caCert := &x509.Certificate{NotBefore: now, NotAfter: now.Add(365 * 24 * time.Hour)}
+	testcases := []struct {
+		name      string
+		lifetimes proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes
+		caCert    *x509.Certificate
+		expectErr bool
+	}{
+		{
+			name:   "defaults",
+			caCert: caCert,
+		},
+		{
+			name:   "defaults outliving the ca",
+			caCert: &x509.Certificate{NotBefore: now, NotAfter: now.Add(30 * 24 * time.Hour)},
+		},
+		{
+			name: "refreshing after the expiry",
+			lifetimes: proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes{
+				Server: proxyv1alpha1.CertificateLifetime{
+					Validity:      &metav1.Duration{Duration: 24 * time.Hour},
+					RefreshBefore: &metav1.Duration{Duration: 48 * time.Hour},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "outliving the ca",
+			lifetimes: proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes{
+				Agent: proxyv1alpha1.AgentCertificateLifetime{
+					Validity: &metav1.Duration{Duration: 2 * 365 * 24 * time.Hour},
+				},
+			},
+			caCert:    caCert,
+			expectErr: true,
+		},
+		{
+			name: "unknown ca",
+			lifetimes: proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes{
+				Agent: proxyv1alpha1.AgentCertificateLifetime{
+					Validity: &metav1.Duration{Duration: 2 * 365 * 24 * time.Hour},
+				},
+			},
+		},
+		{
+			name: "negative validity",
+			lifetimes: proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes{
+				ProxyClient: proxyv1alpha1.CertificateLifetime{
+					Validity:      &metav1.Duration{Duration: -time.Hour},
+					RefreshBefore: &metav1.Duration{Duration: time.Minute},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			config := &proxyv1alpha1.ManagedProxyConfiguration{}
+			config.Spec.Authentication.Lifetimes = testcase.lifetimes
+			err := ValidateCertificateLifetimes(config, testcase.caCert)
+			if (err != nil) != testcase.expectErr {
+				t.Errorf("expected error %v, but got %v", testcase.expectErr, err)
+			}
+		})
+	}
+}
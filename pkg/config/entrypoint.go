@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+// DefaultPassthroughEntrypointPort is the port agents dial on the gateways, ingress
+// controllers and routers passing TLS through to the proxy servers.
+const DefaultPassthroughEntrypointPort int32 = 443
+
+// GetEntrypointPort returns the port agents dial on the entrypoint, falling back to 443
+// for the passthrough types and to the agent server port for the others.
+func GetEntrypointPort(config *proxyv1alpha1.ManagedProxyConfiguration) int32 {
+	entrypoint := config.Spec.ProxyServer.Entrypoint
+	if entrypoint != nil && entrypoint.Port != 0 {
+		return entrypoint.Port
+	}
+	if entrypoint != nil {
+		switch entrypoint.Type {
+		case proxyv1alpha1.EntryPointTypeGatewayRoute,
+			proxyv1alpha1.EntryPointTypeIngress,
+			proxyv1alpha1.EntryPointTypeRoute:
+			return DefaultPassthroughEntrypointPort
+		}
+	}
+	return GetDeployPorts(config).AgentServer
+}
+
+// ValidateEntrypoint checks `spec.proxyServer.entrypoint` carries the details required by
+// its type, so that the entrypoint can be provisioned without dereferencing missing fields.
+func ValidateEntrypoint(config *proxyv1alpha1.ManagedProxyConfiguration) error {
+	entrypoint := config.Spec.ProxyServer.Entrypoint
+	if entrypoint == nil {
+		return fmt.Errorf("entrypoint is required")
+	}
+	switch entrypoint.Type {
+	case proxyv1alpha1.EntryPointTypeHostname:
+		if entrypoint.Hostname == nil || len(entrypoint.Hostname.Value) == 0 {
+			return fmt.Errorf("hostname is required for the Hostname entrypoint")
+		}
+	case proxyv1alpha1.EntryPointTypeLoadBalancerService:
+		if entrypoint.LoadBalancerService == nil {
+			return fmt.Errorf("loadBalancerService is required for the LoadBalancerService entrypoint")
+		}
+	case proxyv1alpha1.EntryPointTypeGatewayRoute:
+		if entrypoint.GatewayRoute == nil {
+			return fmt.Errorf("gatewayRoute is required for the GatewayRoute entrypoint")
+		}
+		if len(entrypoint.GatewayRoute.ParentRefs) == 0 {
+			return fmt.Errorf("parentRefs is required for the GatewayRoute entrypoint")
+		}
+		kind := entrypoint.GatewayRoute.Kind
+		if (len(kind) == 0 || kind == proxyv1alpha1.GatewayRouteKindTLSRoute) && len(entrypoint.GatewayRoute.Hostname) == 0 {
+			return fmt.Errorf("hostname is required for the TLSRoute entrypoint")
+		}
+	case proxyv1alpha1.EntryPointTypeIngress:
+		if entrypoint.Ingress == nil || len(entrypoint.Ingress.Hostname) == 0 {
+			return fmt.Errorf("ingress with a hostname is required for the Ingress entrypoint")
+		}
+	case proxyv1alpha1.EntryPointTypeRoute:
+		if entrypoint.Route == nil {
+			return fmt.Errorf("route is required for the Route entrypoint")
+		}
+	case proxyv1alpha1.EntryPointTypePortForward:
+	default:
+		return fmt.Errorf("unknown entrypoint type %q", entrypoint.Type)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"testing"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func TestValidateEntrypoint(t *testing.T) {
+	testcases := []struct {
+		name       string
+		entrypoint *proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint
+		expectErr  bool
+	}{
+		{
+			name:      "missing entrypoint",
+			expectErr: true,
+		},
+		{
+			name: "port-forward",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypePortForward,
+			},
+		},
+		{
+			name: "hostname",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type:     proxyv1alpha1.EntryPointTypeHostname,
+				Hostname: &proxyv1alpha1.EntryPointHostname{Value: "proxy.example.com"},
+			},
+		},
+		{
+			name: "missing hostname",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypeHostname,
+			},
+			expectErr: true,
+		},
+		{
+			name: "missing load-balancer service",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypeLoadBalancerService,
+			},
+			expectErr: true,
+		},
+		{
+			name: "tcp route without hostname",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypeGatewayRoute,
+				GatewayRoute: &proxyv1alpha1.EntryPointGatewayRoute{
+					Kind:       proxyv1alpha1.GatewayRouteKindTCPRoute,
+					ParentRefs: []proxyv1alpha1.EntryPointGatewayParentRef{{Name: "shared"}},
+				},
+			},
+		},
+		{
+			name: "tls route without hostname",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypeGatewayRoute,
+				GatewayRoute: &proxyv1alpha1.EntryPointGatewayRoute{
+					ParentRefs: []proxyv1alpha1.EntryPointGatewayParentRef{{Name: "shared"}},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "missing ingress",
+			entrypoint: &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypeIngress,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			config := &proxyv1alpha1.ManagedProxyConfiguration{}
+			config.Spec.ProxyServer.Entrypoint = testcase.entrypoint
+			err := ValidateEntrypoint(config)
+			if (err != nil) != testcase.expectErr {
+				t.Errorf("expected error %v, but got %v", testcase.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestGetEntrypointPort(t *testing.T) {
+	testcases := []struct {
+		name           string
+		entrypointType proxyv1alpha1.EntryPointType
+		port           int32
+		agentServer    int32
+		expected       int32
+	}{
+		{name: "port-forward", entrypointType: proxyv1alpha1.EntryPointTypePortForward, expected: 8091},
+		{name: "hostname on a custom agent port", entrypointType: proxyv1alpha1.EntryPointTypeHostname, agentServer: 18091, expected: 18091},
+		{name: "gateway route", entrypointType: proxyv1alpha1.EntryPointTypeGatewayRoute, expected: 443},
+		{name: "ingress", entrypointType: proxyv1alpha1.EntryPointTypeIngress, agentServer: 18091, expected: 443},
+		{name: "route", entrypointType: proxyv1alpha1.EntryPointTypeRoute, expected: 443},
+		{name: "explicit port", entrypointType: proxyv1alpha1.EntryPointTypeIngress, port: 8443, expected: 8443},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			config := &proxyv1alpha1.ManagedProxyConfiguration{}
+			config.Spec.ProxyServer.Entrypoint = &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: testcase.entrypointType,
+				Port: testcase.port,
+			}
+			config.Spec.Deploy = &proxyv1alpha1.ManagedProxyConfigurationDeploy{
+				Ports: proxyv1alpha1.ManagedProxyConfigurationDeployPorts{AgentServer: testcase.agentServer},
+			}
+			if port := GetEntrypointPort(config); port != testcase.expected {
+				t.Errorf("expected port %d, but got %d", testcase.expected, port)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+// The default ports of the proxy-servers, matching the defaults of
+// ManagedProxyConfigurationDeployPorts.
+const (
+	DefaultProxyServerPort  int32 = 8090
+	DefaultAgentServerPort  int32 = 8091
+	DefaultHealthServerPort int32 = 8092
+	DefaultAdminServerPort  int32 = 8095
+)
+
+// GetDeployPorts returns the ports of the proxy-servers from `spec.deploy.ports`,
+// falling back to the defaults for the ports that are not set.
+func GetDeployPorts(config *proxyv1alpha1.ManagedProxyConfiguration) proxyv1alpha1.ManagedProxyConfigurationDeployPorts {
+	ports := proxyv1alpha1.ManagedProxyConfigurationDeployPorts{}
+	if config.Spec.Deploy != nil {
+		ports = config.Spec.Deploy.Ports
+	}
+	if ports.ProxyServer == 0 {
+		ports.ProxyServer = DefaultProxyServerPort
+	}
+	if ports.AgentServer == 0 {
+		ports.AgentServer = DefaultAgentServerPort
+	}
+	if ports.HealthServer == 0 {
+		ports.HealthServer = DefaultHealthServerPort
+	}
+	if ports.AdminServer == 0 {
+		ports.AdminServer = DefaultAdminServerPort
+	}
+	return ports
+}
@@ -9,6 +9,10 @@ const (
 
 	ServiceProxyName = "cluster-proxy-service-proxy"
 
+	// IdentityAssertionServiceName is the agent Service publishing the identity assertion JWKS
+	// of the service-proxy, whose DNS names the service-proxy certificate covers.
+	IdentityAssertionServiceName = "cluster-proxy-identity-assertion"
+
 	AddonName = "cluster-proxy"
 
 	// UserServerSecretName is the fixed secret name for user server certificates.
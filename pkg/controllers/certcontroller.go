@@ -2,7 +2,8 @@ package controllers
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"slices"
 
 	"github.com/openshift/library-go/pkg/crypto"
 	"github.com/spf13/cobra"
@@ -13,15 +14,25 @@ import (
 	corev1listers "k8s.io/client-go/listers/core/v1"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	predicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
 	"open-cluster-management.io/cluster-proxy/pkg/constant"
+	proxyclient "open-cluster-management.io/cluster-proxy/pkg/generated/clientset/versioned"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
 	certrotation "open-cluster-management.io/sdk-go/pkg/certrotation"
 )
 
+// serviceProxyHostNames are the names every service-proxy certificate covers, next to the
+// identity assertion Service of each agent install namespace.
+var serviceProxyHostNames = []string{"*", "localhost", "127.0.0.1", "*.open-cluster-management.proxy"}
+
 var _ reconcile.Reconciler = &reconcileServerCertificates{}
 
 var (
@@ -43,7 +54,8 @@ func addFlagsForCertController(cmd *cobra.Command) {
 // reconcileServerCertificates sign certificates for the server with the signer ca created by the cluster-proxy.
 type reconcileServerCertificates struct {
 	client                                  client.Client
-	serverCertRotation                      *certrotation.TargetRotation
+	proxyClient                             proxyclient.Interface
+	serverCertRotation                      certrotation.TargetRotation
 	signerSecretName, signerSecretNamespace string
 }
 
@@ -51,23 +63,34 @@ func registerCertController(certNamespace string,
 	signerSecretName, signerSecretNamespace string,
 	secertLister corev1listers.SecretLister,
 	secertGetter corev1client.SecretsGetter,
+	proxyClient proxyclient.Interface,
 	ownerRef *metav1.OwnerReference,
 	mgr manager.Manager) error {
 
+	signerRequest := reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: signerSecretNamespace,
+		Name:      signerSecretName,
+	}}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Secret{}).
-		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+		For(&corev1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
 			return object.GetName() == signerSecretName && object.GetNamespace() == signerSecretNamespace
-		})).
+		}))).
+		// the certificate covers the identity assertion Service in the install namespace of each agent
+		Watches(&addonv1beta1.ManagedClusterAddOn{},
+			handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+				return []reconcile.Request{signerRequest}
+			}),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+				return object.GetName() == constant.AddonName
+			}))).
 		Complete(&reconcileServerCertificates{
 			client:                mgr.GetClient(),
+			proxyClient:           proxyClient,
 			signerSecretName:      signerSecretName,
 			signerSecretNamespace: signerSecretNamespace,
-			serverCertRotation: &certrotation.TargetRotation{
+			serverCertRotation: certrotation.TargetRotation{
 				Namespace:      certNamespace,
 				Name:           constant.ServerCertSecretName,
-				Validity:       time.Hour * 24 * 180, // align with the signer ca by cluster-proxy
-				HostNames:      []string{"*", "localhost", "127.0.0.1", "*.open-cluster-management.proxy"},
 				Lister:         secertLister,
 				Client:         secertGetter,
 				OwnerReference: ownerRef,
@@ -92,9 +115,53 @@ func (r *reconcileServerCertificates) Reconcile(context.Context, reconcile.Reque
 		return reconcile.Result{}, err
 	}
 
-	err = r.serverCertRotation.EnsureTargetCertKeyPair(ca, ca.Config.Certs)
+	// the server certificates of the service-proxy align with the ones of the proxy servers.
+	proxyConfig, err := r.proxyClient.ProxyV1alpha1().ManagedProxyConfigurations().Get(
+		context.TODO(), "cluster-proxy", metav1.GetOptions{})
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := proxyconfig.ValidateCertificateLifetimes(proxyConfig, ca.Config.Certs[0]); err != nil {
+		return reconcile.Result{}, err
+	}
+	lifetimes := proxyconfig.GetCertificateLifetimes(proxyConfig, ca.Config.Certs[0])
+	hostNames, err := r.serverCertHostNames(context.TODO())
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	targetRotation := r.serverCertRotation
+	targetRotation.HostNames = hostNames
+	serverCertRotation := selfsigned.RefreshingRotation{
+		TargetRotation: targetRotation,
+		RefreshBefore:  lifetimes.Server.RefreshBefore.Duration,
+	}
+	serverCertRotation.Validity = lifetimes.Server.Validity.Duration
+
+	err = serverCertRotation.EnsureTargetCertKeyPair(ca, ca.Config.Certs)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 	return reconcile.Result{}, nil
 }
+
+// serverCertHostNames adds the DNS names of the identity assertion Service in the install
+// namespace of every agent, so that backends verify the JWKS endpoint with the cluster-proxy CA.
+func (r *reconcileServerCertificates) serverCertHostNames(ctx context.Context) ([]string, error) {
+	addons := &addonv1beta1.ManagedClusterAddOnList{}
+	if err := r.client.List(ctx, addons); err != nil {
+		return nil, err
+	}
+	hostNames := slices.Clone(serviceProxyHostNames)
+	var namespaces []string
+	for _, addon := range addons.Items {
+		if addon.Name == constant.AddonName && addon.Status.Namespace != "" {
+			namespaces = append(namespaces, addon.Status.Namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	for _, namespace := range slices.Compact(namespaces) {
+		service := fmt.Sprintf("%s.%s.svc", constant.IdentityAssertionServiceName, namespace)
+		hostNames = append(hostNames, service, service+".cluster.local")
+	}
+	return hostNames, nil
+}
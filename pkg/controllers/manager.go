@@ -19,6 +19,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
 
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -31,6 +33,7 @@ func init() {
 	log.SetLogger(logger)
 
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(addonv1beta1.AddToScheme(scheme))
 }
 
 var (
@@ -112,7 +115,7 @@ func runControllerManager(ctx context.Context) error {
 
 	// Register CertController
 	err = registerCertController(certificatesNamespace, signerSecretName, signerSecretNamespace,
-		secertLister, secertClient, ownerRef, mgr)
+		secertLister, secertClient, proxyClient, ownerRef, mgr)
 	if err != nil {
 		klog.Error(err, "unable to set up cert-controller")
 		return fmt.Errorf("set up cert controller: %w", err)
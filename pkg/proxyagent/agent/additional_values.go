@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
@@ -18,6 +19,7 @@ import (
 	"open-cluster-management.io/cluster-proxy/pkg/common"
 	"open-cluster-management.io/cluster-proxy/pkg/config"
 	"open-cluster-management.io/cluster-proxy/pkg/constant"
+	"open-cluster-management.io/cluster-proxy/pkg/util"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -95,6 +97,19 @@ func GetClusterProxyAdditionalValueFunc(
 			}
 			values["serviceProxySecretCert"] = base64.StdEncoding.EncodeToString(serviceProxySecretCert)
 			values["serviceProxySecretKey"] = base64.StdEncoding.EncodeToString(serviceProxySecretKey)
+			// the renewals of the certificate are rolled out, so that the hub can tell when every
+			// service-proxy serves a certificate signed by the current CA.
+			values["agentDeploymentAnnotations"] = map[string]string{
+				common.AnnotationKeyServiceProxyCertHash: common.CertificateHash(serviceProxySecretCert),
+			}
+
+			clientIdentityPublicKey, err := getClientIdentityPublicKey(nativeClient, signerNamespace)
+			if err != nil {
+				return nil, err
+			}
+			if len(clientIdentityPublicKey) > 0 {
+				values["base64EncodedClientIdentityPublicKey"] = base64.StdEncoding.EncodeToString(clientIdentityPublicKey)
+			}
 		}
 
 		return values, nil
@@ -131,3 +146,21 @@ func getServerCertificatesFromSecret(nativeClient kubernetes.Interface, secretNa
 	}
 	return key, cert, nil
 }
+
+// getClientIdentityPublicKey returns the public key of the client identity
+// signing key the user-server creates when client certificate authentication
+// is enabled, or nil when it is disabled.
+func getClientIdentityPublicKey(nativeClient kubernetes.Interface, secretNamespace string) ([]byte, error) {
+	secret, err := nativeClient.CoreV1().Secrets(secretNamespace).Get(context.TODO(), util.ClientIdentitySigningSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s in the namespace %s: %v", util.ClientIdentitySigningSecretName, secretNamespace, err)
+	}
+	key, err := util.SigningKeyFromSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	return util.EncodePublicKeyPEM(key)
+}
@@ -1,8 +1,10 @@
 package agent
 
 import (
+	"cmp"
 	"context"
 	"crypto/sha256"
+	"crypto/x509"
 	"embed"
 	"encoding/base64"
 	"fmt"
@@ -11,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/openshift/library-go/pkg/crypto"
 	"github.com/pkg/errors"
 	csrv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -30,6 +33,7 @@ import (
 	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
 	"open-cluster-management.io/cluster-proxy/pkg/common"
 	"open-cluster-management.io/cluster-proxy/pkg/config"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/certmanager"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
 	"open-cluster-management.io/cluster-proxy/pkg/util"
 )
@@ -48,45 +52,96 @@ const (
 	serviceDomain = "svc.cluster.local"
 )
 
+// NewAgentAddon builds the addon agent. The CSRs of the agents are signed by the
+// certManagerSigner when it's not nil, otherwise by the CA of the signer. A
+// selfsigned.RotatingSigner is read upon every use so that its CA can be rotated.
+// The proxyConfigCache is read upon signing every CSR, so it's expected to be backed by
+// an informer cache.
 func NewAgentAddon(
 	signer selfsigned.SelfSigner,
+	certManagerSigner *certmanager.Signer,
 	signerNamespace string,
 	runtimeClient client.Client,
+	proxyConfigCache client.Reader,
 	nativeClient kubernetes.Interface,
 	enableKubeApiProxy bool, //nolint:revive // parameter name is part of the public API
 	enableServiceProxy bool,
 	enableNetworkPolicies bool,
 	addonClient addonclient.Interface) (agent.AgentAddon, error) {
-	caCertData, caKeyData, err := signer.CA().Config.GetPEMBytes()
-	if err != nil {
-		return nil, err
+	var trustBundle, signerCAData func() []byte
+	var signWithExpiry func(validity time.Duration) (agent.CSRSignerFunc, error)
+	switch rotating, isRotating := signer.(*selfsigned.RotatingSigner); {
+	case certManagerSigner != nil:
+		trustBundle = certManagerSigner.CAData
+		signerCAData = trustBundle
+		signWithExpiry = func(validity time.Duration) (agent.CSRSignerFunc, error) {
+			return certManagerSigner.SignerWithExpiry(validity), nil
+		}
+	case isRotating:
+		// the CA is read upon every use since it changes along the rotation.
+		trustBundle = rotating.TrustBundle
+		signerCAData = rotating.CAData
+		signWithExpiry = func(validity time.Duration) (agent.CSRSignerFunc, error) {
+			return newCASignerWithExpiry(rotating.CA(), validity)
+		}
+	default:
+		caCertData, _, err := signer.CA().Config.GetPEMBytes()
+		if err != nil {
+			return nil, err
+		}
+		trustBundle = func() []byte { return caCertData }
+		caData := signer.CAData()
+		signerCAData = func() []byte { return caData }
+		signWithExpiry = func(validity time.Duration) (agent.CSRSignerFunc, error) {
+			return newCASignerWithExpiry(signer.CA(), validity)
+		}
 	}
+	csrSign := CustomSigner(ProxyAgentSignerName, func(ctx context.Context,
+		cluster *clusterv1.ManagedCluster,
+		addon *addonv1beta1.ManagedClusterAddOn,
+		csr *csrv1.CertificateSigningRequest) ([]byte, error) {
+		var caCert *x509.Certificate
+		if certManagerSigner == nil {
+			caCert = signer.CA().Config.Certs[0]
+		}
+		validity, err := getAgentCertValidity(ctx, proxyConfigCache, caCert)
+		if err != nil {
+			return nil, err
+		}
+		sign, err := signWithExpiry(validity)
+		if err != nil {
+			return nil, err
+		}
+		return sign(ctx, cluster, addon, csr)
+	})
 
-	regConfigs := make([]agent.RegistrationConfig, 0, 2)
-	regConfigs = append(regConfigs, &agent.KubeClientRegistration{
+	kubeClientRegistration := &agent.KubeClientRegistration{
 		User: common.SubjectUserClusterAddonAgent,
 		Groups: []string{
 			common.SubjectGroupClusterProxy,
 		},
-	})
+	}
 	// Register the custom signer CSR option if V1 csr is supported
-	// caculate a hash value of signer ca data and add it to the organizationUnits of the subject
-	signerHash := sha256.Sum256(signer.CAData())
-	regConfigs = append(regConfigs, &agent.CustomSignerRegistration{
-		SignerName: ProxyAgentSignerName,
-		User:       common.SubjectUserClusterProxyAgent,
-		Groups: []string{
-			common.SubjectGroupClusterProxy,
-		},
-		OrganizationUnits: []string{
-			fmt.Sprintf("signer-%x", base64.StdEncoding.EncodeToString(signerHash[:])),
-		},
-	})
+	// caculate a hash value of signer ca data and add it to the organizationUnits of the subject,
+	// so that the agents renew their certificates once the signer is changed or rotated.
+	newCustomSignerRegistration := func() agent.RegistrationConfig {
+		signerHash := sha256.Sum256(signerCAData())
+		return &agent.CustomSignerRegistration{
+			SignerName: ProxyAgentSignerName,
+			User:       common.SubjectUserClusterProxyAgent,
+			Groups: []string{
+				common.SubjectGroupClusterProxy,
+			},
+			OrganizationUnits: []string{
+				fmt.Sprintf("signer-%x", base64.StdEncoding.EncodeToString(signerHash[:])),
+			},
+		}
+	}
 
 	agentFactory := addonfactory.NewAgentAddonFactory(common.AddonName, FS, "manifests/charts/addon-agent").
 		WithAgentRegistrationOption(&agent.RegistrationOption{
 			Configurations: func(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonv1beta1.ManagedClusterAddOn) ([]agent.RegistrationConfig, error) {
-				return regConfigs, nil
+				return []agent.RegistrationConfig{kubeClientRegistration, newCustomSignerRegistration()}, nil
 			},
 			CSRApproveCheck: func(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonv1beta1.ManagedClusterAddOn, csr *csrv1.CertificateSigningRequest) bool {
 				return cluster.Spec.HubAcceptsClient
@@ -102,6 +157,19 @@ func NewAgentAddon(
 							Verbs:     []string{"*"},
 							Resources: []string{"leases"},
 						},
+						{
+							// allows the addon-agent to report the rollout of the proxy agents
+							APIGroups:     []string{"addon.open-cluster-management.io"},
+							Verbs:         []string{"get"},
+							Resources:     []string{"managedclusteraddons"},
+							ResourceNames: []string{common.AddonName},
+						},
+						{
+							APIGroups:     []string{"addon.open-cluster-management.io"},
+							Verbs:         []string{"update"},
+							Resources:     []string{"managedclusteraddons/status"},
+							ResourceNames: []string{common.AddonName},
+						},
 					},
 				}).
 				BindKubeClientClusterRole(&rbacv1.ClusterRole{
@@ -114,10 +182,16 @@ func NewAgentAddon(
 							Verbs:     []string{"create"},
 							Resources: []string{"tokenreviews"},
 						},
+						{
+							// allows service-proxy to verify hub service
+							// account tokens locally
+							NonResourceURLs: []string{"/.well-known/openid-configuration", "/openid/v1/jwks"},
+							Verbs:           []string{"get"},
+						},
 					},
 				}).
 				Build(),
-			CSRSign: CustomSignerWithExpiry(ProxyAgentSignerName, caKeyData, caCertData, time.Hour*24*180),
+			CSRSign: csrSign,
 		}).
 		WithConfigGVRs(
 			schema.GroupVersionResource{
@@ -130,11 +204,11 @@ func NewAgentAddon(
 		WithAgentDeployTriggerClusterFilter(utils.ClusterImageRegistriesAnnotationChanged).
 		WithGetValuesFuncs(
 			mergeAndNormalizeValuesFuncs(
-				GetClusterProxyValueFunc(runtimeClient, nativeClient, signerNamespace, caCertData, enableKubeApiProxy),
+				GetClusterProxyValueFunc(runtimeClient, nativeClient, signerNamespace, trustBundle, enableKubeApiProxy),
 				GetClusterProxyAdditionalValueFunc(runtimeClient, nativeClient, signerNamespace, enableServiceProxy, enableNetworkPolicies),
 				addonfactory.GetAddOnDeploymentConfigValues(
 					utils.NewAddOnDeploymentConfigGetter(addonClient),
-					toAgentAddOnChartValues(caCertData),
+					toAgentAddOnChartValues(trustBundle),
 					addonfactory.ToAddOnResourceRequirementsValues,
 				),
 			),
@@ -193,7 +267,7 @@ func GetClusterProxyValueFunc(
 	runtimeClient client.Client,
 	nativeClient kubernetes.Interface,
 	signerNamespace string,
-	caCertData []byte,
+	trustBundle func() []byte,
 	enableKubeApiProxy bool, //nolint:revive // parameter name is part of the public API
 ) addonfactory.GetValuesFunc {
 	return func(cluster *clusterv1.ManagedCluster,
@@ -205,11 +279,16 @@ func GetClusterProxyValueFunc(
 			return nil, err
 		}
 
+		if err := config.ValidateEntrypoint(proxyConfig); err != nil {
+			return nil, errors.Wrapf(err, "invalid ManagedProxyConfiguration %q", proxyConfig.Name)
+		}
+
 		// this is how we set the right ingress endpoint for proxy servers to
 		// receive handshakes from proxy agents:
 		// 1. upon "Hostname" type, use the prescribed hostname directly
-		// 2. upon "LoadBalancerService" type, use the first entry in the ip lists
-		// 3. otherwise defaulted to the in-cluster service endpoint
+		// 2. upon "LoadBalancerService" type, use the ip or the hostname of the first ingress point
+		// 3. upon "GatewayRoute", "Ingress" and "Route" types, use the address resolved in the status
+		// 4. otherwise defaulted to the in-cluster service endpoint
 		serviceEntryPoint := proxyConfig.Spec.ProxyServer.InClusterServiceName + "." + proxyConfig.Spec.ProxyServer.Namespace
 		// find the referenced proxy load-balancer prescribed in the proxy config if there's any
 		var proxyServerLoadBalancer *corev1.Service
@@ -231,24 +310,38 @@ func GetClusterProxyValueFunc(
 			"--hub-kubeconfig=/etc/kubeconfig/kubeconfig",
 			"--cluster-name=" + cluster.Name,
 			"--proxy-server-namespace=" + proxyConfig.Spec.ProxyServer.Namespace,
+			"--agent-deployment-name=" + AgentDeploymentName,
 		}
 		annotations := make(map[string]string)
+		ports := config.GetDeployPorts(proxyConfig)
+		serviceEntryPointPort := config.GetEntrypointPort(proxyConfig)
 		switch proxyConfig.Spec.ProxyServer.Entrypoint.Type {
 		case proxyv1alpha1.EntryPointTypeHostname:
 			serviceEntryPoint = proxyConfig.Spec.ProxyServer.Entrypoint.Hostname.Value
 		case proxyv1alpha1.EntryPointTypeLoadBalancerService:
-			serviceEntryPoint = proxyServerLoadBalancer.Status.LoadBalancer.Ingress[0].IP
+			serviceEntryPoint = cmp.Or(
+				proxyServerLoadBalancer.Status.LoadBalancer.Ingress[0].IP,
+				proxyServerLoadBalancer.Status.LoadBalancer.Ingress[0].Hostname)
+		case proxyv1alpha1.EntryPointTypeGatewayRoute,
+			proxyv1alpha1.EntryPointTypeIngress,
+			proxyv1alpha1.EntryPointTypeRoute:
+			serviceEntryPoint = util.EntrypointAddress(proxyConfig.Status.Entrypoint)
+			if len(serviceEntryPoint) == 0 {
+				return nil, fmt.Errorf("the %s entrypoint for proxy-server is not yet provisioned",
+					proxyConfig.Spec.ProxyServer.Entrypoint.Type)
+			}
 		case proxyv1alpha1.EntryPointTypePortForward:
+			// the local proxy listens on the agent port of the proxy-servers it forwards to
 			serviceEntryPoint = "127.0.0.1"
+			serviceEntryPointPort = ports.AgentServer
 			addonAgentArgs = append(addonAgentArgs,
-				"--enable-port-forward-proxy=true")
+				"--enable-port-forward-proxy=true",
+				"--proxy-server-agent-port="+strconv.Itoa(int(ports.AgentServer)))
 		}
 		annotations[common.AnnotationKeyConfigurationGeneration] = strconv.Itoa(int(proxyConfig.Generation))
-
-		serviceEntryPointPort := proxyConfig.Spec.ProxyServer.Entrypoint.Port
-		if serviceEntryPointPort == 0 {
-			serviceEntryPointPort = 8091
-		}
+		// the proxy agents load the CA bundle on startup, so they're rolled out upon changes.
+		caCertData := trustBundle()
+		annotations[common.AnnotationKeyCABundleHash] = common.CABundleHash(caCertData)
 
 		registry, image, tag, err := config.GetParsedAgentImage(proxyConfig.Spec.ProxyAgent.Image)
 		if err != nil {
@@ -285,7 +378,7 @@ func GetClusterProxyValueFunc(
 		agentIdentifiers := strings.Join(aids, "&")
 
 		values := map[string]interface{}{
-			"agentDeploymentName":          "cluster-proxy-proxy-agent",
+			"agentDeploymentName":          AgentDeploymentName,
 			"serviceDomain":                serviceDomain,
 			"includeNamespaceCreation":     true,
 			"spokeAddonNamespace":          namespace,
@@ -320,17 +413,48 @@ type serviceToExpose struct {
 	ExternalName string `json:"externalName"`
 }
 
+// newCASignerWithExpiry signs the CSRs by the CA.
+func newCASignerWithExpiry(ca *crypto.CA, validity time.Duration) (agent.CSRSignerFunc, error) {
+	caCertData, caKeyData, err := ca.Config.GetPEMBytes()
+	if err != nil {
+		return nil, err
+	}
+	return utils.DefaultSignerWithExpiry(caKeyData, caCertData, validity), nil
+}
+
+// getAgentCertValidity reads the validity of the agent certificates prescribed in the
+// ManagedProxyConfiguration, which must not exceed the lifetime of the signer CA if known.
+// The default validity is shortened to the remaining lifetime of the CA instead.
+func getAgentCertValidity(ctx context.Context, proxyConfigCache client.Reader, caCert *x509.Certificate) (time.Duration, error) {
+	proxyConfig := &proxyv1alpha1.ManagedProxyConfiguration{}
+	if err := proxyConfigCache.Get(ctx, types.NamespacedName{
+		Name: ManagedClusterConfigurationName,
+	}, proxyConfig); err != nil {
+		return 0, err
+	}
+	if err := config.ValidateCertificateLifetimes(proxyConfig, caCert); err != nil {
+		return 0, err
+	}
+	return config.GetCertificateLifetimes(proxyConfig, caCert).Agent.Validity.Duration, nil
+}
+
 func CustomSignerWithExpiry(customSignerName string, caKey, caData []byte, duration time.Duration) agent.CSRSignerFunc {
+	return CustomSigner(customSignerName, utils.DefaultSignerWithExpiry(caKey, caData, duration))
+}
+
+// CustomSigner signs only the CSRs requesting the custom signer by the sign func.
+func CustomSigner(customSignerName string, sign agent.CSRSignerFunc) agent.CSRSignerFunc {
 	return func(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonv1beta1.ManagedClusterAddOn, csr *csrv1.CertificateSigningRequest) ([]byte, error) {
 		if csr.Spec.SignerName != customSignerName {
 			return nil, nil
 		}
-		return utils.DefaultSignerWithExpiry(caKey, caData, duration)(ctx, cluster, addon, csr)
+		return sign(ctx, cluster, addon, csr)
 	}
 }
 
 const (
 	ApiserverNetworkProxyLabelAddon = "open-cluster-management.io/addon"
+	AgentDeploymentName             = "cluster-proxy-proxy-agent"
 	AgentSecretName                 = "cluster-proxy-open-cluster-management.io-proxy-agent-signer-client-cert"
 	AgentCASecretName               = "cluster-proxy-ca"
 )
@@ -356,7 +480,7 @@ func removeDupAndSortServices(services []serviceToExpose) []serviceToExpose {
 	return newServices
 }
 
-func toAgentAddOnChartValues(caCertData []byte) func(config addonv1beta1.AddOnDeploymentConfig) (addonfactory.Values, error) {
+func toAgentAddOnChartValues(trustBundle func() []byte) func(config addonv1beta1.AddOnDeploymentConfig) (addonfactory.Values, error) {
 	return func(config addonv1beta1.AddOnDeploymentConfig) (addonfactory.Values, error) {
 		values := addonfactory.Values{}
 		for _, variable := range config.Spec.CustomizedVariables {
@@ -376,7 +500,7 @@ func toAgentAddOnChartValues(caCertData []byte) func(config addonv1beta1.AddOnDe
 		}
 
 		if strings.HasPrefix(proxyConfig.HTTPSProxy, "https") && len(proxyConfig.CABundle) != 0 {
-			caCert, err := common.MergeCertificateData(proxyConfig.CABundle, caCertData)
+			caCert, err := common.MergeCertificateData(proxyConfig.CABundle, trustBundle())
 			if err != nil {
 				return nil, fmt.Errorf("faield to merge proxy env ca. %v", err)
 			}
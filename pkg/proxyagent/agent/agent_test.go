@@ -22,6 +22,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	csrv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -43,7 +44,9 @@ import (
 	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+	"open-cluster-management.io/cluster-proxy/pkg/util"
 )
 
 var (
@@ -198,11 +201,18 @@ func TestAgentAddonRegistrationOption(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			fakeKubeClient := fakekube.NewSimpleClientset()
+			proxyConfig := &proxyv1alpha1.ManagedProxyConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: ManagedClusterConfigurationName},
+			}
+			proxyConfig.Spec.Authentication.Lifetimes.Agent.Validity = &metav1.Duration{Duration: 30 * 24 * time.Hour}
 
+			fakeRuntimeClient := fakeruntime.NewClientBuilder().WithObjects(proxyConfig).Build()
 			agentAddOn, err := NewAgentAddon(
 				&fakeSelfSigner{t: t},
-				"",
 				nil,
+				"",
+				fakeRuntimeClient,
+				fakeRuntimeClient,
 				fakeKubeClient,
 				true,
 				false,
@@ -258,6 +268,18 @@ func TestAgentAddonRegistrationOption(t *testing.T) {
 					Verbs:     []string{"*"},
 					Resources: []string{"leases"},
 				},
+				{
+					APIGroups:     []string{"addon.open-cluster-management.io"},
+					Verbs:         []string{"get"},
+					Resources:     []string{"managedclusteraddons"},
+					ResourceNames: []string{"cluster-proxy"},
+				},
+				{
+					APIGroups:     []string{"addon.open-cluster-management.io"},
+					Verbs:         []string{"update"},
+					Resources:     []string{"managedclusteraddons/status"},
+					ResourceNames: []string{"cluster-proxy"},
+				},
 			}, role.Rules)
 
 			// Verify RoleBinding was created and references the correct subjects
@@ -280,6 +302,10 @@ func TestAgentAddonRegistrationOption(t *testing.T) {
 					Verbs:     []string{"create"},
 					Resources: []string{"tokenreviews"},
 				},
+				{
+					NonResourceURLs: []string{"/.well-known/openid-configuration", "/openid/v1/jwks"},
+					Verbs:           []string{"get"},
+				},
 			}, clusterRole.Rules)
 
 			// Verify ClusterRoleBinding was created
@@ -295,6 +321,12 @@ func TestAgentAddonRegistrationOption(t *testing.T) {
 			cert, err := options.Registration.CSRSign(context.TODO(), nil, nil, newCSR(c.signerName))
 			assert.NoError(t, err)
 			assert.Equal(t, c.expectedSignedCSR, (len(cert) != 0))
+			if c.expectedSignedCSR {
+				block, _ := pem.Decode(cert)
+				signed, err := x509.ParseCertificate(block.Bytes)
+				assert.NoError(t, err)
+				assert.Equal(t, 30*24*time.Hour, signed.NotAfter.Sub(signed.NotBefore))
+			}
 		})
 	}
 }
@@ -424,6 +456,70 @@ func TestNewAgentAddon(t *testing.T) {
 				assert.Equal(t, getProxyServerHost(agentDeploy), "1.2.3.4")
 			},
 		},
+		{
+			name:    "balancer service proxy server by hostname",
+			cluster: newCluster(clusterName, true),
+			addon: func() *addonv1beta1.ManagedClusterAddOn {
+				addOn := newAddOn(addOnName, clusterName)
+				addOn.Status.ConfigReferences = []addonv1beta1.ConfigReference{newManagedProxyConfigReference(managedProxyConfigName)}
+				return addOn
+			}(),
+			managedProxyConfig:      newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypeLoadBalancerService),
+			addOndDeploymentConfigs: []runtime.Object{},
+			kubeObjs: []runtime.Object{func() *corev1.Service {
+				svc := newLoadBalancerService("")
+				svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "abc.elb.amazonaws.com"}}
+				return svc
+			}()},
+			enableKubeApiProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				agentDeploy := getAgentDeployment(manifests)
+				assert.NotNil(t, agentDeploy)
+				assert.Equal(t, getProxyServerHost(agentDeploy), "abc.elb.amazonaws.com")
+			},
+		},
+		{
+			name:    "ingress proxy server not yet provisioned",
+			cluster: newCluster(clusterName, true),
+			addon: func() *addonv1beta1.ManagedClusterAddOn {
+				addOn := newAddOn(addOnName, clusterName)
+				addOn.Status.ConfigReferences = []addonv1beta1.ConfigReference{newManagedProxyConfigReference(managedProxyConfigName)}
+				return addOn
+			}(),
+			managedProxyConfig:      newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypeIngress),
+			addOndDeploymentConfigs: []runtime.Object{},
+			kubeObjs:                []runtime.Object{},
+			enableKubeApiProxy:      true,
+			expectedErrorMsg:        "the Ingress entrypoint for proxy-server is not yet provisioned",
+			verifyManifests:         func(t *testing.T, manifests []runtime.Object) {},
+		},
+		{
+			name:    "gateway route proxy server",
+			cluster: newCluster(clusterName, true),
+			addon: func() *addonv1beta1.ManagedClusterAddOn {
+				addOn := newAddOn(addOnName, clusterName)
+				addOn.Status.ConfigReferences = []addonv1beta1.ConfigReference{newManagedProxyConfigReference(managedProxyConfigName)}
+				return addOn
+			}(),
+			managedProxyConfig: func() *proxyv1alpha1.ManagedProxyConfiguration {
+				// the port of the gateway listener defaults to 443
+				mpc := newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypeGatewayRoute)
+				mpc.Status.Entrypoint = &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+					Hostnames: []string{"proxy.example.com"},
+					IPs:       []string{"2001:db8::1"},
+				}
+				return mpc
+			}(),
+			addOndDeploymentConfigs: []runtime.Object{},
+			kubeObjs:                []runtime.Object{},
+			enableKubeApiProxy:      true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				agentDeploy := getAgentDeployment(manifests)
+				assert.NotNil(t, agentDeploy)
+				assert.Equal(t, getProxyServerHost(agentDeploy), "proxy.example.com")
+				assert.Contains(t, agentDeploy.Spec.Template.Spec.Containers[0].Args, "--proxy-server-port=443")
+			},
+		},
 		{
 			name:    "hostname proxy server ",
 			cluster: newCluster(clusterName, true),
@@ -484,6 +580,59 @@ func TestNewAgentAddon(t *testing.T) {
 				assert.Equal(t, getProxyServerHost(agentDeploy), "127.0.0.1")
 			},
 		},
+		{
+			name:    "port forward proxy server with deploy ports",
+			cluster: newCluster(clusterName, true),
+			addon: func() *addonv1beta1.ManagedClusterAddOn {
+				addOn := newAddOn(addOnName, clusterName)
+				addOn.Status.ConfigReferences = []addonv1beta1.ConfigReference{newManagedProxyConfigReference(managedProxyConfigName)}
+				return addOn
+			}(),
+			managedProxyConfig: func() *proxyv1alpha1.ManagedProxyConfiguration {
+				mpc := newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward)
+				mpc.Spec.ProxyServer.Entrypoint.Port = 8091
+				mpc.Spec.Deploy = &proxyv1alpha1.ManagedProxyConfigurationDeploy{
+					Ports: proxyv1alpha1.ManagedProxyConfigurationDeployPorts{AgentServer: 18091},
+				}
+				return mpc
+			}(),
+			addOndDeploymentConfigs: []runtime.Object{},
+			kubeObjs:                []runtime.Object{},
+			enableKubeApiProxy:      true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				agentDeploy := getAgentDeployment(manifests)
+				if assert.NotNil(t, agentDeploy) {
+					// the local proxy listens on the agent port regardless of the entrypoint port
+					assert.Contains(t, getDeploymentContainer(agentDeploy, "proxy-agent").Args, "--proxy-server-port=18091")
+					assert.Contains(t, getDeploymentContainer(agentDeploy, "addon-agent").Args, "--proxy-server-agent-port=18091")
+				}
+			},
+		},
+		{
+			name:    "hostname entrypoint defaults to the agent port",
+			cluster: newCluster(clusterName, true),
+			addon: func() *addonv1beta1.ManagedClusterAddOn {
+				addOn := newAddOn(addOnName, clusterName)
+				addOn.Status.ConfigReferences = []addonv1beta1.ConfigReference{newManagedProxyConfigReference(managedProxyConfigName)}
+				return addOn
+			}(),
+			managedProxyConfig: func() *proxyv1alpha1.ManagedProxyConfiguration {
+				mpc := newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypeHostname)
+				mpc.Spec.Deploy = &proxyv1alpha1.ManagedProxyConfigurationDeploy{
+					Ports: proxyv1alpha1.ManagedProxyConfigurationDeployPorts{AgentServer: 18091},
+				}
+				return mpc
+			}(),
+			addOndDeploymentConfigs: []runtime.Object{},
+			kubeObjs:                []runtime.Object{},
+			enableKubeApiProxy:      true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				agentDeploy := getAgentDeployment(manifests)
+				if assert.NotNil(t, agentDeploy) {
+					assert.Contains(t, getDeploymentContainer(agentDeploy, "proxy-agent").Args, "--proxy-server-port=18091")
+				}
+			},
+		},
 		{
 			name:    "port forward proxy server with service proxy",
 			cluster: newCluster(clusterName, true),
@@ -502,6 +651,8 @@ func TestNewAgentAddon(t *testing.T) {
 				assert.ElementsMatch(t, expectedManifestNamesWithServiceProxy, manifestNames(manifests))
 				agentDeploy := getAgentDeployment(manifests)
 				assert.NotNil(t, agentDeploy)
+				assert.Equal(t, common.CertificateHash([]byte("testcrt")),
+					agentDeploy.Spec.Template.Annotations[common.AnnotationKeyServiceProxyCertHash])
 				serviceProxy := getDeploymentContainer(agentDeploy, "service-proxy")
 				if assert.NotNil(t, serviceProxy) {
 					if assert.NotNil(t, serviceProxy.ReadinessProbe) &&
@@ -870,6 +1021,362 @@ func TestNewAgentAddon(t *testing.T) {
 				assert.True(t, clusterRoleAllowsImpersonation(getClusterRole(manifests, "cluster-proxy-addon-agent-impersonator")))
 			},
 		},
+		{
+			name:               "with addon deployment config enabling identity assertion",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "enableIdentityAssertion", Value: "true"},
+				addonv1beta1.CustomizedVariable{Name: "identityAssertionTrustedBackends", Value: "monitoring/grafana"},
+				addonv1beta1.CustomizedVariable{Name: "identityAssertionTokenTTL", Value: "2m"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Subset(t, serviceProxy.Args, []string{
+						"--enable-identity-assertion=true",
+						"--identity-assertion-issuer=cluster-proxy:" + clusterName,
+						"--identity-assertion-trusted-backends=monitoring/grafana",
+						"--identity-assertion-token-ttl=2m",
+					})
+				}
+				assert.Contains(t, manifestNames(manifests), "cluster-proxy-identity-assertion")
+			},
+		},
+		{
+			name:               "with addon deployment config using target tls configmap",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "targetTLSConfigMap", Value: "cluster-proxy-target-tls"},
+				addonv1beta1.CustomizedVariable{Name: "targetTLSClientCertSecrets", Value: "vault-client,etcd-client"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--target-tls-configmap=cluster-proxy-target-tls")
+					assert.Contains(t, serviceProxy.Args, "--target-tls-client-cert-secrets=vault-client,etcd-client")
+				}
+				role := getRole(manifests, "cluster-proxy-addon-agent")
+				if assert.NotNil(t, role) {
+					for _, rule := range role.Rules {
+						if slices.Contains(rule.Resources, "secrets") && slices.Contains(rule.Verbs, "watch") {
+							assert.Equal(t, []string{"vault-client", "etcd-client"}, rule.ResourceNames)
+						}
+					}
+					assert.True(t, slices.ContainsFunc(role.Rules, func(rule rbacv1.PolicyRule) bool {
+						return slices.Contains(rule.Resources, "secrets") && slices.Contains(rule.Verbs, "watch")
+					}))
+				}
+			},
+		},
+		{
+			name:               "hub token verification and audiences",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "hubTokenVerification", Value: "local"},
+				addonv1beta1.CustomizedVariable{Name: "hubTokenAudiences", Value: "cluster-proxy.open-cluster-management.io"},
+				addonv1beta1.CustomizedVariable{Name: "managedClusterTokenAudiences", Value: "https://kubernetes.default.svc,cluster-proxy"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--hub-token-verification=local")
+					assert.Contains(t, serviceProxy.Args, "--hub-token-audiences=cluster-proxy.open-cluster-management.io")
+					assert.Contains(t, serviceProxy.Args, "--managed-cluster-token-audiences=https://kubernetes.default.svc,cluster-proxy")
+				}
+			},
+		},
+		{
+			name:               "client certificate authentication",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			kubeObjs:           []runtime.Object{newClientIdentitySigningSecret(t)},
+			addOndDeploymentConfigs: []runtime.Object{
+				newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName),
+			},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				var publicKey *corev1.ConfigMap
+				for _, manifest := range manifests {
+					if configMap, ok := manifest.(*corev1.ConfigMap); ok && configMap.Name == "cluster-proxy-client-identity-public-key" {
+						publicKey = configMap
+					}
+				}
+				if assert.NotNil(t, publicKey) {
+					assert.Contains(t, publicKey.Data["public.pem"], "BEGIN PUBLIC KEY")
+				}
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--cluster-name="+clusterName)
+					assert.Contains(t, serviceProxy.Args, "--client-certificate-identity-public-key=/client-identity/public.pem")
+				}
+			},
+		},
+		{
+			name:               "authentication token webhook",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "authenticationTokenWebhookConfigSecret", Value: "token-webhook"},
+				addonv1beta1.CustomizedVariable{Name: "authProviderOrder", Value: "webhook,hub"},
+				addonv1beta1.CustomizedVariable{Name: "authProviderTerminal", Value: "webhook"},
+				addonv1beta1.CustomizedVariable{Name: "authProviderIssuerRouting", Value: "true"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				deploy := getAgentDeployment(manifests)
+				serviceProxy := getDeploymentContainer(deploy, "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--authentication-token-webhook-config-file=/authentication-token-webhook/kubeconfig")
+					assert.Contains(t, serviceProxy.Args, "--auth-provider-order=webhook,hub")
+					assert.Contains(t, serviceProxy.Args, "--auth-provider-terminal=webhook")
+					assert.Contains(t, serviceProxy.Args, "--auth-provider-issuer-routing=true")
+				}
+				var webhookSecret string
+				for _, volume := range deploy.Spec.Template.Spec.Volumes {
+					if volume.Name == "authentication-token-webhook" && volume.Secret != nil {
+						webhookSecret = volume.Secret.SecretName
+					}
+				}
+				assert.Equal(t, "token-webhook", webhookSecret)
+				assert.True(t, clusterRoleAllowsImpersonation(getClusterRole(manifests, "cluster-proxy-addon-agent-impersonator")))
+			},
+		},
+		{
+			name:               "dedicated impersonator",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "enableDedicatedImpersonator", Value: "true"},
+				addonv1beta1.CustomizedVariable{Name: "impersonatorTokenAudiences", Value: "https://kubernetes.default.svc"},
+				addonv1beta1.CustomizedVariable{Name: "impersonatorTokenExpiration", Value: "30m"},
+				addonv1beta1.CustomizedVariable{Name: "impersonatorAllowedGroups", Value: "cluster:hub:system:serviceaccounts"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--impersonator-service-account=cluster-proxy-impersonator")
+					assert.Contains(t, serviceProxy.Args, "--impersonator-token-audiences=https://kubernetes.default.svc")
+					assert.Contains(t, serviceProxy.Args, "--impersonator-token-expiration=30m")
+					assert.Contains(t, serviceProxy.Args, "--impersonator-allowed-groups=cluster:hub:system:serviceaccounts")
+				}
+				// tokens are requested, never stored in a long-lived Secret
+				for _, manifest := range manifests {
+					if secret, ok := manifest.(*corev1.Secret); ok {
+						assert.NotEqual(t, corev1.SecretTypeServiceAccountToken, secret.Type)
+					}
+				}
+				assert.Contains(t, manifestNames(manifests), "cluster-proxy-impersonator:"+addOnName)
+				assert.False(t, clusterRoleAllowsImpersonation(getClusterRole(manifests, "cluster-proxy-addon-agent-impersonator")))
+
+				impersonator := getClusterRole(manifests, "cluster-proxy-impersonator")
+				assert.True(t, clusterRoleAllowsImpersonation(impersonator))
+				assert.Empty(t, clusterRoleImpersonateResourceNames(impersonator, "users"))
+				// every authenticated identity carries system:authenticated
+				assert.Equal(t, []string{"cluster:hub:system:serviceaccounts", "system:authenticated"},
+					clusterRoleImpersonateResourceNames(impersonator, "groups"))
+
+				var tokenRule *rbacv1.PolicyRule
+				for _, rule := range getRole(manifests, "cluster-proxy-addon-agent").Rules {
+					if slices.Contains(rule.Resources, "serviceaccounts/token") {
+						tokenRule = &rule
+					}
+				}
+				if assert.NotNil(t, tokenRule) {
+					assert.Equal(t, []string{"cluster-proxy-impersonator"}, tokenRule.ResourceNames)
+					assert.Equal(t, []string{"create"}, tokenRule.Verbs)
+				}
+			},
+		},
+		{
+			name:               "stream settings",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "streamingConnectionIdleTimeout", Value: "1h"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--streaming-connection-idle-timeout=1h")
+				}
+			},
+		},
+		{
+			name:               "pod proxy",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "enablePodProxy", Value: "true"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--enable-pod-proxy=true")
+				}
+				clusterRole := getClusterRole(manifests, "cluster-proxy-addon-agent-impersonator")
+				if assert.NotNil(t, clusterRole) {
+					assert.Contains(t, clusterRole.Rules, rbacv1.PolicyRule{
+						APIGroups: []string{""},
+						Resources: []string{"pods"},
+						Verbs:     []string{"get"},
+					})
+				}
+			},
+		},
+		{
+			name:               "hosted kube-apiserver",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "kubeAPIServerURL", Value: "https://api.hosted.example.com:6443"},
+				addonv1beta1.CustomizedVariable{Name: "kubeAPIServerCAConfigMap", Value: "hosted-ca"},
+				addonv1beta1.CustomizedVariable{Name: "kubeAPIServerTokenSecret", Value: "hosted-token"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				deploy := getAgentDeployment(manifests)
+				serviceProxy := getDeploymentContainer(deploy, "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--kube-apiserver-url=https://api.hosted.example.com:6443")
+					assert.Contains(t, serviceProxy.Args, "--kube-apiserver-ca-file=/kube-apiserver-ca/ca.crt")
+					assert.Contains(t, serviceProxy.Args, "--impersonator-token-file=/kube-apiserver-token/token")
+				}
+				proxyAgent := getDeploymentContainer(deploy, "proxy-agent")
+				if assert.NotNil(t, proxyAgent) {
+					assert.Contains(t, strings.Join(proxyAgent.Args, " "), "&host=api.hosted.example.com")
+				}
+				externalNameService := getKubeAPIServerExternalNameService(manifests, clusterName)
+				if assert.NotNil(t, externalNameService) {
+					assert.Equal(t, "api.hosted.example.com", externalNameService.Spec.ExternalName)
+				}
+			},
+		},
+		{
+			name:               "hosted kube-apiserver by IP",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "kubeAPIServerURL", Value: "https://10.0.0.1:6443"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				service := getKubeAPIServerExternalNameService(manifests, clusterName)
+				if assert.NotNil(t, service) {
+					assert.Empty(t, service.Spec.ExternalName)
+					assert.Equal(t, int32(443), service.Spec.Ports[0].Port)
+					assert.Equal(t, 6443, service.Spec.Ports[0].TargetPort.IntValue())
+				}
+				var endpointSlice *discoveryv1.EndpointSlice
+				for _, manifest := range manifests {
+					if obj, ok := manifest.(*discoveryv1.EndpointSlice); ok {
+						endpointSlice = obj
+					}
+				}
+				if assert.NotNil(t, endpointSlice) {
+					assert.Equal(t, discoveryv1.AddressTypeIPv4, endpointSlice.AddressType)
+					assert.Equal(t, []string{"10.0.0.1"}, endpointSlice.Endpoints[0].Addresses)
+				}
+			},
+		},
+		{
+			name:               "tcp tunnel",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "enableTCPTunnel", Value: "true"},
+			)},
+			enableKubeApiProxy: true,
+			enableServiceProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				serviceProxy := getDeploymentContainer(getAgentDeployment(manifests), "service-proxy")
+				if assert.NotNil(t, serviceProxy) {
+					assert.Contains(t, serviceProxy.Args, "--enable-tcp-tunnel=true")
+				}
+				clusterRole := getClusterRole(manifests, "cluster-proxy-addon-agent-impersonator")
+				if assert.NotNil(t, clusterRole) {
+					assert.Contains(t, clusterRole.Rules, rbacv1.PolicyRule{
+						APIGroups: []string{"authorization.k8s.io"},
+						Resources: []string{"subjectaccessreviews"},
+						Verbs:     []string{"create"},
+					})
+				}
+			},
+		},
+		{
+			name:               "tcp tunnel requires service proxy",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "enableTCPTunnel", Value: "true"},
+			)},
+			enableKubeApiProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				clusterRole := getClusterRole(manifests, "cluster-proxy-addon-agent-impersonator")
+				if assert.NotNil(t, clusterRole) {
+					for _, rule := range clusterRole.Rules {
+						assert.NotContains(t, rule.Resources, "subjectaccessreviews")
+					}
+				}
+			},
+		},
+		{
+			name:               "client certificate authentication requires service proxy",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			kubeObjs:           []runtime.Object{newClientIdentitySigningSecret(t)},
+			addOndDeploymentConfigs: []runtime.Object{
+				newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName),
+			},
+			enableKubeApiProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				assert.NotContains(t, manifestNames(manifests), "cluster-proxy-client-identity-public-key")
+			},
+		},
+		{
+			name:               "identity assertion requires service proxy",
+			cluster:            newCluster(clusterName, true),
+			addon:              newAddonWithDeploymentConfig(),
+			managedProxyConfig: newManagedProxyConfig(managedProxyConfigName, proxyv1alpha1.EntryPointTypePortForward),
+			addOndDeploymentConfigs: []runtime.Object{newAddOnDeploymentConfigWithVariables(addOndDeployConfigName, clusterName,
+				addonv1beta1.CustomizedVariable{Name: "enableIdentityAssertion", Value: "true"},
+			)},
+			enableKubeApiProxy: true,
+			verifyManifests: func(t *testing.T, manifests []runtime.Object) {
+				assert.NotContains(t, manifestNames(manifests), "cluster-proxy-identity-assertion")
+			},
+		},
 		{
 			// customizedVariables are string-typed, so the chart's schema is what
 			// rejects an empty prefix before it reaches the agent
@@ -997,8 +1504,10 @@ func TestNewAgentAddon(t *testing.T) {
 
 			agentAddOn, err := NewAgentAddon(
 				&fakeSelfSigner{t: t},
+				nil,
 				"test",
 				fakeRuntimeClient,
+				fakeRuntimeClient,
 				fakeKubeClient,
 				c.enableKubeApiProxy,
 				c.enableServiceProxy,
@@ -1135,10 +1644,13 @@ func TestImpersonationRBACConfiguration(t *testing.T) {
 			}
 			deploymentConfig := newAddOnDeploymentConfigWithVariables(deployConfigName, clusterName, variables...)
 
+			fakeRuntimeClient := fakeruntime.NewClientBuilder().WithObjects(proxyConfig).Build()
 			agentAddon, err := NewAgentAddon(
 				&fakeSelfSigner{t: t},
+				nil,
 				"test",
-				fakeruntime.NewClientBuilder().WithObjects(proxyConfig).Build(),
+				fakeRuntimeClient,
+				fakeRuntimeClient,
 				fakekube.NewSimpleClientset(&corev1.Secret{
 					ObjectMeta: metav1.ObjectMeta{Name: "cluster-proxy-service-proxy-server-cert", Namespace: "test"},
 					Data:       map[string][]byte{"tls.crt": []byte("testcrt"), "tls.key": []byte("testkey")},
@@ -1341,6 +1853,13 @@ func newManagedProxyConfig(name string, entryPointType proxyv1alpha1.EntryPointT
 					Hostname: &proxyv1alpha1.EntryPointHostname{
 						Value: "hostname",
 					},
+					GatewayRoute: &proxyv1alpha1.EntryPointGatewayRoute{
+						Hostname:   "hostname",
+						ParentRefs: []proxyv1alpha1.EntryPointGatewayParentRef{{Name: "gateway"}},
+					},
+					Ingress: &proxyv1alpha1.EntryPointIngress{
+						Hostname: "hostname",
+					},
 				},
 				Namespace: "test",
 			},
@@ -1455,6 +1974,18 @@ func newLoadBalancerService(ingress string) *corev1.Service {
 	return svc
 }
 
+func newClientIdentitySigningSecret(t *testing.T) *corev1.Secret {
+	client := fakekube.NewSimpleClientset()
+	if _, err := util.LoadOrCreateSigningKey(context.TODO(), client, "test", util.ClientIdentitySigningSecretName); err != nil {
+		t.Fatalf("failed to create signing key: %v", err)
+	}
+	secret, err := client.CoreV1().Secrets("test").Get(context.TODO(), util.ClientIdentitySigningSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get signing key: %v", err)
+	}
+	return secret
+}
+
 func newAgentClientSecret() *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1500,18 +2031,39 @@ func getClusterRole(manifests []runtime.Object, name string) *rbacv1.ClusterRole
 	return nil
 }
 
+func getRole(manifests []runtime.Object, name string) *rbacv1.Role {
+	for _, manifest := range manifests {
+		if role, ok := manifest.(*rbacv1.Role); ok && role.Name == name {
+			return role
+		}
+	}
+	return nil
+}
+
 func clusterRoleAllowsImpersonation(clusterRole *rbacv1.ClusterRole) bool {
 	if clusterRole == nil {
 		return false
 	}
+	var users, groups bool
 	for _, rule := range clusterRole.Rules {
-		if slices.Contains(rule.Verbs, "impersonate") &&
-			slices.Contains(rule.Resources, "users") &&
-			slices.Contains(rule.Resources, "groups") {
-			return true
+		if slices.Contains(rule.Verbs, "impersonate") {
+			users = users || slices.Contains(rule.Resources, "users")
+			groups = groups || slices.Contains(rule.Resources, "groups")
 		}
 	}
-	return false
+	return users && groups
+}
+
+func clusterRoleImpersonateResourceNames(clusterRole *rbacv1.ClusterRole, resource string) []string {
+	if clusterRole == nil {
+		return nil
+	}
+	for _, rule := range clusterRole.Rules {
+		if slices.Contains(rule.Verbs, "impersonate") && slices.Contains(rule.Resources, resource) {
+			return rule.ResourceNames
+		}
+	}
+	return nil
 }
 
 func getAgentNetworkPolicy(manifests []runtime.Object) *networkingv1.NetworkPolicy {
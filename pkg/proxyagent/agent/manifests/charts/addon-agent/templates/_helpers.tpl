@@ -3,7 +3,7 @@ Return true when the rendered service-proxy configuration may authenticate an
 identity that must be forwarded with Kubernetes impersonation.
 */}}
 {{- define "cluster-proxy-agent.requiresImpersonation" -}}
-{{- $requiresImpersonation := or (has .Values.enableImpersonation (list "1" "t" "T" "TRUE" "true" "True")) (not (empty .Values.oidcIssuerURL)) -}}
+{{- $requiresImpersonation := or (has .Values.enableImpersonation (list "1" "t" "T" "TRUE" "true" "True")) (not (empty .Values.oidcIssuerURL)) (not (empty .Values.authenticationTokenWebhookConfigSecret)) (eq (include "cluster-proxy-agent.clientCertificateAuthenticationEnabled" .) "true") -}}
 {{- $additionalHubAuthConfigured := false -}}
 {{- $additionalOIDCIssuerConfigured := false -}}
 {{- $duplicateAdditionalOIDCIssuer := false -}}
@@ -34,3 +34,82 @@ identity that must be forwarded with Kubernetes impersonation.
 {{- end -}}
 {{- $requiresImpersonation -}}
 {{- end -}}
+
+{{/*
+Return true when service-proxy asserts authenticated identities to Services
+other than the kube-apiserver.
+*/}}
+{{- define "cluster-proxy-agent.identityAssertionEnabled" -}}
+{{- and .Values.enableServiceProxy (has (toString .Values.enableIdentityAssertion) (list "1" "t" "T" "TRUE" "true" "True")) -}}
+{{- end -}}
+
+{{/*
+Return the host of kubeAPIServerURL without brackets and port, or nothing when
+the in-cluster kube-apiserver is used.
+*/}}
+{{- define "cluster-proxy-agent.kubeAPIServerHost" -}}
+{{- if .Values.kubeAPIServerURL -}}
+{{- regexReplaceAll "^\\[?([^\\]]*?)\\]?(:[0-9]+)?$" (urlParse .Values.kubeAPIServerURL).host "${1}" -}}
+{{- end -}}
+{{- end -}}
+
+{{/*
+Return the port of kubeAPIServerURL, 443 when it has none.
+*/}}
+{{- define "cluster-proxy-agent.kubeAPIServerPort" -}}
+{{- $port := regexFind ":[0-9]+$" (urlParse .Values.kubeAPIServerURL).host -}}
+{{- if $port -}}{{ trimPrefix ":" $port }}{{- else -}}443{{- end -}}
+{{- end -}}
+
+{{/*
+Return true when service-proxy accepts requests targeting single pods.
+*/}}
+{{- define "cluster-proxy-agent.podProxyEnabled" -}}
+{{- and .Values.enableServiceProxy (has (toString .Values.enablePodProxy) (list "1" "t" "T" "TRUE" "true" "True")) -}}
+{{- end -}}
+
+{{/*
+Return true when service-proxy accepts raw TCP tunnels to Services.
+*/}}
+{{- define "cluster-proxy-agent.tcpTunnelEnabled" -}}
+{{- and .Values.enableServiceProxy (has (toString .Values.enableTCPTunnel) (list "1" "t" "T" "TRUE" "true" "True")) -}}
+{{- end -}}
+
+{{/*
+Return true when service-proxy authenticates client certificate identities
+forwarded by the hub user-server.
+*/}}
+{{- define "cluster-proxy-agent.clientCertificateAuthenticationEnabled" -}}
+{{- and .Values.enableServiceProxy (ne (toString .Values.base64EncodedClientIdentityPublicKey) "") -}}
+{{- end -}}
+
+{{/*
+Return true when service-proxy impersonates with the cluster-proxy-impersonator
+ServiceAccount instead of its own ServiceAccount.
+*/}}
+{{- define "cluster-proxy-agent.dedicatedImpersonatorEnabled" -}}
+{{- and .Values.enableServiceProxy (has (toString .Values.enableDedicatedImpersonator) (list "1" "t" "T" "TRUE" "true" "True")) (eq (include "cluster-proxy-agent.requiresImpersonation" .) "true") -}}
+{{- end -}}
+
+{{/*
+Render the impersonate rules for users and groups, limited to the allowlisted
+names. Every authenticated identity carries system:authenticated, so a group
+allowlist always includes it.
+*/}}
+{{- define "cluster-proxy-agent.impersonateRules" -}}
+{{- range $resource, $allowlist := dict "users" .Values.impersonatorAllowedUsers "groups" .Values.impersonatorAllowedGroups }}
+{{- $names := compact (splitList "," (toString $allowlist)) }}
+{{- if and $names (eq $resource "groups") (not (has "system:authenticated" $names)) }}
+  {{- $names = append $names "system:authenticated" }}
+{{- end }}
+- apiGroups: [""]
+  resources: [{{ $resource | quote }}]
+  verbs: ["impersonate"]
+  {{- if $names }}
+  resourceNames:
+  {{- range $names }}
+  - {{ . | quote }}
+  {{- end }}
+  {{- end }}
+{{- end }}
+{{- end -}}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	certutil "k8s.io/client-go/util/cert"
+
+	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+	"open-cluster-management.io/cluster-proxy/pkg/constant"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyagent/agent"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+	"open-cluster-management.io/cluster-proxy/pkg/util"
+)
+
+// ensureCARotation advances the rotation of the self-signed CA. Every phase waits for the
+// proxy servers to be rolled out with the current trust bundle and for the pending clusters
+// to acknowledge it before the next phase starts. The clusters acknowledge by the rollouts
+// their addon-agents report on the ManagedClusterAddOns:
+//  1. "Trusting": the agents of every cluster are rolled out with the new CA in the trust bundle.
+//  2. "Reissuing": the agents of every cluster renew their client certificates from the new CA,
+//     and the service-proxies are rolled out with server certificates reissued by the new CA.
+//  3. "Completed": the previous CA is removed from the trust bundles.
+func (c *ManagedProxyConfigurationReconciler) ensureCARotation(
+	config *proxyv1alpha1.ManagedProxyConfiguration) (*proxyv1alpha1.ManagedProxyConfigurationCARotationStatus, error) {
+	signer, ok := c.SelfSigner.(*selfsigned.RotatingSigner)
+	if !ok || config.Spec.Authentication.Signer.Type != proxyv1alpha1.SelfSigned {
+		return config.Status.CARotation, nil
+	}
+	status := config.Status.CARotation.DeepCopy()
+	var trigger string
+	if config.Spec.Authentication.Signer.SelfSigned != nil {
+		trigger = config.Spec.Authentication.Signer.SelfSigned.CARotationTrigger
+	}
+
+	phase := signer.Phase()
+	switch {
+	case len(phase) == 0 && status != nil && status.Phase != proxyv1alpha1.CARotationPhaseCompleted:
+		// the previous CA is retired while the status is not yet updated.
+		return c.newCARotationPhase(status, proxyv1alpha1.CARotationPhaseCompleted, nil), nil
+	case len(phase) == 0:
+		if len(trigger) == 0 || (status != nil && status.Trigger == trigger) {
+			return status, nil
+		}
+		clusters, err := c.listAddOnClusters()
+		if err != nil {
+			return nil, err
+		}
+		if err := signer.AddNextCA(); err != nil {
+			return nil, errors.Wrapf(err, "failed to add the next ca")
+		}
+		now := metav1.Now()
+		status = c.newCARotationPhase(&proxyv1alpha1.ManagedProxyConfigurationCARotationStatus{
+			Trigger:   trigger,
+			StartTime: &now,
+		}, proxyv1alpha1.CARotationPhaseTrusting, clusters)
+		return status, nil
+	case status == nil || status.Phase != phase:
+		// resuming the rotation persisted in the signer secret, e.g. the status is lost.
+		clusters, err := c.listAddOnClusters()
+		if err != nil {
+			return nil, err
+		}
+		if status == nil {
+			status = &proxyv1alpha1.ManagedProxyConfigurationCARotationStatus{Trigger: trigger}
+		}
+		return c.newCARotationPhase(status, phase, clusters), nil
+	}
+
+	caData, err := c.getCAData(config)
+	if err != nil {
+		return nil, err
+	}
+	rolledOut, err := c.isProxyServerRolledOut(config, common.CABundleHash(caData))
+	if err != nil {
+		return nil, err
+	}
+	var acknowledged sets.Set[string]
+	if phase == proxyv1alpha1.CARotationPhaseTrusting {
+		acknowledged, err = c.listTrustingClusters(common.CABundleHash(caData))
+	} else {
+		acknowledged, err = c.listReissuedClusters(signer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := c.listAddOnClusters()
+	if err != nil {
+		return nil, err
+	}
+	// the clusters without the addon are no longer waited for.
+	status.PendingClusters = sets.List(sets.New(status.PendingClusters...).
+		Intersection(sets.New(clusters...)).
+		Difference(acknowledged))
+	if !rolledOut || len(status.PendingClusters) > 0 {
+		return status, nil
+	}
+
+	if phase == proxyv1alpha1.CARotationPhaseTrusting {
+		if err := signer.PromoteNextCA(); err != nil {
+			return nil, errors.Wrapf(err, "failed to promote the next ca")
+		}
+		return c.newCARotationPhase(status, proxyv1alpha1.CARotationPhaseReissuing, clusters), nil
+	}
+	if err := signer.RetirePreviousCA(); err != nil {
+		return nil, errors.Wrapf(err, "failed to retire the previous ca")
+	}
+	return c.newCARotationPhase(status, proxyv1alpha1.CARotationPhaseCompleted, nil), nil
+}
+
+func (c *ManagedProxyConfigurationReconciler) newCARotationPhase(
+	status *proxyv1alpha1.ManagedProxyConfigurationCARotationStatus,
+	phase proxyv1alpha1.CARotationPhase,
+	pendingClusters []string) *proxyv1alpha1.ManagedProxyConfigurationCARotationStatus {
+	c.EventRecorder.ForComponent("ClusterManagementAddonReconciler").
+		Eventf("CARotationPhaseChanged", "The rotation of the signer CA entered the %q phase", phase)
+	now := metav1.Now()
+	status = status.DeepCopy()
+	status.Phase = phase
+	status.LastTransitionTime = &now
+	status.TotalClusters = int32(len(pendingClusters))
+	status.PendingClusters = pendingClusters
+	return status
+}
+
+// isProxyServerRolledOut checks if every proxy-server replica is running with the CA bundle.
+func (c *ManagedProxyConfigurationReconciler) isProxyServerRolledOut(config *proxyv1alpha1.ManagedProxyConfiguration, caBundleHash string) (bool, error) {
+	deploy, err := c.DeploymentGetter.Deployments(config.Spec.ProxyServer.Namespace).
+		Get(context.TODO(), config.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return deploy.Spec.Template.Annotations[common.AnnotationKeyCABundleHash] == caBundleHash &&
+		util.IsDeploymentRolledOut(deploy), nil
+}
+
+// listAddOnClusters lists the managed clusters where the addon is installed.
+func (c *ManagedProxyConfigurationReconciler) listAddOnClusters() ([]string, error) {
+	addons := &addonv1beta1.ManagedClusterAddOnList{}
+	if err := c.APIReader.List(context.TODO(), addons); err != nil {
+		return nil, errors.Wrapf(err, "failed to list managed cluster addons")
+	}
+	var clusters []string
+	for _, addon := range addons.Items {
+		if addon.Name == common.AddonName {
+			clusters = append(clusters, addon.Namespace)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// listAgentRolloutHashes returns the rollout hashes reported by the addon-agents of every
+// managed cluster whose proxy agents are rolled out.
+func (c *ManagedProxyConfigurationReconciler) listAgentRolloutHashes() (map[string]map[string]string, error) {
+	addons := &addonv1beta1.ManagedClusterAddOnList{}
+	if err := c.APIReader.List(context.TODO(), addons); err != nil {
+		return nil, errors.Wrapf(err, "failed to list managed cluster addons")
+	}
+	hashes := map[string]map[string]string{}
+	for _, addon := range addons.Items {
+		if addon.Name != common.AddonName {
+			continue
+		}
+		rolledOut := meta.FindStatusCondition(addon.Status.Conditions, common.AddonConditionTypeProxyAgentRolledOut)
+		if rolledOut == nil || rolledOut.Status != metav1.ConditionTrue {
+			continue
+		}
+		hashes[addon.Namespace] = common.ParseRolloutHashes(rolledOut.Message)
+	}
+	return hashes, nil
+}
+
+// listTrustingClusters lists the managed clusters whose proxy agents are rolled out with the
+// CA bundle.
+func (c *ManagedProxyConfigurationReconciler) listTrustingClusters(caBundleHash string) (sets.Set[string], error) {
+	rolloutHashes, err := c.listAgentRolloutHashes()
+	if err != nil {
+		return nil, err
+	}
+	clusters := sets.New[string]()
+	for cluster, hashes := range rolloutHashes {
+		if hashes[common.AnnotationKeyCABundleHash] == caBundleHash {
+			clusters.Insert(cluster)
+		}
+	}
+	return clusters, nil
+}
+
+// listReissuedClusters lists the managed clusters whose proxy agents are signed a client
+// certificate by the current CA, and whose service-proxies are rolled out with a server
+// certificate signed by the current CA.
+func (c *ManagedProxyConfigurationReconciler) listReissuedClusters(signer selfsigned.SelfSigner) (sets.Set[string], error) {
+	csrs, err := c.CSRGetter.CertificateSigningRequests().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: addonv1beta1.AddonLabelKey + "=" + common.AddonName,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list certificate signing requests")
+	}
+	caCert := signer.CA().Config.Certs[0]
+	clusters := sets.New[string]()
+	for _, csr := range csrs.Items {
+		if csr.Spec.SignerName != agent.ProxyAgentSignerName || len(csr.Status.Certificate) == 0 {
+			continue
+		}
+		certs, err := certutil.ParseCertsPEM(csr.Status.Certificate)
+		if err != nil || certs[0].CheckSignatureFrom(caCert) != nil {
+			continue
+		}
+		clusters.Insert(csr.Labels[clusterv1.ClusterNameLabelKey])
+	}
+
+	serviceProxyCertHash, err := c.getServiceProxyCertHash(caCert)
+	if err != nil {
+		return nil, err
+	}
+	rolloutHashes, err := c.listAgentRolloutHashes()
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range sets.List(clusters) {
+		hashes, ok := rolloutHashes[cluster]
+		if !ok {
+			clusters.Delete(cluster)
+			continue
+		}
+		// the clusters without the service-proxy don't report its certificate.
+		if hash, ok := hashes[common.AnnotationKeyServiceProxyCertHash]; ok && hash != serviceProxyCertHash {
+			clusters.Delete(cluster)
+		}
+	}
+	return clusters, nil
+}
+
+// getServiceProxyCertHash returns the hash of the server certificate of the service-proxies,
+// or an empty hash if it's not yet reissued by the CA.
+func (c *ManagedProxyConfigurationReconciler) getServiceProxyCertHash(caCert *x509.Certificate) (string, error) {
+	if len(c.serviceProxySecretNamespace) == 0 {
+		return "", nil
+	}
+	secret, err := c.SecretGetter.Secrets(c.serviceProxySecretNamespace).
+		Get(context.TODO(), constant.ServerCertSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the service-proxy server certificate")
+	}
+	cert := getPEMCert(secret.Data[corev1.TLSCertKey])
+	if cert == nil || cert.CheckSignatureFrom(caCert) != nil {
+		return "", nil
+	}
+	return common.CertificateHash(secret.Data[corev1.TLSCertKey]), nil
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+	"open-cluster-management.io/cluster-proxy/pkg/constant"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyagent/agent"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+)
+
+func newAddOn(cluster, name string) *addonv1beta1.ManagedClusterAddOn {
+	return &addonv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Namespace: cluster, Name: name},
+	}
+}
+
+func newAgentCSR(t *testing.T, name, cluster string, signer selfsigned.SelfSigner) *certificatesv1.CertificateSigningRequest {
+	pair, err := signer.Sign(cert.Config{CommonName: name}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				addonv1beta1.AddonLabelKey:    common.AddonName,
+				clusterv1.ClusterNameLabelKey: cluster,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{SignerName: agent.ProxyAgentSignerName},
+		Status: certificatesv1.CertificateSigningRequestStatus{
+			Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pair.Cert.Raw}),
+		},
+	}
+}
+
+func TestEnsureCARotation(t *testing.T) {
+	config := newEntrypointTestConfig(nil)
+	config.Spec.Authentication.Signer = proxyv1alpha1.ManagedProxyConfigurationCertificateSigner{
+		Type:       proxyv1alpha1.SelfSigned,
+		SelfSigned: &proxyv1alpha1.AuthenticationSelfSigned{},
+	}
+	proxyServer := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "proxy-system", Name: "cluster-proxy", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           3,
+			UpdatedReplicas:    3,
+			AvailableReplicas:  3,
+		},
+	}
+	nativeClient := fake.NewSimpleClientset(proxyServer)
+	signer, err := selfsigned.NewRotatingSignerFromSecretOrGenerate(nativeClient, "open-cluster-management", "cluster-proxy-signer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := runtime.NewScheme()
+	if err := addonv1beta1.Install(scheme); err != nil {
+		t.Fatal(err)
+	}
+	apiReader := ctrlfake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newAddOn("cluster1", common.AddonName),
+		newAddOn("cluster2", common.AddonName),
+		newAddOn("cluster3", common.AddonName),
+		newAddOn("cluster4", "other-addon"),
+	).Build()
+	r := &ManagedProxyConfigurationReconciler{
+		SelfSigner:                  signer,
+		SecretGetter:                nativeClient.CoreV1(),
+		DeploymentGetter:            nativeClient.AppsV1(),
+		CSRGetter:                   nativeClient.CertificatesV1(),
+		APIReader:                   apiReader,
+		EventRecorder:               events.NewInMemoryRecorder("test", clock.RealClock{}),
+		serviceProxySecretNamespace: "open-cluster-management",
+	}
+	rolloutProxyServer := func() {
+		deploy := proxyServer.DeepCopy()
+		deploy.Spec.Template.Annotations = map[string]string{
+			common.AnnotationKeyCABundleHash: common.CABundleHash(signer.TrustBundle()),
+		}
+		if _, err := nativeClient.AppsV1().Deployments("proxy-system").Update(context.TODO(), deploy, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// issueServiceProxyCert signs the server certificate of the service-proxies by the current CA.
+	issueServiceProxyCert := func() string {
+		pair, err := signer.Sign(cert.Config{CommonName: "service-proxy"}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "open-cluster-management", Name: constant.ServerCertSecretName},
+			Data: map[string][]byte{
+				corev1.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pair.Cert.Raw}),
+			},
+		}
+		secrets := nativeClient.CoreV1().Secrets("open-cluster-management")
+		if _, err := secrets.Update(context.TODO(), secret, metav1.UpdateOptions{}); apierrors.IsNotFound(err) {
+			_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return common.CertificateHash(secret.Data[corev1.TLSCertKey])
+	}
+	// report updates the rollout of the proxy agents reported by the addon-agent of the cluster.
+	report := func(cluster string, rolledOut bool, caBundleHash, serviceProxyCertHash string) {
+		addon := &addonv1beta1.ManagedClusterAddOn{}
+		if err := apiReader.Get(context.TODO(), types.NamespacedName{Namespace: cluster, Name: common.AddonName}, addon); err != nil {
+			t.Fatal(err)
+		}
+		condition := metav1.Condition{
+			Type:   common.AddonConditionTypeProxyAgentRolledOut,
+			Status: metav1.ConditionTrue,
+			Reason: "DeploymentRolledOut",
+			Message: common.FormatRolloutHashes(map[string]string{
+				common.AnnotationKeyCABundleHash:         caBundleHash,
+				common.AnnotationKeyServiceProxyCertHash: serviceProxyCertHash,
+			}),
+		}
+		if !rolledOut {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "DeploymentRollingOut"
+		}
+		meta.SetStatusCondition(&addon.Status.Conditions, condition)
+		if err := apiReader.Update(context.TODO(), addon); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ensure := func() {
+		status, err := r.ensureCARotation(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Status.CARotation = status
+	}
+
+	// no rotation is triggered
+	ensure()
+	assert.Nil(t, config.Status.CARotation)
+	originalCA := signer.CA().Config.Certs[0]
+	originalCertHash := issueServiceProxyCert()
+	originalCABundleHash := common.CABundleHash(signer.TrustBundle())
+	_, err = nativeClient.CertificatesV1().CertificateSigningRequests().Create(context.TODO(),
+		newAgentCSR(t, "cluster1-original", "cluster1", signer), metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	// the next CA is trusted besides the current one
+	config.Spec.Authentication.Signer.SelfSigned.CARotationTrigger = "2026-10"
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseTrusting, config.Status.CARotation.Phase)
+	assert.Equal(t, "2026-10", config.Status.CARotation.Trigger)
+	assert.Equal(t, int32(3), config.Status.CARotation.TotalClusters)
+	assert.Equal(t, []string{"cluster1", "cluster2", "cluster3"}, config.Status.CARotation.PendingClusters)
+	trustBundle, err := cert.ParseCertsPEM(signer.TrustBundle())
+	assert.NoError(t, err)
+	assert.Len(t, trustBundle, 2)
+	assert.True(t, signer.CA().Config.Certs[0].Equal(originalCA))
+
+	// waiting for the agents to be rolled out with the new trust bundle, the agents of cluster2
+	// are still rolling out while the ones of cluster3 are rolled out with the stale bundle.
+	caBundleHash := common.CABundleHash(signer.TrustBundle())
+	report("cluster1", true, caBundleHash, originalCertHash)
+	report("cluster2", false, caBundleHash, originalCertHash)
+	report("cluster3", true, originalCABundleHash, originalCertHash)
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseTrusting, config.Status.CARotation.Phase)
+	assert.Equal(t, []string{"cluster2", "cluster3"}, config.Status.CARotation.PendingClusters)
+
+	// the clusters without the addon are skipped, but the proxy servers are waited for
+	report("cluster2", true, caBundleHash, originalCertHash)
+	assert.NoError(t, apiReader.Delete(context.TODO(), newAddOn("cluster3", common.AddonName)))
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseTrusting, config.Status.CARotation.Phase)
+	assert.Empty(t, config.Status.CARotation.PendingClusters)
+
+	// the next CA is promoted
+	rolloutProxyServer()
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseReissuing, config.Status.CARotation.Phase)
+	assert.Equal(t, []string{"cluster1", "cluster2"}, config.Status.CARotation.PendingClusters)
+	assert.False(t, signer.CA().Config.Certs[0].Equal(originalCA))
+	trustBundle, err = cert.ParseCertsPEM(signer.TrustBundle())
+	assert.NoError(t, err)
+	assert.Len(t, trustBundle, 2)
+
+	// waiting for the agents to be reissued by the new CA
+	rolloutProxyServer()
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseReissuing, config.Status.CARotation.Phase)
+	assert.Equal(t, []string{"cluster1", "cluster2"}, config.Status.CARotation.PendingClusters)
+
+	// waiting for the service-proxy certificate to be reissued by the new CA
+	for _, cluster := range []string{"cluster1", "cluster2"} {
+		_, err = nativeClient.CertificatesV1().CertificateSigningRequests().Create(context.TODO(),
+			newAgentCSR(t, cluster+"-reissued", cluster, signer), metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseReissuing, config.Status.CARotation.Phase)
+	assert.Equal(t, []string{"cluster1", "cluster2"}, config.Status.CARotation.PendingClusters)
+
+	// waiting for the service-proxies to be rolled out with the reissued certificate
+	reissuedCertHash := issueServiceProxyCert()
+	report("cluster1", true, caBundleHash, reissuedCertHash)
+	report("cluster2", false, caBundleHash, reissuedCertHash)
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseReissuing, config.Status.CARotation.Phase)
+	assert.Equal(t, []string{"cluster2"}, config.Status.CARotation.PendingClusters)
+	trustBundle, err = cert.ParseCertsPEM(signer.TrustBundle())
+	assert.NoError(t, err)
+	assert.Len(t, trustBundle, 2)
+
+	// the previous CA is retired
+	report("cluster2", true, caBundleHash, reissuedCertHash)
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseCompleted, config.Status.CARotation.Phase)
+	assert.Empty(t, config.Status.CARotation.PendingClusters)
+	trustBundle, err = cert.ParseCertsPEM(signer.TrustBundle())
+	assert.NoError(t, err)
+	assert.Len(t, trustBundle, 1)
+
+	// the same trigger doesn't rotate again
+	ensure()
+	assert.Equal(t, proxyv1alpha1.CARotationPhaseCompleted, config.Status.CARotation.Phase)
+	assert.Empty(t, signer.Phase())
+}
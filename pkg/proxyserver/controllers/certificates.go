@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/constant"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/certmanager"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+)
+
+// annotationKeyCertManagerCertificateName is set by cert-manager on the secrets it issues.
+const annotationKeyCertManagerCertificateName = "cert-manager.io/certificate-name"
+
+// additionalSANs returns a copy of the custom SANs prescribed for the signer.
+func additionalSANs(signer proxyv1alpha1.ManagedProxyConfigurationCertificateSigner) []string {
+	switch {
+	case signer.Type == proxyv1alpha1.Provided && signer.Provided != nil:
+		return slices.Clone(signer.Provided.AdditionalSANs)
+	case signer.Type == proxyv1alpha1.CertManager && signer.CertManager != nil:
+		return slices.Clone(signer.CertManager.AdditionalSANs)
+	case signer.SelfSigned != nil:
+		return slices.Clone(signer.SelfSigned.AdditionalSANs)
+	}
+	return nil
+}
+
+// getCAData returns the CA certificates trusted by the proxy servers.
+func (c *ManagedProxyConfigurationReconciler) getCAData(config *proxyv1alpha1.ManagedProxyConfiguration) ([]byte, error) {
+	signer := config.Spec.Authentication.Signer
+	if signer.Type == proxyv1alpha1.CertManager {
+		if signer.CertManager == nil {
+			return nil, fmt.Errorf("certManager is required for the CertManager signer")
+		}
+		return certmanager.LoadCABundle(c.SecretGetter, signer.CertManager.CABundle)
+	}
+	if c.SelfSigner == nil {
+		return nil, fmt.Errorf("the signer is changed to %q, restart the addon-manager to pick it up", signer.Type)
+	}
+	return selfsigned.TrustBundle(c.SelfSigner), nil
+}
+
+// newCertificates builds the cert-manager Certificates issuing the certificates of the proxy
+// servers and their clients into the dumped secrets.
+func newCertificates(config *proxyv1alpha1.ManagedProxyConfiguration,
+	lifetimes proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes,
+	sans []string, userServerSANs []string) []*unstructured.Unstructured {
+	namespace := config.Spec.ProxyServer.Namespace
+	secrets := config.Spec.Authentication.Dump.Secrets
+	issuerRef := config.Spec.Authentication.Signer.CertManager.IssuerRef
+	server, proxyClient := lifetimes.Server, lifetimes.ProxyClient
+	certificates := []*unstructured.Unstructured{
+		certmanager.NewCertificate(namespace, secrets.SigningProxyServerSecretName, secrets.SigningProxyServerSecretName,
+			issuerRef, server.Validity.Duration, server.RefreshBefore.Duration, sans, certmanager.UsageServerAuth),
+		certmanager.NewCertificate(namespace, secrets.SigningAgentServerSecretName, secrets.SigningAgentServerSecretName,
+			issuerRef, server.Validity.Duration, server.RefreshBefore.Duration, sans, certmanager.UsageServerAuth),
+		certmanager.NewCertificate(namespace, secrets.SigningProxyClientSecretName, secrets.SigningProxyClientSecretName,
+			issuerRef, proxyClient.Validity.Duration, proxyClient.RefreshBefore.Duration, sans, certmanager.UsageClientAuth),
+	}
+	if config.Spec.UserServer != nil {
+		certificates = append(certificates,
+			certmanager.NewCertificate(namespace, constant.UserServerSecretName, constant.UserServerSecretName,
+				issuerRef, server.Validity.Duration, server.RefreshBefore.Duration, userServerSANs, certmanager.UsageServerAuth))
+	}
+	for _, certificate := range certificates {
+		certificate.SetOwnerReferences([]metav1.OwnerReference{newOwnerReference(config)})
+	}
+	return certificates
+}
+
+// ensureCertificates has the certificates issued by cert-manager instead of being rotated
+// from the self-signed or provided CA.
+func (c *ManagedProxyConfigurationReconciler) ensureCertificates(config *proxyv1alpha1.ManagedProxyConfiguration,
+	lifetimes proxyv1alpha1.ManagedProxyConfigurationCertificateLifetimes, sans []string) error {
+	if config.Spec.Authentication.Signer.CertManager == nil {
+		return fmt.Errorf("certManager is required for the CertManager signer")
+	}
+	var userServerSANs []string
+	if config.Spec.UserServer != nil {
+		userServerSANs = c.buildUserServerSANs(config)
+	}
+	for _, certificate := range newCertificates(config, lifetimes, sans, userServerSANs) {
+		if _, _, err := c.ensure(config.Generation, certmanager.CertificateGVK, certificate); err != nil {
+			if meta.IsNoMatchError(err) {
+				return errors.Wrapf(err, "cert-manager is not installed")
+			}
+			return errors.Wrapf(err, "fails to ensure certificate %q", certificate.GetName())
+		}
+	}
+	return nil
+}
+
+// removeStaleCertificates deletes the cert-manager Certificates left behind by a previous
+// "CertManager" signer, which would otherwise keep overwriting the rotated secrets. The
+// secrets issued by cert-manager are annotated with the name of their Certificate.
+func (c *ManagedProxyConfigurationReconciler) removeStaleCertificates(config *proxyv1alpha1.ManagedProxyConfiguration) error {
+	namespace := config.Spec.ProxyServer.Namespace
+	secrets := config.Spec.Authentication.Dump.Secrets
+	for _, secretName := range []string{
+		secrets.SigningProxyServerSecretName,
+		secrets.SigningAgentServerSecretName,
+		secrets.SigningProxyClientSecretName,
+		constant.UserServerSecretName,
+	} {
+		secret, err := c.SecretGetter.Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		certificateName, ok := secret.Annotations[annotationKeyCertManagerCertificateName]
+		if !ok {
+			continue
+		}
+		certificate := &unstructured.Unstructured{}
+		certificate.SetGroupVersionKind(certmanager.CertificateGVK)
+		certificate.SetNamespace(namespace)
+		certificate.SetName(certificateName)
+		if err := c.deleteIfExists(certificate); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/certmanager"
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+)
+
+func newCertManagerTestConfig() *proxyv1alpha1.ManagedProxyConfiguration {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypePortForward,
+	})
+	config.Spec.Authentication.Signer = proxyv1alpha1.ManagedProxyConfigurationCertificateSigner{
+		Type: proxyv1alpha1.CertManager,
+		CertManager: &proxyv1alpha1.AuthenticationCertManager{
+			IssuerRef:      proxyv1alpha1.CertManagerIssuerReference{Name: "corp-ca", Kind: "ClusterIssuer"},
+			AdditionalSANs: []string{"proxy.example.com"},
+		},
+	}
+	config.Spec.Authentication.Dump.Secrets = proxyv1alpha1.CertificateSigningSecrets{
+		SigningProxyServerSecretName: "proxy-server",
+		SigningAgentServerSecretName: "agent-server",
+		SigningProxyClientSecretName: "proxy-client",
+	}
+	return config
+}
+
+func getCertificate(t *testing.T, c client.Client, name string) *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certmanager.CertificateGVK)
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "proxy-system", Name: name}, certificate); err != nil {
+		t.Fatal(err)
+	}
+	return certificate
+}
+
+func TestEnsureRotation_CertManager(t *testing.T) {
+	config := newCertManagerTestConfig()
+	config.Spec.Authentication.Lifetimes.Server.Validity = &metav1.Duration{Duration: 30 * 24 * time.Hour}
+	r := &ManagedProxyConfigurationReconciler{
+		Client: ctrlfake.NewClientBuilder().Build(),
+		newCertRotatorFunc: func(namespace, name string, lifetime proxyv1alpha1.CertificateLifetime, sans ...string) selfsigned.CertRotation {
+			t.Fatalf("unexpected rotation of %s", name)
+			return nil
+		},
+	}
+
+	if err := r.ensureRotation(config, &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+		IPs: []string{"1.2.3.4"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]struct{ usage, duration, renewBefore string }{
+		"proxy-server": {certmanager.UsageServerAuth, "720h0m0s", "144h0m0s"},
+		"agent-server": {certmanager.UsageServerAuth, "720h0m0s", "144h0m0s"},
+		"proxy-client": {certmanager.UsageClientAuth, "4320h0m0s", "864h0m0s"},
+	} {
+		certificate := getCertificate(t, r.Client, name)
+		secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+		assert.Equal(t, name, secretName)
+		issuerName, _, _ := unstructured.NestedString(certificate.Object, "spec", "issuerRef", "name")
+		assert.Equal(t, "corp-ca", issuerName)
+		dnsNames, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "dnsNames")
+		assert.Equal(t, []string{
+			"proxy.example.com",
+			"localhost",
+			"proxy-entrypoint.proxy-system",
+			"proxy-entrypoint.proxy-system.svc",
+		}, dnsNames)
+		ipAddresses, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "ipAddresses")
+		assert.Equal(t, []string{"127.0.0.1", "1.2.3.4"}, ipAddresses)
+		usages, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "usages")
+		assert.Contains(t, usages, expected.usage)
+		duration, _, _ := unstructured.NestedString(certificate.Object, "spec", "duration")
+		assert.Equal(t, expected.duration, duration)
+		renewBefore, _, _ := unstructured.NestedString(certificate.Object, "spec", "renewBefore")
+		assert.Equal(t, expected.renewBefore, renewBefore)
+		assert.Len(t, certificate.GetOwnerReferences(), 1)
+	}
+}
+
+func TestRemoveStaleCertificates(t *testing.T) {
+	config := newCertManagerTestConfig()
+	stale := certmanager.NewCertificate("proxy-system", "proxy-server", "proxy-server",
+		config.Spec.Authentication.Signer.CertManager.IssuerRef, proxyconfig.DefaultCertificateValidity, 0, nil)
+	unrelated := certmanager.NewCertificate("proxy-system", "unrelated", "unrelated",
+		config.Spec.Authentication.Signer.CertManager.IssuerRef, proxyconfig.DefaultCertificateValidity, 0, nil)
+	r := &ManagedProxyConfigurationReconciler{
+		Client: ctrlfake.NewClientBuilder().WithObjects(stale, unrelated).Build(),
+		SecretGetter: fake.NewSimpleClientset(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "proxy-system",
+					Name:      "proxy-server",
+					Annotations: map[string]string{
+						annotationKeyCertManagerCertificateName: "proxy-server",
+					},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "proxy-system", Name: "agent-server"},
+			},
+		).CoreV1(),
+	}
+
+	config.Spec.Authentication.Signer.Type = proxyv1alpha1.SelfSigned
+	if err := r.removeStaleCertificates(config); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(stale), stale.DeepCopy())
+	assert.True(t, apierrors.IsNotFound(err), "expected the stale certificate to be deleted, got %v", err)
+	getCertificate(t, r.Client, "unrelated")
+}
+
+func TestAdditionalSANs(t *testing.T) {
+	selfSigned := &proxyv1alpha1.AuthenticationSelfSigned{AdditionalSANs: []string{"self-signed.example.com"}}
+	provided := &proxyv1alpha1.AuthenticationProvided{AdditionalSANs: []string{"provided.example.com"}}
+
+	assert.Equal(t, []string{"self-signed.example.com"}, additionalSANs(proxyv1alpha1.ManagedProxyConfigurationCertificateSigner{
+		Type:       proxyv1alpha1.SelfSigned,
+		SelfSigned: selfSigned,
+		Provided:   provided,
+	}))
+	assert.Equal(t, []string{"provided.example.com"}, additionalSANs(proxyv1alpha1.ManagedProxyConfigurationCertificateSigner{
+		Type:       proxyv1alpha1.Provided,
+		SelfSigned: selfSigned,
+		Provided:   provided,
+	}))
+
+	// appending to the returned SANs must not write into the spec
+	sans := make([]string, 1, 4)
+	signer := proxyv1alpha1.ManagedProxyConfigurationCertificateSigner{
+		Type:       proxyv1alpha1.SelfSigned,
+		SelfSigned: &proxyv1alpha1.AuthenticationSelfSigned{AdditionalSANs: sans},
+	}
+	_ = append(additionalSANs(signer), "localhost")
+	assert.Equal(t, "", sans[:2][1])
+}
@@ -6,6 +6,7 @@ import (
 
 	openshiftcrypto "github.com/openshift/library-go/pkg/crypto"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
@@ -16,7 +17,7 @@ func TestEnsureSecretRotation(t *testing.T) {
 	receivingSANs := make([]string, 0)
 
 	r := &ManagedProxyConfigurationReconciler{
-		newCertRotatorFunc: func(namespace, name string, sans ...string) selfsigned.CertRotation {
+		newCertRotatorFunc: func(namespace, name string, lifetime v1alpha1.CertificateLifetime, sans ...string) selfsigned.CertRotation {
 			receivingServiceNamespace = namespace
 			receivingSANs = sans
 			return dummyRotator{}
@@ -24,8 +25,12 @@ func TestEnsureSecretRotation(t *testing.T) {
 		CAPair: &openshiftcrypto.CA{
 			Config: &openshiftcrypto.TLSCertificateConfig{},
 		},
+		SecretGetter: fake.NewSimpleClientset().CoreV1(),
+	}
+	expectedEntrypoint := &v1alpha1.ManagedProxyConfigurationEntrypointStatus{
+		Hostnames: []string{"example.com", "foo"},
+		IPs:       []string{"fd00::1"},
 	}
-	expectedEntrypoint := "foo"
 	expectedServiceName := "tik"
 	expectedNamespace := "bar"
 	cfg := &v1alpha1.ManagedProxyConfiguration{
@@ -53,6 +58,7 @@ func TestEnsureSecretRotation(t *testing.T) {
 		"tik.bar.svc",
 		"example.com",
 		"foo",
+		"fd00::1",
 	}, receivingSANs)
 }
 
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
+)
+
+var (
+	serviceGVK = corev1.SchemeGroupVersion.WithKind("Service")
+	gatewayGVK = schema.GroupVersionKind{
+		Group:   "gateway.networking.k8s.io",
+		Version: "v1",
+		Kind:    "Gateway",
+	}
+	ingressGVK = networkingv1.SchemeGroupVersion.WithKind("Ingress")
+	routeGVK   = schema.GroupVersionKind{
+		Group:   "route.openshift.io",
+		Version: "v1",
+		Kind:    "Route",
+	}
+)
+
+// ingressSSLPassthroughAnnotations enables SSL passthrough on the NGINX ingress controller,
+// so that the proxy servers terminate the TLS handshakes of the proxy agents.
+func ingressSSLPassthroughAnnotations() map[string]string {
+	return map[string]string{
+		"nginx.ingress.kubernetes.io/ssl-passthrough":  "true",
+		"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+	}
+}
+
+func newEntrypointLoadBalancerService(config *proxyv1alpha1.ManagedProxyConfiguration) *corev1.Service {
+	entrypoint := config.Spec.ProxyServer.Entrypoint.LoadBalancerService
+	var loadBalancerClass *string
+	if len(entrypoint.LoadBalancerClass) > 0 {
+		loadBalancerClass = ptr.To(entrypoint.LoadBalancerClass)
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   config.Spec.ProxyServer.Namespace,
+			Name:        entrypoint.Name,
+			Annotations: getAnnotation(entrypoint.Annotations),
+			Labels: map[string]string{
+				common.LabelKeyComponentName: common.ComponentNameProxyEntrypoint,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				newOwnerReference(config),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				common.LabelKeyComponentName: common.ComponentNameProxyServer,
+			},
+			Type:                     corev1.ServiceTypeLoadBalancer,
+			Ports:                    proxyServerServicePorts(proxyconfig.GetDeployPorts(config)),
+			LoadBalancerClass:        loadBalancerClass,
+			LoadBalancerSourceRanges: entrypoint.LoadBalancerSourceRanges,
+			ExternalTrafficPolicy:    entrypoint.ExternalTrafficPolicy,
+			IPFamilyPolicy:           entrypoint.IPFamilyPolicy,
+		},
+	}
+}
+
+func gatewayRouteGVK(route *proxyv1alpha1.EntryPointGatewayRoute) schema.GroupVersionKind {
+	kind := route.Kind
+	if len(kind) == 0 {
+		kind = proxyv1alpha1.GatewayRouteKindTLSRoute
+	}
+	return schema.GroupVersionKind{
+		Group:   "gateway.networking.k8s.io",
+		Version: "v1alpha2",
+		Kind:    string(kind),
+	}
+}
+
+func newGatewayRoute(config *proxyv1alpha1.ManagedProxyConfiguration) *unstructured.Unstructured {
+	ports := proxyconfig.GetDeployPorts(config)
+	entrypoint := config.Spec.ProxyServer.Entrypoint.GatewayRoute
+	gvk := gatewayRouteGVK(entrypoint)
+
+	parentRefs := make([]interface{}, 0, len(entrypoint.ParentRefs))
+	for _, ref := range entrypoint.ParentRefs {
+		parentRef := map[string]interface{}{
+			"group": gatewayGVK.Group,
+			"kind":  gatewayGVK.Kind,
+			"name":  ref.Name,
+		}
+		if len(ref.Namespace) > 0 {
+			parentRef["namespace"] = ref.Namespace
+		}
+		if len(ref.SectionName) > 0 {
+			parentRef["sectionName"] = ref.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+	spec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": config.Spec.ProxyServer.InClusterServiceName,
+						"port": int64(ports.AgentServer),
+					},
+				},
+			},
+		},
+	}
+	if gvk.Kind == string(proxyv1alpha1.GatewayRouteKindTLSRoute) && len(entrypoint.Hostname) > 0 {
+		spec["hostnames"] = []interface{}{entrypoint.Hostname}
+	}
+
+	route := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	route.SetGroupVersionKind(gvk)
+	route.SetNamespace(config.Spec.ProxyServer.Namespace)
+	route.SetName(entrypoint.Name)
+	route.SetOwnerReferences([]metav1.OwnerReference{newOwnerReference(config)})
+	return route
+}
+
+func newEntrypointIngress(config *proxyv1alpha1.ManagedProxyConfiguration) *networkingv1.Ingress {
+	ports := proxyconfig.GetDeployPorts(config)
+	entrypoint := config.Spec.ProxyServer.Entrypoint.Ingress
+	annotations := ingressSSLPassthroughAnnotations()
+	if len(entrypoint.Annotations) > 0 {
+		annotations = getAnnotation(entrypoint.Annotations)
+	}
+	var ingressClassName *string
+	if len(entrypoint.IngressClassName) > 0 {
+		ingressClassName = ptr.To(entrypoint.IngressClassName)
+	}
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   config.Spec.ProxyServer.Namespace,
+			Name:        entrypoint.Name,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				newOwnerReference(config),
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: ingressClassName,
+			TLS: []networkingv1.IngressTLS{
+				{
+					Hosts: []string{entrypoint.Hostname},
+				},
+			},
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: entrypoint.Hostname,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: ptr.To(networkingv1.PathTypePrefix),
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: config.Spec.ProxyServer.InClusterServiceName,
+											Port: networkingv1.ServiceBackendPort{
+												Number: ports.AgentServer,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newEntrypointRoute(config *proxyv1alpha1.ManagedProxyConfiguration) *unstructured.Unstructured {
+	entrypoint := config.Spec.ProxyServer.Entrypoint.Route
+	spec := map[string]interface{}{
+		"to": map[string]interface{}{
+			"kind": "Service",
+			"name": config.Spec.ProxyServer.InClusterServiceName,
+		},
+		"port": map[string]interface{}{
+			"targetPort": "agent-server",
+		},
+		"tls": map[string]interface{}{
+			"termination":                   "passthrough",
+			"insecureEdgeTerminationPolicy": "None",
+		},
+	}
+	if len(entrypoint.Hostname) > 0 {
+		spec["host"] = entrypoint.Hostname
+	}
+
+	route := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	route.SetGroupVersionKind(routeGVK)
+	route.SetNamespace(config.Spec.ProxyServer.Namespace)
+	route.SetName(entrypoint.Name)
+	route.SetOwnerReferences([]metav1.OwnerReference{newOwnerReference(config)})
+	return route
+}
+
+// routeForUpdate keeps the host generated by the router when the Route doesn't prescribe one.
+func routeForUpdate(resource client.Object, current *unstructured.Unstructured) (client.Object, error) {
+	desiredRoute, ok := resource.DeepCopyObject().(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("expected *unstructured.Unstructured, got %T", resource)
+	}
+	if host, _, _ := unstructured.NestedString(desiredRoute.Object, "spec", "host"); len(host) > 0 {
+		return desiredRoute, nil
+	}
+	currentHost, _, err := unstructured.NestedString(current.Object, "spec", "host")
+	if err != nil {
+		return nil, err
+	}
+	if len(currentHost) > 0 {
+		if err := unstructured.SetNestedField(desiredRoute.Object, currentHost, "spec", "host"); err != nil {
+			return nil, err
+		}
+	}
+	return desiredRoute, nil
+}
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func newEntrypointTestConfig(entrypoint *proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint) *proxyv1alpha1.ManagedProxyConfiguration {
+	config := newTestConfig(3)
+	config.Name = "cluster-proxy"
+	config.Spec.ProxyServer.Namespace = "proxy-system"
+	config.Spec.ProxyServer.InClusterServiceName = "proxy-entrypoint"
+	config.Spec.ProxyServer.Entrypoint = entrypoint
+	return config
+}
+
+func newEntrypointTestReconciler(t *testing.T, objs ...client.Object) *ManagedProxyConfigurationReconciler {
+	var services []runtime.Object
+	for _, obj := range objs {
+		if svc, ok := obj.(*corev1.Service); ok {
+			services = append(services, svc.DeepCopy())
+		}
+	}
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := networkingv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &ManagedProxyConfigurationReconciler{
+		Client:        ctrlfake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		ServiceGetter: fake.NewSimpleClientset(services...).CoreV1(),
+		EventRecorder: events.NewInMemoryRecorder("test", clock.RealClock{}),
+	}
+}
+
+func TestEnsureEntrypoint_LoadBalancerHostname(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeLoadBalancerService,
+		LoadBalancerService: &proxyv1alpha1.EntryPointLoadBalancerService{
+			Name: "proxy-agent-entrypoint",
+		},
+	})
+	lbSvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "proxy-system",
+			Name:      "proxy-agent-entrypoint",
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{
+					{Hostname: "abc.elb.amazonaws.com"},
+					{IP: "2001:db8::1"},
+				},
+			},
+		},
+	}
+	reconciler := newEntrypointTestReconciler(t)
+	reconciler.ServiceGetter = fake.NewSimpleClientset(lbSvc).CoreV1()
+
+	status, err := reconciler.ensureEntrypoint(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+		Hostnames: []string{"abc.elb.amazonaws.com"},
+		IPs:       []string{"2001:db8::1"},
+	}, status)
+}
+
+func TestEnsureEntrypoint_LoadBalancerServiceUpdatedAndRenamed(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeLoadBalancerService,
+		LoadBalancerService: &proxyv1alpha1.EntryPointLoadBalancerService{
+			Name: "old-entrypoint",
+		},
+	})
+	config.UID = "mpc-uid"
+	config.Generation = 1
+	old := newEntrypointLoadBalancerService(config)
+	old.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+	unowned := newEntrypointLoadBalancerService(config)
+	unowned.Name = "unowned-entrypoint"
+	unowned.OwnerReferences = nil
+	reconciler := newEntrypointTestReconciler(t, old, unowned)
+
+	renamed := config.DeepCopy()
+	renamed.Generation = 2
+	renamed.Spec.ProxyServer.Entrypoint.LoadBalancerService = &proxyv1alpha1.EntryPointLoadBalancerService{
+		Name:                     "new-entrypoint",
+		Annotations:              []proxyv1alpha1.AnnotationVar{{Key: "service.beta.kubernetes.io/azure-load-balancer-internal", Value: "true"}},
+		LoadBalancerClass:        "example.com/lb",
+		LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyLocal,
+		IPFamilyPolicy:           ptr.To(corev1.IPFamilyPolicyPreferDualStack),
+	}
+	// the load-balancer of the new service is not yet provisioned
+	_, err := reconciler.ensureEntrypoint(renamed)
+	assert.Error(t, err)
+
+	actual := &corev1.Service{}
+	if err := reconciler.Get(context.Background(),
+		client.ObjectKey{Namespace: "proxy-system", Name: "new-entrypoint"}, actual); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", actual.Annotations["service.beta.kubernetes.io/azure-load-balancer-internal"])
+	assert.Equal(t, "example.com/lb", *actual.Spec.LoadBalancerClass)
+	assert.Equal(t, []string{"10.0.0.0/8"}, actual.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, corev1.ServiceExternalTrafficPolicyLocal, actual.Spec.ExternalTrafficPolicy)
+	assert.Equal(t, corev1.IPFamilyPolicyPreferDualStack, *actual.Spec.IPFamilyPolicy)
+	assert.Equal(t, "mpc-uid", string(actual.OwnerReferences[0].UID))
+
+	err = reconciler.Get(context.Background(), client.ObjectKeyFromObject(old), &corev1.Service{})
+	assert.True(t, apierrors.IsNotFound(err), "expected the old service to be deleted, got %v", err)
+	err = reconciler.Get(context.Background(), client.ObjectKeyFromObject(unowned), &corev1.Service{})
+	assert.NoError(t, err, "expected the unowned service to be kept")
+}
+
+func TestServiceForUpdatePreservesNodePorts(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeLoadBalancerService,
+		LoadBalancerService: &proxyv1alpha1.EntryPointLoadBalancerService{
+			Name:                  "proxy-agent-entrypoint",
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyLocal,
+		},
+	})
+	current := newEntrypointLoadBalancerService(config)
+	current.Spec.Ports[0].NodePort = 30090
+	current.Spec.Ports[1].NodePort = 30091
+	current.Spec.HealthCheckNodePort = 30092
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := serviceForUpdate(newEntrypointLoadBalancerService(config), &unstructured.Unstructured{Object: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatedService := updated.(*corev1.Service)
+	assert.Equal(t, int32(30090), updatedService.Spec.Ports[0].NodePort)
+	assert.Equal(t, int32(30091), updatedService.Spec.Ports[1].NodePort)
+	assert.Equal(t, int32(30092), updatedService.Spec.HealthCheckNodePort)
+
+	// the health check node port is released without the "Local" policy
+	config.Spec.ProxyServer.Entrypoint.LoadBalancerService.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+	updated, err = serviceForUpdate(newEntrypointLoadBalancerService(config), &unstructured.Unstructured{Object: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Zero(t, updated.(*corev1.Service).Spec.HealthCheckNodePort)
+}
+
+func TestEnsureEntrypoint_GatewayRoute(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeGatewayRoute,
+		GatewayRoute: &proxyv1alpha1.EntryPointGatewayRoute{
+			Name:     "proxy-agent-entrypoint",
+			Kind:     proxyv1alpha1.GatewayRouteKindTLSRoute,
+			Hostname: "proxy.example.com",
+			ParentRefs: []proxyv1alpha1.EntryPointGatewayParentRef{
+				{Name: "shared", Namespace: "gateways", SectionName: "tls"},
+			},
+		},
+	})
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "IPAddress", "value": "10.0.0.10"},
+				map[string]interface{}{"type": "Hostname", "value": "gw.example.com"},
+			},
+		},
+	}}
+	gateway.SetGroupVersionKind(gatewayGVK)
+	gateway.SetNamespace("gateways")
+	gateway.SetName("shared")
+	reconciler := newEntrypointTestReconciler(t, gateway)
+
+	status, err := reconciler.ensureEntrypoint(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+		Hostnames: []string{"proxy.example.com", "gw.example.com"},
+		IPs:       []string{"10.0.0.10"},
+	}, status)
+
+	route, err := reconciler.getEntrypointObject(gatewayRouteGVK(config.Spec.ProxyServer.Entrypoint.GatewayRoute),
+		"proxy-system", "proxy-agent-entrypoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	assert.Equal(t, []string{"proxy.example.com"}, hostnames)
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"backendRefs": []interface{}{
+				map[string]interface{}{"name": "proxy-entrypoint", "port": int64(8091)},
+			},
+		},
+	}, rules)
+}
+
+func TestEnsureEntrypoint_TLSRouteRequiresHostname(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeGatewayRoute,
+		GatewayRoute: &proxyv1alpha1.EntryPointGatewayRoute{
+			Name:       "proxy-agent-entrypoint",
+			ParentRefs: []proxyv1alpha1.EntryPointGatewayParentRef{{Name: "shared"}},
+		},
+	})
+	_, err := newEntrypointTestReconciler(t).ensureEntrypoint(config)
+	assert.EqualError(t, err, "hostname is required for the TLSRoute entrypoint")
+}
+
+func TestEnsureEntrypoint_Ingress(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeIngress,
+		Ingress: &proxyv1alpha1.EntryPointIngress{
+			Name:             "proxy-agent-entrypoint",
+			Hostname:         "proxy.example.com",
+			IngressClassName: "nginx",
+		},
+	})
+	reconciler := newEntrypointTestReconciler(t)
+
+	status, err := reconciler.ensureEntrypoint(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+		Hostnames: []string{"proxy.example.com"},
+	}, status)
+
+	ingress := &networkingv1.Ingress{}
+	if err := reconciler.Get(context.Background(),
+		client.ObjectKey{Namespace: "proxy-system", Name: "proxy-agent-entrypoint"}, ingress); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", ingress.Annotations["nginx.ingress.kubernetes.io/ssl-passthrough"])
+	assert.Equal(t, "nginx", *ingress.Spec.IngressClassName)
+	assert.Equal(t, "proxy.example.com", ingress.Spec.Rules[0].Host)
+	assert.Equal(t, int32(8091), ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number)
+
+	// the addresses of the ingress controller are published once provisioned
+	ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "1.2.3.4"}}
+	if err := reconciler.Status().Update(context.Background(), ingress); err != nil {
+		t.Fatal(err)
+	}
+	status, err = reconciler.ensureEntrypoint(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"1.2.3.4"}, status.IPs)
+}
+
+func TestEnsureEntrypoint_Route(t *testing.T) {
+	config := newEntrypointTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeRoute,
+		Route: &proxyv1alpha1.EntryPointRoute{
+			Name: "proxy-agent-entrypoint",
+		},
+	})
+	reconciler := newEntrypointTestReconciler(t)
+
+	_, err := reconciler.ensureEntrypoint(config)
+	assert.EqualError(t, err, "route host not yet admitted")
+
+	// the router generates the host of the route
+	route, err := reconciler.getEntrypointObject(routeGVK, "proxy-system", "proxy-agent-entrypoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	termination, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "termination")
+	assert.Equal(t, "passthrough", termination)
+	if err := unstructured.SetNestedField(route.Object, "proxy-agent-entrypoint.apps.example.com", "spec", "host"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.Update(context.Background(), route); err != nil {
+		t.Fatal(err)
+	}
+
+	// a configuration change re-renders the route without dropping the generated host
+	config.Generation = 2
+	status, err := reconciler.ensureEntrypoint(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"proxy-agent-entrypoint.apps.example.com"}, status.Hostnames)
+}
@@ -1,19 +1,22 @@
 package controllers
 
 import (
+	"cmp"
 	"context"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
-	"time"
 
 	addonutils "open-cluster-management.io/addon-framework/pkg/utils"
 	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
 	"open-cluster-management.io/cluster-proxy/pkg/common"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
 	"open-cluster-management.io/cluster-proxy/pkg/constant"
 	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+	"open-cluster-management.io/cluster-proxy/pkg/util"
 	"open-cluster-management.io/sdk-go/pkg/certrotation"
 	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
 
@@ -22,6 +25,8 @@ import (
 	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -35,6 +40,7 @@ import (
 	informercorev1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+	certificatesv1client "k8s.io/client-go/kubernetes/typed/certificates/v1"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog/v2"
@@ -60,29 +66,42 @@ func RegisterClusterManagementAddonReconciler(
 	imagePullPolicy string,
 	ownerReference *metav1.OwnerReference,
 	tlsConfig *sdktls.TLSConfig,
+	serviceProxySecretNamespace string,
 ) error {
 	r := &ManagedProxyConfigurationReconciler{
 		Client:     mgr.GetClient(),
 		SelfSigner: selfSigner,
-		CAPair:     selfSigner.CA(),
-		newCertRotatorFunc: func(namespace, name string, sans ...string) selfsigned.CertRotation {
-			return &certrotation.TargetRotation{
-				Namespace:      namespace,
-				Name:           name,
-				Validity:       time.Hour * 24 * 180,
-				HostNames:      sans,
-				Lister:         secretInformer.Lister(),
-				Client:         nativeClient.CoreV1(),
-				OwnerReference: ownerReference,
+		newCertRotatorFunc: func(namespace, name string, lifetime proxyv1alpha1.CertificateLifetime, sans ...string) selfsigned.CertRotation {
+			return &selfsigned.RefreshingRotation{
+				TargetRotation: certrotation.TargetRotation{
+					Namespace:      namespace,
+					Name:           name,
+					Validity:       lifetime.Validity.Duration,
+					HostNames:      sans,
+					Lister:         secretInformer.Lister(),
+					Client:         nativeClient.CoreV1(),
+					OwnerReference: ownerReference,
+				},
+				RefreshBefore: lifetime.RefreshBefore.Duration,
 			}
 		},
 		SecretLister:     secretInformer.Lister(),
 		SecretGetter:     nativeClient.CoreV1(),
 		ServiceGetter:    nativeClient.CoreV1(),
+		PodGetter:        nativeClient.CoreV1(),
 		DeploymentGetter: nativeClient.AppsV1(),
+		CSRGetter:        nativeClient.CertificatesV1(),
+		APIReader:        mgr.GetAPIReader(),
 		EventRecorder:    events.NewInMemoryRecorder("ClusterManagementAddonReconciler", clock.RealClock{}),
 		imagePullPolicy:  imagePullPolicy,
 		tlsConfig:        tlsConfig,
+
+		serviceProxySecretNamespace: serviceProxySecretNamespace,
+		scrapeConnectedAgentsFunc:   scrapeConnectedAgents,
+	}
+	// the self-signer is absent upon the "CertManager" signer.
+	if selfSigner != nil {
+		r.CAPair = selfSigner.CA()
 	}
 	return r.SetupWithManager(mgr, secretInformer.Informer())
 }
@@ -95,11 +114,19 @@ type ManagedProxyConfigurationReconciler struct {
 	SecretGetter     corev1client.SecretsGetter
 	DeploymentGetter appsv1client.DeploymentsGetter
 	ServiceGetter    corev1client.ServicesGetter
-	EventRecorder    events.Recorder
+	PodGetter        corev1client.PodsGetter
+	CSRGetter        certificatesv1client.CertificateSigningRequestsGetter
+	// APIReader reads the resources which are not cached by the manager, e.g. the manifest works.
+	APIReader     client.Reader
+	EventRecorder events.Recorder
 
-	newCertRotatorFunc func(namespace, name string, sans ...string) selfsigned.CertRotation
+	newCertRotatorFunc func(namespace, name string, lifetime proxyv1alpha1.CertificateLifetime, sans ...string) selfsigned.CertRotation
 	imagePullPolicy    string
 	tlsConfig          *sdktls.TLSConfig
+
+	// serviceProxySecretNamespace is where the certificate of the service-proxy is signed.
+	serviceProxySecretNamespace string
+	scrapeConnectedAgentsFunc   func(ctx context.Context, address string) (int32, error)
 }
 
 func (c *ManagedProxyConfigurationReconciler) SetupWithManager(mgr ctrl.Manager, secretInformer ctrlcache.Informer) error {
@@ -139,6 +166,12 @@ func (c *ManagedProxyConfigurationReconciler) Reconcile(ctx context.Context, req
 		return reconcile.Result{}, err
 	}
 
+	// advance the rotation of the self-signed CA before the certificates are rotated from it.
+	caRotation, err := c.ensureCARotation(config)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "fails to rotate the signer ca")
+	}
+
 	// ensure proxy-server cert rotation.
 	// at an interval of 10 hrs which is the default resync period of controller-runtime's informer.
 	if err := c.ensureRotation(config, entrypoint); err != nil {
@@ -155,13 +188,18 @@ func (c *ManagedProxyConfigurationReconciler) Reconcile(ctx context.Context, req
 	}
 
 	// refreshing status
-	if err := c.refreshStatus(isModified, config); err != nil {
+	if err := c.refreshStatus(isModified, config, entrypoint, caRotation); err != nil {
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{}, nil
+	// requeue to keep the connected agents in the status up-to-date.
+	return reconcile.Result{RequeueAfter: statusResyncInterval}, nil
 }
 
-func (c *ManagedProxyConfigurationReconciler) refreshStatus(isModified bool, config *proxyv1alpha1.ManagedProxyConfiguration) error {
+func (c *ManagedProxyConfigurationReconciler) refreshStatus(
+	isModified bool,
+	config *proxyv1alpha1.ManagedProxyConfiguration,
+	entrypoint *proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus,
+	caRotation *proxyv1alpha1.ManagedProxyConfigurationCARotationStatus) error {
 	currentState, err := c.getCurrentState(isModified, config)
 	if err != nil {
 		return err
@@ -169,6 +207,14 @@ func (c *ManagedProxyConfigurationReconciler) refreshStatus(isModified bool, con
 	expectingStatus := proxyv1alpha1.ManagedProxyConfigurationStatus{}
 	expectingStatus.LastObservedGeneration = config.Generation
 	expectingStatus.Conditions = c.getConditions(currentState)
+	expectingStatus.Entrypoint = entrypoint
+	expectingStatus.CARotation = caRotation
+	if expectingStatus.Certificates, err = c.getCertificateStatuses(config); err != nil {
+		return err
+	}
+	if expectingStatus.ProxyServers, err = c.getProxyServerStatuses(config); err != nil {
+		return err
+	}
 	currentStatus := config.Status.DeepCopy()
 	for i := range currentStatus.Conditions {
 		currentStatus.Conditions[i].LastTransitionTime = metav1.Time{}
@@ -177,6 +223,11 @@ func (c *ManagedProxyConfigurationReconciler) refreshStatus(isModified bool, con
 		return nil
 	}
 	editingConfig := config.DeepCopy()
+	editingConfig.Status.LastObservedGeneration = expectingStatus.LastObservedGeneration
+	editingConfig.Status.Entrypoint = expectingStatus.Entrypoint
+	editingConfig.Status.CARotation = expectingStatus.CARotation
+	editingConfig.Status.Certificates = expectingStatus.Certificates
+	editingConfig.Status.ProxyServers = expectingStatus.ProxyServers
 	for _, cond := range expectingStatus.Conditions {
 		expectingCondition := cond
 		meta.SetStatusCondition(&editingConfig.Status.Conditions, expectingCondition)
@@ -185,14 +236,32 @@ func (c *ManagedProxyConfigurationReconciler) refreshStatus(isModified bool, con
 }
 
 func (c *ManagedProxyConfigurationReconciler) deployProxyServer(config *proxyv1alpha1.ManagedProxyConfiguration) (bool, error) {
+	caData, err := c.getCAData(config)
+	if err != nil {
+		return false, err
+	}
+	// rolling the proxy servers out on changes of the trusted CAs, so that the progress of the
+	// CA rotation can be told from the deployment.
+	deployment := newProxyServerDeployment(config, c.imagePullPolicy, c.tlsConfig)
+	podAnnotations := maps.Clone(deployment.Spec.Template.Annotations)
+	if podAnnotations == nil {
+		podAnnotations = map[string]string{}
+	}
+	podAnnotations[common.AnnotationKeyCABundleHash] = common.CABundleHash(caData)
+	deployment.Spec.Template.Annotations = podAnnotations
 	resources := []client.Object{
 		newServiceAccount(config),
 		newProxyService(config),
-		newProxySecret(config, c.SelfSigner.CAData()),
-		newProxyServerDeployment(config, c.imagePullPolicy, c.tlsConfig),
+		newProxySecret(config, caData),
+		deployment,
 		newProxyServerRole(config),
 		newProxyServerRoleBinding(config),
 	}
+	if config.Spec.ProxyServer.Replicas > 1 {
+		resources = append(resources, newProxyServerPodDisruptionBudget(config))
+	} else if err := c.deletePodDisruptionBudget(config); err != nil {
+		return false, err
+	}
 	anyCreated := false
 	createdKinds := sets.NewString()
 	anyUpdated := false
@@ -303,6 +372,12 @@ func (c *ManagedProxyConfigurationReconciler) ensure(incomingGeneration int64, g
 				return false, false, errors.Wrap(err, "failed preparing Service update")
 			}
 		}
+		if gvk == routeGVK {
+			resourceToUpdate, err = routeForUpdate(resource, current)
+			if err != nil {
+				return false, false, errors.Wrap(err, "failed preparing Route update")
+			}
+		}
 
 		resourceToUpdate.SetResourceVersion(current.GetResourceVersion())
 		if err := c.Update(context.TODO(), resourceToUpdate); err != nil {
@@ -321,6 +396,33 @@ func (c *ManagedProxyConfigurationReconciler) ensure(incomingGeneration int64, g
 	return created, updated, nil
 }
 
+// deleteIfExists removes a resource that is no longer rendered for the configuration.
+func (c *ManagedProxyConfigurationReconciler) deleteIfExists(resource client.Object) error {
+	if err := c.Delete(context.TODO(), resource); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete resource namespace: %s, name %s",
+			resource.GetNamespace(),
+			resource.GetName(),
+		)
+	}
+	return nil
+}
+
+// deletePodDisruptionBudget removes the budget left over from more than one replica. The
+// cached read keeps every reconcile of a single proxy server from issuing a DELETE.
+func (c *ManagedProxyConfigurationReconciler) deletePodDisruptionBudget(config *proxyv1alpha1.ManagedProxyConfiguration) error {
+	budget := newProxyServerPodDisruptionBudget(config)
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(budget), &policyv1.PodDisruptionBudget{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get pod disruption budget namespace: %s, name %s",
+			budget.Namespace,
+			budget.Name,
+		)
+	}
+	return c.deleteIfExists(budget)
+}
+
 // serviceForUpdate preserves the network fields assigned by the API server. It mutates a copy so
 // that those cluster-specific values do not become part of the rendered hash on a conflict retry.
 func serviceForUpdate(resource client.Object, current *unstructured.Unstructured) (client.Object, error) {
@@ -343,6 +445,18 @@ func serviceForUpdate(resource client.Object, current *unstructured.Unstructured
 		ipFamilyPolicy := *currentService.Spec.IPFamilyPolicy
 		desiredService.Spec.IPFamilyPolicy = &ipFamilyPolicy
 	}
+	// node ports are allocated for load-balancer services
+	for i := range desiredService.Spec.Ports {
+		for _, currentPort := range currentService.Spec.Ports {
+			if currentPort.Name == desiredService.Spec.Ports[i].Name && desiredService.Spec.Ports[i].NodePort == 0 {
+				desiredService.Spec.Ports[i].NodePort = currentPort.NodePort
+			}
+		}
+	}
+	if desiredService.Spec.HealthCheckNodePort == 0 &&
+		desiredService.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyLocal {
+		desiredService.Spec.HealthCheckNodePort = currentService.Spec.HealthCheckNodePort
+	}
 
 	return desiredService, nil
 }
@@ -410,73 +524,201 @@ func (c *ManagedProxyConfigurationReconciler) getConditions(s *state) []metav1.C
 	return conditions
 }
 
-func (c *ManagedProxyConfigurationReconciler) ensureEntrypoint(config *proxyv1alpha1.ManagedProxyConfiguration) (string, error) {
-	if config.Spec.ProxyServer.Entrypoint.Type == proxyv1alpha1.EntryPointTypeLoadBalancerService {
-		proxyService := &corev1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace:   config.Spec.ProxyServer.Namespace,
-				Name:        config.Spec.ProxyServer.Entrypoint.LoadBalancerService.Name,
-				Annotations: getAnnotation(config.Spec.ProxyServer.Entrypoint.LoadBalancerService.Annotations),
-			},
-			Spec: corev1.ServiceSpec{
-				Selector: map[string]string{
-					common.LabelKeyComponentName: common.ComponentNameProxyServer,
-				},
-				Type: corev1.ServiceTypeLoadBalancer,
-				Ports: []corev1.ServicePort{
-					{
-						Name: "proxy-server",
-						Port: 8090,
-					},
-					{
-						Name: "agent-server",
-						Port: 8091,
-					},
-				},
-			},
-		}
-		if err := c.Create(context.TODO(), proxyService); err != nil {
-			if !apierrors.IsAlreadyExists(err) {
-				return "", errors.Wrapf(err, "failed to ensure entrypoint service for proxy-server")
-			}
-		}
+// ensureEntrypoint provisions the entrypoint of the proxy servers and resolves the addresses
+// published for it. The addresses are nil for the "PortForward" type.
+func (c *ManagedProxyConfigurationReconciler) ensureEntrypoint(config *proxyv1alpha1.ManagedProxyConfiguration) (*proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus, error) {
+	if err := proxyconfig.ValidateEntrypoint(config); err != nil {
+		return nil, err
 	}
-
-	switch config.Spec.ProxyServer.Entrypoint.Type {
+	entrypoint := config.Spec.ProxyServer.Entrypoint
+	namespace := config.Spec.ProxyServer.Namespace
+	loadBalancerServiceName := ""
+	if entrypoint.Type == proxyv1alpha1.EntryPointTypeLoadBalancerService {
+		loadBalancerServiceName = entrypoint.LoadBalancerService.Name
+	}
+	if err := c.removeStaleLoadBalancerServices(config, loadBalancerServiceName); err != nil {
+		return nil, err
+	}
+	switch entrypoint.Type {
+	case proxyv1alpha1.EntryPointTypeHostname:
+		return util.NewEntrypointStatus(entrypoint.Hostname.Value), nil
 	case proxyv1alpha1.EntryPointTypeLoadBalancerService:
-		namespace := config.Spec.ProxyServer.Namespace
-		name := config.Spec.ProxyServer.Entrypoint.LoadBalancerService.Name
+		if _, _, err := c.ensure(config.Generation, serviceGVK, newEntrypointLoadBalancerService(config)); err != nil {
+			return nil, errors.Wrapf(err, "failed to ensure entrypoint service for proxy-server")
+		}
+		name := loadBalancerServiceName
 		lbSvc, err := c.ServiceGetter.Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 		if err != nil {
-			return "", errors.Wrapf(err, "failed to get service %q/%q", namespace, name)
+			return nil, errors.Wrapf(err, "failed to get service %q/%q", namespace, name)
 		}
 		if len(lbSvc.Status.LoadBalancer.Ingress) == 0 {
-			return "", errors.New("external ip not yet provisioned")
+			return nil, errors.New("external ip not yet provisioned")
 		}
-		return lbSvc.Status.LoadBalancer.Ingress[0].IP, nil
+		var addresses []string
+		for _, ingress := range lbSvc.Status.LoadBalancer.Ingress {
+			addresses = append(addresses, ingress.IP, ingress.Hostname)
+		}
+		return util.NewEntrypointStatus(addresses...), nil
+	case proxyv1alpha1.EntryPointTypeGatewayRoute:
+		route := newGatewayRoute(config)
+		if _, _, err := c.ensure(config.Generation, route.GroupVersionKind(), route); err != nil {
+			return nil, errors.Wrapf(err, "failed to ensure entrypoint %s for proxy-server", route.GetKind())
+		}
+		addresses := []string{entrypoint.GatewayRoute.Hostname}
+		for _, ref := range entrypoint.GatewayRoute.ParentRefs {
+			gatewayNamespace := cmp.Or(ref.Namespace, namespace)
+			gateway, err := c.getEntrypointObject(gatewayGVK, gatewayNamespace, ref.Name)
+			if err != nil {
+				return nil, err
+			}
+			gatewayAddresses, _, err := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed reading addresses of gateway %q/%q", gatewayNamespace, ref.Name)
+			}
+			for _, address := range gatewayAddresses {
+				if address, ok := address.(map[string]interface{}); ok {
+					value, _, _ := unstructured.NestedString(address, "value")
+					addresses = append(addresses, value)
+				}
+			}
+		}
+		status := util.NewEntrypointStatus(addresses...)
+		if len(util.EntrypointAddress(status)) == 0 {
+			return nil, errors.New("gateway addresses not yet provisioned")
+		}
+		return status, nil
+	case proxyv1alpha1.EntryPointTypeIngress:
+		if _, _, err := c.ensure(config.Generation, ingressGVK, newEntrypointIngress(config)); err != nil {
+			return nil, errors.Wrapf(err, "failed to ensure entrypoint ingress for proxy-server")
+		}
+		current, err := c.getEntrypointObject(ingressGVK, namespace, entrypoint.Ingress.Name)
+		if err != nil {
+			return nil, err
+		}
+		ingress := &networkingv1.Ingress{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(current.Object, ingress); err != nil {
+			return nil, err
+		}
+		addresses := []string{entrypoint.Ingress.Hostname}
+		for _, lbIngress := range ingress.Status.LoadBalancer.Ingress {
+			addresses = append(addresses, lbIngress.IP, lbIngress.Hostname)
+		}
+		return util.NewEntrypointStatus(addresses...), nil
+	case proxyv1alpha1.EntryPointTypeRoute:
+		if _, _, err := c.ensure(config.Generation, routeGVK, newEntrypointRoute(config)); err != nil {
+			return nil, errors.Wrapf(err, "failed to ensure entrypoint route for proxy-server")
+		}
+		current, err := c.getEntrypointObject(routeGVK, namespace, entrypoint.Route.Name)
+		if err != nil {
+			return nil, err
+		}
+		host, _, _ := unstructured.NestedString(current.Object, "spec", "host")
+		addresses := []string{host}
+		routeIngresses, _, _ := unstructured.NestedSlice(current.Object, "status", "ingress")
+		for _, routeIngress := range routeIngresses {
+			if routeIngress, ok := routeIngress.(map[string]interface{}); ok {
+				host, _, _ := unstructured.NestedString(routeIngress, "host")
+				addresses = append(addresses, host)
+			}
+		}
+		status := util.NewEntrypointStatus(addresses...)
+		if len(util.EntrypointAddress(status)) == 0 {
+			return nil, errors.New("route host not yet admitted")
+		}
+		return status, nil
+	}
+	return nil, nil
+}
+
+// removeStaleLoadBalancerServices deletes the load-balancer services left over by a renamed
+// or replaced "LoadBalancerService" entrypoint, except for the one named by keep.
+func (c *ManagedProxyConfigurationReconciler) removeStaleLoadBalancerServices(config *proxyv1alpha1.ManagedProxyConfiguration, keep string) error {
+	namespace := config.Spec.ProxyServer.Namespace
+	services, err := c.ServiceGetter.Services(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: common.LabelKeyComponentName + "=" + common.ComponentNameProxyEntrypoint,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list entrypoint services in namespace %q", namespace)
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if svc.Name == keep || !isOwnedBy(svc, config) {
+			continue
+		}
+		if err := c.deleteIfExists(svc); err != nil {
+			return err
+		}
+		c.EventRecorder.ForComponent("ClusterManagementAddonReconciler").
+			Eventf("EntrypointServiceDeleted", "Stale entrypoint service %q is deleted", svc.Name)
 	}
-	return "", nil
+	return nil
 }
 
-func (c *ManagedProxyConfigurationReconciler) ensureRotation(config *proxyv1alpha1.ManagedProxyConfiguration, entrypoint string) error {
-	var hostNames []string
-	if config.Spec.Authentication.Signer.SelfSigned != nil {
-		hostNames = config.Spec.Authentication.Signer.SelfSigned.AdditionalSANs
+func isOwnedBy(obj metav1.Object, config *proxyv1alpha1.ManagedProxyConfiguration) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == config.UID {
+			return true
+		}
 	}
+	return false
+}
+
+// getEntrypointObject reads an object backing the entrypoint directly from the API server,
+// so that no informer is started for kinds which may not be served by the hub cluster.
+func (c *ManagedProxyConfigurationReconciler) getEntrypointObject(gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s %q/%q", gvk.Kind, namespace, name)
+	}
+	return obj, nil
+}
+
+func (c *ManagedProxyConfigurationReconciler) ensureRotation(
+	config *proxyv1alpha1.ManagedProxyConfiguration,
+	entrypoint *proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus) error {
 	sans := append(
-		hostNames,
+		additionalSANs(config.Spec.Authentication.Signer),
 		"127.0.0.1",
 		"localhost",
 		config.Spec.ProxyServer.InClusterServiceName+"."+config.Spec.ProxyServer.Namespace,
 		config.Spec.ProxyServer.InClusterServiceName+"."+config.Spec.ProxyServer.Namespace+".svc")
-	if config.Spec.ProxyServer.Entrypoint != nil && config.Spec.ProxyServer.Entrypoint.Type == proxyv1alpha1.EntryPointTypeHostname {
-		sans = append(sans, config.Spec.ProxyServer.Entrypoint.Hostname.Value)
+	if spec := config.Spec.ProxyServer.Entrypoint; spec != nil && spec.Type == proxyv1alpha1.EntryPointTypeHostname && spec.Hostname != nil {
+		sans = append(sans, spec.Hostname.Value)
 	}
 
-	if len(entrypoint) > 0 {
-		sans = append(sans, entrypoint)
+	if entrypoint != nil {
+		for _, address := range append(entrypoint.Hostnames, entrypoint.IPs...) {
+			if !slices.Contains(sans, address) {
+				sans = append(sans, address)
+			}
+		}
 	}
 
+	if config.Spec.Authentication.Signer.Type == proxyv1alpha1.CertManager {
+		// cert-manager caps the certificates at the lifetime of the issuer by itself.
+		if err := proxyconfig.ValidateCertificateLifetimes(config, nil); err != nil {
+			return err
+		}
+		return c.ensureCertificates(config, proxyconfig.GetCertificateLifetimes(config, nil), sans)
+	}
+	if err := c.removeStaleCertificates(config); err != nil {
+		return err
+	}
+	caPair := c.caPair()
+	if caPair == nil {
+		return fmt.Errorf("the signer is changed to %q, restart the addon-manager to pick it up",
+			config.Spec.Authentication.Signer.Type)
+	}
+	var caCert *x509.Certificate
+	if len(caPair.Config.Certs) > 0 {
+		caCert = caPair.Config.Certs[0]
+	}
+	if err := proxyconfig.ValidateCertificateLifetimes(config, caCert); err != nil {
+		return err
+	}
+	lifetimes := proxyconfig.GetCertificateLifetimes(config, caCert)
+
 	tweakClientCertUsageFunc := func(cert *x509.Certificate) error {
 		cert.ExtKeyUsage = []x509.ExtKeyUsage{
 			x509.ExtKeyUsageClientAuth,
@@ -488,8 +730,9 @@ func (c *ManagedProxyConfigurationReconciler) ensureRotation(config *proxyv1alph
 	proxyServerRotator := c.newCertRotatorFunc(
 		config.Spec.ProxyServer.Namespace,
 		config.Spec.Authentication.Dump.Secrets.SigningProxyServerSecretName,
+		lifetimes.Server,
 		sans...)
-	if err := proxyServerRotator.EnsureTargetCertKeyPair(c.CAPair, c.CAPair.Config.Certs); err != nil {
+	if err := proxyServerRotator.EnsureTargetCertKeyPair(caPair, caPair.Config.Certs); err != nil {
 		return errors.Wrapf(err, "fails to rotate proxy server cert")
 	}
 
@@ -497,8 +740,9 @@ func (c *ManagedProxyConfigurationReconciler) ensureRotation(config *proxyv1alph
 	agentServerRotator := c.newCertRotatorFunc(
 		config.Spec.ProxyServer.Namespace,
 		config.Spec.Authentication.Dump.Secrets.SigningAgentServerSecretName,
+		lifetimes.Server,
 		sans...)
-	if err := agentServerRotator.EnsureTargetCertKeyPair(c.CAPair, c.CAPair.Config.Certs); err != nil {
+	if err := agentServerRotator.EnsureTargetCertKeyPair(caPair, caPair.Config.Certs); err != nil {
 		return errors.Wrapf(err, "fails to rotate proxy agent cert")
 	}
 
@@ -506,8 +750,9 @@ func (c *ManagedProxyConfigurationReconciler) ensureRotation(config *proxyv1alph
 	proxyClientRotator := c.newCertRotatorFunc(
 		config.Spec.ProxyServer.Namespace,
 		config.Spec.Authentication.Dump.Secrets.SigningProxyClientSecretName,
+		lifetimes.ProxyClient,
 		sans...)
-	if err := proxyClientRotator.EnsureTargetCertKeyPair(c.CAPair, c.CAPair.Config.Certs, tweakClientCertUsageFunc); err != nil {
+	if err := proxyClientRotator.EnsureTargetCertKeyPair(caPair, caPair.Config.Certs, tweakClientCertUsageFunc); err != nil {
 		return errors.Wrapf(err, "fails to rotate proxy client cert")
 	}
 
@@ -519,8 +764,9 @@ func (c *ManagedProxyConfigurationReconciler) ensureRotation(config *proxyv1alph
 		userServerRotator := c.newCertRotatorFunc(
 			config.Spec.ProxyServer.Namespace,
 			constant.UserServerSecretName,
+			lifetimes.Server,
 			userServerSANs...)
-		if err := userServerRotator.EnsureTargetCertKeyPair(c.CAPair, c.CAPair.Config.Certs); err != nil {
+		if err := userServerRotator.EnsureTargetCertKeyPair(caPair, caPair.Config.Certs); err != nil {
 			return errors.Wrapf(err, "fails to rotate user server cert")
 		}
 	}
@@ -528,6 +774,14 @@ func (c *ManagedProxyConfigurationReconciler) ensureRotation(config *proxyv1alph
 	return nil
 }
 
+// caPair returns the CA which the certificates are currently rotated from.
+func (c *ManagedProxyConfigurationReconciler) caPair() *crypto.CA {
+	if rotating, ok := c.SelfSigner.(*selfsigned.RotatingSigner); ok {
+		return rotating.CA()
+	}
+	return c.CAPair
+}
+
 // buildUserServerSANs builds the SANs for user server certificate based on configuration.
 func (c *ManagedProxyConfigurationReconciler) buildUserServerSANs(config *proxyv1alpha1.ManagedProxyConfiguration) []string {
 	userServer := config.Spec.UserServer
@@ -656,10 +910,8 @@ func (c *ManagedProxyConfigurationReconciler) getCurrentState(isRedeployed bool,
 }
 
 func getPEMCertExpireTime(pemBytes []byte) *metav1.Time {
-	b, _ := pem.Decode(pemBytes)
-	cert, err := x509.ParseCertificate(b.Bytes)
-	if err != nil {
-		log.Error(err, "Failed parsing cert")
+	cert := getPEMCert(pemBytes)
+	if cert == nil {
 		return nil
 	}
 	return &metav1.Time{Time: cert.NotAfter}
@@ -671,7 +923,7 @@ func getAnnotation(list []proxyv1alpha1.AnnotationVar) map[string]string {
 	}
 	annotation := make(map[string]string, len(list))
 	for _, v := range list {
-		if errs := validation.IsQualifiedName(v.Key); len(errs) == 0 {
+		if errs := validation.IsQualifiedName(v.Key); len(errs) > 0 {
 			klog.Warningf("Annotation key %s validate failed: %s, skip it!", v.Key, strings.Join(errs, ";"))
 			continue
 		}
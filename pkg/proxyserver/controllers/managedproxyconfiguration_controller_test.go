@@ -7,10 +7,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
 	"open-cluster-management.io/cluster-proxy/pkg/common"
 )
 
@@ -31,6 +35,23 @@ func TestRenderedResourceHashChangesWithRenderedContent(t *testing.T) {
 	assert.NotEqual(t, baseline, updated)
 }
 
+func TestRenderedResourceHashChangesWithPodAnnotations(t *testing.T) {
+	config := newTestConfig(3)
+	baseline, err := renderedResourceHash(newProxyServerDeployment(config, "IfNotPresent", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := config.DeepCopy()
+	changed.Spec.ProxyServer.PodAnnotations = map[string]string{"sidecar.istio.io/inject": "false"}
+	updated, err := renderedResourceHash(newProxyServerDeployment(changed, "IfNotPresent", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, baseline, updated)
+}
+
 func TestRenderedResourceHashChangesWithRoleRules(t *testing.T) {
 	config := newTestConfig(3)
 	baselineRole := newProxyServerRole(config)
@@ -180,3 +201,72 @@ func TestEnsureUpdatesRenderedServiceWithoutGenerationChange(t *testing.T) {
 	assert.Empty(t, desired.Spec.IPFamilies)
 	assert.Nil(t, desired.Spec.IPFamilyPolicy)
 }
+
+func TestDeleteIfExistsRemovesPodDisruptionBudget(t *testing.T) {
+	config := newTestConfig(1)
+	config.Name = "cluster-proxy"
+	config.Spec.ProxyServer.Namespace = "proxy-system"
+
+	scheme := runtime.NewScheme()
+	if err := policyv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	existing := newProxyServerPodDisruptionBudget(config)
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+	reconciler := &ManagedProxyConfigurationReconciler{Client: fakeClient}
+
+	if err := reconciler.deleteIfExists(newProxyServerPodDisruptionBudget(config)); err != nil {
+		t.Fatal(err)
+	}
+	err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(existing), &policyv1.PodDisruptionBudget{})
+	assert.True(t, apierrors.IsNotFound(err), "expected the budget to be deleted, got %v", err)
+
+	// a missing budget is not an error
+	if err := reconciler.deleteIfExists(newProxyServerPodDisruptionBudget(config)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeletePodDisruptionBudgetSkipsMissingBudget(t *testing.T) {
+	config := newTestConfig(1)
+	config.Name = "cluster-proxy"
+	config.Spec.ProxyServer.Namespace = "proxy-system"
+
+	scheme := runtime.NewScheme()
+	if err := policyv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	deletes := 0
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deletes++
+			return c.Delete(ctx, obj, opts...)
+		},
+	}).Build()
+	reconciler := &ManagedProxyConfigurationReconciler{Client: fakeClient}
+
+	if err := reconciler.deletePodDisruptionBudget(config); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, deletes, "expected no DELETE for a missing budget")
+
+	if err := fakeClient.Create(context.Background(), newProxyServerPodDisruptionBudget(config)); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.deletePodDisruptionBudget(config); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, deletes)
+	err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(newProxyServerPodDisruptionBudget(config)), &policyv1.PodDisruptionBudget{})
+	assert.True(t, apierrors.IsNotFound(err), "expected the budget to be deleted, got %v", err)
+}
+
+func TestGetAnnotationSkipsInvalidKeys(t *testing.T) {
+	annotations := getAnnotation([]proxyv1alpha1.AnnotationVar{
+		{Key: "service.beta.kubernetes.io/aws-load-balancer-internal", Value: "true"},
+		{Key: "invalid key", Value: "skipped"},
+	})
+	assert.Equal(t, map[string]string{
+		"service.beta.kubernetes.io/aws-load-balancer-internal": "true",
+	}, annotations)
+}
@@ -1,18 +1,22 @@
 package controllers
 
 import (
+	"cmp"
 	"crypto/sha256"
 	"fmt"
 	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 
 	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
 	"open-cluster-management.io/cluster-proxy/pkg/common"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
 	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
 )
 
@@ -55,6 +59,7 @@ func newProxySecret(config *proxyv1alpha1.ManagedProxyConfiguration, caData []by
 	}
 }
 func newProxyService(config *proxyv1alpha1.ManagedProxyConfiguration) *corev1.Service {
+	ports := proxyconfig.GetDeployPorts(config)
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: config.Spec.ProxyServer.Namespace,
@@ -67,17 +72,8 @@ func newProxyService(config *proxyv1alpha1.ManagedProxyConfiguration) *corev1.Se
 			Selector: map[string]string{
 				common.LabelKeyComponentName: common.ComponentNameProxyServer,
 			},
-			Type: corev1.ServiceTypeClusterIP,
-			Ports: []corev1.ServicePort{
-				{
-					Name: "proxy-server",
-					Port: 8090,
-				},
-				{
-					Name: "agent-server",
-					Port: 8091,
-				},
-			},
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: proxyServerServicePorts(ports),
 		},
 	}
 }
@@ -102,49 +98,55 @@ func newProxyServerDeployment(config *proxyv1alpha1.ManagedProxyConfiguration, i
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &config.Spec.ProxyServer.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					common.LabelKeyComponentName: common.ComponentNameProxyServer,
-				},
-			},
+			Selector: proxyServerSelector(),
+			// never surge so that the running proxy servers stay within --server-count
 			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RecreateDeploymentStrategyType,
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: proxyServerMaxUnavailable(config),
+					MaxSurge:       ptr.To(intstr.FromInt32(0)),
+				},
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
 						common.LabelKeyComponentName: common.ComponentNameProxyServer,
 					},
+					Annotations: config.Spec.ProxyServer.PodAnnotations,
 				},
 				Spec: corev1.PodSpec{
 					ServiceAccountName: common.AddonName,
-					SecurityContext: &corev1.PodSecurityContext{
-						RunAsNonRoot: ptr.To(true),
-						SeccompProfile: &corev1.SeccompProfile{
-							Type: corev1.SeccompProfileTypeRuntimeDefault,
-						},
-					},
+					PriorityClassName:  config.Spec.ProxyServer.PriorityClassName,
+					ImagePullSecrets:   proxyServerImagePullSecrets(config),
+					SecurityContext:    proxyServerPodSecurityContext(config),
 					Containers: []corev1.Container{
 						{
-							Name:            common.ComponentNameProxyServer,
-							Image:           config.Spec.ProxyServer.Image,
-							ImagePullPolicy: corev1.PullPolicy(imagePullPolicy), // TODO @xuezhaojun, the image pull policy should be configurable and by default should be IfNotPresent. Will update this later to a better solution. Currently, using the image pull policy from the command line flag.
+							Name:  common.ComponentNameProxyServer,
+							Image: config.Spec.ProxyServer.Image,
+							// the addon-manager flag is the default for configurations without a policy
+							ImagePullPolicy: corev1.PullPolicy(cmp.Or(string(config.Spec.ProxyServer.ImagePullPolicy), imagePullPolicy)),
 							Command: []string{
 								"/proxy-server",
 							},
 							// TLS configuration from ocm-tls-profile ConfigMap is injected
 							// via proxyServerArgs (both --cipher-suites and --tls-min-version)
-							Args: proxyServerArgs(config, tlsConfig),
-							SecurityContext: &corev1.SecurityContext{
-								Capabilities: &corev1.Capabilities{
-									Drop: []corev1.Capability{"ALL"},
-								},
-								Privileged:               ptr.To(false),
-								RunAsNonRoot:             ptr.To(true),
-								ReadOnlyRootFilesystem:   ptr.To(true),
-								AllowPrivilegeEscalation: ptr.To(false),
+							Args:            proxyServerArgs(config, tlsConfig),
+							Env:             config.Spec.ProxyServer.Env,
+							Resources:       ptr.Deref(config.Spec.ProxyServer.Resources, corev1.ResourceRequirements{}),
+							SecurityContext: proxyServerSecurityContext(config),
+							// /readyz fails until an agent connects, which would keep agents
+							// from reaching the servers through the entrypoint Service.
+							LivenessProbe: &corev1.Probe{
+								ProbeHandler:     proxyServerHealthProbe(config),
+								PeriodSeconds:    10,
+								FailureThreshold: 3,
 							},
-							VolumeMounts: []corev1.VolumeMount{
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler:     proxyServerHealthProbe(config),
+								PeriodSeconds:    5,
+								FailureThreshold: 1,
+							},
+							VolumeMounts: append([]corev1.VolumeMount{
 								{
 									Name:      "proxy-server-ca-certs",
 									ReadOnly:  true,
@@ -160,10 +162,10 @@ func newProxyServerDeployment(config *proxyv1alpha1.ManagedProxyConfiguration, i
 									ReadOnly:  true,
 									MountPath: "/etc/agent-pki/",
 								},
-							},
+							}, config.Spec.ProxyServer.VolumeMounts...),
 						},
 					},
-					Volumes: []corev1.Volume{
+					Volumes: append([]corev1.Volume{
 						{
 							Name: "proxy-server-ca-certs",
 							VolumeSource: corev1.VolumeSource{
@@ -188,15 +190,148 @@ func newProxyServerDeployment(config *proxyv1alpha1.ManagedProxyConfiguration, i
 								},
 							},
 						},
+					}, config.Spec.ProxyServer.Volumes...),
+					NodeSelector:              config.Spec.ProxyServer.NodePlacement.NodeSelector,
+					Tolerations:               config.Spec.ProxyServer.NodePlacement.Tolerations,
+					Affinity:                  proxyServerAffinity(config),
+					TopologySpreadConstraints: proxyServerTopologySpreadConstraints(config),
+				},
+			},
+		},
+	}
+}
+
+// newProxyServerPodDisruptionBudget is only applied for more than one replica, a
+// budget for a single proxy server would block node drains.
+func newProxyServerPodDisruptionBudget(config *proxyv1alpha1.ManagedProxyConfiguration) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: config.Spec.ProxyServer.Namespace,
+			Name:      config.Name,
+			OwnerReferences: []metav1.OwnerReference{
+				newOwnerReference(config),
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: proxyServerMaxUnavailable(config),
+			Selector:       proxyServerSelector(),
+		},
+	}
+}
+
+func proxyServerPodSecurityContext(config *proxyv1alpha1.ManagedProxyConfiguration) *corev1.PodSecurityContext {
+	if config.Spec.ProxyServer.PodSecurityContext != nil {
+		return config.Spec.ProxyServer.PodSecurityContext
+	}
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot: ptr.To(true),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+func proxyServerSecurityContext(config *proxyv1alpha1.ManagedProxyConfiguration) *corev1.SecurityContext {
+	if config.Spec.ProxyServer.SecurityContext != nil {
+		return config.Spec.ProxyServer.SecurityContext
+	}
+	return &corev1.SecurityContext{
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		Privileged:               ptr.To(false),
+		RunAsNonRoot:             ptr.To(true),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		AllowPrivilegeEscalation: ptr.To(false),
+	}
+}
+
+func proxyServerImagePullSecrets(config *proxyv1alpha1.ManagedProxyConfiguration) []corev1.LocalObjectReference {
+	var secrets []corev1.LocalObjectReference
+	for _, name := range config.Spec.ProxyServer.ImagePullSecrets {
+		secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+	}
+	return secrets
+}
+
+func proxyServerSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			common.LabelKeyComponentName: common.ComponentNameProxyServer,
+		},
+	}
+}
+
+func proxyServerMaxUnavailable(config *proxyv1alpha1.ManagedProxyConfiguration) *intstr.IntOrString {
+	maxUnavailable := config.Spec.ProxyServer.MaxUnavailable
+	if maxUnavailable == nil {
+		return ptr.To(intstr.FromInt32(1))
+	}
+	// a rolling update without surge needs at least one unavailable pod to progress, and
+	// the deployment rounds percentages down. Invalid values are left to the API server.
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, int(config.Spec.ProxyServer.Replicas), false)
+	if err == nil && scaled < 1 {
+		return ptr.To(intstr.FromInt32(1))
+	}
+	return ptr.To(*maxUnavailable)
+}
+
+func proxyServerAffinity(config *proxyv1alpha1.ManagedProxyConfiguration) *corev1.Affinity {
+	if config.Spec.ProxyServer.Affinity != nil {
+		return config.Spec.ProxyServer.Affinity
+	}
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: proxyServerSelector(),
+						TopologyKey:   corev1.LabelHostname,
 					},
-					NodeSelector: config.Spec.ProxyServer.NodePlacement.NodeSelector,
-					Tolerations:  config.Spec.ProxyServer.NodePlacement.Tolerations,
 				},
 			},
 		},
 	}
 }
 
+func proxyServerTopologySpreadConstraints(config *proxyv1alpha1.ManagedProxyConfiguration) []corev1.TopologySpreadConstraint {
+	if len(config.Spec.ProxyServer.TopologySpreadConstraints) > 0 {
+		return config.Spec.ProxyServer.TopologySpreadConstraints
+	}
+	return []corev1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       corev1.LabelTopologyZone,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     proxyServerSelector(),
+		},
+	}
+}
+
+func proxyServerHealthProbe(config *proxyv1alpha1.ManagedProxyConfiguration) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: "/healthz",
+			Port: intstr.FromInt32(proxyconfig.GetDeployPorts(config).HealthServer),
+		},
+	}
+}
+
+// proxyServerServicePorts exposes the proxy and agent ports of the proxy-servers.
+func proxyServerServicePorts(ports proxyv1alpha1.ManagedProxyConfigurationDeployPorts) []corev1.ServicePort {
+	return []corev1.ServicePort{
+		{
+			Name: "proxy-server",
+			Port: ports.ProxyServer,
+		},
+		{
+			Name: "agent-server",
+			Port: ports.AgentServer,
+		},
+	}
+}
+
 func newProxyServerRole(config *proxyv1alpha1.ManagedProxyConfiguration) *rbacv1.Role {
 	return &rbacv1.Role{
 		ObjectMeta: metav1.ObjectMeta{
@@ -240,8 +375,13 @@ func newProxyServerRoleBinding(config *proxyv1alpha1.ManagedProxyConfiguration)
 }
 
 func proxyServerArgs(config *proxyv1alpha1.ManagedProxyConfiguration, tlsConfig *sdktls.TLSConfig) []string {
+	ports := proxyconfig.GetDeployPorts(config)
 	args := append([]string{
 		"--server-count=" + strconv.Itoa(int(config.Spec.ProxyServer.Replicas)),
+		"--server-port=" + strconv.Itoa(int(ports.ProxyServer)),
+		"--agent-port=" + strconv.Itoa(int(ports.AgentServer)),
+		"--health-port=" + strconv.Itoa(int(ports.HealthServer)),
+		"--admin-port=" + strconv.Itoa(int(ports.AdminServer)),
 		"--proxy-strategies=destHost",
 		"--server-ca-cert=/etc/server-ca-pki/ca.crt",
 		"--server-cert=/etc/server-pki/tls.crt",
@@ -5,7 +5,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 
 	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
@@ -25,6 +28,10 @@ func newTestConfig(replicas int32, additionalArgs ...string) *proxyv1alpha1.Mana
 
 var baseArgs = []string{
 	"--server-count=3",
+	"--server-port=8090",
+	"--agent-port=8091",
+	"--health-port=8092",
+	"--admin-port=8095",
 	"--proxy-strategies=destHost",
 	"--server-ca-cert=/etc/server-ca-pki/ca.crt",
 	"--server-cert=/etc/server-pki/tls.crt",
@@ -81,6 +88,37 @@ func TestProxyServerArgs_WithAdditionalArgsAndCipherSuites(t *testing.T) {
 	assert.Equal(t, expected, args)
 }
 
+func TestProxyServerManifests_UseDeployPorts(t *testing.T) {
+	config := newTestConfig(3)
+	config.Spec.Deploy = &proxyv1alpha1.ManagedProxyConfigurationDeploy{
+		Ports: proxyv1alpha1.ManagedProxyConfigurationDeployPorts{
+			ProxyServer:  18090,
+			AgentServer:  18091,
+			HealthServer: 18092,
+			// the admin port falls back to its default
+		},
+	}
+
+	args := proxyServerArgs(config, nil)
+	assert.Equal(t, []string{
+		"--server-count=3",
+		"--server-port=18090",
+		"--agent-port=18091",
+		"--health-port=18092",
+		"--admin-port=8095",
+	}, args[:5])
+
+	container := newProxyServerDeployment(config, "IfNotPresent", nil).Spec.Template.Spec.Containers[0]
+	assert.Equal(t, intstr.FromInt32(18092), container.LivenessProbe.HTTPGet.Port)
+	assert.Equal(t, intstr.FromInt32(18092), container.ReadinessProbe.HTTPGet.Port)
+
+	service := newProxyService(config)
+	assert.Equal(t, []corev1.ServicePort{
+		{Name: "proxy-server", Port: 18090},
+		{Name: "agent-server", Port: 18091},
+	}, service.Spec.Ports)
+}
+
 func TestNewProxyServerDeployment_SetsPodSecurityContext(t *testing.T) {
 	config := newTestConfig(3)
 	config.Name = "cluster-proxy"
@@ -98,6 +136,106 @@ func TestNewProxyServerDeployment_SetsPodSecurityContext(t *testing.T) {
 	assert.Equal(t, expected, deploy.Spec.Template.Spec.SecurityContext)
 }
 
+func TestNewProxyServerDeployment_RollsWithoutSurge(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxUnavailable *intstr.IntOrString
+		expected       intstr.IntOrString
+	}{
+		{name: "default", expected: intstr.FromInt32(1)},
+		{name: "zero", maxUnavailable: ptr.To(intstr.FromInt32(0)), expected: intstr.FromInt32(1)},
+		{name: "percent", maxUnavailable: ptr.To(intstr.FromString("50%")), expected: intstr.FromString("50%")},
+		{name: "zero percent", maxUnavailable: ptr.To(intstr.FromString("0%")), expected: intstr.FromInt32(1)},
+		{name: "percent rounding to zero", maxUnavailable: ptr.To(intstr.FromString("10%")), expected: intstr.FromInt32(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(3)
+			config.Spec.ProxyServer.MaxUnavailable = tt.maxUnavailable
+
+			deploy := newProxyServerDeployment(config, "IfNotPresent", nil)
+			assert.Equal(t, appsv1.RollingUpdateDeploymentStrategyType, deploy.Spec.Strategy.Type)
+			assert.Equal(t, intstr.FromInt32(0), *deploy.Spec.Strategy.RollingUpdate.MaxSurge)
+			assert.Equal(t, tt.expected, *deploy.Spec.Strategy.RollingUpdate.MaxUnavailable)
+
+			pdb := newProxyServerPodDisruptionBudget(config)
+			assert.Equal(t, tt.expected, *pdb.Spec.MaxUnavailable)
+			assert.Equal(t, deploy.Spec.Selector, pdb.Spec.Selector)
+		})
+	}
+}
+
+func TestNewProxyServerDeployment_SpreadsProxyServers(t *testing.T) {
+	deploy := newProxyServerDeployment(newTestConfig(3), "IfNotPresent", nil)
+	podSpec := deploy.Spec.Template.Spec
+	if assert.NotNil(t, podSpec.Affinity) && assert.NotNil(t, podSpec.Affinity.PodAntiAffinity) {
+		terms := podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+		if assert.Len(t, terms, 1) {
+			assert.Equal(t, corev1.LabelHostname, terms[0].PodAffinityTerm.TopologyKey)
+		}
+	}
+	if assert.Len(t, podSpec.TopologySpreadConstraints, 1) {
+		assert.Equal(t, corev1.LabelTopologyZone, podSpec.TopologySpreadConstraints[0].TopologyKey)
+		assert.Equal(t, corev1.ScheduleAnyway, podSpec.TopologySpreadConstraints[0].WhenUnsatisfiable)
+	}
+
+	config := newTestConfig(3)
+	config.Spec.ProxyServer.Affinity = &corev1.Affinity{}
+	config.Spec.ProxyServer.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: corev1.LabelHostname, WhenUnsatisfiable: corev1.DoNotSchedule},
+	}
+	podSpec = newProxyServerDeployment(config, "IfNotPresent", nil).Spec.Template.Spec
+	assert.Equal(t, config.Spec.ProxyServer.Affinity, podSpec.Affinity)
+	assert.Equal(t, config.Spec.ProxyServer.TopologySpreadConstraints, podSpec.TopologySpreadConstraints)
+}
+
+func TestNewProxyServerDeployment_ProbesHealthPort(t *testing.T) {
+	container := newProxyServerDeployment(newTestConfig(3), "IfNotPresent", nil).Spec.Template.Spec.Containers[0]
+	for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe} {
+		if assert.NotNil(t, probe) && assert.NotNil(t, probe.HTTPGet) {
+			assert.Equal(t, "/healthz", probe.HTTPGet.Path)
+			assert.Equal(t, intstr.FromInt32(8092), probe.HTTPGet.Port)
+		}
+	}
+}
+
+func TestNewProxyServerDeployment_AppliesPodCustomization(t *testing.T) {
+	config := newTestConfig(3)
+	proxyServer := &config.Spec.ProxyServer
+	proxyServer.ImagePullPolicy = corev1.PullAlways
+	proxyServer.ImagePullSecrets = []string{"registry"}
+	proxyServer.Resources = &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+	}
+	proxyServer.PriorityClassName = "system-cluster-critical"
+	proxyServer.PodSecurityContext = &corev1.PodSecurityContext{RunAsUser: ptr.To(int64(1000))}
+	proxyServer.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(true)}
+	proxyServer.Env = []corev1.EnvVar{{Name: "GODEBUG", Value: "http2debug=1"}}
+	proxyServer.Volumes = []corev1.Volume{{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+	proxyServer.VolumeMounts = []corev1.VolumeMount{{Name: "tmp", MountPath: "/tmp"}}
+	proxyServer.PodAnnotations = map[string]string{"sidecar.istio.io/inject": "false"}
+
+	podTemplate := newProxyServerDeployment(config, "IfNotPresent", nil).Spec.Template
+	podSpec := podTemplate.Spec
+	container := podSpec.Containers[0]
+	assert.Equal(t, corev1.PullAlways, container.ImagePullPolicy)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "registry"}}, podSpec.ImagePullSecrets)
+	assert.Equal(t, *proxyServer.Resources, container.Resources)
+	assert.Equal(t, "system-cluster-critical", podSpec.PriorityClassName)
+	assert.Equal(t, proxyServer.PodSecurityContext, podSpec.SecurityContext)
+	assert.Equal(t, proxyServer.SecurityContext, container.SecurityContext)
+	assert.Equal(t, proxyServer.Env, container.Env)
+	assert.Len(t, podSpec.Volumes, 4)
+	assert.Equal(t, proxyServer.Volumes[0], podSpec.Volumes[3])
+	assert.Len(t, container.VolumeMounts, 4)
+	assert.Equal(t, proxyServer.VolumeMounts[0], container.VolumeMounts[3])
+	assert.Equal(t, proxyServer.PodAnnotations, podTemplate.Annotations)
+
+	// the addon-manager flag applies without a configured policy
+	container = newProxyServerDeployment(newTestConfig(3), "IfNotPresent", nil).Spec.Template.Spec.Containers[0]
+	assert.Equal(t, corev1.PullIfNotPresent, container.ImagePullPolicy)
+}
+
 func TestTLSConfigHash_Nil(t *testing.T) {
 	assert.Equal(t, "", tlsConfigHash(nil))
 }
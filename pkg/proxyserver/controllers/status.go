@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
+	"open-cluster-management.io/cluster-proxy/pkg/constant"
+)
+
+const (
+	// statusResyncInterval is how often the connections reported in the status are refreshed.
+	statusResyncInterval = 5 * time.Minute
+	// metricsScrapeTimeout bounds the time spent scraping the metrics of a proxy-server replica.
+	metricsScrapeTimeout = 5 * time.Second
+
+	// connectionsMetricName counts the gRPC connections opened to a proxy-server, labelled by
+	// the gRPC method. Every proxy agent holds one "Connect" connection to each replica.
+	connectionsMetricName = "konnectivity_network_proxy_server_grpc_connections"
+	connectMethod         = "Connect"
+)
+
+// scrapeConnectedAgents reads the number of proxy agents connected to the proxy-server
+// listening on the address from its metrics endpoint.
+func scrapeConnectedAgents(ctx context.Context, address string) (int32, error) {
+	ctx, cancel := context.WithTimeout(ctx, metricsScrapeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/metrics", nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %q scraping %s", resp.Status, address)
+	}
+	return parseConnectedAgents(resp.Body)
+}
+
+// parseConnectedAgents counts the agent connections from metrics in the Prometheus text format.
+func parseConnectedAgents(in io.Reader) (int32, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(in)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse the proxy-server metrics")
+	}
+	family, ok := families[connectionsMetricName]
+	if !ok {
+		return 0, nil
+	}
+	var agents int32
+	for _, metric := range family.GetMetric() {
+		if labelValue(metric, "service_method") == connectMethod && metric.GetGauge() != nil {
+			agents += int32(metric.GetGauge().GetValue())
+		}
+	}
+	return agents, nil
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// getProxyServerStatuses scrapes the connected agents from every running proxy-server replica.
+// The replicas that can't be scraped are left out of the status rather than failing the
+// reconciliation.
+func (c *ManagedProxyConfigurationReconciler) getProxyServerStatuses(
+	config *proxyv1alpha1.ManagedProxyConfiguration) ([]proxyv1alpha1.ManagedProxyConfigurationProxyServerStatus, error) {
+	if c.PodGetter == nil || c.scrapeConnectedAgentsFunc == nil {
+		return nil, nil
+	}
+	pods, err := c.PodGetter.Pods(config.Spec.ProxyServer.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: common.LabelKeyComponentName + "=" + common.ComponentNameProxyServer,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list proxy-server pods")
+	}
+	port := strconv.Itoa(int(proxyconfig.GetDeployPorts(config).HealthServer))
+
+	var statuses []proxyv1alpha1.ManagedProxyConfigurationProxyServerStatus
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || len(pod.Status.PodIP) == 0 || !isOwnedByDeployment(&pod, config.Name) {
+			continue
+		}
+		agents, err := c.scrapeConnectedAgentsFunc(context.TODO(), net.JoinHostPort(pod.Status.PodIP, port))
+		if err != nil {
+			log.Error(err, "Failed scraping proxy-server metrics", "pod", pod.Name)
+			continue
+		}
+		statuses = append(statuses, proxyv1alpha1.ManagedProxyConfigurationProxyServerStatus{
+			PodName:         pod.Name,
+			ConnectedAgents: agents,
+		})
+	}
+	return statuses, nil
+}
+
+// isOwnedByDeployment tells if the pod is rolled out from the proxy-server deployment of the
+// name, whose replica sets are named after it.
+func isOwnedByDeployment(pod *corev1.Pod, name string) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "ReplicaSet" && strings.HasPrefix(ref.Name, name+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// getCertificateStatuses reports the certificates dumped into secrets. The secrets not yet
+// signed are left out.
+func (c *ManagedProxyConfigurationReconciler) getCertificateStatuses(
+	config *proxyv1alpha1.ManagedProxyConfiguration) ([]proxyv1alpha1.ManagedProxyConfigurationCertificateStatus, error) {
+	namespace := config.Spec.ProxyServer.Namespace
+	secrets := config.Spec.Authentication.Dump.Secrets
+	certificates := []proxyv1alpha1.ManagedProxyConfigurationCertificateStatus{
+		{Name: "proxy-server", SecretNamespace: namespace, SecretName: secrets.SigningProxyServerSecretName},
+		{Name: "agent-server", SecretNamespace: namespace, SecretName: secrets.SigningAgentServerSecretName},
+		{Name: "proxy-client", SecretNamespace: namespace, SecretName: secrets.SigningProxyClientSecretName},
+	}
+	if config.Spec.UserServer != nil {
+		certificates = append(certificates, proxyv1alpha1.ManagedProxyConfigurationCertificateStatus{
+			Name: "user-server", SecretNamespace: namespace, SecretName: constant.UserServerSecretName,
+		})
+	}
+	if len(c.serviceProxySecretNamespace) > 0 {
+		certificates = append(certificates, proxyv1alpha1.ManagedProxyConfigurationCertificateStatus{
+			Name: "service-proxy", SecretNamespace: c.serviceProxySecretNamespace, SecretName: constant.ServerCertSecretName,
+		})
+	}
+
+	var statuses []proxyv1alpha1.ManagedProxyConfigurationCertificateStatus
+	for _, certificate := range certificates {
+		secret, err := c.SecretGetter.Secrets(certificate.SecretNamespace).
+			Get(context.TODO(), certificate.SecretName, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		cert := getPEMCert(secret.Data[corev1.TLSCertKey])
+		if cert == nil {
+			continue
+		}
+		certificate.NotAfter = &metav1.Time{Time: cert.NotAfter}
+		certificate.LastRotationTime = &metav1.Time{Time: cert.NotBefore}
+		statuses = append(statuses, certificate)
+	}
+	return statuses, nil
+}
+
+func getPEMCert(pemBytes []byte) *x509.Certificate {
+	b, _ := pem.Decode(pemBytes)
+	if b == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		log.Error(err, "Failed parsing cert")
+		return nil
+	}
+	return cert
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	openshiftcrypto "github.com/openshift/library-go/pkg/crypto"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+	"open-cluster-management.io/cluster-proxy/pkg/constant"
+)
+
+const testProxyServerMetrics = `# HELP konnectivity_network_proxy_server_grpc_connections Number of current grpc connections, partitioned by service method.
+# TYPE konnectivity_network_proxy_server_grpc_connections gauge
+konnectivity_network_proxy_server_grpc_connections{service_method="Connect"} 6
+konnectivity_network_proxy_server_grpc_connections{service_method="Proxy"} 2
+# HELP konnectivity_network_proxy_server_ready_backends Number of konnectivity agent connected to the proxy server
+# TYPE konnectivity_network_proxy_server_ready_backends gauge
+konnectivity_network_proxy_server_ready_backends{proxy_strategy="destHost"} 8
+`
+
+func TestParseConnectedAgents(t *testing.T) {
+	agents, err := parseConnectedAgents(strings.NewReader(testProxyServerMetrics))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(6), agents)
+
+	agents, err = parseConnectedAgents(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), agents)
+
+	_, err = parseConnectedAgents(strings.NewReader("not a metric line"))
+	assert.Error(t, err)
+}
+
+func TestScrapeConnectedAgents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, testProxyServerMetrics)
+	}))
+	defer server.Close()
+
+	agents, err := scrapeConnectedAgents(context.TODO(), strings.TrimPrefix(server.URL, "http://"))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(6), agents)
+}
+
+func newProxyServerPod(name, replicaSet, podIP string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "proxy-system",
+			Name:      name,
+			Labels: map[string]string{
+				common.LabelKeyComponentName: common.ComponentNameProxyServer,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: replicaSet},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			PodIP: podIP,
+		},
+	}
+}
+
+func TestGetProxyServerStatuses(t *testing.T) {
+	config := newEntrypointTestConfig(nil)
+	scraped := map[string]int32{
+		"10.0.0.1:8092": 6,
+		"10.0.0.2:8092": 5,
+	}
+	r := &ManagedProxyConfigurationReconciler{
+		PodGetter: fake.NewSimpleClientset(
+			newProxyServerPod("cluster-proxy-abc-1", "cluster-proxy-abc", "10.0.0.1", corev1.PodRunning),
+			newProxyServerPod("cluster-proxy-abc-2", "cluster-proxy-abc", "10.0.0.2", corev1.PodRunning),
+			newProxyServerPod("cluster-proxy-abc-3", "cluster-proxy-abc", "10.0.0.3", corev1.PodRunning),
+			newProxyServerPod("cluster-proxy-abc-4", "cluster-proxy-abc", "", corev1.PodPending),
+			newProxyServerPod("another-proxy-abc-1", "another-proxy-abc", "10.0.0.5", corev1.PodRunning),
+		).CoreV1(),
+		scrapeConnectedAgentsFunc: func(_ context.Context, address string) (int32, error) {
+			agents, ok := scraped[address]
+			if !ok {
+				return 0, fmt.Errorf("connection refused")
+			}
+			return agents, nil
+		},
+	}
+
+	statuses, err := r.getProxyServerStatuses(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []proxyv1alpha1.ManagedProxyConfigurationProxyServerStatus{
+		{PodName: "cluster-proxy-abc-1", ConnectedAgents: 6},
+		{PodName: "cluster-proxy-abc-2", ConnectedAgents: 5},
+	}, statuses)
+}
+
+func TestGetCertificateStatuses(t *testing.T) {
+	ca, err := openshiftcrypto.MakeSelfSignedCAConfigForDuration("test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _, err := ca.GetPEMBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSecret := func(namespace, name string, certPEM []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       map[string][]byte{corev1.TLSCertKey: certPEM},
+		}
+	}
+
+	config := newEntrypointTestConfig(nil)
+	config.Spec.Authentication.Dump.Secrets = proxyv1alpha1.CertificateSigningSecrets{
+		SigningProxyServerSecretName: "proxy-server",
+		SigningAgentServerSecretName: "agent-server",
+		SigningProxyClientSecretName: "proxy-client",
+	}
+	config.Spec.UserServer = &proxyv1alpha1.ManagedProxyConfigurationUserServer{}
+	r := &ManagedProxyConfigurationReconciler{
+		SecretGetter: fake.NewSimpleClientset(
+			newSecret("proxy-system", "proxy-server", certPEM),
+			newSecret("proxy-system", "agent-server", certPEM),
+			newSecret("proxy-system", "proxy-client", []byte("not a certificate")),
+			newSecret("open-cluster-management-addon", constant.ServerCertSecretName, certPEM),
+		).CoreV1(),
+		serviceProxySecretNamespace: "open-cluster-management-addon",
+	}
+
+	statuses, err := r.getCertificateStatuses(config)
+	assert.NoError(t, err)
+	notAfter := &metav1.Time{Time: ca.Certs[0].NotAfter}
+	notBefore := &metav1.Time{Time: ca.Certs[0].NotBefore}
+	assert.Equal(t, []proxyv1alpha1.ManagedProxyConfigurationCertificateStatus{
+		{Name: "proxy-server", SecretNamespace: "proxy-system", SecretName: "proxy-server", NotAfter: notAfter, LastRotationTime: notBefore},
+		{Name: "agent-server", SecretNamespace: "proxy-system", SecretName: "agent-server", NotAfter: notAfter, LastRotationTime: notBefore},
+		{Name: "service-proxy", SecretNamespace: "open-cluster-management-addon", SecretName: constant.ServerCertSecretName, NotAfter: notAfter, LastRotationTime: notBefore},
+	}, statuses)
+}
@@ -0,0 +1,280 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+var (
+	CertificateGVK = schema.GroupVersionKind{
+		Group:   "cert-manager.io",
+		Version: "v1",
+		Kind:    "Certificate",
+	}
+	CertificateRequestGVK = schema.GroupVersionKind{
+		Group:   "cert-manager.io",
+		Version: "v1",
+		Kind:    "CertificateRequest",
+	}
+)
+
+const (
+	UsageServerAuth       = "server auth"
+	UsageClientAuth       = "client auth"
+	UsageDigitalSignature = "digital signature"
+	UsageKeyEncipherment  = "key encipherment"
+)
+
+// NewCertificate builds a cert-manager Certificate issuing the certificate into the secret.
+// The SANs are sorted into DNS names and IP addresses. The certificate is renewed renewBefore
+// its expiry if it's positive, otherwise when cert-manager defaults to.
+func NewCertificate(
+	namespace, name, secretName string,
+	issuerRef proxyv1alpha1.CertManagerIssuerReference,
+	validity, renewBefore time.Duration,
+	sans []string,
+	usages ...string) *unstructured.Unstructured {
+	var dnsNames, ipAddresses []interface{}
+	for _, san := range sans {
+		if net.ParseIP(san) != nil {
+			ipAddresses = append(ipAddresses, san)
+		} else {
+			dnsNames = append(dnsNames, san)
+		}
+	}
+	allUsages := []interface{}{UsageDigitalSignature, UsageKeyEncipherment}
+	for _, usage := range usages {
+		allUsages = append(allUsages, usage)
+	}
+	spec := map[string]interface{}{
+		"secretName": secretName,
+		"issuerRef":  issuerReference(issuerRef),
+		"duration":   validity.String(),
+		"usages":     allUsages,
+		"privateKey": map[string]interface{}{
+			"algorithm":      "RSA",
+			"rotationPolicy": "Always",
+		},
+	}
+	if renewBefore > 0 {
+		spec["renewBefore"] = renewBefore.String()
+	}
+	if len(dnsNames) > 0 {
+		spec["dnsNames"] = dnsNames
+	}
+	if len(ipAddresses) > 0 {
+		spec["ipAddresses"] = ipAddresses
+	}
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	certificate.SetGroupVersionKind(CertificateGVK)
+	certificate.SetNamespace(namespace)
+	certificate.SetName(name)
+	return certificate
+}
+
+func issuerReference(issuerRef proxyv1alpha1.CertManagerIssuerReference) map[string]interface{} {
+	ref := map[string]interface{}{
+		"name":  issuerRef.Name,
+		"kind":  issuerRef.Kind,
+		"group": issuerRef.Group,
+	}
+	if len(issuerRef.Kind) == 0 {
+		ref["kind"] = "Issuer"
+	}
+	if len(issuerRef.Group) == 0 {
+		ref["group"] = CertificateGVK.Group
+	}
+	return ref
+}
+
+// LoadCABundle reads the PEM-encoded CA certificates the issuer chains to.
+func LoadCABundle(c corev1client.SecretsGetter, caBundle proxyv1alpha1.CertManagerCABundle) ([]byte, error) {
+	secret, err := c.Secrets(caBundle.SecretNamespace).Get(context.TODO(), caBundle.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read ca bundle from secret %v/%v", caBundle.SecretNamespace, caBundle.SecretName)
+	}
+	return caBundleFromSecret(secret, caBundle)
+}
+
+func caBundleFromSecret(secret *corev1.Secret, caBundle proxyv1alpha1.CertManagerCABundle) ([]byte, error) {
+	key := caBundle.Key
+	if len(key) == 0 {
+		key = "ca.crt"
+	}
+	data := secret.Data[key]
+	if block, _ := pem.Decode(data); block == nil {
+		return nil, fmt.Errorf("no PEM-encoded ca certificates found under %q in secret %v/%v",
+			key, caBundle.SecretNamespace, caBundle.SecretName)
+	}
+	return data, nil
+}
+
+// Signer signs the CSRs of the agents by a cert-manager issuer.
+type Signer struct {
+	Client client.Client
+	// Namespace is where the CertificateRequests are created.
+	Namespace string
+	IssuerRef proxyv1alpha1.CertManagerIssuerReference
+	// CABundle locates the CA certificates the issuer chains to.
+	CABundle proxyv1alpha1.CertManagerCABundle
+
+	lock   sync.RWMutex
+	caData []byte
+}
+
+// NewSigner loads the CA bundle of the issuer and builds the signer.
+func NewSigner(c client.Client, secrets corev1client.SecretsGetter, namespace string,
+	issuerRef proxyv1alpha1.CertManagerIssuerReference, caBundle proxyv1alpha1.CertManagerCABundle) (*Signer, error) {
+	caData, err := LoadCABundle(secrets, caBundle)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		Client:    c,
+		Namespace: namespace,
+		IssuerRef: issuerRef,
+		CABundle:  caBundle,
+		caData:    caData,
+	}, nil
+}
+
+// CAData returns the CA certificates the issuer chains to.
+func (s *Signer) CAData() []byte {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.caData
+}
+
+// ReloadOnChange reloads the CA bundle whenever its secret is changed, e.g. when the CA of
+// the issuer is renewed, so that the agents are handed the new CA.
+func (s *Signer) ReloadOnChange(informer cache.SharedIndexInformer) error {
+	reload := func(obj interface{}) {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok || secret.Namespace != s.CABundle.SecretNamespace || secret.Name != s.CABundle.SecretName {
+			return
+		}
+		caData, err := caBundleFromSecret(secret, s.CABundle)
+		if err != nil {
+			klog.Errorf("Failed reloading the cert-manager ca bundle: %v", err)
+			return
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.caData = caData
+	}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: reload,
+		UpdateFunc: func(_, newObj interface{}) {
+			reload(newObj)
+		},
+	})
+	return err
+}
+
+// SignerWithExpiry requests a client certificate for the CSR through a cert-manager
+// CertificateRequest named after the CSR. An error is returned until the request is
+// issued, so that the CSR is retried.
+func (s *Signer) SignerWithExpiry(duration time.Duration) agent.CSRSignerFunc {
+	return func(ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		_ *addonv1beta1.ManagedClusterAddOn,
+		csr *certificatesv1.CertificateSigningRequest) ([]byte, error) {
+		request := &unstructured.Unstructured{}
+		request.SetGroupVersionKind(CertificateRequestGVK)
+		err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: csr.Name}, request)
+		if apierrors.IsNotFound(err) {
+			request = newCertificateRequest(s.Namespace, csr, s.IssuerRef, duration)
+			if err := s.Client.Create(ctx, request); err != nil {
+				return nil, errors.Wrapf(err, "failed to create certificate request for csr %q", csr.Name)
+			}
+			return nil, fmt.Errorf("certificate request %s/%s is pending", s.Namespace, csr.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ready, reason, message := readyCondition(request)
+		if !ready {
+			if reason == "Failed" || reason == "Denied" {
+				// drop the failed request so that the next retry requests again.
+				if err := s.Client.Delete(ctx, request); err != nil && !apierrors.IsNotFound(err) {
+					return nil, err
+				}
+				return nil, fmt.Errorf("certificate request %s/%s %s: %s", s.Namespace, csr.Name, reason, message)
+			}
+			return nil, fmt.Errorf("certificate request %s/%s is pending", s.Namespace, csr.Name)
+		}
+		encoded, _, err := unstructured.NestedString(request.Object, "status", "certificate")
+		if err != nil {
+			return nil, err
+		}
+		certData, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid certificate in certificate request %s/%s", s.Namespace, csr.Name)
+		}
+		if err := s.Client.Delete(ctx, request); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		return certData, nil
+	}
+}
+
+func newCertificateRequest(
+	namespace string,
+	csr *certificatesv1.CertificateSigningRequest,
+	issuerRef proxyv1alpha1.CertManagerIssuerReference,
+	duration time.Duration) *unstructured.Unstructured {
+	request := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"request":   base64.StdEncoding.EncodeToString(csr.Spec.Request),
+			"issuerRef": issuerReference(issuerRef),
+			"duration":  duration.String(),
+			"usages": []interface{}{
+				UsageDigitalSignature,
+				UsageKeyEncipherment,
+				UsageClientAuth,
+			},
+		},
+	}}
+	request.SetGroupVersionKind(CertificateRequestGVK)
+	request.SetNamespace(namespace)
+	request.SetName(csr.Name)
+	return request
+}
+
+func readyCondition(request *unstructured.Unstructured) (bool, string, string) {
+	conditions, _, _ := unstructured.NestedSlice(request.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		return condition["status"] == string(metav1.ConditionTrue), reason, message
+	}
+	return false, "", ""
+}
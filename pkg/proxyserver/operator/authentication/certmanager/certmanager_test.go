@@ -0,0 +1,178 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func TestNewCertificate(t *testing.T) {
+	certificate := NewCertificate("proxy-system", "proxy-server", "proxy-server-tls",
+		proxyv1alpha1.CertManagerIssuerReference{Name: "corp-ca", Kind: "ClusterIssuer"},
+		time.Hour*24, time.Hour*4,
+		[]string{"127.0.0.1", "localhost", "fd00::1", "proxy-entrypoint.proxy-system"},
+		UsageServerAuth)
+
+	assert.Equal(t, CertificateGVK, certificate.GroupVersionKind())
+	assert.Equal(t, "proxy-system", certificate.GetNamespace())
+	assert.Equal(t, "proxy-server", certificate.GetName())
+	assert.Equal(t, map[string]interface{}{
+		"secretName": "proxy-server-tls",
+		"issuerRef": map[string]interface{}{
+			"name":  "corp-ca",
+			"kind":  "ClusterIssuer",
+			"group": "cert-manager.io",
+		},
+		"duration":    "24h0m0s",
+		"renewBefore": "4h0m0s",
+		"usages":      []interface{}{UsageDigitalSignature, UsageKeyEncipherment, UsageServerAuth},
+		"privateKey": map[string]interface{}{
+			"algorithm":      "RSA",
+			"rotationPolicy": "Always",
+		},
+		"dnsNames":    []interface{}{"localhost", "proxy-entrypoint.proxy-system"},
+		"ipAddresses": []interface{}{"127.0.0.1", "fd00::1"},
+	}, certificate.Object["spec"])
+}
+
+func TestLoadCABundle(t *testing.T) {
+	caData := []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")
+	c := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "corp-ca"},
+		Data: map[string][]byte{
+			"ca.crt":  caData,
+			"tls.crt": []byte("not a certificate"),
+		},
+	}).CoreV1()
+
+	actual, err := LoadCABundle(c, proxyv1alpha1.CertManagerCABundle{SecretNamespace: "cert-manager", SecretName: "corp-ca"})
+	assert.NoError(t, err)
+	assert.Equal(t, caData, actual)
+
+	_, err = LoadCABundle(c, proxyv1alpha1.CertManagerCABundle{SecretNamespace: "cert-manager", SecretName: "corp-ca", Key: "tls.crt"})
+	assert.Error(t, err)
+
+	_, err = LoadCABundle(c, proxyv1alpha1.CertManagerCABundle{SecretNamespace: "cert-manager", SecretName: "missing"})
+	assert.Error(t, err)
+}
+
+func TestSignerReloadsCABundle(t *testing.T) {
+	caBundle := proxyv1alpha1.CertManagerCABundle{SecretNamespace: "cert-manager", SecretName: "corp-ca"}
+	oldCA := []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")
+	newCA := []byte("-----BEGIN CERTIFICATE-----\nMIIC\n-----END CERTIFICATE-----\n")
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cert-manager", Name: "corp-ca"},
+		Data:       map[string][]byte{"ca.crt": oldCA},
+	}
+	kubeClient := fake.NewSimpleClientset(secret)
+	signer, err := NewSigner(ctrlfake.NewClientBuilder().Build(), kubeClient.CoreV1(), "proxy-system",
+		proxyv1alpha1.CertManagerIssuerReference{Name: "corp-ca"}, caBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, oldCA, signer.CAData())
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	if err := signer.ReloadOnChange(informerFactory.Core().V1().Secrets().Informer()); err != nil {
+		t.Fatal(err)
+	}
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+
+	secret.Data = map[string][]byte{"ca.crt": newCA}
+	if _, err := kubeClient.CoreV1().Secrets("cert-manager").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return string(signer.CAData()) == string(newCA)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func setReadyCondition(t *testing.T, c client.Client, status, reason string, certificate []byte) {
+	request := &unstructured.Unstructured{}
+	request.SetGroupVersionKind(CertificateRequestGVK)
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "proxy-system", Name: "addon-csr"}, request); err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedSlice(request.Object, []interface{}{
+		map[string]interface{}{
+			"type":    "Ready",
+			"status":  status,
+			"reason":  reason,
+			"message": "issued by corp-ca",
+		},
+	}, "status", "conditions"); err != nil {
+		t.Fatal(err)
+	}
+	if len(certificate) > 0 {
+		if err := unstructured.SetNestedField(request.Object,
+			base64.StdEncoding.EncodeToString(certificate), "status", "certificate"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Update(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignerWithExpiry(t *testing.T) {
+	c := ctrlfake.NewClientBuilder().Build()
+	signer := &Signer{
+		Client:    c,
+		Namespace: "proxy-system",
+		IssuerRef: proxyv1alpha1.CertManagerIssuerReference{Name: "corp-ca"},
+	}
+	sign := signer.SignerWithExpiry(time.Hour)
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte("csr"),
+		},
+	}
+
+	// the request is created and pending
+	_, err := sign(context.TODO(), nil, nil, csr)
+	assert.ErrorContains(t, err, "pending")
+	request := &unstructured.Unstructured{}
+	request.SetGroupVersionKind(CertificateRequestGVK)
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "proxy-system", Name: "addon-csr"}, request); err != nil {
+		t.Fatal(err)
+	}
+	encodedRequest, _, _ := unstructured.NestedString(request.Object, "spec", "request")
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("csr")), encodedRequest)
+	issuerKind, _, _ := unstructured.NestedString(request.Object, "spec", "issuerRef", "kind")
+	assert.Equal(t, "Issuer", issuerKind)
+
+	// a failed request is dropped to be requested again
+	setReadyCondition(t, c, "False", "Failed", nil)
+	_, err = sign(context.TODO(), nil, nil, csr)
+	assert.ErrorContains(t, err, "Failed")
+	err = c.Get(context.TODO(), client.ObjectKey{Namespace: "proxy-system", Name: "addon-csr"}, request)
+	assert.True(t, apierrors.IsNotFound(err), "expected the failed request to be deleted, got %v", err)
+
+	// the issued certificate is returned
+	_, err = sign(context.TODO(), nil, nil, csr)
+	assert.ErrorContains(t, err, "pending")
+	setReadyCondition(t, c, "True", "Issued", []byte("certificate"))
+	certData, err := sign(context.TODO(), nil, nil, csr)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("certificate"), certData)
+	err = c.Get(context.TODO(), client.ObjectKey{Namespace: "proxy-system", Name: "addon-csr"}, request)
+	assert.True(t, apierrors.IsNotFound(err), "expected the issued request to be deleted, got %v", err)
+}
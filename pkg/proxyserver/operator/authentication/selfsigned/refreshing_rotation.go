@@ -0,0 +1,55 @@
+package selfsigned
+
+import (
+	"crypto/x509"
+	"time"
+
+	openshiftcrypto "github.com/openshift/library-go/pkg/crypto"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/cert"
+
+	"open-cluster-management.io/sdk-go/pkg/certrotation"
+)
+
+var _ CertRotation = RefreshingRotation{}
+
+// RefreshingRotation renews the certificate once it expires within RefreshBefore, on top of
+// the renewals by the TargetRotation which happen when 80% of the validity has passed.
+type RefreshingRotation struct {
+	certrotation.TargetRotation
+	RefreshBefore time.Duration
+}
+
+func (r RefreshingRotation) EnsureTargetCertKeyPair(signingCertKeyPair *openshiftcrypto.CA, caBundleCerts []*x509.Certificate,
+	fns ...openshiftcrypto.CertificateExtensionFunc) error {
+	expiring, err := r.isExpiring(signingCertKeyPair)
+	if err != nil {
+		return err
+	}
+	if expiring {
+		// the TargetRotation reissues the certificates whose issuer is absent from the bundle.
+		caBundleCerts = nil
+	}
+	return r.TargetRotation.EnsureTargetCertKeyPair(signingCertKeyPair, caBundleCerts, fns...)
+}
+
+func (r RefreshingRotation) isExpiring(signingCertKeyPair *openshiftcrypto.CA) (bool, error) {
+	secret, err := r.Lister.Secrets(r.Namespace).Get(r.Name)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	certs, err := cert.ParseCertsPEM(secret.Data["tls.crt"])
+	if err != nil || len(certs) == 0 {
+		// left to the TargetRotation to replace.
+		return false, nil
+	}
+	notAfter := certs[0].NotAfter
+	// a reissued certificate can't outlive the CA, renewing it again wouldn't help.
+	if !notAfter.Before(signingCertKeyPair.Config.Certs[0].NotAfter.Add(-time.Minute)) {
+		return false, nil
+	}
+	return time.Now().After(notAfter.Add(-r.RefreshBefore)), nil
+}
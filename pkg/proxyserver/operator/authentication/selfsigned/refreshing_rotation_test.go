@@ -0,0 +1,58 @@
+package selfsigned
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"open-cluster-management.io/sdk-go/pkg/certrotation"
+)
+
+func TestRefreshingRotation(t *testing.T) {
+	signer, err := NewGeneratedSelfSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	rotation := RefreshingRotation{
+		TargetRotation: certrotation.TargetRotation{
+			Namespace: "proxy-system",
+			Name:      "proxy-server",
+			Validity:  10 * time.Hour,
+			HostNames: []string{"localhost"},
+			Lister:    corev1listers.NewSecretLister(indexer),
+			Client:    client.CoreV1(),
+		},
+		RefreshBefore: time.Hour,
+	}
+	ensure := func() []byte {
+		if err := rotation.EnsureTargetCertKeyPair(signer.CA(), signer.CA().Config.Certs); err != nil {
+			t.Fatal(err)
+		}
+		secret, err := client.CoreV1().Secrets("proxy-system").Get(context.TODO(), "proxy-server", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := indexer.Update(secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret.Data["tls.crt"]
+	}
+
+	issued := ensure()
+	if !bytes.Equal(issued, ensure()) {
+		t.Errorf("expected the certificate not to be renewed before the refresh threshold")
+	}
+
+	rotation.RefreshBefore = rotation.Validity
+	if bytes.Equal(issued, ensure()) {
+		t.Errorf("expected the certificate to be renewed after the refresh threshold")
+	}
+}
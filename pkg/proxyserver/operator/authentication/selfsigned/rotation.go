@@ -0,0 +1,243 @@
+package selfsigned
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	openshiftcrypto "github.com/openshift/library-go/pkg/crypto"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+)
+
+const (
+	// TLSNextCACert and TLSNextCAKey keep the CA being introduced into the trust bundles.
+	TLSNextCACert = "next-ca.crt"
+	TLSNextCAKey  = "next-ca.key"
+	// TLSPreviousCACert keeps the retiring CA, which is trusted until the rotation completes.
+	TLSPreviousCACert = "previous-ca.crt"
+)
+
+var _ SelfSigner = &RotatingSigner{}
+
+// RotatingSigner is a SelfSigner loaded from the signer secret, whose CA can be rotated
+// without interrupting the connections:
+//  1. AddNextCA generates a new CA which is trusted besides the current one.
+//  2. PromoteNextCA signs the certificates by the new CA, the previous CA stays trusted.
+//  3. RetirePreviousCA removes the previous CA from the trust bundle.
+//
+// The progress is persisted in the signer secret so that it survives restarts.
+type RotatingSigner struct {
+	client          kubernetes.Interface
+	secretNamespace string
+	secretName      string
+
+	lock            sync.RWMutex
+	signer          SelfSigner
+	nextCACert      []byte
+	previousCACert  []byte
+	resourceVersion string
+}
+
+// NewRotatingSignerFromSecretOrGenerate loads the signer secret, or generates it upon absence.
+func NewRotatingSignerFromSecretOrGenerate(c kubernetes.Interface, secretNamespace, secretName string, ownerRef *metav1.OwnerReference) (*RotatingSigner, error) {
+	if _, err := NewSelfSignerFromSecretOrGenerate(c, secretNamespace, secretName, ownerRef); err != nil {
+		return nil, err
+	}
+	s := &RotatingSigner{
+		client:          c,
+		secretNamespace: secretNamespace,
+		secretName:      secretName,
+	}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh reloads the signer from the secret.
+func (s *RotatingSigner) Refresh() error {
+	secret, err := s.client.CoreV1().Secrets(s.secretNamespace).Get(context.TODO(), s.secretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read ca from secret %v/%v", s.secretNamespace, s.secretName)
+	}
+	return s.Load(secret)
+}
+
+// Load reloads the signer from the secret if it's changed.
+func (s *RotatingSigner) Load(secret *corev1.Secret) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(secret.ResourceVersion) > 0 && secret.ResourceVersion == s.resourceVersion {
+		return nil
+	}
+	signer, err := NewSelfSignerWithCAData(secret.Data[TLSCACert], secret.Data[TLSCAKey])
+	if err != nil {
+		return errors.Wrapf(err, "invalid ca in secret %v/%v", secret.Namespace, secret.Name)
+	}
+	s.signer = signer
+	s.nextCACert = secret.Data[TLSNextCACert]
+	s.previousCACert = secret.Data[TLSPreviousCACert]
+	s.resourceVersion = secret.ResourceVersion
+	return nil
+}
+
+// ReloadOnChange reloads the signer whenever the secret is changed, e.g. when the rotation
+// is advanced by the addon-manager elected as the leader.
+func (s *RotatingSigner) ReloadOnChange(informer cache.SharedIndexInformer) error {
+	reload := func(obj interface{}) {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok || secret.Namespace != s.secretNamespace || secret.Name != s.secretName {
+			return
+		}
+		if err := s.Load(secret); err != nil {
+			klog.Errorf("Failed reloading the signer: %v", err)
+		}
+	}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: reload,
+		UpdateFunc: func(_, newObj interface{}) {
+			reload(newObj)
+		},
+	})
+	return err
+}
+
+func (s *RotatingSigner) current() SelfSigner {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.signer
+}
+
+func (s *RotatingSigner) Sign(cfg cert.Config, expiry time.Duration) (CertPair, error) {
+	return s.current().Sign(cfg, expiry)
+}
+
+func (s *RotatingSigner) CAData() []byte {
+	return s.current().CAData()
+}
+
+func (s *RotatingSigner) GetSigner() crypto.Signer {
+	return s.current().GetSigner()
+}
+
+func (s *RotatingSigner) CA() *openshiftcrypto.CA {
+	return s.current().CA()
+}
+
+// TrustBundle returns the signing CA followed by the CAs being rotated in or out.
+func (s *RotatingSigner) TrustBundle() []byte {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return bytes.Join([][]byte{s.signer.CAData(), s.nextCACert, s.previousCACert}, nil)
+}
+
+// Phase returns the step of the ongoing rotation, or an empty phase if there's none.
+func (s *RotatingSigner) Phase() proxyv1alpha1.CARotationPhase {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	switch {
+	case len(s.nextCACert) > 0:
+		return proxyv1alpha1.CARotationPhaseTrusting
+	case len(s.previousCACert) > 0:
+		return proxyv1alpha1.CARotationPhaseReissuing
+	}
+	return ""
+}
+
+// AddNextCA generates the CA to rotate to, which is only trusted until it's promoted.
+func (s *RotatingSigner) AddNextCA() error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return err
+	}
+	caCert, err := cert.NewSelfSignedCACert(cert.Config{
+		CommonName: common.AddonFullName,
+	}, privateKey)
+	if err != nil {
+		return err
+	}
+	rawKeyData, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	return s.update(func(secret *corev1.Secret) error {
+		if len(secret.Data[TLSNextCACert]) > 0 || len(secret.Data[TLSPreviousCACert]) > 0 {
+			return fmt.Errorf("the ca rotation is already in progress")
+		}
+		secret.Data[TLSNextCACert] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+		secret.Data[TLSNextCAKey] = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawKeyData})
+		return nil
+	})
+}
+
+// PromoteNextCA signs the certificates by the next CA, while the current CA is kept trusted
+// as the previous CA.
+func (s *RotatingSigner) PromoteNextCA() error {
+	return s.update(func(secret *corev1.Secret) error {
+		if len(secret.Data[TLSNextCACert]) == 0 || len(secret.Data[TLSNextCAKey]) == 0 {
+			return fmt.Errorf("no next ca to promote")
+		}
+		secret.Data[TLSPreviousCACert] = secret.Data[TLSCACert]
+		secret.Data[TLSCACert] = secret.Data[TLSNextCACert]
+		secret.Data[TLSCAKey] = secret.Data[TLSNextCAKey]
+		delete(secret.Data, TLSNextCACert)
+		delete(secret.Data, TLSNextCAKey)
+		return nil
+	})
+}
+
+// RetirePreviousCA stops trusting the previous CA.
+func (s *RotatingSigner) RetirePreviousCA() error {
+	return s.update(func(secret *corev1.Secret) error {
+		delete(secret.Data, TLSPreviousCACert)
+		return nil
+	})
+}
+
+func (s *RotatingSigner) update(mutate func(secret *corev1.Secret) error) error {
+	secret, err := s.client.CoreV1().Secrets(s.secretNamespace).Get(context.TODO(), s.secretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read ca from secret %v/%v", s.secretNamespace, s.secretName)
+	}
+	secret = secret.DeepCopy()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	if err := mutate(secret); err != nil {
+		return err
+	}
+	// validate before persisting so that a broken ca never replaces the working one
+	if _, err := NewSelfSignerWithCAData(secret.Data[TLSCACert], secret.Data[TLSCAKey]); err != nil {
+		return errors.Wrapf(err, "invalid ca for secret %v/%v", s.secretNamespace, s.secretName)
+	}
+	updated, err := s.client.CoreV1().Secrets(s.secretNamespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update ca secret %v/%v", s.secretNamespace, s.secretName)
+	}
+	return s.Load(updated)
+}
+
+// TrustBundle returns the CA certificates to be trusted for the signer, which includes the
+// CAs being rotated in or out besides its own.
+func TrustBundle(s SelfSigner) []byte {
+	if rotating, ok := s.(*RotatingSigner); ok {
+		return rotating.TrustBundle()
+	}
+	return s.CAData()
+}
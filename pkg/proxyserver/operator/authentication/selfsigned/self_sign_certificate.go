@@ -16,6 +16,7 @@ import (
 
 	openshiftcrypto "github.com/openshift/library-go/pkg/crypto"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -114,20 +115,62 @@ func NewGeneratedSelfSigner() (SelfSigner, error) {
 	return NewSelfSignerWithCA(caCert, privateKey, big.NewInt(1))
 }
 
+// NewSelfSignerFromProvidedSecret loads the signer from an externally provided CA secret of
+// the "kubernetes.io/tls" type. Unlike NewSelfSignerFromSecretOrGenerate, the CA is never
+// generated.
+func NewSelfSignerFromProvidedSecret(c kubernetes.Interface, secretNamespace, secretName string) (SelfSigner, error) {
+	caSecret, err := c.CoreV1().Secrets(secretNamespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read provided ca from secret %v/%v", secretNamespace, secretName)
+	}
+	signer, err := NewSelfSignerWithCAData(caSecret.Data[corev1.TLSCertKey], caSecret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid provided ca in secret %v/%v", secretNamespace, secretName)
+	}
+	return signer, nil
+}
+
 func NewSelfSignerWithCAData(caCertData, caKeyData []byte) (SelfSigner, error) {
 	certBlock, _ := pem.Decode(caCertData)
+	if certBlock == nil {
+		return nil, fmt.Errorf("failed to decode ca certificate")
+	}
 	caCert, err := x509.ParseCertificate(certBlock.Bytes)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse ca certificate")
 	}
+	if !caCert.IsCA {
+		return nil, fmt.Errorf("certificate %q is not a ca", caCert.Subject.CommonName)
+	}
 	keyBlock, _ := pem.Decode(caKeyData)
-	caKey, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("failed to decode ca key")
+	}
+	caKey, err := parseRSAPrivateKey(keyBlock.Bytes)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse ca key")
 	}
 	next := big.NewInt(0)
 	next.Add(caCert.SerialNumber, big.NewInt(1))
-	return NewSelfSignerWithCA(caCert, caKey.(*rsa.PrivateKey), next)
+	return NewSelfSignerWithCA(caCert, caKey, next)
+}
+
+// parseRSAPrivateKey parses an RSA key in either PKCS#8 or PKCS#1 form. Other key types are
+// rejected up front because the agent CSRs are signed by the addon-framework signer, which
+// only accepts the CA key re-encoded as PKCS#1 RSA.
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ca key type %T, the ca must use an RSA key", key)
+	}
+	return rsaKey, nil
 }
 
 func NewSelfSignerWithCA(caCert *x509.Certificate, caKey *rsa.PrivateKey, nextSerial *big.Int) (SelfSigner, error) {
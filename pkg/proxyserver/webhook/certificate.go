@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"open-cluster-management.io/cluster-proxy/pkg/proxyserver/operator/authentication/selfsigned"
+)
+
+const (
+	// WebhookConfigurationName is the name of both the mutating and the validating webhook
+	// configurations of the addon-manager.
+	WebhookConfigurationName = "cluster-proxy-addon-manager"
+	// SignerSecretName is the secret storing the CA signing the serving certificates of
+	// the webhooks, which is shared by the addon-manager replicas.
+	SignerSecretName = "cluster-proxy-webhook-signer"
+
+	crdName = "managedproxyconfigurations.proxy.open-cluster-management.io"
+
+	servingCertValidity      = time.Hour * 24 * 365
+	servingCertRefreshBefore = servingCertValidity / 5
+	caBundleResyncInterval   = 10 * time.Minute
+)
+
+var crdGVK = schema.GroupVersionKind{
+	Group:   "apiextensions.k8s.io",
+	Version: "v1",
+	Kind:    "CustomResourceDefinition",
+}
+
+// ServingCertificate serves the webhooks of an addon-manager replica with a certificate
+// signed on demand by the shared CA.
+type ServingCertificate struct {
+	signer    selfsigned.SelfSigner
+	hostnames []string
+
+	lock    sync.Mutex
+	current *tls.Certificate
+}
+
+// NewServingCertificate loads the CA from the SignerSecretName secret in the namespace,
+// generating it if not found, to sign the serving certificate of the webhook service.
+func NewServingCertificate(c kubernetes.Interface, namespace, serviceName string) (*ServingCertificate, error) {
+	signer, err := selfsigned.NewSelfSignerFromSecretOrGenerate(c, namespace, SignerSecretName, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed loading webhook signer")
+	}
+	return &ServingCertificate{
+		signer: signer,
+		hostnames: []string{
+			serviceName + "." + namespace + ".svc",
+			serviceName + "." + namespace,
+			serviceName,
+		},
+	}, nil
+}
+
+// CAData returns the CA bundle verifying the serving certificates.
+func (s *ServingCertificate) CAData() []byte {
+	return s.signer.CAData()
+}
+
+// ConfigureTLS has the webhook server present the serving certificate.
+func (s *ServingCertificate) ConfigureTLS(config *tls.Config) {
+	config.GetCertificate = s.GetCertificate
+}
+
+// GetCertificate returns the serving certificate, signing a new one when the current one
+// is about to expire.
+func (s *ServingCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.current != nil && time.Until(s.current.Leaf.NotAfter) > servingCertRefreshBefore {
+		return s.current, nil
+	}
+	certPair, err := s.signer.Sign(cert.Config{
+		CommonName: s.hostnames[0],
+		AltNames:   cert.AltNames{DNSNames: s.hostnames},
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, servingCertValidity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed signing webhook serving certificate")
+	}
+	certData, keyData, err := certPair.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, err
+	}
+	certificate.Leaf = certPair.Cert
+	s.current = &certificate
+	return s.current, nil
+}
+
+// CABundleInjector publishes the CA bundle to the webhook configurations and to the conversion
+// webhook the ManagedProxyConfiguration CRD ships with, pointing it to the webhook service of
+// the addon-manager namespace. It resyncs periodically since re-applying the manifests can
+// drop the injected fields.
+type CABundleInjector struct {
+	NativeClient kubernetes.Interface
+	// Client patches the CRD, which is not covered by the native client.
+	Client      client.Client
+	CAData      []byte
+	Namespace   string
+	ServiceName string
+}
+
+func (i *CABundleInjector) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := i.inject(ctx); err != nil {
+			klog.Errorf("Failed to inject the webhook ca bundle: %v", err)
+		}
+	}, caBundleResyncInterval)
+	return nil
+}
+
+func (i *CABundleInjector) inject(ctx context.Context) error {
+	if err := i.injectMutatingWebhooks(ctx); err != nil {
+		return err
+	}
+	if err := i.injectValidatingWebhooks(ctx); err != nil {
+		return err
+	}
+	return i.injectConversion(ctx)
+}
+
+func (i *CABundleInjector) injectMutatingWebhooks(ctx context.Context) error {
+	configs := i.NativeClient.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	config, err := configs.Get(ctx, WebhookConfigurationName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var clientConfigs []*admissionregistrationv1.WebhookClientConfig
+	for idx := range config.Webhooks {
+		clientConfigs = append(clientConfigs, &config.Webhooks[idx].ClientConfig)
+	}
+	if !setCABundle(i.CAData, clientConfigs) {
+		return nil
+	}
+	_, err = configs.Update(ctx, config, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "failed to update mutating webhook configuration %q", WebhookConfigurationName)
+}
+
+func (i *CABundleInjector) injectValidatingWebhooks(ctx context.Context) error {
+	configs := i.NativeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	config, err := configs.Get(ctx, WebhookConfigurationName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var clientConfigs []*admissionregistrationv1.WebhookClientConfig
+	for idx := range config.Webhooks {
+		clientConfigs = append(clientConfigs, &config.Webhooks[idx].ClientConfig)
+	}
+	if !setCABundle(i.CAData, clientConfigs) {
+		return nil
+	}
+	_, err = configs.Update(ctx, config, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "failed to update validating webhook configuration %q", WebhookConfigurationName)
+}
+
+// setCABundle returns whether any of the client configs is modified.
+func setCABundle(caData []byte, clientConfigs []*admissionregistrationv1.WebhookClientConfig) bool {
+	modified := false
+	for _, clientConfig := range clientConfigs {
+		if !bytes.Equal(clientConfig.CABundle, caData) {
+			clientConfig.CABundle = caData
+			modified = true
+		}
+	}
+	return modified
+}
+
+func (i *CABundleInjector) injectConversion(ctx context.Context) error {
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(crdGVK)
+	if err := i.Client.Get(ctx, types.NamespacedName{Name: crdName}, crd); err != nil {
+		return errors.Wrapf(err, "failed to get crd %q", crdName)
+	}
+	conversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"clientConfig": map[string]interface{}{
+				"service": map[string]interface{}{
+					"namespace": i.Namespace,
+					"name":      i.ServiceName,
+					"path":      "/convert",
+					"port":      int64(443),
+				},
+				"caBundle": base64.StdEncoding.EncodeToString(i.CAData),
+			},
+			"conversionReviewVersions": []interface{}{"v1"},
+		},
+	}
+	current, _, _ := unstructured.NestedMap(crd.Object, "spec", "conversion")
+	if equality.Semantic.DeepEqual(current, conversion) {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"conversion": conversion},
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(i.Client.Patch(ctx, crd, client.RawPatch(types.MergePatchType, patch)),
+		"failed to patch the conversion of crd %q", crdName)
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServingCertificate(t *testing.T) {
+	servingCert, err := NewServingCertificate(fake.NewSimpleClientset(), "open-cluster-management", "webhook")
+	assert.NoError(t, err)
+
+	certificate, err := servingCert.GetCertificate(nil)
+	assert.NoError(t, err)
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(servingCert.CAData()))
+	_, err = certificate.Leaf.Verify(x509.VerifyOptions{
+		DNSName:   "webhook.open-cluster-management.svc",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	assert.NoError(t, err)
+
+	cached, err := servingCert.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Same(t, certificate, cached)
+}
+
+func TestCABundleInjector(t *testing.T) {
+	nativeClient := fake.NewSimpleClientset(
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: WebhookConfigurationName},
+			Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "mutate"}},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: WebhookConfigurationName},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "validate"}},
+		},
+	)
+	scheme := runtime.NewScheme()
+	assert.NoError(t, apiextensionsv1.AddToScheme(scheme))
+	runtimeClient := ctrlfake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: crdName},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				// the conversion shipped with the chart, which misses the CA bundle
+				Conversion: &apiextensionsv1.CustomResourceConversion{
+					Strategy: apiextensionsv1.WebhookConverter,
+					Webhook: &apiextensionsv1.WebhookConversion{
+						ClientConfig: &apiextensionsv1.WebhookClientConfig{
+							Service: &apiextensionsv1.ServiceReference{
+								Namespace: "open-cluster-management-addon",
+								Name:      "webhook",
+								Path:      ptr.To("/convert"),
+								Port:      ptr.To[int32](443),
+							},
+						},
+						ConversionReviewVersions: []string{"v1"},
+					},
+				},
+			},
+		},
+	).Build()
+
+	injector := &CABundleInjector{
+		NativeClient: nativeClient,
+		Client:       runtimeClient,
+		CAData:       []byte("ca"),
+		Namespace:    "open-cluster-management",
+		ServiceName:  "webhook",
+	}
+	assert.NoError(t, injector.inject(context.TODO()))
+
+	mutating, err := nativeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().
+		Get(context.TODO(), WebhookConfigurationName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca"), mutating.Webhooks[0].ClientConfig.CABundle)
+	validating, err := nativeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().
+		Get(context.TODO(), WebhookConfigurationName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca"), validating.Webhooks[0].ClientConfig.CABundle)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	assert.NoError(t, runtimeClient.Get(context.TODO(), types.NamespacedName{Name: crdName}, crd))
+	assert.Equal(t, apiextensionsv1.WebhookConverter, crd.Spec.Conversion.Strategy)
+	clientConfig := crd.Spec.Conversion.Webhook.ClientConfig
+	assert.Equal(t, []byte("ca"), clientConfig.CABundle)
+	assert.Equal(t, "webhook", clientConfig.Service.Name)
+	assert.Equal(t, "/convert", *clientConfig.Service.Path)
+	assert.Equal(t, "open-cluster-management", clientConfig.Service.Namespace)
+
+	// a second pass is a no-op.
+	nativeClient.ClearActions()
+	assert.NoError(t, injector.inject(context.TODO()))
+	for _, action := range nativeClient.Actions() {
+		assert.Equal(t, "get", action.GetVerb())
+	}
+	unchanged := &apiextensionsv1.CustomResourceDefinition{}
+	assert.NoError(t, runtimeClient.Get(context.TODO(), types.NamespacedName{Name: crdName}, unchanged))
+	assert.Equal(t, crd.ResourceVersion, unchanged.ResourceVersion)
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+	proxyconfig "open-cluster-management.io/cluster-proxy/pkg/config"
+)
+
+// defaultEntrypointObjectName is the default name of the objects backing an entrypoint,
+// matching the defaults of the entrypoint types.
+const defaultEntrypointObjectName = "proxy-agent-entrypoint"
+
+var (
+	_ admission.Defaulter[*proxyv1alpha1.ManagedProxyConfiguration] = &ManagedProxyConfigurationDefaulter{}
+	_ admission.Validator[*proxyv1alpha1.ManagedProxyConfiguration] = &ManagedProxyConfigurationValidator{}
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of the
+// ManagedProxyConfiguration, as well as the conversion webhook between its versions.
+func SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &proxyv1alpha1.ManagedProxyConfiguration{}).
+		WithDefaulter(&ManagedProxyConfigurationDefaulter{}).
+		WithValidator(&ManagedProxyConfigurationValidator{}).
+		Complete()
+}
+
+// ManagedProxyConfigurationDefaulter fills in the details of the entrypoint that can be
+// derived from its type.
+type ManagedProxyConfigurationDefaulter struct{}
+
+func (d *ManagedProxyConfigurationDefaulter) Default(_ context.Context, config *proxyv1alpha1.ManagedProxyConfiguration) error {
+	if config.Spec.ProxyServer.Entrypoint == nil {
+		// agents tunnel through the port-forwarding proxies unless the proxy servers are exposed.
+		config.Spec.ProxyServer.Entrypoint = &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+			Type: proxyv1alpha1.EntryPointTypePortForward,
+		}
+	}
+	entrypoint := config.Spec.ProxyServer.Entrypoint
+	switch entrypoint.Type {
+	case proxyv1alpha1.EntryPointTypeLoadBalancerService:
+		if entrypoint.LoadBalancerService == nil {
+			entrypoint.LoadBalancerService = &proxyv1alpha1.EntryPointLoadBalancerService{
+				Name: defaultEntrypointObjectName,
+			}
+		}
+	case proxyv1alpha1.EntryPointTypeRoute:
+		if entrypoint.Route == nil {
+			entrypoint.Route = &proxyv1alpha1.EntryPointRoute{
+				Name: defaultEntrypointObjectName,
+			}
+		}
+	}
+	return nil
+}
+
+// ManagedProxyConfigurationValidator rejects the ManagedProxyConfigurations which can't
+// be reconciled.
+type ManagedProxyConfigurationValidator struct{}
+
+func (v *ManagedProxyConfigurationValidator) ValidateCreate(_ context.Context,
+	config *proxyv1alpha1.ManagedProxyConfiguration) (admission.Warnings, error) {
+	return nil, validate(config)
+}
+
+func (v *ManagedProxyConfigurationValidator) ValidateUpdate(_ context.Context,
+	oldConfig, newConfig *proxyv1alpha1.ManagedProxyConfiguration) (admission.Warnings, error) {
+	var warnings admission.Warnings
+	oldType, newType := oldConfig.Spec.Authentication.Signer.Type, newConfig.Spec.Authentication.Signer.Type
+	if oldType != newType {
+		warnings = append(warnings, fmt.Sprintf(
+			"the signer is changed from %q to %q, restart the addon-manager to pick it up", oldType, newType))
+	}
+	return warnings, validate(newConfig)
+}
+
+func (v *ManagedProxyConfigurationValidator) ValidateDelete(context.Context,
+	*proxyv1alpha1.ManagedProxyConfiguration) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validate(config *proxyv1alpha1.ManagedProxyConfiguration) error {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	entrypointPath := specPath.Child("proxyServer", "entrypoint")
+	if config.Spec.ProxyServer.Entrypoint == nil {
+		errs = append(errs, field.Required(entrypointPath, ""))
+	} else if err := proxyconfig.ValidateEntrypoint(config); err != nil {
+		errs = append(errs, field.Invalid(entrypointPath, config.Spec.ProxyServer.Entrypoint.Type, err.Error()))
+	}
+
+	signer := config.Spec.Authentication.Signer
+	signerPath := specPath.Child("authentication", "signer")
+	switch signer.Type {
+	case proxyv1alpha1.Provided:
+		if signer.Provided == nil {
+			errs = append(errs, field.Required(signerPath.Child("provided"), "required for the Provided signer"))
+		}
+	case proxyv1alpha1.CertManager:
+		if signer.CertManager == nil {
+			errs = append(errs, field.Required(signerPath.Child("certManager"), "required for the CertManager signer"))
+		} else if len(signer.CertManager.IssuerRef.Name) == 0 {
+			errs = append(errs, field.Required(signerPath.Child("certManager", "issuerRef", "name"), ""))
+		}
+	}
+
+	// the CA is unknown here, the lifetimes are checked against it upon reconciling.
+	if err := proxyconfig.ValidateCertificateLifetimes(config, nil); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("authentication", "lifetimes"),
+			config.Spec.Authentication.Lifetimes, err.Error()))
+	}
+
+	ports := proxyconfig.GetDeployPorts(config)
+	portsPath := specPath.Child("deploy", "ports")
+	seen := map[int32]string{}
+	for _, port := range []struct {
+		name   string
+		number int32
+	}{
+		{"proxyServer", ports.ProxyServer},
+		{"agentServer", ports.AgentServer},
+		{"healthServer", ports.HealthServer},
+		{"adminServer", ports.AdminServer},
+	} {
+		if other, ok := seen[port.number]; ok {
+			errs = append(errs, field.Invalid(portsPath.Child(port.name), port.number,
+				fmt.Sprintf("conflicts with the %s port", other)))
+			continue
+		}
+		seen[port.number] = port.name
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(proxyv1alpha1.GroupVersion.WithKind("ManagedProxyConfiguration").GroupKind(),
+		config.Name, errs)
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func newTestConfig(entrypoint *proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint) *proxyv1alpha1.ManagedProxyConfiguration {
+	config := &proxyv1alpha1.ManagedProxyConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-proxy"},
+	}
+	config.Spec.Authentication.Signer.Type = proxyv1alpha1.SelfSigned
+	config.Spec.ProxyServer.Entrypoint = entrypoint
+	return config
+}
+
+func TestDefault(t *testing.T) {
+	defaulter := &ManagedProxyConfigurationDefaulter{}
+
+	config := newTestConfig(nil)
+	assert.NoError(t, defaulter.Default(context.TODO(), config))
+	assert.Equal(t, proxyv1alpha1.EntryPointTypePortForward, config.Spec.ProxyServer.Entrypoint.Type)
+
+	config = newTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeLoadBalancerService,
+	})
+	assert.NoError(t, defaulter.Default(context.TODO(), config))
+	assert.Equal(t, &proxyv1alpha1.EntryPointLoadBalancerService{Name: "proxy-agent-entrypoint"},
+		config.Spec.ProxyServer.Entrypoint.LoadBalancerService)
+
+	config = newTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypeHostname,
+	})
+	assert.NoError(t, defaulter.Default(context.TODO(), config))
+	assert.Nil(t, config.Spec.ProxyServer.Entrypoint.Hostname)
+}
+
+func TestValidate(t *testing.T) {
+	portForward := &proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypePortForward,
+	}
+	testcases := []struct {
+		name      string
+		config    *proxyv1alpha1.ManagedProxyConfiguration
+		expectErr string
+	}{
+		{
+			name:   "valid",
+			config: newTestConfig(portForward),
+		},
+		{
+			name:      "missing entrypoint",
+			config:    newTestConfig(nil),
+			expectErr: "spec.proxyServer.entrypoint: Required value",
+		},
+		{
+			name: "missing hostname",
+			config: newTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+				Type: proxyv1alpha1.EntryPointTypeHostname,
+			}),
+			expectErr: "hostname is required for the Hostname entrypoint",
+		},
+		{
+			name: "missing cert-manager",
+			config: func() *proxyv1alpha1.ManagedProxyConfiguration {
+				config := newTestConfig(portForward)
+				config.Spec.Authentication.Signer.Type = proxyv1alpha1.CertManager
+				return config
+			}(),
+			expectErr: "spec.authentication.signer.certManager: Required value",
+		},
+		{
+			name: "refreshing after the expiry",
+			config: func() *proxyv1alpha1.ManagedProxyConfiguration {
+				config := newTestConfig(portForward)
+				config.Spec.Authentication.Lifetimes.Server.Validity = &metav1.Duration{Duration: time.Hour}
+				config.Spec.Authentication.Lifetimes.Server.RefreshBefore = &metav1.Duration{Duration: 2 * time.Hour}
+				return config
+			}(),
+			expectErr: "spec.authentication.lifetimes: Invalid value",
+		},
+		{
+			name: "conflicting ports",
+			config: func() *proxyv1alpha1.ManagedProxyConfiguration {
+				config := newTestConfig(portForward)
+				config.Spec.Deploy = &proxyv1alpha1.ManagedProxyConfigurationDeploy{
+					Ports: proxyv1alpha1.ManagedProxyConfigurationDeployPorts{AdminServer: 8090},
+				}
+				return config
+			}(),
+			expectErr: "spec.deploy.ports.adminServer: Invalid value: 8090: conflicts with the proxyServer port",
+		},
+	}
+
+	validator := &ManagedProxyConfigurationValidator{}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			_, err := validator.ValidateCreate(context.TODO(), testcase.config)
+			if len(testcase.expectErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), testcase.expectErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate_SignerChanged(t *testing.T) {
+	oldConfig := newTestConfig(&proxyv1alpha1.ManagedProxyConfigurationProxyServerEntrypoint{
+		Type: proxyv1alpha1.EntryPointTypePortForward,
+	})
+	newConfig := oldConfig.DeepCopy()
+	newConfig.Spec.Authentication.Signer.Type = proxyv1alpha1.Provided
+	newConfig.Spec.Authentication.Signer.Provided = &proxyv1alpha1.AuthenticationProvided{
+		SecretNamespace: "corp", SecretName: "corp-ca",
+	}
+
+	warnings, err := (&ManagedProxyConfigurationValidator{}).ValidateUpdate(context.TODO(), oldConfig, newConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`the signer is changed from "SelfSigned" to "Provided", restart the addon-manager to pick it up`,
+	}, []string(warnings))
+}
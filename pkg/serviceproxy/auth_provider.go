@@ -3,7 +3,6 @@ package serviceproxy
 import (
 	"context"
 	"net/http"
-	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
@@ -50,29 +49,39 @@ type authProviderFactoryLogger interface {
 }
 
 type authProviderDependencies struct {
+	agentKubeClient          kubernetes.Interface
 	managedClusterKubeClient kubernetes.Interface
-	podNamespace             string
-	kubeClientQPS            float32
-	kubeClientBurst          int
-	tokenReviewCacheTTL      time.Duration
-	impersonateUser          impersonateUserFunc
+	// managedClusterTokenAudiences restricts managed cluster tokens to the
+	// listed audiences; empty accepts tokens of any audience.
+	managedClusterTokenAudiences []string
+	clusterName                  string
+	podNamespace                 string
+	kubeClientQPS                float32
+	kubeClientBurst              int
+	// tokenCache is shared by all providers; nil disables caching.
+	tokenCache      *tokenCache
+	impersonateUser impersonateUserFunc
 }
 
 func defaultAuthProviderFactories() []authProviderFactory {
 	return []authProviderFactory{
 		newHubAuthProviderFactory(),
 		newOIDCAuthProviderFactory(),
+		newWebhookAuthProviderFactory(),
+		newX509AuthProviderFactory(),
 	}
 }
 
 func (s *serviceProxy) authProviderDependencies() authProviderDependencies {
 	return authProviderDependencies{
-		managedClusterKubeClient: s.managedClusterKubeClient,
-		podNamespace:             s.podNamespace,
-		kubeClientQPS:            s.kubeClientQPS,
-		kubeClientBurst:          s.kubeClientBurst,
-		tokenReviewCacheTTL:      s.tokenReviewCacheTTL,
-		impersonateUser:          s.impersonateUser,
+		agentKubeClient:              s.agentKubeClient,
+		managedClusterKubeClient:     s.managedClusterKubeClient,
+		managedClusterTokenAudiences: s.managedClusterTokenAudiences,
+		clusterName:                  s.clusterName,
+		podNamespace:                 s.podNamespace,
+		kubeClientQPS:                s.kubeClientQPS,
+		kubeClientBurst:              s.kubeClientBurst,
+		impersonateUser:              s.impersonateUser,
 	}
 }
 
@@ -85,12 +94,15 @@ func (s *serviceProxy) initializeAuthProviders(ctx context.Context) error {
 		}
 	}
 
-	if len(enabledFactories) == 0 {
+	// identity assertion and TCP tunnels authenticate requests even when only
+	// the managed cluster TokenReview is available
+	if len(enabledFactories) == 0 && !s.identityAssertion.enabled && !s.tcpTunnel.enabled {
 		s.authProviders = nil
 		return nil
 	}
 
 	dependencies := s.authProviderDependencies()
+	dependencies.tokenCache = newTokenCache(s.tokenCache)
 	providers := make([]authProvider, 0, len(enabledFactories)+1)
 	providers = append(providers, newManagedClusterAuthProvider(dependencies))
 
@@ -101,17 +113,20 @@ func (s *serviceProxy) initializeAuthProviders(ctx context.Context) error {
 		}
 		providers = append(providers, provider)
 	}
+	s.authProviderChain.sort(providers)
 
-	if dependencies.tokenReviewCacheTTL > 0 {
-		klog.Infof("TokenReview cache enabled with TTL %v", dependencies.tokenReviewCacheTTL)
+	if dependencies.tokenCache != nil {
+		klog.Infof("token cache enabled: size=%d, successTTL=%v, failureTTL=%v",
+			s.tokenCache.size, s.tokenCache.successTTL, s.tokenCache.failureTTL)
 	} else {
-		klog.Infof("TokenReview cache disabled")
+		klog.Infof("token cache disabled")
 	}
 	for _, factory := range enabledFactories {
 		if logger, ok := factory.(authProviderFactoryLogger); ok {
 			logger.logConfiguration()
 		}
 	}
+	s.authProviderChain.logConfiguration(providers)
 
 	s.authProviders = providers
 	return nil
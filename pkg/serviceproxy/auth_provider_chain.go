@@ -0,0 +1,148 @@
+package serviceproxy
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+// configurableAuthProviderIDs lists the providers the chain flags accept, in
+// their default order.
+var configurableAuthProviderIDs = []authProviderID{
+	managedClusterAuthProviderID,
+	hubAuthProviderID,
+	oidcAuthProviderID,
+	webhookAuthProviderID,
+	x509AuthProviderID,
+}
+
+// issuerMatcher is implemented by providers that verify JWTs of a known
+// issuer. With issuer routing, such tokens are offered to the matching
+// provider before the configured order.
+type issuerMatcher interface {
+	matchesIssuer(issuer string) bool
+}
+
+// requestCredentialDetector is implemented by request providers to report
+// whether a request carries their credential. Providers are not tried for
+// requests without it, like token providers for requests without a token.
+type requestCredentialDetector interface {
+	hasCredential(*http.Request) bool
+}
+
+// authProviderChainOptions configures the order in which providers are tried
+// and when the chain stops early.
+type authProviderChainOptions struct {
+	order         []string
+	terminal      []string
+	issuerRouting bool
+}
+
+func (o *authProviderChainOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&o.order, "auth-provider-order", o.order, fmt.Sprintf("Comma-separated list of auth providers %v in the order they are tried. Enabled providers that are not listed are tried afterwards in the order above, except that an unlisted managed-cluster provider is always tried first.", configurableAuthProviderIDs))
+	flags.StringSliceVar(&o.terminal, "auth-provider-terminal", o.terminal, "Comma-separated list of auth providers that end the chain: when one of them is tried and does not authenticate the request, no further provider is tried.")
+	flags.BoolVar(&o.issuerRouting, "auth-provider-issuer-routing", o.issuerRouting, "Try providers that verify JWTs of a known issuer, such as OIDC, first for tokens of that issuer. The remaining providers are tried afterwards unless the matching provider is terminal.")
+}
+
+func (o authProviderChainOptions) validate() error {
+	if err := validateAuthProviderIDs("--auth-provider-order", o.order); err != nil {
+		return err
+	}
+	return validateAuthProviderIDs("--auth-provider-terminal", o.terminal)
+}
+
+func validateAuthProviderIDs(flag string, ids []string) error {
+	for i, id := range ids {
+		if !slices.Contains(configurableAuthProviderIDs, authProviderID(id)) {
+			return fmt.Errorf("%s: unknown auth provider %q, must be one of %v", flag, id, configurableAuthProviderIDs)
+		}
+		if slices.Contains(ids[:i], id) {
+			return fmt.Errorf("%s: auth provider %q is listed more than once", flag, id)
+		}
+	}
+	return nil
+}
+
+// sort orders providers by their position in the configured order. Unlisted
+// providers keep their registry order after the listed ones; an unlisted
+// managed cluster provider stays first.
+func (o authProviderChainOptions) sort(providers []authProvider) {
+	rank := func(provider authProvider) int {
+		id := provider.Metadata().id
+		if i := slices.Index(o.order, string(id)); i >= 0 {
+			return i
+		}
+		if id == managedClusterAuthProviderID {
+			return -1
+		}
+		return len(o.order)
+	}
+	slices.SortStableFunc(providers, func(a, b authProvider) int {
+		return cmp.Compare(rank(a), rank(b))
+	})
+}
+
+func (o authProviderChainOptions) isTerminal(id authProviderID) bool {
+	return slices.Contains(o.terminal, string(id))
+}
+
+// route returns providers in the order they are tried for token. With issuer
+// routing, providers matching the issuer of a JWT move to the front.
+func (o authProviderChainOptions) route(providers []authProvider, token string) []authProvider {
+	if !o.issuerRouting {
+		return providers
+	}
+	issuer := unverifiedTokenIssuer(token)
+	if issuer == "" {
+		return providers
+	}
+	matched := make([]authProvider, 0, len(providers))
+	remaining := make([]authProvider, 0, len(providers))
+	for _, provider := range providers {
+		if matcher, ok := provider.(issuerMatcher); ok && matcher.matchesIssuer(issuer) {
+			matched = append(matched, provider)
+		} else {
+			remaining = append(remaining, provider)
+		}
+	}
+	if len(matched) == 0 {
+		return providers
+	}
+	return append(matched, remaining...)
+}
+
+func (o authProviderChainOptions) logConfiguration(providers []authProvider) {
+	ids := make([]authProviderID, 0, len(providers))
+	for _, provider := range providers {
+		ids = append(ids, provider.Metadata().id)
+	}
+	klog.Infof("auth provider chain: order=%v, terminal=%v, issuerRouting=%t", ids, o.terminal, o.issuerRouting)
+}
+
+// unverifiedTokenIssuer returns the iss claim of a JWT without verifying it,
+// or an empty string for tokens that are not JWTs. The issuer is only used to
+// pick the provider that verifies the token.
+func unverifiedTokenIssuer(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	claims := struct {
+		Issuer string `json:"iss"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
@@ -0,0 +1,249 @@
+package serviceproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const testOIDCIssuer = "https://issuer.example.com"
+
+// newTestJWT returns an unsigned token carrying payload; the chain only reads
+// the issuer before a provider verifies the token.
+func newTestJWT(payload string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256"}`)) + "." + encode([]byte(payload)) + ".signature"
+}
+
+func TestAuthProviderChainOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options authProviderChainOptions
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			options: authProviderChainOptions{},
+		},
+		{
+			name:    "known providers",
+			options: authProviderChainOptions{order: []string{"webhook", "managed-cluster"}, terminal: []string{"oidc"}},
+		},
+		{
+			name:    "unknown provider in order",
+			options: authProviderChainOptions{order: []string{"ldap"}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate provider in order",
+			options: authProviderChainOptions{order: []string{"oidc", "oidc"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown terminal provider",
+			options: authProviderChainOptions{terminal: []string{""}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.options.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthProviderChainOptionsSort(t *testing.T) {
+	registry := []authProviderID{managedClusterAuthProviderID, hubAuthProviderID, oidcAuthProviderID, webhookAuthProviderID}
+	tests := []struct {
+		name  string
+		order []string
+		want  []authProviderID
+	}{
+		{
+			name: "registry order",
+			want: registry,
+		},
+		{
+			name:  "unlisted managed cluster stays first",
+			order: []string{"webhook", "oidc"},
+			want:  []authProviderID{managedClusterAuthProviderID, webhookAuthProviderID, oidcAuthProviderID, hubAuthProviderID},
+		},
+		{
+			name:  "listed managed cluster",
+			order: []string{"oidc", "managed-cluster"},
+			want:  []authProviderID{oidcAuthProviderID, managedClusterAuthProviderID, hubAuthProviderID, webhookAuthProviderID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := make([]authProvider, 0, len(registry))
+			for _, id := range registry {
+				providers = append(providers, newFakeAuthProvider(id))
+			}
+			authProviderChainOptions{order: tt.order}.sort(providers)
+
+			got := make([]authProviderID, 0, len(providers))
+			for _, provider := range providers {
+				got = append(got, provider.Metadata().id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("sorted providers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnverifiedTokenIssuer(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{name: "jwt", token: newTestJWT(`{"iss":"` + testOIDCIssuer + `"}`), want: testOIDCIssuer},
+		{name: "jwt without issuer", token: newTestJWT(`{"sub":"alice"}`)},
+		{name: "opaque token", token: "opaque-token"},
+		{name: "malformed payload", token: "a.%%%.c"},
+		{name: "payload is not json", token: newTestJWT("alice")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unverifiedTokenIssuer(tt.token); got != tt.want {
+				t.Fatalf("unverifiedTokenIssuer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessAuthentication_IssuerRouting(t *testing.T) {
+	var calls []string
+	authn := func(name string, authenticated bool) authenticator.Token {
+		return authenticator.TokenFunc(func(context.Context, string) (*authenticator.Response, bool, error) {
+			calls = append(calls, name)
+			if !authenticated {
+				return nil, false, nil
+			}
+			return &authenticator.Response{User: &user.DefaultInfo{Name: "alice"}}, true, nil
+		})
+	}
+
+	tests := []struct {
+		name          string
+		issuerRouting bool
+		token         string
+		wantCalls     []string
+	}{
+		{
+			name:      "routing disabled",
+			token:     newTestJWT(`{"iss":"` + testOIDCIssuer + `"}`),
+			wantCalls: []string{"managed cluster", "hub", "oidc"},
+		},
+		{
+			name:          "known issuer",
+			issuerRouting: true,
+			token:         newTestJWT(`{"iss":"` + testOIDCIssuer + `"}`),
+			wantCalls:     []string{"oidc"},
+		},
+		{
+			name:          "unknown issuer",
+			issuerRouting: true,
+			token:         newTestJWT(`{"iss":"https://other.example.com"}`),
+			wantCalls:     []string{"managed cluster", "hub", "oidc"},
+		},
+		{
+			name:          "opaque token",
+			issuerRouting: true,
+			token:         "opaque-token",
+			wantCalls:     []string{"managed cluster", "hub", "oidc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			s := &serviceProxy{
+				authProviderChain: authProviderChainOptions{issuerRouting: tt.issuerRouting},
+				getImpersonateTokenFunc: func() (string, error) {
+					return "fake-sa-token", nil
+				},
+			}
+			setTestAuthProviders(s, testAuthenticators{
+				managedCluster: authn("managed cluster", false),
+				hub:            authn("hub", false),
+				oidc:           authn("oidc", true),
+			})
+			s.authProviders[2].(*oidcAuthProvider).issuerURL = testOIDCIssuer
+
+			req := httptest.NewRequest(http.MethodGet, "https://example.com/api", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			if err := s.processAuthentication(req.Context(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Fatalf("provider calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestProcessAuthentication_TerminalProvider(t *testing.T) {
+	var calls []string
+	reject := func(name string) authenticator.Token {
+		return authenticator.TokenFunc(func(context.Context, string) (*authenticator.Response, bool, error) {
+			calls = append(calls, name)
+			return nil, false, errors.Join(errors.New("token expired"), ErrTokenNotAuthenticated)
+		})
+	}
+	s := &serviceProxy{
+		authProviderChain: authProviderChainOptions{
+			order:         []string{"oidc"},
+			terminal:      []string{"oidc"},
+			issuerRouting: true,
+		},
+	}
+	setTestAuthProviders(s, testAuthenticators{
+		managedCluster: reject("managed cluster"),
+		hub:            reject("hub"),
+		oidc:           reject("oidc"),
+	})
+	s.authProviders[2].(*oidcAuthProvider).issuerURL = testOIDCIssuer
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/api", nil)
+	req.Header.Set("Authorization", "Bearer "+newTestJWT(`{"iss":"`+testOIDCIssuer+`"}`))
+	err := s.processAuthentication(req.Context(), req)
+	if err == nil {
+		t.Fatal("expected authentication error")
+	}
+	if want := []string{"oidc"}; !slices.Equal(calls, want) {
+		t.Fatalf("provider calls = %v, want %v", calls, want)
+	}
+	// only the provider that was tried is reported
+	if want := "authentication failed: token is not valid for the configured OIDC issuer"; err.Error() != want {
+		t.Fatalf("error = %q, want %q", err, want)
+	}
+}
+
+func TestProcessAuthentication_NoCredentials(t *testing.T) {
+	s := &serviceProxy{}
+	setTestAuthProviders(s, testAuthenticators{
+		managedCluster: authenticator.TokenFunc(func(context.Context, string) (*authenticator.Response, bool, error) {
+			t.Fatal("token providers must not be called without a bearer token")
+			return nil, false, nil
+		}),
+	})
+	s.authProviders = append(s.authProviders, &x509AuthProvider{})
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/api", nil)
+	err := s.processAuthentication(req.Context(), req)
+	if err == nil || !strings.Contains(err.Error(), "carries no credentials") {
+		t.Fatalf("expected a missing credentials error, got %v", err)
+	}
+}
@@ -167,6 +167,36 @@ func TestInitializeAuthProvidersUsesEnabledFactoriesInRegistryOrder(t *testing.T
 	}
 }
 
+func TestInitializeAuthProvidersUsesConfiguredOrder(t *testing.T) {
+	newFactory := func(id authProviderID) authProviderFactory {
+		return fakeAuthProviderFactory{
+			enabledValue: true,
+			buildFunc: func(context.Context, authProviderDependencies) (authProvider, error) {
+				return newFakeAuthProvider(id), nil
+			},
+		}
+	}
+
+	s := &serviceProxy{
+		authProviderFactories: []authProviderFactory{
+			newFactory(hubAuthProviderID),
+			newFactory(oidcAuthProviderID),
+			newFactory(webhookAuthProviderID),
+			newFactory(x509AuthProviderID),
+		},
+		authProviderChain: authProviderChainOptions{order: []string{"webhook", "oidc"}},
+	}
+
+	if err := s.initializeAuthProviders(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []authProviderID{managedClusterAuthProviderID, webhookAuthProviderID, oidcAuthProviderID, hubAuthProviderID, x509AuthProviderID}
+	if got := s.activeAuthProviderIDs(); !slices.Equal(got, want) {
+		t.Fatalf("published providers = %v, want %v", got, want)
+	}
+}
+
 func TestInitializeAuthProvidersDoesNotPublishPartialFactoryResults(t *testing.T) {
 	buildErr := errors.New("build failed")
 	existing := newFakeAuthProvider("existing")
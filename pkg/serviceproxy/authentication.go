@@ -5,24 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"slices"
 	"strings"
 
 	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/klog/v2"
 )
 
-func (s *serviceProxy) readImpersonateTokenFromFile() (string, error) {
-	// Read the latest token from the mounted file
-	token, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
-	if err != nil {
-		return "", err
-	}
-	return string(token), nil
-}
-
 // impersonateHeaderPrefix is the common prefix of the impersonation headers declared by
 // authenticationv1: Impersonate-User, Impersonate-Group, Impersonate-Uid and Impersonate-Extra-<key>.
 const impersonateHeaderPrefix = "Impersonate-"
@@ -55,17 +46,50 @@ func deleteImpersonationHeaders(headers http.Header) {
 // Infrastructure errors stop the flow; only an unauthenticated result falls
 // through to the next provider.
 func (s *serviceProxy) processAuthentication(ctx context.Context, req *http.Request) error {
+	provider, info, err := s.authenticateRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if err := provider.ApplyIdentity(ctx, req, info); err != nil {
+		return fmt.Errorf("failed to apply %s identity: %v", provider.Metadata().displayName, err)
+	}
+	return nil
+}
+
+// authenticateRequest returns the first provider accepting the request
+// together with the user it authenticated. Providers implementing
+// authenticator.Request authenticate the request itself; the others only see
+// the bearer token and are skipped when there is none. A terminal provider
+// that is tried without authenticating the request ends the chain.
+func (s *serviceProxy) authenticateRequest(ctx context.Context, req *http.Request) (authProvider, user.Info, error) {
 	logger := klog.FromContext(ctx)
 	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
 
-	for _, provider := range s.authProviders {
+	tried := make([]authProviderMetadata, 0, len(s.authProviders))
+	for _, provider := range s.authProviderChain.route(s.authProviders, token) {
 		metadata := provider.Metadata()
-		resp, authenticated, err := provider.AuthenticateToken(ctx, token)
+		var (
+			resp          *authenticator.Response
+			authenticated bool
+			err           error
+		)
+		if requestAuthenticator, ok := provider.(authenticator.Request); ok {
+			if detector, ok := provider.(requestCredentialDetector); ok && !detector.hasCredential(req) {
+				continue
+			}
+			resp, authenticated, err = requestAuthenticator.AuthenticateRequest(req)
+		} else if token != "" {
+			resp, authenticated, err = provider.AuthenticateToken(ctx, token)
+		} else {
+			continue
+		}
+		tried = append(tried, metadata)
 		if err != nil {
 			if errors.Is(err, ErrTokenNotAuthenticated) {
 				authenticated = false
 			} else {
-				return fmt.Errorf("%s authentication failed: %v", metadata.displayName, err)
+				return nil, nil, fmt.Errorf("%s authentication failed: %v", metadata.displayName, err)
 			}
 		}
 
@@ -74,37 +98,44 @@ func (s *serviceProxy) processAuthentication(ctx context.Context, req *http.Requ
 			"authenticated", authenticated,
 		)
 
-		if !authenticated {
-			continue
+		if authenticated {
+			return provider, resp.User, nil
 		}
-
-		if err := provider.ApplyIdentity(ctx, req, resp.User); err != nil {
-			return fmt.Errorf("failed to apply %s identity: %v", metadata.displayName, err)
+		if s.authProviderChain.isTerminal(metadata.id) {
+			logger.V(4).Info("terminal provider did not authenticate the request", "provider", metadata.id)
+			break
 		}
-		return nil
 	}
 
-	return s.authenticationFailureError()
+	return nil, nil, s.authenticationFailureError(tried)
 }
 
-func (s *serviceProxy) authenticationFailureError() error {
-	if len(s.authProviders) == 0 {
-		return errors.New("authentication failed: token is not valid for the managed cluster")
+// effectiveIdentity returns the identity under which an authenticated user is
+// presented on the managed cluster.
+func effectiveIdentity(provider authProvider, info user.Info) user.Info {
+	if mapper, ok := provider.(identityMapper); ok {
+		return mapper.MapIdentity(info)
 	}
+	return info
+}
 
-	metadata := make([]authProviderMetadata, 0, len(s.authProviders))
-	for _, provider := range s.authProviders {
-		metadata = append(metadata, provider.Metadata())
+// authenticationFailureError describes the providers that were tried and
+// did not authenticate the request.
+func (s *serviceProxy) authenticationFailureError(tried []authProviderMetadata) error {
+	if len(s.authProviders) == 0 {
+		return errors.New("authentication failed: token is not valid for the managed cluster")
 	}
 
-	switch len(metadata) {
+	switch len(tried) {
+	case 0:
+		return errors.New("authentication failed: the request carries no credentials")
 	case 1:
-		return fmt.Errorf("authentication failed: token is not valid for %s", metadata[0].standaloneTarget())
+		return fmt.Errorf("authentication failed: token is not valid for %s", tried[0].standaloneTarget())
 	case 2:
-		return fmt.Errorf("authentication failed: token is neither valid for %s nor %s", metadata[0].authenticationTarget, metadata[1].authenticationTarget)
+		return fmt.Errorf("authentication failed: token is neither valid for %s nor %s", tried[0].authenticationTarget, tried[1].authenticationTarget)
 	default:
-		targets := make([]string, 0, len(metadata))
-		for _, providerMetadata := range metadata {
+		targets := make([]string, 0, len(tried))
+		for _, providerMetadata := range tried {
 			targets = append(targets, providerMetadata.authenticationTarget)
 		}
 		return fmt.Errorf("authentication failed: token is not valid for %s, or %s",
@@ -116,22 +147,45 @@ func (s *serviceProxy) authenticationFailureError() error {
 
 type impersonateUserFunc func(context.Context, *http.Request, string, []string) error
 
-// applyExternalIdentity carries an identity that is unknown to the managed cluster
-// into the request via impersonation headers.
-func applyExternalIdentity(ctx context.Context, req *http.Request, externalUser user.Info, impersonateUser impersonateUserFunc) error {
+// identityMapper is implemented by providers whose authenticated users are
+// not known to the managed cluster and are therefore presented under a
+// derived identity.
+type identityMapper interface {
+	MapIdentity(user.Info) user.Info
+}
+
+// externalIdentity returns an identity that is unknown to the managed cluster
+// as a member of system:authenticated.
+func externalIdentity(externalUser user.Info) user.Info {
 	groups := slices.Clone(externalUser.GetGroups())
 	if !slices.Contains(groups, user.AllAuthenticated) {
 		groups = append(groups, user.AllAuthenticated)
 	}
-	return impersonateUser(ctx, req, externalUser.GetName(), groups)
+	return &user.DefaultInfo{
+		Name:   externalUser.GetName(),
+		UID:    externalUser.GetUID(),
+		Groups: groups,
+		Extra:  externalUser.GetExtra(),
+	}
+}
+
+// applyExternalIdentity carries an identity that is unknown to the managed cluster
+// into the request via impersonation headers.
+func applyExternalIdentity(ctx context.Context, req *http.Request, externalUser user.Info, impersonateUser impersonateUserFunc) error {
+	info := externalIdentity(externalUser)
+	return impersonateUser(ctx, req, info.GetName(), info.GetGroups())
 }
 
 // impersonateUser sets the impersonation headers for the given identity and
-// replaces the original token with the cluster-proxy service-account token
-// which has impersonate permission.
+// replaces the original token with the impersonator token, whose service
+// account has impersonate permission.
 func (s *serviceProxy) impersonateUser(ctx context.Context, req *http.Request, username string, groups []string) error {
 	logger := klog.FromContext(ctx)
 
+	if err := s.impersonator.allows(username, groups); err != nil {
+		return err
+	}
+
 	// Ensure no client-supplied impersonation values survive when applying the authenticated identity.
 	deleteImpersonationHeaders(req.Header)
 	for _, group := range groups {
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 const hubAuthProviderID authProviderID = "hub"
@@ -23,6 +26,9 @@ var hubAuthProviderMetadata = authProviderMetadata{
 
 type hubAuthProvider struct {
 	authenticator.Token
+	// issuer returns the hub service account issuer when tokens are verified
+	// locally; it is nil for TokenReview verification.
+	issuer          func() string
 	impersonateUser impersonateUserFunc
 }
 
@@ -31,28 +37,65 @@ func (*hubAuthProvider) Metadata() authProviderMetadata {
 }
 
 func (p *hubAuthProvider) ApplyIdentity(ctx context.Context, req *http.Request, hubUser user.Info) error {
+	info := p.MapIdentity(hubUser)
+	return p.impersonateUser(ctx, req, info.GetName(), info.GetGroups())
+}
+
+func (p *hubAuthProvider) matchesIssuer(issuer string) bool {
+	return p.issuer != nil && issuer == p.issuer()
+}
+
+// MapIdentity namespaces hub service accounts so they cannot collide with
+// service accounts of the managed cluster.
+func (*hubAuthProvider) MapIdentity(hubUser user.Info) user.Info {
 	username := hubUser.GetName()
 	if strings.HasPrefix(username, "system:serviceaccount:") {
 		username = fmt.Sprintf("cluster:hub:%s", username)
 	}
-	return p.impersonateUser(ctx, req, username, hubUser.GetGroups())
+	return &user.DefaultInfo{
+		Name:   username,
+		UID:    hubUser.GetUID(),
+		Groups: hubUser.GetGroups(),
+		Extra:  hubUser.GetExtra(),
+	}
 }
 
 type hubAuthProviderFactory struct {
-	enableImpersonation bool
-	kubeConfig          string
+	enableImpersonation  bool
+	kubeConfig           string
+	tokenVerification    string
+	serviceAccountIssuer string
+	tokenAudiences       []string
+	jwksRefreshInterval  time.Duration
 }
 
 func newHubAuthProviderFactory() *hubAuthProviderFactory {
-	return &hubAuthProviderFactory{enableImpersonation: true}
+	return &hubAuthProviderFactory{
+		enableImpersonation: true,
+		tokenVerification:   hubTokenVerificationTokenReview,
+		jwksRefreshInterval: defaultHubJWKSRefreshInterval,
+	}
 }
 
 func (f *hubAuthProviderFactory) addFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&f.kubeConfig, "hub-kubeconfig", f.kubeConfig, "The kubeconfig file for connecting to the hub cluster")
 	flags.BoolVar(&f.enableImpersonation, "enable-impersonation", f.enableImpersonation, "Enable hub token authentication")
+	flags.StringVar(&f.tokenVerification, "hub-token-verification", f.tokenVerification, "How hub tokens are verified: 'tokenreview' sends every token to the hub TokenReview API; 'local' verifies bound service account tokens against the hub's published signing keys and falls back to TokenReview for other tokens.")
+	flags.StringVar(&f.serviceAccountIssuer, "hub-service-account-issuer", f.serviceAccountIssuer, "The issuer of hub service account tokens verified locally. Defaults to the issuer in the hub's OIDC discovery document.")
+	flags.StringSliceVar(&f.tokenAudiences, "hub-token-audiences", f.tokenAudiences, "Comma-separated list of audiences hub tokens must be issued for, such as cluster-proxy.open-cluster-management.io. If unset, TokenReview accepts tokens for the hub kube-apiserver audiences and local verification accepts tokens for the hub service account issuer.")
+	flags.DurationVar(&f.jwksRefreshInterval, "hub-jwks-refresh-interval", f.jwksRefreshInterval, "How often the hub service account signing keys are refreshed when --hub-token-verification=local.")
 }
 
-func (*hubAuthProviderFactory) validate() error {
+func (f *hubAuthProviderFactory) validate() error {
+	switch f.tokenVerification {
+	case hubTokenVerificationTokenReview:
+	case hubTokenVerificationLocal:
+		if f.jwksRefreshInterval <= 0 {
+			return fmt.Errorf("--hub-jwks-refresh-interval must be positive")
+		}
+	default:
+		return fmt.Errorf("--hub-token-verification must be %q or %q", hubTokenVerificationTokenReview, hubTokenVerificationLocal)
+	}
 	return nil
 }
 
@@ -67,7 +110,7 @@ func (f *hubAuthProviderFactory) configFiles() []string {
 	return []string{f.kubeConfig}
 }
 
-func (f *hubAuthProviderFactory) build(_ context.Context, dependencies authProviderDependencies) (authProvider, error) {
+func (f *hubAuthProviderFactory) build(ctx context.Context, dependencies authProviderDependencies) (authProvider, error) {
 	hubConfig, err := clientcmd.BuildConfigFromFlags("", f.kubeConfig)
 	if err != nil {
 		return nil, err
@@ -80,17 +123,50 @@ func (f *hubAuthProviderFactory) build(_ context.Context, dependencies authProvi
 		return nil, err
 	}
 
+	authn := newTokenReviewAuthenticator(
+		hubKubeClient,
+		hubAuthProviderMetadata.displayName,
+		f.tokenAudiences,
+	)
+	var issuer func() string
+	if f.tokenVerification == hubTokenVerificationLocal {
+		// the keys are fetched on the request path when a token references an
+		// unknown key, so a hub that does not answer must not stall requests
+		jwksConfig := rest.CopyConfig(hubConfig)
+		jwksConfig.Timeout = hubJWKSFetchTimeout
+		jwksClient, err := kubernetes.NewForConfig(jwksConfig)
+		if err != nil {
+			return nil, err
+		}
+		verifier := newHubTokenVerifier(
+			func(ctx context.Context, path string) ([]byte, error) {
+				return jwksClient.Discovery().RESTClient().Get().AbsPath(path).DoRaw(ctx)
+			},
+			authn,
+			f.serviceAccountIssuer,
+			f.tokenAudiences,
+			f.jwksRefreshInterval,
+		)
+		verifier.start(ctx)
+		authn = verifier
+		issuer = verifier.currentIssuer
+	}
+
 	return &hubAuthProvider{
-		Token: newTokenReviewAuthenticator(
-			hubKubeClient,
-			hubAuthProviderMetadata.displayName,
-			dependencies.tokenReviewCacheTTL,
-		),
+		Token:           dependencies.tokenCache.wrap(hubAuthProviderID, authn),
+		issuer:          issuer,
 		impersonateUser: dependencies.impersonateUser,
 	}, nil
 }
 
+func (f *hubAuthProviderFactory) logConfiguration() {
+	klog.Infof("hub token verification: %s", f.tokenVerification)
+}
+
 var (
-	_ authProvider        = (*hubAuthProvider)(nil)
-	_ authProviderFactory = (*hubAuthProviderFactory)(nil)
+	_ authProvider              = (*hubAuthProvider)(nil)
+	_ identityMapper            = (*hubAuthProvider)(nil)
+	_ issuerMatcher             = (*hubAuthProvider)(nil)
+	_ authProviderFactory       = (*hubAuthProviderFactory)(nil)
+	_ authProviderFactoryLogger = (*hubAuthProviderFactory)(nil)
 )
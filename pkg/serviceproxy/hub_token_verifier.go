@@ -0,0 +1,302 @@
+package serviceproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+)
+
+const (
+	hubTokenVerificationTokenReview = "tokenreview"
+	hubTokenVerificationLocal       = "local"
+
+	// hubOIDCDiscoveryPath and hubJWKSPath are served by every kube-apiserver
+	// with ServiceAccountIssuerDiscovery. The JWKS is fetched from the apiserver
+	// itself because the jwks_uri of the discovery document is often not
+	// reachable from managed clusters.
+	hubOIDCDiscoveryPath = "/.well-known/openid-configuration"
+	hubJWKSPath          = "/openid/v1/jwks"
+
+	defaultHubJWKSRefreshInterval = 5 * time.Minute
+	// hubJWKSMinRefreshInterval limits refreshes triggered by tokens signed with
+	// an unknown key.
+	hubJWKSMinRefreshInterval = 10 * time.Second
+	// hubJWKSFetchTimeout bounds each request for the discovery document or
+	// the keys.
+	hubJWKSFetchTimeout = 10 * time.Second
+)
+
+// hubServiceAccountSigningAlgs are the algorithms the kube-apiserver signs
+// service account tokens with.
+var hubServiceAccountSigningAlgs = []jose.SignatureAlgorithm{jose.RS256, jose.ES256, jose.ES384, jose.ES512}
+
+// hubDocumentFetcher returns the body of a GET request to a hub apiserver path.
+type hubDocumentFetcher func(ctx context.Context, path string) ([]byte, error)
+
+type hubServiceAccountClaims struct {
+	jwt.Claims
+	Kubernetes *hubServiceAccountKubernetesClaims `json:"kubernetes.io,omitempty"`
+}
+
+type hubServiceAccountKubernetesClaims struct {
+	Namespace      string                      `json:"namespace"`
+	ServiceAccount hubServiceAccountObjectRef  `json:"serviceaccount"`
+	Pod            *hubServiceAccountObjectRef `json:"pod,omitempty"`
+	Node           *hubServiceAccountObjectRef `json:"node,omitempty"`
+}
+
+type hubServiceAccountObjectRef struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// hubTokenVerifier verifies bound service account tokens of the hub against
+// the hub's published signing keys. Tokens it cannot verify locally, such as
+// legacy Secret-based tokens, tokens of other issuers, opaque tokens, or tokens
+// signed with a key that is not published yet, are passed to the fallback
+// TokenReview authenticator.
+//
+// Unlike a TokenReview, local verification does not observe the deletion of
+// the pod or service account a token is bound to; such a token stays valid
+// until it expires.
+type hubTokenVerifier struct {
+	fetch           hubDocumentFetcher
+	fallback        authenticator.Token
+	issuer          string
+	audiences       []string
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	// refreshes shares one in-flight key refresh between concurrent callers.
+	refreshes   singleflight.Group
+	refreshMu   sync.Mutex
+	lastRefresh time.Time
+
+	mu              sync.RWMutex
+	keys            *jose.JSONWebKeySet
+	activeIssuer    string
+	activeAudiences []string
+}
+
+func newHubTokenVerifier(
+	fetch hubDocumentFetcher,
+	fallback authenticator.Token,
+	issuer string,
+	audiences []string,
+	refreshInterval time.Duration,
+) *hubTokenVerifier {
+	return &hubTokenVerifier{
+		fetch:           fetch,
+		fallback:        fallback,
+		issuer:          issuer,
+		audiences:       audiences,
+		refreshInterval: refreshInterval,
+		now:             time.Now,
+	}
+}
+
+// start loads the hub keys and refreshes them periodically until ctx is done.
+// A hub that cannot be reached only delays local verification; requests are
+// authenticated with TokenReview in the meantime.
+func (v *hubTokenVerifier) start(ctx context.Context) {
+	if err := v.refresh(ctx); err != nil {
+		klog.FromContext(ctx).Error(err, "failed to load hub service account keys, falling back to TokenReview")
+	}
+	go wait.JitterUntilWithContext(ctx, func(ctx context.Context) {
+		if err := v.refresh(ctx); err != nil {
+			klog.FromContext(ctx).Error(err, "failed to refresh hub service account keys")
+		}
+	}, v.refreshInterval, 0.1, false)
+}
+
+func (v *hubTokenVerifier) refresh(ctx context.Context) error {
+	return v.sharedRefresh(ctx, false)
+}
+
+// sharedRefresh reloads the keys, unless rateLimited and the last refresh is
+// more recent than hubJWKSMinRefreshInterval. Concurrent callers share a
+// single fetch, which is detached from the first caller's cancellation; each
+// caller still stops waiting on its own.
+func (v *hubTokenVerifier) sharedRefresh(ctx context.Context, rateLimited bool) error {
+	result := v.refreshes.DoChan("refresh", func() (interface{}, error) {
+		if rateLimited {
+			v.refreshMu.Lock()
+			recent := v.now().Sub(v.lastRefresh) < hubJWKSMinRefreshInterval
+			v.refreshMu.Unlock()
+			if recent {
+				return nil, nil
+			}
+		}
+		return nil, v.load(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case shared := <-result:
+		return shared.Err
+	}
+}
+
+func (v *hubTokenVerifier) load(ctx context.Context) error {
+	v.refreshMu.Lock()
+	v.lastRefresh = v.now()
+	v.refreshMu.Unlock()
+
+	issuer := v.issuer
+	if issuer == "" {
+		data, err := v.fetch(ctx, hubOIDCDiscoveryPath)
+		if err != nil {
+			return fmt.Errorf("failed to get hub service account issuer discovery: %w", err)
+		}
+		discovery := struct {
+			Issuer string `json:"issuer"`
+		}{}
+		if err := json.Unmarshal(data, &discovery); err != nil {
+			return fmt.Errorf("failed to parse hub service account issuer discovery: %w", err)
+		}
+		if discovery.Issuer == "" {
+			return fmt.Errorf("hub service account issuer discovery does not contain an issuer")
+		}
+		issuer = discovery.Issuer
+	}
+
+	data, err := v.fetch(ctx, hubJWKSPath)
+	if err != nil {
+		return fmt.Errorf("failed to get hub service account keys: %w", err)
+	}
+	keys := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(data, keys); err != nil {
+		return fmt.Errorf("failed to parse hub service account keys: %w", err)
+	}
+
+	audiences := v.audiences
+	if len(audiences) == 0 {
+		// bound tokens requested without an audience carry the apiserver's
+		// default audience, which is the issuer unless configured otherwise
+		audiences = []string{issuer}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.activeIssuer = issuer
+	v.activeAudiences = audiences
+	v.mu.Unlock()
+	return nil
+}
+
+// refreshForUnknownKey reloads the keys after a token referenced an unknown
+// key, at most once per hubJWKSMinRefreshInterval.
+func (v *hubTokenVerifier) refreshForUnknownKey(ctx context.Context) {
+	if err := v.sharedRefresh(ctx, true); err != nil {
+		klog.FromContext(ctx).Error(err, "failed to refresh hub service account keys")
+	}
+}
+
+func (v *hubTokenVerifier) snapshot() (*jose.JSONWebKeySet, string, []string) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.keys, v.activeIssuer, v.activeAudiences
+}
+
+// currentIssuer returns the issuer of the loaded hub keys, or an empty string
+// before the keys are loaded.
+func (v *hubTokenVerifier) currentIssuer() string {
+	keys, issuer, _ := v.snapshot()
+	if keys == nil {
+		return ""
+	}
+	return issuer
+}
+
+func (v *hubTokenVerifier) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	keys, issuer, audiences := v.snapshot()
+	if keys == nil {
+		return v.fallback.AuthenticateToken(ctx, token)
+	}
+
+	parsed, err := jwt.ParseSigned(token, hubServiceAccountSigningAlgs)
+	if err != nil {
+		return v.fallback.AuthenticateToken(ctx, token)
+	}
+	unverified := hubServiceAccountClaims{}
+	if err := parsed.UnsafeClaimsWithoutVerification(&unverified); err != nil ||
+		unverified.Issuer != issuer || unverified.Kubernetes == nil {
+		return v.fallback.AuthenticateToken(ctx, token)
+	}
+
+	keyID := parsed.Headers[0].KeyID
+	if len(keys.Key(keyID)) == 0 {
+		v.refreshForUnknownKey(ctx)
+		keys, issuer, audiences = v.snapshot()
+		if len(keys.Key(keyID)) == 0 {
+			klog.FromContext(ctx).V(4).Info("hub service account token signed with an unknown key, falling back to TokenReview", "kid", keyID)
+			return v.fallback.AuthenticateToken(ctx, token)
+		}
+	}
+
+	claims := hubServiceAccountClaims{}
+	if err := parsed.Claims(keys.Key(keyID)[0].Key, &claims); err != nil {
+		return nil, false, fmt.Errorf("hub service account token: %v: %w", err, ErrTokenNotAuthenticated)
+	}
+	if claims.Expiry == nil {
+		return nil, false, fmt.Errorf("hub service account token has no expiry: %w", ErrTokenNotAuthenticated)
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      issuer,
+		AnyAudience: audiences,
+		Time:        v.now(),
+	}, jwt.DefaultLeeway); err != nil {
+		return nil, false, fmt.Errorf("hub service account token: %v: %w", err, ErrTokenNotAuthenticated)
+	}
+
+	info, err := claims.userInfo()
+	if err != nil {
+		return nil, false, fmt.Errorf("hub service account token: %v: %w", err, ErrTokenNotAuthenticated)
+	}
+	return &authenticator.Response{
+		Audiences: authenticator.Audiences(claims.Audience),
+		User:      info,
+	}, true, nil
+}
+
+// userInfo returns the identity a TokenReview of the hub reports for the
+// token.
+func (c hubServiceAccountClaims) userInfo() (user.Info, error) {
+	k := c.Kubernetes
+	if k.Namespace == "" || k.ServiceAccount.Name == "" || k.ServiceAccount.UID == "" {
+		return nil, fmt.Errorf("token does not identify a service account")
+	}
+
+	extra := map[string][]string{}
+	if k.Pod != nil {
+		extra[serviceaccount.PodNameKey] = []string{k.Pod.Name}
+		extra[serviceaccount.PodUIDKey] = []string{k.Pod.UID}
+	}
+	if k.Node != nil {
+		extra[serviceaccount.NodeNameKey] = []string{k.Node.Name}
+		extra[serviceaccount.NodeUIDKey] = []string{k.Node.UID}
+	}
+	if c.ID != "" {
+		extra[user.CredentialIDKey] = []string{"JTI=" + c.ID}
+	}
+
+	return &user.DefaultInfo{
+		Name:   serviceaccount.MakeUsername(k.Namespace, k.ServiceAccount.Name),
+		UID:    k.ServiceAccount.UID,
+		Groups: append(serviceaccount.MakeGroupNames(k.Namespace), user.AllAuthenticated),
+		Extra:  extra,
+	}, nil
+}
+
+var _ authenticator.Token = (*hubTokenVerifier)(nil)
@@ -0,0 +1,329 @@
+package serviceproxy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const testHubIssuer = "https://kubernetes.default.svc"
+
+type testHubSigner struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+func newTestHubSigner(t *testing.T, keyID string) testHubSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return testHubSigner{key: key, keyID: keyID}
+}
+
+func (s testHubSigner) publicKey() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: &s.key.PublicKey, KeyID: s.keyID, Algorithm: string(jose.RS256), Use: "sig"}
+}
+
+func (s testHubSigner) sign(t *testing.T, claims any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: s.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", s.keyID),
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func boundServiceAccountClaims(issuer string, expiry time.Time) hubServiceAccountClaims {
+	return hubServiceAccountClaims{
+		Claims: jwt.Claims{
+			Issuer:   issuer,
+			Subject:  "system:serviceaccount:team-a:deployer",
+			Audience: jwt.Audience{testHubIssuer},
+			Expiry:   jwt.NewNumericDate(expiry),
+			IssuedAt: jwt.NewNumericDate(expiry.Add(-time.Hour)),
+			ID:       "a1b2",
+		},
+		Kubernetes: &hubServiceAccountKubernetesClaims{
+			Namespace:      "team-a",
+			ServiceAccount: hubServiceAccountObjectRef{Name: "deployer", UID: "sa-uid"},
+			Pod:            &hubServiceAccountObjectRef{Name: "deployer-0", UID: "pod-uid"},
+		},
+	}
+}
+
+// fakeHubDocuments serves the discovery document and the JWKS of the
+// currently published keys.
+type fakeHubDocuments struct {
+	keys        atomic.Pointer[jose.JSONWebKeySet]
+	jwksFetches atomic.Int32
+}
+
+func (f *fakeHubDocuments) fetch(_ context.Context, path string) ([]byte, error) {
+	switch path {
+	case hubOIDCDiscoveryPath:
+		return json.Marshal(map[string]string{"issuer": testHubIssuer, "jwks_uri": testHubIssuer + hubJWKSPath})
+	case hubJWKSPath:
+		f.jwksFetches.Add(1)
+		return json.Marshal(f.keys.Load())
+	}
+	return nil, fmt.Errorf("unexpected path %s", path)
+}
+
+type countingAuthenticator struct {
+	calls atomic.Int32
+}
+
+func (a *countingAuthenticator) AuthenticateToken(context.Context, string) (*authenticator.Response, bool, error) {
+	a.calls.Add(1)
+	return &authenticator.Response{User: &user.DefaultInfo{Name: "tokenreview-user"}}, true, nil
+}
+
+func TestHubTokenVerifier(t *testing.T) {
+	signer := newTestHubSigner(t, "key-1")
+	otherIssuer := newTestHubSigner(t, "key-1")
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		token        func(t *testing.T) string
+		wantUser     string
+		wantFallback bool
+		wantRejected bool
+	}{
+		{
+			name: "bound token verified locally",
+			token: func(t *testing.T) string {
+				return signer.sign(t, boundServiceAccountClaims(testHubIssuer, now.Add(time.Hour)))
+			},
+			wantUser: "system:serviceaccount:team-a:deployer",
+		},
+		{
+			name: "legacy token falls back",
+			token: func(t *testing.T) string {
+				return signer.sign(t, jwt.Claims{Issuer: "kubernetes/serviceaccount", Subject: "system:serviceaccount:team-a:legacy"})
+			},
+			wantFallback: true,
+		},
+		{
+			name:         "opaque token falls back",
+			token:        func(*testing.T) string { return "sha256~opaque" },
+			wantFallback: true,
+		},
+		{
+			name: "expired token is rejected",
+			token: func(t *testing.T) string {
+				return signer.sign(t, boundServiceAccountClaims(testHubIssuer, now.Add(-time.Hour)))
+			},
+			wantRejected: true,
+		},
+		{
+			name: "wrong audience is rejected",
+			token: func(t *testing.T) string {
+				claims := boundServiceAccountClaims(testHubIssuer, now.Add(time.Hour))
+				claims.Audience = jwt.Audience{"vault"}
+				return signer.sign(t, claims)
+			},
+			wantRejected: true,
+		},
+		{
+			name: "forged signature is rejected",
+			token: func(t *testing.T) string {
+				return otherIssuer.sign(t, boundServiceAccountClaims(testHubIssuer, now.Add(time.Hour)))
+			},
+			wantRejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents := &fakeHubDocuments{}
+			documents.keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{signer.publicKey()}})
+			fallback := &countingAuthenticator{}
+			verifier := newHubTokenVerifier(documents.fetch, fallback, "", nil, time.Hour)
+			if err := verifier.refresh(t.Context()); err != nil {
+				t.Fatalf("failed to load keys: %v", err)
+			}
+
+			resp, authenticated, err := verifier.AuthenticateToken(t.Context(), tt.token(t))
+			if tt.wantRejected {
+				if !errors.Is(err, ErrTokenNotAuthenticated) || authenticated {
+					t.Fatalf("expected rejection, got authenticated=%t err=%v", authenticated, err)
+				}
+				return
+			}
+			if err != nil || !authenticated {
+				t.Fatalf("unexpected result: authenticated=%t err=%v", authenticated, err)
+			}
+			if gotFallback := fallback.calls.Load() > 0; gotFallback != tt.wantFallback {
+				t.Fatalf("fallback used = %t, want %t", gotFallback, tt.wantFallback)
+			}
+			if tt.wantFallback {
+				return
+			}
+
+			info := resp.User
+			if info.GetName() != tt.wantUser || info.GetUID() != "sa-uid" {
+				t.Fatalf("unexpected user: %q (%q)", info.GetName(), info.GetUID())
+			}
+			wantGroups := []string{"system:serviceaccounts", "system:serviceaccounts:team-a", user.AllAuthenticated}
+			if !slices.Equal(info.GetGroups(), wantGroups) {
+				t.Fatalf("groups = %v, want %v", info.GetGroups(), wantGroups)
+			}
+			if got := info.GetExtra()["authentication.kubernetes.io/pod-name"]; !slices.Equal(got, []string{"deployer-0"}) {
+				t.Fatalf("pod-name extra = %v", got)
+			}
+		})
+	}
+}
+
+func TestHubTokenVerifierRefreshesUnknownKeys(t *testing.T) {
+	oldKey := newTestHubSigner(t, "old")
+	newKey := newTestHubSigner(t, "new")
+	documents := &fakeHubDocuments{}
+	documents.keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey.publicKey()}})
+	fallback := &countingAuthenticator{}
+
+	now := time.Now()
+	verifier := newHubTokenVerifier(documents.fetch, fallback, testHubIssuer, nil, time.Hour)
+	verifier.now = func() time.Time { return now }
+	if err := verifier.refresh(t.Context()); err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	// the hub rotated its signing key
+	documents.keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey.publicKey(), newKey.publicKey()}})
+	token := newKey.sign(t, boundServiceAccountClaims(testHubIssuer, now.Add(time.Hour)))
+
+	// a refresh just happened, so the token is reviewed by the hub instead
+	if _, authenticated, err := verifier.AuthenticateToken(t.Context(), token); err != nil || !authenticated || fallback.calls.Load() != 1 {
+		t.Fatalf("expected a TokenReview fallback, got authenticated=%t err=%v fallbacks=%d", authenticated, err, fallback.calls.Load())
+	}
+
+	now = now.Add(hubJWKSMinRefreshInterval)
+	resp, authenticated, err := verifier.AuthenticateToken(t.Context(), token)
+	if err != nil || !authenticated || fallback.calls.Load() != 1 {
+		t.Fatalf("expected local verification after refresh, got authenticated=%t err=%v fallbacks=%d", authenticated, err, fallback.calls.Load())
+	}
+	if resp.User.GetName() != "system:serviceaccount:team-a:deployer" {
+		t.Fatalf("unexpected user %q", resp.User.GetName())
+	}
+	if got := documents.jwksFetches.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestHubTokenVerifierSharesRefreshes(t *testing.T) {
+	oldKey := newTestHubSigner(t, "old")
+	newKey := newTestHubSigner(t, "new")
+	documents := &fakeHubDocuments{}
+	documents.keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey.publicKey()}})
+	fallback := &countingAuthenticator{}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var blocked atomic.Bool
+	fetch := func(ctx context.Context, path string) ([]byte, error) {
+		if path == hubJWKSPath && blocked.CompareAndSwap(true, false) {
+			close(started)
+			<-release
+		}
+		return documents.fetch(ctx, path)
+	}
+
+	now := time.Now()
+	verifier := newHubTokenVerifier(fetch, fallback, testHubIssuer, nil, time.Hour)
+	verifier.now = func() time.Time { return now }
+	if err := verifier.refresh(t.Context()); err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+	documents.keys.Store(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey.publicKey(), newKey.publicKey()}})
+	token := newKey.sign(t, boundServiceAccountClaims(testHubIssuer, now.Add(time.Hour)))
+	now = now.Add(hubJWKSMinRefreshInterval)
+	blocked.Store(true)
+
+	// a caller giving up does not cancel the fetch the others wait for
+	canceled, cancel := context.WithCancel(t.Context())
+	canceledDone := make(chan struct{})
+	go func() {
+		defer close(canceledDone)
+		_, _, _ = verifier.AuthenticateToken(canceled, token)
+	}()
+	<-started
+	cancel()
+	<-canceledDone
+
+	const callers = 5
+	var wg sync.WaitGroup
+	var authenticated atomic.Int32
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := verifier.AuthenticateToken(t.Context(), token); err == nil && ok {
+				authenticated.Add(1)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if got := authenticated.Load(); got != callers {
+		t.Fatalf("%d of %d callers authenticated", got, callers)
+	}
+	if got := documents.jwksFetches.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestHubTokenVerifierWithoutKeysFallsBack(t *testing.T) {
+	fallback := &countingAuthenticator{}
+	verifier := newHubTokenVerifier(func(context.Context, string) ([]byte, error) {
+		return nil, errors.New("forbidden")
+	}, fallback, "", nil, time.Hour)
+	verifier.start(t.Context())
+
+	if _, authenticated, err := verifier.AuthenticateToken(t.Context(), "token"); err != nil || !authenticated || fallback.calls.Load() != 1 {
+		t.Fatalf("expected TokenReview fallback, got authenticated=%t err=%v", authenticated, err)
+	}
+}
+
+func TestHubAuthProviderFactoryValidateTokenVerification(t *testing.T) {
+	factory := newHubAuthProviderFactory()
+	if err := factory.validate(); err != nil {
+		t.Fatalf("default configuration is invalid: %v", err)
+	}
+	factory.tokenVerification = hubTokenVerificationLocal
+	if err := factory.validate(); err != nil {
+		t.Fatalf("local verification is invalid: %v", err)
+	}
+	factory.jwksRefreshInterval = 0
+	if err := factory.validate(); err == nil {
+		t.Fatal("expected an error for a non-positive refresh interval")
+	}
+	factory.tokenVerification = "jwks"
+	if err := factory.validate(); err == nil {
+		t.Fatal("expected an error for an unknown verification mode")
+	}
+}
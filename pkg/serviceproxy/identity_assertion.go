@@ -0,0 +1,199 @@
+package serviceproxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	// identityAssertionJWKSPath is served on the TLS listener so that backends
+	// can fetch the keys verifying identity assertions.
+	identityAssertionJWKSPath = "/.well-known/jwks.json"
+
+	// headerForwardedUser and headerForwardedGroups carry the authenticated
+	// identity in plain text to trusted backends only.
+	headerForwardedUser   = "X-Forwarded-User"
+	headerForwardedGroups = "X-Forwarded-Groups"
+
+	defaultIdentityAssertionSigningSecret = "cluster-proxy-identity-assertion-signing-key"
+	defaultIdentityAssertionTokenTTL      = 5 * time.Minute
+
+	identityAssertionSigningAlgorithm = jose.ES256
+	identityAssertionClockSkew        = 30 * time.Second
+)
+
+type identityAssertionOptions struct {
+	enabled         bool
+	issuer          string
+	signingSecret   string
+	tokenTTL        time.Duration
+	trustedBackends []string
+}
+
+func newIdentityAssertionOptions() identityAssertionOptions {
+	return identityAssertionOptions{
+		signingSecret: defaultIdentityAssertionSigningSecret,
+		tokenTTL:      defaultIdentityAssertionTokenTTL,
+	}
+}
+
+func (o *identityAssertionOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.enabled, "enable-identity-assertion", o.enabled, "Authenticate requests to services other than the kube-apiserver and replace their credential with a short-lived JWT asserting the caller's identity.")
+	flags.StringVar(&o.issuer, "identity-assertion-issuer", o.issuer, "The iss claim of identity assertion tokens. Required when --enable-identity-assertion is set.")
+	flags.StringVar(&o.signingSecret, "identity-assertion-signing-secret", o.signingSecret, "The name of the Secret in POD_NAMESPACE holding the identity assertion signing key. The Secret is created with a new ECDSA P-256 key when it does not exist.")
+	flags.DurationVar(&o.tokenTTL, "identity-assertion-token-ttl", o.tokenTTL, "The lifetime of identity assertion tokens.")
+	flags.StringSliceVar(&o.trustedBackends, "identity-assertion-trusted-backends", o.trustedBackends, "Comma-separated list of <namespace>/<service> backends that additionally receive the identity in X-Forwarded-User and X-Forwarded-Groups headers.")
+}
+
+func (o identityAssertionOptions) validate() error {
+	if !o.enabled {
+		return nil
+	}
+	if o.issuer == "" {
+		return fmt.Errorf("--identity-assertion-issuer is required when identity assertion is enabled")
+	}
+	if o.signingSecret == "" {
+		return fmt.Errorf("--identity-assertion-signing-secret must not be empty")
+	}
+	if o.tokenTTL <= 0 {
+		return fmt.Errorf("--identity-assertion-token-ttl must be positive")
+	}
+	for _, backend := range o.trustedBackends {
+		if _, _, err := splitTrustedBackend(backend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitTrustedBackend(backend string) (string, string, error) {
+	namespace, service, ok := strings.Cut(backend, "/")
+	if !ok || namespace == "" || service == "" || strings.Contains(service, "/") {
+		return "", "", fmt.Errorf("invalid identity assertion trusted backend %q, expected <namespace>/<service>", backend)
+	}
+	return namespace, service, nil
+}
+
+// identityAssertionClaims is the payload of an identity assertion token. The
+// subject is the username the managed cluster would impersonate.
+type identityAssertionClaims struct {
+	jwt.Claims
+	Groups []string `json:"groups,omitempty"`
+}
+
+// identityAsserter signs identity assertions with the per-cluster key and
+// publishes the matching JWKS.
+type identityAsserter struct {
+	issuer          string
+	tokenTTL        time.Duration
+	trustedBackends sets.Set[string]
+	signer          jose.Signer
+	jwks            []byte
+	now             func() time.Time
+}
+
+func newIdentityAsserter(key *ecdsa.PrivateKey, opts identityAssertionOptions) (*identityAsserter, error) {
+	publicKey := jose.JSONWebKey{
+		Key:       &key.PublicKey,
+		Algorithm: string(identityAssertionSigningAlgorithm),
+		Use:       "sig",
+	}
+	thumbprint, err := publicKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute identity assertion key ID: %w", err)
+	}
+	publicKey.KeyID = fmt.Sprintf("%x", thumbprint)
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: identityAssertionSigningAlgorithm, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", publicKey.KeyID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create identity assertion signer: %w", err)
+	}
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{publicKey}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal identity assertion JWKS: %w", err)
+	}
+
+	trustedBackends := sets.New[string]()
+	for _, backend := range opts.trustedBackends {
+		namespace, service, err := splitTrustedBackend(backend)
+		if err != nil {
+			return nil, err
+		}
+		trustedBackends.Insert(backendAudience(namespace, service))
+	}
+
+	return &identityAsserter{
+		issuer:          opts.issuer,
+		tokenTTL:        opts.tokenTTL,
+		trustedBackends: trustedBackends,
+		signer:          signer,
+		jwks:            jwks,
+		now:             time.Now,
+	}, nil
+}
+
+// backendAudience is the aud claim of assertions sent to a Service, matching
+// the host the service-proxy forwards to.
+func backendAudience(namespace, service string) string {
+	return fmt.Sprintf("%s.%s.svc", service, namespace)
+}
+
+// apply replaces the caller's credential with an assertion of info scoped to
+// the target audience. Client-supplied forwarded identity headers never reach
+// the backend.
+func (a *identityAsserter) apply(req *http.Request, audience string, info user.Info) error {
+	now := a.now()
+	token, err := jwt.Signed(a.signer).Claims(identityAssertionClaims{
+		Claims: jwt.Claims{
+			Issuer:    a.issuer,
+			Subject:   info.GetName(),
+			Audience:  jwt.Audience{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now.Add(-identityAssertionClockSkew)),
+			Expiry:    jwt.NewNumericDate(now.Add(a.tokenTTL)),
+		},
+		Groups: info.GetGroups(),
+	}).Serialize()
+	if err != nil {
+		return fmt.Errorf("failed to sign identity assertion: %w", err)
+	}
+
+	req.Header.Del(headerForwardedUser)
+	req.Header.Del(headerForwardedGroups)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if a.trustedBackends.Has(audience) {
+		req.Header.Set(headerForwardedUser, info.GetName())
+		for _, group := range info.GetGroups() {
+			req.Header.Add(headerForwardedGroups, group)
+		}
+	}
+	return nil
+}
+
+func (a *identityAsserter) jwksHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/jwk-set+json")
+		writer.Header().Set("Cache-Control", "public, max-age=300")
+		_, _ = writer.Write(a.jwks)
+	})
+}
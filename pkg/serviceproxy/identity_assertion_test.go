@@ -0,0 +1,267 @@
+package serviceproxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	k8stesting "k8s.io/client-go/testing"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+func newTestIdentityAsserter(t *testing.T, trustedBackends ...string) *identityAsserter {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	opts := newIdentityAssertionOptions()
+	opts.enabled = true
+	opts.issuer = "cluster-proxy:cluster1"
+	opts.trustedBackends = trustedBackends
+	asserter, err := newIdentityAsserter(key, opts)
+	if err != nil {
+		t.Fatalf("failed to create identity asserter: %v", err)
+	}
+	return asserter
+}
+
+func verifyIdentityAssertion(t *testing.T, asserter *identityAsserter, header string, audience string) identityAssertionClaims {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	asserter.jwksHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, identityAssertionJWKSPath, nil))
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(recorder.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("failed to decode JWKS: %v", err)
+	}
+
+	token, err := jwt.ParseSigned(strings.TrimPrefix(header, "Bearer "), []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse assertion: %v", err)
+	}
+	var claims identityAssertionClaims
+	if err := token.Claims(jwks, &claims); err != nil {
+		t.Fatalf("assertion does not verify against the published JWKS: %v", err)
+	}
+	if err := claims.Validate(jwt.Expected{
+		Issuer:      "cluster-proxy:cluster1",
+		AnyAudience: jwt.Audience{audience},
+		Time:        time.Now(),
+	}); err != nil {
+		t.Fatalf("invalid assertion claims: %v", err)
+	}
+	return claims
+}
+
+func TestIdentityAsserterApply(t *testing.T) {
+	tests := []struct {
+		name            string
+		trustedBackends []string
+		wantForwarded   bool
+	}{
+		{
+			name: "untrusted backend receives only the assertion",
+		},
+		{
+			name:            "trusted backend also receives forwarded headers",
+			trustedBackends: []string{"monitoring/grafana"},
+			wantForwarded:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asserter := newTestIdentityAsserter(t, tt.trustedBackends...)
+			req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/dashboards", nil)
+			req.Header.Set("Authorization", "Bearer original")
+			req.Header.Set(headerForwardedUser, "spoofed")
+			req.Header.Add(headerForwardedGroups, "spoofed-group")
+
+			info := &user.DefaultInfo{Name: "alice", Groups: []string{"dev", "ops"}}
+			if err := asserter.apply(req, "grafana.monitoring.svc", info); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			claims := verifyIdentityAssertion(t, asserter, req.Header.Get("Authorization"), "grafana.monitoring.svc")
+			if claims.Subject != "alice" || !slices.Equal(claims.Groups, []string{"dev", "ops"}) {
+				t.Fatalf("unexpected claims: sub=%q groups=%v", claims.Subject, claims.Groups)
+			}
+
+			gotUser, gotGroups := req.Header.Get(headerForwardedUser), req.Header.Values(headerForwardedGroups)
+			if tt.wantForwarded {
+				if gotUser != "alice" || !slices.Equal(gotGroups, []string{"dev", "ops"}) {
+					t.Fatalf("unexpected forwarded identity: user=%q groups=%v", gotUser, gotGroups)
+				}
+			} else if gotUser != "" || len(gotGroups) != 0 {
+				t.Fatalf("forwarded identity must not reach untrusted backends: user=%q groups=%v", gotUser, gotGroups)
+			}
+		})
+	}
+}
+
+func TestIdentityAssertionOptionsValidate(t *testing.T) {
+	valid := newIdentityAssertionOptions()
+	valid.enabled = true
+	valid.issuer = "cluster-proxy:cluster1"
+
+	tests := []struct {
+		name    string
+		modify  func(*identityAssertionOptions)
+		wantErr bool
+	}{
+		{name: "disabled", modify: func(o *identityAssertionOptions) { *o = newIdentityAssertionOptions() }},
+		{name: "valid", modify: func(o *identityAssertionOptions) { o.trustedBackends = []string{"monitoring/grafana"} }},
+		{name: "missing issuer", modify: func(o *identityAssertionOptions) { o.issuer = "" }, wantErr: true},
+		{name: "non-positive ttl", modify: func(o *identityAssertionOptions) { o.tokenTTL = 0 }, wantErr: true},
+		{name: "malformed backend", modify: func(o *identityAssertionOptions) { o.trustedBackends = []string{"grafana"} }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			if err := opts.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServeHTTPAssertsIdentityForServices(t *testing.T) {
+	var backendRequest *http.Request
+	s := &serviceProxy{
+		identityAsserter: newTestIdentityAsserter(t),
+		proxyTransport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			backendRequest = req
+			return (&recordingRoundTripper{}).RoundTrip(req)
+		}),
+	}
+	s.authProviders = []authProvider{
+		&hubAuthProvider{
+			Token: authenticator.TokenFunc(func(_ context.Context, token string) (*authenticator.Response, bool, error) {
+				if token != "hub-token" {
+					return nil, false, nil
+				}
+				return &authenticator.Response{User: &user.DefaultInfo{Name: "system:serviceaccount:ns:sa"}}, true, nil
+			}),
+		},
+	}
+
+	newRequest := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/dashboards", nil)
+		req.Header.Set(utils.HeaderClusterProxyProto, "http")
+		req.Header.Set(utils.HeaderClusterProxyNamespace, "monitoring")
+		req.Header.Set(utils.HeaderClusterProxyService, "grafana")
+		req.Header.Set(utils.HeaderClusterProxyPort, "3000")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, newRequest("unknown"))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status for unauthenticated request: %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, newRequest("hub-token"))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", recorder.Code, recorder.Body.String())
+	}
+	claims := verifyIdentityAssertion(t, s.identityAsserter, backendRequest.Header.Get("Authorization"), "grafana.monitoring.svc")
+	if claims.Subject != "cluster:hub:system:serviceaccount:ns:sa" {
+		t.Fatalf("assertion subject = %q, want the hub-mapped identity", claims.Subject)
+	}
+}
+
+func TestPublicHandlerServesJWKSAndMetricsWithoutTarget(t *testing.T) {
+	proxied := false
+	metricsAllowed := false
+	agentClient := newFakeClient(true, "system:serviceaccount:monitoring:prometheus", []string{"system:authenticated"})
+	agentClient.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = metricsAllowed && review.Spec.User == "system:serviceaccount:monitoring:prometheus" &&
+			review.Spec.NonResourceAttributes.Path == "/metrics" && review.Spec.NonResourceAttributes.Verb == "get"
+		return true, review, nil
+	})
+	s := &serviceProxy{
+		agentKubeClient:  agentClient,
+		identityAsserter: newTestIdentityAsserter(t),
+		proxyTransport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			proxied = true
+			return (&recordingRoundTripper{}).RoundTrip(req)
+		}),
+	}
+	s.authProviders = []authProvider{
+		&hubAuthProvider{
+			Token: authenticator.TokenFunc(func(_ context.Context, _ string) (*authenticator.Response, bool, error) {
+				return &authenticator.Response{User: &user.DefaultInfo{Name: "alice"}}, true, nil
+			}),
+		},
+	}
+	handler := s.publicHandler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "https://service-proxy.example"+identityAssertionJWKSPath, nil))
+	var jwks jose.JSONWebKeySet
+	if recorder.Code != http.StatusOK || json.Unmarshal(recorder.Body.Bytes(), &jwks) != nil || len(jwks.Keys) != 1 {
+		t.Fatalf("unexpected JWKS response: %d: %s", recorder.Code, recorder.Body.String())
+	}
+	// the metrics require an identity allowed to get /metrics
+	metricsStatus := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	if status := metricsStatus(""); status != http.StatusUnauthorized {
+		t.Fatalf("unexpected metrics status without a token: %d", status)
+	}
+	if status := metricsStatus("prometheus"); status != http.StatusForbidden {
+		t.Fatalf("unexpected metrics status without permission: %d", status)
+	}
+	metricsAllowed = true
+	if status := metricsStatus("prometheus"); status != http.StatusOK {
+		t.Fatalf("unexpected metrics status: %d", status)
+	}
+	if proxied {
+		t.Fatal("requests without a target must not be proxied")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example"+identityAssertionJWKSPath, nil)
+	req.Header.Set(utils.HeaderClusterProxyProto, "http")
+	req.Header.Set(utils.HeaderClusterProxyNamespace, "monitoring")
+	req.Header.Set(utils.HeaderClusterProxyService, "grafana")
+	req.Header.Set(utils.HeaderClusterProxyPort, "3000")
+	req.Header.Set("Authorization", "Bearer token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !proxied {
+		t.Fatal("requests with a target must be proxied")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (roundTripperFunc) CloseIdleConnections() {}
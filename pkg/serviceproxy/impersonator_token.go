@@ -0,0 +1,252 @@
+package serviceproxy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	defaultImpersonatorTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// minImpersonatorTokenExpiration is the shortest lifetime the TokenRequest API accepts.
+	minImpersonatorTokenExpiration = 10 * time.Minute
+)
+
+// impersonatorOptions selects the token service-proxy presents when it
+// impersonates an authenticated identity.
+type impersonatorOptions struct {
+	tokenFile      string
+	serviceAccount string
+	audiences      []string
+	expiration     time.Duration
+
+	// allowedUsers and allowedGroups limit the identities service-proxy
+	// impersonates to exact names; empty allows all.
+	allowedUsers  []string
+	allowedGroups []string
+}
+
+func newImpersonatorOptions() impersonatorOptions {
+	return impersonatorOptions{
+		tokenFile:  defaultImpersonatorTokenFile,
+		expiration: time.Hour,
+	}
+}
+
+func (o *impersonatorOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.tokenFile, "impersonator-token-file", o.tokenFile, "The path to the token presented with impersonation headers. The file is watched and reloaded when it changes, such as when the kubelet rotates a projected token.")
+	flags.StringVar(&o.serviceAccount, "impersonator-service-account", o.serviceAccount, "The name of a ServiceAccount in POD_NAMESPACE whose tokens are requested with the TokenRequest API and presented with impersonation headers instead of --impersonator-token-file. The service-proxy ServiceAccount needs create permission on its serviceaccounts/token subresource.")
+	flags.StringSliceVar(&o.audiences, "impersonator-token-audiences", o.audiences, "Comma-separated audiences of the tokens requested for --impersonator-service-account. Empty requests tokens for the kube-apiserver audiences.")
+	flags.DurationVar(&o.expiration, "impersonator-token-expiration", o.expiration, "The lifetime of the tokens requested for --impersonator-service-account. Tokens are renewed after 80% of their lifetime.")
+	flags.StringSliceVar(&o.allowedUsers, "impersonator-allowed-users", o.allowedUsers, "Comma-separated usernames service-proxy may impersonate. Prefixes are not supported since the impersonate rule could only grant them by allowing every username. Empty allows every username. Requests for other users are rejected before they reach the kube-apiserver.")
+	flags.StringSliceVar(&o.allowedGroups, "impersonator-allowed-groups", o.allowedGroups, "Comma-separated groups service-proxy may impersonate. system:authenticated, which every authenticated identity carries, is always allowed. Empty allows every group. Requests carrying other groups are rejected before they reach the kube-apiserver.")
+}
+
+func (o impersonatorOptions) validate() error {
+	for _, name := range slices.Concat(o.allowedUsers, o.allowedGroups) {
+		if name == "" || strings.Contains(name, "*") {
+			// RBAC resourceNames match exact names only, so a wildcard could
+			// not be granted without granting every name
+			return fmt.Errorf("--impersonator-allowed-users and --impersonator-allowed-groups must contain exact names, got %q", name)
+		}
+	}
+	if o.serviceAccount == "" {
+		return nil
+	}
+	if o.tokenFile != "" && o.tokenFile != defaultImpersonatorTokenFile {
+		return fmt.Errorf("--impersonator-service-account and --impersonator-token-file are mutually exclusive")
+	}
+	if o.expiration < minImpersonatorTokenExpiration {
+		return fmt.Errorf("--impersonator-token-expiration must be at least %v", minImpersonatorTokenExpiration)
+	}
+	return nil
+}
+
+// allows returns an error when the identity is outside the allowed users or
+// groups, mirroring the resourceNames of the impersonator ClusterRole so the
+// rejection is explicit rather than a kube-apiserver 403.
+func (o impersonatorOptions) allows(username string, groups []string) error {
+	if len(o.allowedUsers) > 0 && !slices.Contains(o.allowedUsers, username) {
+		return fmt.Errorf("user %q is not allowed to be impersonated", username)
+	}
+	for _, group := range groups {
+		// the ClusterRole always grants system:authenticated
+		if len(o.allowedGroups) > 0 && group != user.AllAuthenticated && !slices.Contains(o.allowedGroups, group) {
+			return fmt.Errorf("group %q is not allowed to be impersonated", group)
+		}
+	}
+	return nil
+}
+
+// start returns the function reading the current impersonator token.
+func (o impersonatorOptions) start(ctx context.Context, client kubernetes.Interface, namespace string) (func() (string, error), error) {
+	if o.serviceAccount != "" {
+		source := newServiceAccountTokenSource(client, namespace, o.serviceAccount, o.audiences, o.expiration)
+		// request the first token now so missing permissions fail the start
+		if _, err := source.token(ctx); err != nil {
+			return nil, err
+		}
+		klog.Infof("impersonating with ServiceAccount %s/%s: audiences=%v, expiration=%v", namespace, o.serviceAccount, o.audiences, o.expiration)
+		return func() (string, error) { return source.token(ctx) }, nil
+	}
+
+	source := &fileTokenSource{path: cmp.Or(o.tokenFile, defaultImpersonatorTokenFile)}
+	if err := source.start(ctx); err != nil {
+		return nil, err
+	}
+	klog.Infof("impersonating with token file %s", source.path)
+	return source.token, nil
+}
+
+// fileTokenSource caches a token file and reloads it when the file changes.
+// The parent directory is watched because the kubelet updates projected and
+// Secret volumes by swapping a symlink rather than writing the file.
+type fileTokenSource struct {
+	path string
+
+	mu    sync.RWMutex
+	value string
+}
+
+func (s *fileTokenSource) start(ctx context.Context) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch impersonator token: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("failed to watch impersonator token: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// keep serving the last token if the new one cannot be read
+				if err := s.load(); err != nil {
+					klog.Errorf("failed to reload impersonator token: %v", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("impersonator token watch error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *fileTokenSource) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read impersonator token: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return fmt.Errorf("impersonator token file %s is empty", s.path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value != s.value {
+		klog.V(4).Infof("impersonator token loaded from %s", s.path)
+	}
+	s.value = value
+	return nil
+}
+
+func (s *fileTokenSource) token() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value, nil
+}
+
+// serviceAccountTokenSource requests tokens of a dedicated ServiceAccount and
+// renews them after 80% of their lifetime.
+type serviceAccountTokenSource struct {
+	client     kubernetes.Interface
+	namespace  string
+	name       string
+	audiences  []string
+	expiration time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	value     string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+func newServiceAccountTokenSource(
+	client kubernetes.Interface,
+	namespace, name string,
+	audiences []string,
+	expiration time.Duration,
+) *serviceAccountTokenSource {
+	return &serviceAccountTokenSource{
+		client:     client,
+		namespace:  namespace,
+		name:       name,
+		audiences:  audiences,
+		expiration: expiration,
+		now:        time.Now,
+	}
+}
+
+func (s *serviceAccountTokenSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.value != "" && now.Before(s.refreshAt) {
+		return s.value, nil
+	}
+
+	tokenRequest, err := s.client.CoreV1().ServiceAccounts(s.namespace).CreateToken(ctx, s.name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         s.audiences,
+			ExpirationSeconds: ptr.To(int64(s.expiration.Seconds())),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		// a failed renewal must not interrupt requests while the token is valid
+		if s.value != "" && now.Before(s.expiresAt) {
+			klog.Errorf("failed to renew impersonator token, using the current token until it expires at %v: %v", s.expiresAt, err)
+			return s.value, nil
+		}
+		return "", fmt.Errorf("failed to request token for ServiceAccount %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	// the apiserver may shorten the lifetime, so renew relative to the issued expiry
+	s.value = tokenRequest.Status.Token
+	s.expiresAt = tokenRequest.Status.ExpirationTimestamp.Time
+	s.refreshAt = now.Add(s.expiresAt.Sub(now) * 4 / 5)
+	klog.V(4).Infof("impersonator token for ServiceAccount %s/%s renewed, expires at %v", s.namespace, s.name, s.expiresAt)
+	return s.value, nil
+}
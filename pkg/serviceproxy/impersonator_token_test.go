@@ -0,0 +1,245 @@
+package serviceproxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestImpersonatorOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*impersonatorOptions)
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			mutate: func(*impersonatorOptions) {},
+		},
+		{
+			name: "exact names",
+			mutate: func(o *impersonatorOptions) {
+				o.allowedUsers = []string{"alice"}
+				o.allowedGroups = []string{"cluster:hub:system:serviceaccounts"}
+			},
+		},
+		{
+			name:   "service account",
+			mutate: func(o *impersonatorOptions) { o.serviceAccount = "cluster-proxy-impersonator" },
+		},
+		{
+			name: "service account with token file",
+			mutate: func(o *impersonatorOptions) {
+				o.serviceAccount = "cluster-proxy-impersonator"
+				o.tokenFile = "/impersonator/token"
+			},
+			wantErr: true,
+		},
+		{
+			name: "short expiration",
+			mutate: func(o *impersonatorOptions) {
+				o.serviceAccount = "cluster-proxy-impersonator"
+				o.expiration = time.Minute
+			},
+			wantErr: true,
+		},
+		{
+			name:    "bare wildcard user",
+			mutate:  func(o *impersonatorOptions) { o.allowedUsers = []string{"*"} },
+			wantErr: true,
+		},
+		{
+			name:    "prefix group",
+			mutate:  func(o *impersonatorOptions) { o.allowedGroups = []string{"cluster:hub:*"} },
+			wantErr: true,
+		},
+		{
+			name:    "empty group",
+			mutate:  func(o *impersonatorOptions) { o.allowedGroups = []string{"system:authenticated", ""} },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := newImpersonatorOptions()
+			tt.mutate(&options)
+			if err := options.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImpersonatorOptionsAllows(t *testing.T) {
+	options := impersonatorOptions{
+		allowedUsers:  []string{"cluster:hub:system:serviceaccount:team-a:deployer", "alice"},
+		allowedGroups: []string{"cluster:hub:system:serviceaccounts"},
+	}
+	tests := []struct {
+		name     string
+		username string
+		groups   []string
+		wantErr  bool
+	}{
+		{
+			name:     "exact user",
+			username: "alice",
+			groups:   []string{"system:authenticated"},
+		},
+		{
+			name:     "listed user and group",
+			username: "cluster:hub:system:serviceaccount:team-a:deployer",
+			groups:   []string{"cluster:hub:system:serviceaccounts", "system:authenticated"},
+		},
+		{
+			name:     "user outside the allowlist",
+			username: "bob",
+			wantErr:  true,
+		},
+		{
+			name:     "entries are not prefixes",
+			username: "cluster:hub:system:serviceaccount:team-a:deployer-2",
+			wantErr:  true,
+		},
+		{
+			name:     "group outside the allowlist",
+			username: "alice",
+			groups:   []string{"system:authenticated", "system:masters"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := options.allows(tt.username, tt.groups); (err != nil) != tt.wantErr {
+				t.Fatalf("allows() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := (impersonatorOptions{}).allows("bob", []string{"system:masters"}); err != nil {
+		t.Fatalf("empty allowlists must allow every identity: %v", err)
+	}
+}
+
+func TestFileTokenSourceReloadsRotatedToken(t *testing.T) {
+	// mimic the kubelet, which swaps the ..data symlink of projected volumes
+	dir := t.TempDir()
+	writeVersion := func(version, token string) {
+		t.Helper()
+		versionDir := filepath.Join(dir, version)
+		if err := os.Mkdir(versionDir, 0700); err != nil {
+			t.Fatalf("failed to create %s: %v", versionDir, err)
+		}
+		if err := os.WriteFile(filepath.Join(versionDir, "token"), []byte(token), 0600); err != nil {
+			t.Fatalf("failed to write token: %v", err)
+		}
+		tmpLink := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(version, tmpLink); err != nil {
+			t.Fatalf("failed to link %s: %v", version, err)
+		}
+		if err := os.Rename(tmpLink, filepath.Join(dir, "..data")); err != nil {
+			t.Fatalf("failed to swap ..data: %v", err)
+		}
+	}
+	writeVersion("v1", "first-token\n")
+	if err := os.Symlink(filepath.Join("..data", "token"), filepath.Join(dir, "token")); err != nil {
+		t.Fatalf("failed to link token: %v", err)
+	}
+
+	source := &fileTokenSource{path: filepath.Join(dir, "token")}
+	if err := source.start(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token, _ := source.token(); token != "first-token" {
+		t.Fatalf("token = %q, want first-token", token)
+	}
+
+	writeVersion("v2", "second-token")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if token, _ := source.token(); token == "second-token" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rotated token was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFileTokenSourceMissingFile(t *testing.T) {
+	source := &fileTokenSource{path: filepath.Join(t.TempDir(), "token")}
+	if err := source.start(t.Context()); err == nil {
+		t.Fatal("expected an error for a missing token file")
+	}
+}
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var (
+		requests int
+		failNext bool
+	)
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "serviceaccounts", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		createAction := action.(clienttesting.CreateActionImpl)
+		if createAction.GetNamespace() != "addon" || createAction.Name != "cluster-proxy-impersonator" {
+			t.Fatalf("unexpected token request for %s/%s", createAction.GetNamespace(), createAction.Name)
+		}
+		request := createAction.GetObject().(*authenticationv1.TokenRequest)
+		if *request.Spec.ExpirationSeconds != 3600 || len(request.Spec.Audiences) != 1 || request.Spec.Audiences[0] != "https://kubernetes.default.svc" {
+			t.Fatalf("unexpected token request spec %+v", request.Spec)
+		}
+		if failNext {
+			return true, nil, errors.New("apiserver unavailable")
+		}
+		requests++
+		request.Status = authenticationv1.TokenRequestStatus{
+			Token:               "token-" + string(rune('0'+requests)),
+			ExpirationTimestamp: metav1.NewTime(now.Add(time.Hour)),
+		}
+		return true, request, nil
+	})
+
+	source := newServiceAccountTokenSource(client, "addon", "cluster-proxy-impersonator", []string{"https://kubernetes.default.svc"}, time.Hour)
+	clock := now
+	source.now = func() time.Time { return clock }
+
+	expectToken := func(want string) {
+		t.Helper()
+		token, err := source.token(t.Context())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != want {
+			t.Fatalf("token = %q, want %q", token, want)
+		}
+	}
+
+	expectToken("token-1")
+	clock = now.Add(47 * time.Minute)
+	expectToken("token-1")
+
+	// renewal failures keep the current token until it expires
+	clock = now.Add(50 * time.Minute)
+	failNext = true
+	expectToken("token-1")
+	clock = now.Add(61 * time.Minute)
+	if _, err := source.token(t.Context()); err == nil {
+		t.Fatal("expected an error once the current token expired")
+	}
+
+	failNext = false
+	expectToken("token-2")
+}
@@ -0,0 +1,108 @@
+package serviceproxy
+
+import (
+	"cmp"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
+	certutil "k8s.io/client-go/util/cert"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+const defaultKubeAPIServerURL = "https://" + utils.KubeAPIServerHost
+
+// kubeAPIServerOptions selects the kube-apiserver that requests for the
+// managed cluster API are proxied to. Hosted control planes serve the API
+// outside the cluster the agent runs in.
+type kubeAPIServerOptions struct {
+	url    string
+	caFile string
+}
+
+func (o *kubeAPIServerOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.url, "kube-apiserver-url", o.url, "The https URL kube-apiserver requests are proxied to, such as the endpoint of a hosted control plane. Empty uses "+defaultKubeAPIServerURL+". The token presented with impersonation headers must be accepted by this kube-apiserver.")
+	flags.StringVar(&o.caFile, "kube-apiserver-ca-file", o.caFile, "The CA bundle verifying --kube-apiserver-url. Empty uses the in-cluster CA and --additional-service-ca. The file is watched and service-proxy restarts when it changes.")
+}
+
+func (o kubeAPIServerOptions) validate() error {
+	_, err := o.target()
+	return err
+}
+
+// target returns the parsed kube-apiserver URL.
+func (o kubeAPIServerOptions) target() (*url.URL, error) {
+	target, err := url.Parse(cmp.Or(o.url, defaultKubeAPIServerURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --kube-apiserver-url: %w", err)
+	}
+	if target.Scheme != "https" || target.Host == "" {
+		return nil, fmt.Errorf("--kube-apiserver-url must be an https URL")
+	}
+	if (target.Path != "" && target.Path != "/") || target.RawQuery != "" || target.User != nil {
+		return nil, fmt.Errorf("--kube-apiserver-url must not contain a path, query or user information")
+	}
+	return &url.URL{Scheme: target.Scheme, Host: target.Host}, nil
+}
+
+// tlsConfig returns the client configuration verifying the kube-apiserver, or
+// nil when the default trust roots apply.
+func (o kubeAPIServerOptions) tlsConfig() (*tls.Config, error) {
+	if o.caFile == "" {
+		return nil, nil
+	}
+	rootCAs, err := certutil.NewPool(o.caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load --kube-apiserver-ca-file: %w", err)
+	}
+	return &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// initKubeAPIServerTarget parses the kube-apiserver URL and, when it has its
+// own CA, builds the transport verifying it.
+func (s *serviceProxy) initKubeAPIServerTarget() error {
+	target, err := s.kubeAPIServer.target()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := s.kubeAPIServer.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.kubeAPIServerTarget = target
+	if tlsConfig != nil {
+		s.kubeAPIServerTransport = s.newTargetTransport(tlsConfig)
+	}
+	return nil
+}
+
+// managedClusterRestConfig returns the client configuration for the managed
+// cluster API. Without --kube-apiserver-url that is the in-cluster
+// configuration; otherwise it is the configured kube-apiserver, verified by
+// the same CA and authenticated with the same token as proxied requests.
+func (s *serviceProxy) managedClusterRestConfig(inClusterConfig *rest.Config) (*rest.Config, error) {
+	if s.kubeAPIServer.url == "" {
+		return inClusterConfig, nil
+	}
+	tlsConfig, err := s.kubeAPIServer.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := s.newHTTPTransport()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &rest.Config{
+		Host:            s.kubeAPIServerTarget.String(),
+		Transport:       transport,
+		BearerTokenFile: cmp.Or(s.impersonator.tokenFile, defaultImpersonatorTokenFile),
+		QPS:             s.kubeClientQPS,
+		Burst:           s.kubeClientBurst,
+	}, nil
+}
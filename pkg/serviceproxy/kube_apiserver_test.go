@@ -0,0 +1,141 @@
+package serviceproxy
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+func TestKubeAPIServerOptionsValidate(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: ""},
+		{url: defaultKubeAPIServerURL},
+		{url: "https://api.hosted.example.com:6443"},
+		{url: "https://10.0.0.1:6443/"},
+		{url: "http://api.hosted.example.com", wantErr: true},
+		{url: "https://", wantErr: true},
+		{url: "https://api.hosted.example.com/prefix", wantErr: true},
+		{url: "https://admin@api.hosted.example.com", wantErr: true},
+	}
+	for _, test := range tests {
+		opts := kubeAPIServerOptions{url: test.url}
+		if err := opts.validate(); (err != nil) != test.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %t", test.url, err, test.wantErr)
+		}
+	}
+}
+
+func TestServeHTTPProxiesToConfiguredKubeAPIServer(t *testing.T) {
+	var backendPath string
+	apiserver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiserver.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: apiserver.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+
+	s := &serviceProxy{
+		kubeAPIServer: kubeAPIServerOptions{url: apiserver.URL, caFile: caFile},
+		// the default transport does not trust the hosted kube-apiserver
+		proxyTransport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			t.Errorf("unexpected request to %s through the default transport", req.URL)
+			return (&recordingRoundTripper{}).RoundTrip(req)
+		}),
+	}
+	if err := s.initKubeAPIServerTarget(); err != nil {
+		t.Fatalf("failed to initialize the kube-apiserver target: %v", err)
+	}
+	defer s.closeIdleConnections()
+
+	req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/api/v1/namespaces", nil)
+	req.Header.Set(utils.HeaderClusterProxyProto, "https")
+	req.Header.Set(utils.HeaderClusterProxyNamespace, "default")
+	req.Header.Set(utils.HeaderClusterProxyService, "kubernetes")
+	req.Header.Set(utils.HeaderClusterProxyPort, "443")
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if backendPath != "/api/v1/namespaces" {
+		t.Fatalf("backend path = %q, want /api/v1/namespaces", backendPath)
+	}
+}
+
+func TestManagedClusterTokenReviewsUseConfiguredKubeAPIServer(t *testing.T) {
+	var reviewPath, authorization string
+	apiserver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reviewPath = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&authenticationv1.TokenReview{
+			Status: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authenticationv1.UserInfo{Username: "system:serviceaccount:default:reader"},
+			},
+		})
+	}))
+	defer apiserver.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: apiserver.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("hosted-token"), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+
+	s := &serviceProxy{
+		kubeAPIServer: kubeAPIServerOptions{url: apiserver.URL, caFile: caFile},
+		impersonator:  impersonatorOptions{tokenFile: tokenFile},
+	}
+	if err := s.initKubeAPIServerTarget(); err != nil {
+		t.Fatalf("failed to initialize the kube-apiserver target: %v", err)
+	}
+	defer s.closeIdleConnections()
+
+	// the in-cluster configuration must not be used for a configured URL
+	config, err := s.managedClusterRestConfig(nil)
+	if err != nil {
+		t.Fatalf("failed to build the managed cluster config: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to build the managed cluster client: %v", err)
+	}
+
+	response, ok, err := newTokenReviewAuthenticator(client, "managed cluster", nil).AuthenticateToken(t.Context(), "user-token")
+	if err != nil || !ok {
+		t.Fatalf("expected the token to be authenticated, got ok=%v err=%v", ok, err)
+	}
+	if response.User.GetName() != "system:serviceaccount:default:reader" {
+		t.Errorf("unexpected user %q", response.User.GetName())
+	}
+	if reviewPath != "/apis/authentication.k8s.io/v1/tokenreviews" {
+		t.Errorf("TokenReview path = %q", reviewPath)
+	}
+	if authorization != "Bearer hosted-token" {
+		t.Errorf("TokenReview authorization = %q, want the impersonator token", authorization)
+	}
+}
@@ -31,11 +31,11 @@ func (*managedClusterAuthProvider) ApplyIdentity(context.Context, *http.Request,
 
 func newManagedClusterAuthProvider(dependencies authProviderDependencies) authProvider {
 	return &managedClusterAuthProvider{
-		Token: newTokenReviewAuthenticator(
+		Token: dependencies.tokenCache.wrap(managedClusterAuthProviderID, newTokenReviewAuthenticator(
 			dependencies.managedClusterKubeClient,
 			managedClusterAuthProviderMetadata.displayName,
-			dependencies.tokenReviewCacheTTL,
-		),
+			dependencies.managedClusterTokenAudiences,
+		)),
 	}
 }
 
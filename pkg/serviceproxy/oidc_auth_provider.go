@@ -22,6 +22,7 @@ var oidcAuthProviderMetadata = authProviderMetadata{
 
 type oidcAuthProvider struct {
 	authenticator.Token
+	issuerURL       string
 	impersonateUser impersonateUserFunc
 }
 
@@ -33,6 +34,14 @@ func (p *oidcAuthProvider) ApplyIdentity(ctx context.Context, req *http.Request,
 	return applyExternalIdentity(ctx, req, info, p.impersonateUser)
 }
 
+func (*oidcAuthProvider) MapIdentity(info user.Info) user.Info {
+	return externalIdentity(info)
+}
+
+func (p *oidcAuthProvider) matchesIssuer(issuer string) bool {
+	return issuer == p.issuerURL
+}
+
 type oidcAuthProviderFactory struct {
 	options oidcOptions
 }
@@ -80,7 +89,7 @@ func (f *oidcAuthProviderFactory) build(ctx context.Context, dependencies authPr
 	if f.options.caConfigMap != "" {
 		if err := startOIDCCAConfigMapController(
 			ctx,
-			dependencies.managedClusterKubeClient,
+			dependencies.agentKubeClient,
 			dependencies.podNamespace,
 			f.options.caConfigMap,
 			authn,
@@ -90,7 +99,8 @@ func (f *oidcAuthProviderFactory) build(ctx context.Context, dependencies authPr
 	}
 
 	return &oidcAuthProvider{
-		Token:           authn,
+		Token:           dependencies.tokenCache.wrap(oidcAuthProviderID, authn),
+		issuerURL:       f.options.issuerURL,
 		impersonateUser: dependencies.impersonateUser,
 	}, nil
 }
@@ -101,5 +111,7 @@ func (f *oidcAuthProviderFactory) logConfiguration() {
 
 var (
 	_ authProvider        = (*oidcAuthProvider)(nil)
+	_ identityMapper      = (*oidcAuthProvider)(nil)
+	_ issuerMatcher       = (*oidcAuthProvider)(nil)
 	_ authProviderFactory = (*oidcAuthProviderFactory)(nil)
 )
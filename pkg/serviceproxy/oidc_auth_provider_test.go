@@ -30,8 +30,8 @@ func TestOIDCAuthProviderFactoryBuildsConfigMapBackedProvider(t *testing.T) {
 	})
 
 	provider, err := factory.build(t.Context(), authProviderDependencies{
-		managedClusterKubeClient: client,
-		podNamespace:             namespace,
+		agentKubeClient: client,
+		podNamespace:    namespace,
 	})
 	if err != nil {
 		t.Fatalf("build OIDC provider: %v", err)
@@ -56,8 +56,8 @@ func TestOIDCAuthProviderFactoryDoesNotBuildWhenControllerCannotSync(t *testing.
 	factory.options.caConfigMap = "oidc-ca"
 
 	provider, err := factory.build(ctx, authProviderDependencies{
-		managedClusterKubeClient: fake.NewSimpleClientset(),
-		podNamespace:             "addon",
+		agentKubeClient: fake.NewSimpleClientset(),
+		podNamespace:    "addon",
 	})
 	if err == nil || !strings.Contains(err.Error(), "failed to sync OIDC CA ConfigMap informer") {
 		t.Fatalf("expected controller sync error, got provider=%v err=%v", provider, err)
@@ -0,0 +1,112 @@
+package serviceproxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+type podTargetOptions struct {
+	enabled bool
+}
+
+func (o *podTargetOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.enabled, "enable-pod-proxy", o.enabled, "Accept requests targeting a single pod. The pod is read from the managed cluster and must match a pod selector forwarded by the user-server allowlist.")
+}
+
+// resolvePodTarget reads the pod, checks its labels against the encoded
+// selectors of the allowlist entries exposing it, and returns the URL of its
+// IP and port. Pods on the host network are rejected. A pod has no DNS name of its own, so https backends are
+// verified against the IP.
+func (s *serviceProxy) resolvePodTarget(ctx context.Context, target utils.TargetServiceConfig, encodedSelectors []string) (*url.URL, error) {
+	if !s.podTarget.enabled {
+		return nil, targetErrorf(http.StatusForbidden, "pod targets are not enabled on this managed cluster")
+	}
+	selectors, err := utils.DecodePodSelectors(encodedSelectors)
+	if err != nil {
+		return nil, targetErrorf(http.StatusBadRequest, "%v", err)
+	}
+	// the user-server forwards at least one selector for every exposed pod
+	if len(selectors) == 0 {
+		return nil, targetErrorf(http.StatusForbidden, "pod %s/%s is not exposed", target.Namespace, target.Pod)
+	}
+
+	pod, err := s.managedClusterKubeClient.CoreV1().Pods(target.Namespace).Get(ctx, target.Pod, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, targetErrorf(http.StatusNotFound, "pod %s/%s not found", target.Namespace, target.Pod)
+	}
+	if err != nil {
+		return nil, targetErrorf(http.StatusInternalServerError, "failed to get pod %s/%s: %v", target.Namespace, target.Pod, err)
+	}
+
+	matched := false
+	for _, selector := range selectors {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, targetErrorf(http.StatusBadRequest, "invalid pod selector %q: %v", selector, err)
+		}
+		if parsed.Matches(labels.Set(pod.Labels)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, targetErrorf(http.StatusForbidden, "pod %s/%s does not match the exposed pod selectors", target.Namespace, target.Pod)
+	}
+
+	// a host network pod shares the node IP, which would expose node ports
+	// such as the kubelet
+	if pod.Spec.HostNetwork {
+		return nil, targetErrorf(http.StatusForbidden, "pod %s/%s uses the host network", target.Namespace, target.Pod)
+	}
+
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return nil, targetErrorf(http.StatusServiceUnavailable, "pod %s/%s is not running", target.Namespace, target.Pod)
+	}
+
+	host := pod.Status.PodIP
+	if target.Port != "" {
+		port, err := podPort(pod, target.Port)
+		if err != nil {
+			return nil, err
+		}
+		host = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	} else if net.ParseIP(host).To4() == nil {
+		host = "[" + host + "]"
+	}
+	return &url.URL{Scheme: target.Proto, Host: host}, nil
+}
+
+// podPort resolves a port number or the name of a container port.
+func podPort(pod *corev1.Pod, port string) (int32, error) {
+	if number, err := strconv.ParseUint(port, 10, 16); err == nil {
+		if number == 0 {
+			return 0, targetErrorf(http.StatusBadRequest, "invalid port %q", port)
+		}
+		return int32(number), nil //nolint:gosec // bounded by ParseUint
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			if containerPort.Name == port && containerPort.Protocol != corev1.ProtocolUDP && containerPort.Protocol != corev1.ProtocolSCTP {
+				return containerPort.ContainerPort, nil
+			}
+		}
+	}
+	return 0, targetErrorf(http.StatusBadRequest, "pod %s/%s has no container port named %q", pod.Namespace, pod.Name, port)
+}
+
+// podAudience names a pod in identity assertions independently of its IP.
+func podAudience(namespace, pod string) string {
+	return fmt.Sprintf("%s.%s.pod", pod, namespace)
+}
@@ -0,0 +1,180 @@
+package serviceproxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+func newTestPod(name string, labels map[string]string, phase corev1.PodPhase, podIP string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: name, Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "prometheus",
+				Ports: []corev1.ContainerPort{{Name: "web", ContainerPort: 9090, Protocol: corev1.ProtocolTCP}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: phase, PodIP: podIP},
+	}
+}
+
+func TestServeHTTPProxiesToPods(t *testing.T) {
+	var backendURL string
+	s := &serviceProxy{
+		podTarget: podTargetOptions{enabled: true},
+		managedClusterKubeClient: fake.NewSimpleClientset(
+			newTestPod("prometheus-0", map[string]string{"app": "prometheus", "tier": "monitoring"}, corev1.PodRunning, "10.0.0.7"),
+			newTestPod("prometheus-1", map[string]string{"app": "prometheus"}, corev1.PodPending, ""),
+			newTestPod("grafana-0", map[string]string{"app": "grafana"}, corev1.PodRunning, "10.0.0.8"),
+			newTestPod("node-exporter-0", map[string]string{"app": "node-exporter"}, corev1.PodRunning, "fd00::9"),
+			func() *corev1.Pod {
+				pod := newTestPod("node-exporter-1", map[string]string{"app": "node-exporter"}, corev1.PodRunning, "192.168.0.4")
+				pod.Spec.HostNetwork = true
+				return pod
+			}(),
+		),
+		proxyTransport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			backendURL = req.URL.String()
+			return (&recordingRoundTripper{}).RoundTrip(req)
+		}),
+	}
+
+	newRequest := func(pod, port string, selectors ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/metrics", nil)
+		req.Header.Set(utils.HeaderClusterProxyProto, "http")
+		req.Header.Set(utils.HeaderClusterProxyNamespace, "monitoring")
+		req.Header.Set(utils.HeaderClusterProxyPod, pod)
+		req.Header.Set(utils.HeaderClusterProxyPort, port)
+		for _, selector := range selectors {
+			req.Header.Add(utils.HeaderClusterProxyPodSelector, utils.EncodePodSelector(selector))
+		}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		status  int
+		backend string
+	}{
+		{
+			name:    "numeric port",
+			req:     newRequest("prometheus-0", "9090", "app=prometheus"),
+			status:  http.StatusOK,
+			backend: "http://10.0.0.7:9090/metrics",
+		},
+		{
+			name:    "named port",
+			req:     newRequest("prometheus-0", "web", "app=grafana", "app=prometheus"),
+			status:  http.StatusOK,
+			backend: "http://10.0.0.7:9090/metrics",
+		},
+		{
+			name:    "default port of an IPv6 pod",
+			req:     newRequest("node-exporter-0", "", ""),
+			status:  http.StatusOK,
+			backend: "http://[fd00::9]/metrics",
+		},
+		{
+			name:    "selector with several requirements",
+			req:     newRequest("prometheus-0", "9090", "app=prometheus,tier in (monitoring)"),
+			status:  http.StatusOK,
+			backend: "http://10.0.0.7:9090/metrics",
+		},
+		{name: "unknown named port", req: newRequest("prometheus-0", "grpc", "app=prometheus"), status: http.StatusBadRequest},
+		{name: "labels do not match", req: newRequest("grafana-0", "3000", "app=prometheus"), status: http.StatusForbidden},
+		{name: "no selectors", req: newRequest("prometheus-0", "9090"), status: http.StatusForbidden},
+		{name: "host network", req: newRequest("node-exporter-1", "10250", "app=node-exporter"), status: http.StatusForbidden},
+		{name: "not running", req: newRequest("prometheus-1", "9090", "app=prometheus"), status: http.StatusServiceUnavailable},
+		{name: "not found", req: newRequest("prometheus-2", "9090", ""), status: http.StatusNotFound},
+		{name: "selector not encoded", req: func() *http.Request {
+			req := newRequest("prometheus-0", "9090")
+			req.Header.Set(utils.HeaderClusterProxyPodSelector, "app=prometheus")
+			return req
+		}(), status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backendURL = ""
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, test.req)
+			if recorder.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body.String())
+			}
+			if backendURL != test.backend {
+				t.Fatalf("backend URL = %q, want %q", backendURL, test.backend)
+			}
+		})
+	}
+}
+
+func TestServeHTTPRejectsPodsWhenDisabled(t *testing.T) {
+	s := &serviceProxy{
+		managedClusterKubeClient: fake.NewSimpleClientset(
+			newTestPod("prometheus-0", map[string]string{"app": "prometheus"}, corev1.PodRunning, "10.0.0.7"),
+		),
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/metrics", nil)
+	req.Header.Set(utils.HeaderClusterProxyProto, "http")
+	req.Header.Set(utils.HeaderClusterProxyNamespace, "monitoring")
+	req.Header.Set(utils.HeaderClusterProxyPod, "prometheus-0")
+	req.Header.Set(utils.HeaderClusterProxyPort, "9090")
+	req.Header.Set(utils.HeaderClusterProxyPodSelector, "")
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+}
+
+func TestServeHTTPAssertsIdentityForPods(t *testing.T) {
+	var backendRequest *http.Request
+	s := &serviceProxy{
+		podTarget: podTargetOptions{enabled: true},
+		managedClusterKubeClient: fake.NewSimpleClientset(
+			newTestPod("prometheus-0", map[string]string{"app": "prometheus"}, corev1.PodRunning, "10.0.0.7"),
+		),
+		identityAsserter: newTestIdentityAsserter(t),
+		proxyTransport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			backendRequest = req
+			return (&recordingRoundTripper{}).RoundTrip(req)
+		}),
+	}
+	s.authProviders = []authProvider{
+		&hubAuthProvider{
+			Token: authenticator.TokenFunc(func(_ context.Context, token string) (*authenticator.Response, bool, error) {
+				return &authenticator.Response{User: &user.DefaultInfo{Name: "sre"}}, token == "hub-token", nil
+			}),
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/metrics", nil)
+	req.Header.Set(utils.HeaderClusterProxyProto, "http")
+	req.Header.Set(utils.HeaderClusterProxyNamespace, "monitoring")
+	req.Header.Set(utils.HeaderClusterProxyPod, "prometheus-0")
+	req.Header.Set(utils.HeaderClusterProxyPort, "9090")
+	req.Header.Set(utils.HeaderClusterProxyPodSelector, utils.EncodePodSelector("app=prometheus"))
+	req.Header.Set("Authorization", "Bearer hub-token")
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", recorder.Code, recorder.Body.String())
+	}
+	// the audience names the pod rather than its IP
+	claims := verifyIdentityAssertion(t, s.identityAsserter, backendRequest.Header.Get("Authorization"), "prometheus-0.monitoring.pod")
+	if claims.Subject != "sre" {
+		t.Fatalf("assertion subject = %q, want sre", claims.Subject)
+	}
+}
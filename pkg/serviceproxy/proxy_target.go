@@ -0,0 +1,73 @@
+package serviceproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+// targetError is a target resolution failure answered with a specific status.
+type targetError struct {
+	code int
+	err  error
+}
+
+func (e *targetError) Error() string {
+	return e.err.Error()
+}
+
+func targetErrorf(code int, format string, args ...any) error {
+	return &targetError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// targetErrorStatus returns the status of a resolution failure, Bad Request
+// unless the failure carries its own.
+func targetErrorStatus(err error) int {
+	var target *targetError
+	if errors.As(err, &target) {
+		return target.code
+	}
+	return http.StatusBadRequest
+}
+
+// proxyTarget is the backend a request is forwarded to.
+type proxyTarget struct {
+	url *url.URL
+	// audience names the backend in identity assertions.
+	audience string
+	// kubeAPIServer is set for the kube-apiserver of the managed cluster.
+	kubeAPIServer bool
+}
+
+// resolveTarget returns the backend of a request. Services are addressed by
+// their DNS name; pods by their IP, since a pod name does not resolve without
+// a Service; the kube-apiserver by the configured URL.
+func (s *serviceProxy) resolveTarget(ctx context.Context, req *http.Request) (proxyTarget, error) {
+	pod, ok, err := utils.GetTargetPodFromRequest(req)
+	if err != nil {
+		return proxyTarget{}, err
+	}
+	if !ok {
+		target, err := utils.GetTargetServiceURLFromRequest(req)
+		if err != nil {
+			return proxyTarget{}, err
+		}
+		if target.Host != utils.KubeAPIServerHost {
+			return proxyTarget{url: target, audience: target.Hostname()}, nil
+		}
+		if s.kubeAPIServerTarget != nil {
+			target = s.kubeAPIServerTarget
+		}
+		return proxyTarget{url: target, audience: utils.KubeAPIServerHost, kubeAPIServer: true}, nil
+	}
+
+	target, err := s.resolvePodTarget(ctx, pod, req.Header.Values(utils.HeaderClusterProxyPodSelector))
+	if err != nil {
+		return proxyTarget{}, err
+	}
+	return proxyTarget{url: target, audience: podAudience(pod.Namespace, pod.Pod)}, nil
+}
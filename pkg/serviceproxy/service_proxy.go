@@ -9,19 +9,25 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	addonutils "open-cluster-management.io/addon-framework/pkg/utils"
 	"open-cluster-management.io/cluster-proxy/pkg/constant"
+	"open-cluster-management.io/cluster-proxy/pkg/util"
 	"open-cluster-management.io/cluster-proxy/pkg/utils"
 	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
 )
@@ -43,12 +49,6 @@ func NewServiceProxyCommand() *cobra.Command {
 }
 
 const (
-	// defaultTokenReviewCacheTTL is the default TTL for cached TokenReview results.
-	// Cached entries expire after this duration, forcing a fresh TokenReview API call.
-	// A short TTL (10s) is sufficient because the primary goal is deduplicating
-	// concurrent requests for the same token, not long-term caching.
-	defaultTokenReviewCacheTTL = 10 * time.Second
-
 	// defaultKubeClientQPS is the default QPS for kube clients used by service-proxy.
 	// The default client-go value (5) is too low for high-concurrency TokenReview workloads,
 	// causing client-side throttling delays of 1min+ when many requests are proxied simultaneously.
@@ -61,29 +61,54 @@ const (
 type serviceProxy struct {
 	cert, key           string
 	additionalServiceCA string
+	targetTLSConfigMap  string
 	rootCAs             *x509.CertPool
 
+	// targetTLSClientCertSecrets names the Secrets target TLS policies may
+	// reference as client certificates.
+	targetTLSClientCertSecrets []string
+
 	maxIdleConns          int
 	idleConnTimeout       time.Duration
 	tLSHandshakeTimeout   time.Duration
 	expectContinueTimeout time.Duration
 	drain                 utils.DrainConfig
-
-	tokenReviewCacheTTL time.Duration
-	kubeClientQPS       float32
-	kubeClientBurst     int
-	podNamespace        string
-
+	streams               utils.StreamConfig
+
+	tokenCache                   tokenCacheOptions
+	managedClusterTokenAudiences []string
+	kubeClientQPS                float32
+	kubeClientBurst              int
+	clusterName                  string
+	podNamespace                 string
+
+	// agentKubeClient reads the addon namespace of the cluster the agent runs
+	// in. managedClusterKubeClient reviews tokens and reads pods through the
+	// managed cluster API, which hosted control planes serve elsewhere.
+	agentKubeClient          kubernetes.Interface
 	managedClusterKubeClient kubernetes.Interface
 
 	authProviderFactories []authProviderFactory
+	authProviderChain     authProviderChainOptions
 	authProviders         []authProvider
 
-	proxyTransport closeIdleRoundTripper
+	identityAssertion identityAssertionOptions
+	identityAsserter  *identityAsserter
+
+	impersonator impersonatorOptions
+
+	tcpTunnel tcpTunnelOptions
+	podTarget podTargetOptions
 
-	// getImpersonateTokenFunc reads the service account token used for impersonation.
-	// Defaults to reading from the mounted service account token file.
-	// Can be overridden in tests.
+	kubeAPIServer          kubeAPIServerOptions
+	kubeAPIServerTarget    *url.URL
+	kubeAPIServerTransport closeIdleRoundTripper
+
+	proxyTransport   closeIdleRoundTripper
+	targetTransports *targetTransports
+
+	// getImpersonateTokenFunc returns the token presented with impersonation
+	// headers. Run sets it from the impersonator options; tests override it.
 	getImpersonateTokenFunc func() (string, error)
 }
 
@@ -93,14 +118,15 @@ type closeIdleRoundTripper interface {
 }
 
 func newServiceProxy() *serviceProxy {
-	s := &serviceProxy{
-		tokenReviewCacheTTL:   defaultTokenReviewCacheTTL,
+	return &serviceProxy{
+		tokenCache:            newTokenCacheOptions(),
 		kubeClientQPS:         defaultKubeClientQPS,
 		kubeClientBurst:       defaultKubeClientBurst,
 		authProviderFactories: defaultAuthProviderFactories(),
+		identityAssertion:     newIdentityAssertionOptions(),
+		impersonator:          newImpersonatorOptions(),
+		tcpTunnel:             newTCPTunnelOptions(),
 	}
-	s.getImpersonateTokenFunc = s.readImpersonateTokenFromFile
-	return s
 }
 
 func (s *serviceProxy) AddFlags(cmd *cobra.Command) {
@@ -109,6 +135,9 @@ func (s *serviceProxy) AddFlags(cmd *cobra.Command) {
 	flags.StringVar(&s.cert, "cert", s.cert, "The path to the certificate of the service proxy server")
 	flags.StringVar(&s.key, "key", s.key, "The path to the key of the service proxy server")
 	flags.StringVar(&s.additionalServiceCA, "additional-service-ca", s.additionalServiceCA, "The path to the additional CA certificate for services")
+	flags.StringVar(&s.clusterName, "cluster-name", s.clusterName, "The name of the managed cluster the service proxy runs on.")
+	flags.StringVar(&s.targetTLSConfigMap, "target-tls-configmap", s.targetTLSConfigMap, "The name of a ConfigMap in POD_NAMESPACE whose policies.yaml entry configures outbound TLS per target Service. The ConfigMap and the CA ConfigMaps and client certificate Secrets it references are watched.")
+	flags.StringSliceVar(&s.targetTLSClientCertSecrets, "target-tls-client-cert-secrets", s.targetTLSClientCertSecrets, "Comma-separated names of the Secrets in POD_NAMESPACE that target TLS policies may reference as client certificates. Only these Secrets are read; policies referencing others fail closed.")
 
 	// proxy related flags
 	flags.IntVar(&s.maxIdleConns, "max-idle-conns", 100, "The maximum number of idle (keep-alive) connections across all hosts.")
@@ -116,13 +145,21 @@ func (s *serviceProxy) AddFlags(cmd *cobra.Command) {
 	flags.DurationVar(&s.tLSHandshakeTimeout, "tls-handshake-timeout", 10*time.Second, "The maximum amount of time waiting to wait for a TLS handshake.")
 	flags.DurationVar(&s.expectContinueTimeout, "expect-continue-timeout", 1*time.Second, "The amount of time to wait for a server's first response headers after fully writing the request headers if the request has an \"Expect: 100-continue\" header.")
 	s.drain.AddFlags(flags)
+	s.streams.AddFlags(flags)
+	s.kubeAPIServer.addFlags(flags)
 
-	// token review cache flags
-	flags.DurationVar(&s.tokenReviewCacheTTL, "token-review-cache-ttl", defaultTokenReviewCacheTTL, "TTL for cached TokenReview results. Set to 0 to disable caching.")
+	// token authentication flags
+	s.tokenCache.addFlags(flags)
+	flags.StringSliceVar(&s.managedClusterTokenAudiences, "managed-cluster-token-audiences", s.managedClusterTokenAudiences, "Comma-separated list of audiences managed cluster tokens must be issued for. Managed cluster tokens are forwarded unchanged, so they must also be valid for the managed cluster kube-apiserver. If unset, tokens for the kube-apiserver audiences are accepted.")
 
 	for _, factory := range s.authProviderFactories {
 		factory.addFlags(flags)
 	}
+	s.authProviderChain.addFlags(flags)
+	s.identityAssertion.addFlags(flags)
+	s.impersonator.addFlags(flags)
+	s.tcpTunnel.addFlags(flags)
+	s.podTarget.addFlags(flags)
 
 	// kube client rate limiting flags
 	flags.Float32Var(&s.kubeClientQPS, "kube-api-qps", defaultKubeClientQPS, "QPS for Kubernetes API clients. Increase if client-side throttling is observed under high concurrency.")
@@ -139,8 +176,11 @@ func (s *serviceProxy) Run(ctx context.Context) error {
 	var err error
 	customChecks := []healthz.Checker{}
 	providerConfigFiles := s.authProviderConfigFiles()
-	configFiles := make([]string, 0, 3+len(providerConfigFiles))
+	configFiles := make([]string, 0, 4+len(providerConfigFiles))
 	configFiles = append(configFiles, s.cert, s.key, rootCAFile)
+	if s.kubeAPIServer.caFile != "" {
+		configFiles = append(configFiles, s.kubeAPIServer.caFile)
+	}
 	configFiles = append(configFiles, providerConfigFiles...)
 	cc, err := addonutils.NewConfigChecker("cert", configFiles...)
 	if err != nil {
@@ -169,8 +209,11 @@ func (s *serviceProxy) Run(ctx context.Context) error {
 	s.proxyTransport = s.newProxyTransport()
 	defer s.closeIdleConnections()
 
-	// init managedClusterKubeClient
-	// managedClusterKubeClient is the kubeClient of current cluster using in-cluster config
+	if err := s.initKubeAPIServerTarget(); err != nil {
+		return err
+	}
+	klog.Infof("proxying kube-apiserver requests to %s", s.kubeAPIServerTarget)
+
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return fmt.Errorf("failed to get in-cluster config: %v", err)
@@ -178,7 +221,15 @@ func (s *serviceProxy) Run(ctx context.Context) error {
 	config.QPS = s.kubeClientQPS
 	config.Burst = s.kubeClientBurst
 
-	s.managedClusterKubeClient, err = kubernetes.NewForConfig(config)
+	s.agentKubeClient, err = kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	managedClusterConfig, err := s.managedClusterRestConfig(config)
+	if err != nil {
+		return err
+	}
+	s.managedClusterKubeClient, err = kubernetes.NewForConfig(managedClusterConfig)
 	if err != nil {
 		return err
 	}
@@ -188,11 +239,44 @@ func (s *serviceProxy) Run(ctx context.Context) error {
 		return errors.New("pod namespace is empty, please set the POD_NAMESPACE environment variable")
 	}
 
+	s.getImpersonateTokenFunc, err = s.impersonator.start(runCtx, s.managedClusterKubeClient, s.podNamespace)
+	if err != nil {
+		return err
+	}
+
 	if err := s.initializeAuthProviders(runCtx); err != nil {
 		return err
 	}
 
-	sdkTLSConfig, err := sdktls.StartTLSConfigMapWatcher(runCtx, s.managedClusterKubeClient, s.podNamespace, func() {
+	if s.targetTLSConfigMap != "" {
+		s.targetTransports = &targetTransports{}
+		if err := startTargetTLSController(
+			runCtx,
+			s.agentKubeClient,
+			s.podNamespace,
+			s.targetTLSConfigMap,
+			s.targetTLSClientCertSecrets,
+			s.rootCAs,
+			s.newTargetTransport,
+			s.targetTransports,
+		); err != nil {
+			return fmt.Errorf("failed to start target TLS controller: %w", err)
+		}
+	}
+
+	if s.identityAssertion.enabled {
+		key, err := util.LoadOrCreateSigningKey(runCtx, s.agentKubeClient, s.podNamespace, s.identityAssertion.signingSecret)
+		if err != nil {
+			return err
+		}
+		s.identityAsserter, err = newIdentityAsserter(key, s.identityAssertion)
+		if err != nil {
+			return err
+		}
+		klog.Infof("identity assertion enabled: issuer=%s, trustedBackends=%v", s.identityAssertion.issuer, s.identityAssertion.trustedBackends)
+	}
+
+	sdkTLSConfig, err := sdktls.StartTLSConfigMapWatcher(runCtx, s.agentKubeClient, s.podNamespace, func() {
 		klog.Info("TLS ConfigMap changed, shutting down gracefully for restart")
 		cancel()
 	})
@@ -208,19 +292,95 @@ func (s *serviceProxy) Run(ctx context.Context) error {
 	}
 
 	healthServer := utils.NewHealthProbeServer(":8000", customChecks...)
-	publicServer := utils.NewProxyHTTPServer(fmt.Sprintf(":%d", constant.ServiceProxyPort), tlsConfig, s)
+	connections := utils.NewConnectionTracker()
+	publicServer := utils.NewProxyHTTPServer(fmt.Sprintf(":%d", constant.ServiceProxyPort), tlsConfig,
+		connections.Handler(s.publicHandler()))
 
 	klog.Infof("starting service proxy HTTPS server on %d and health server on 8000", constant.ServiceProxyPort)
 	return utils.RunHTTPServers(
 		runCtx,
 		s.drain,
 		publicServer,
+		connections,
 		s.cert,
 		s.key,
 		healthServer,
 	)
 }
 
+// publicHandler serves the metrics and the identity assertion JWKS to
+// requests without a proxy target and proxies all others. Keeping them off
+// the plaintext health server means they are only reachable over TLS.
+func (s *serviceProxy) publicHandler() http.Handler {
+	local := http.NewServeMux()
+	local.Handle("/metrics", s.metricsHandler())
+	if s.identityAsserter != nil {
+		local.Handle(identityAssertionJWKSPath, s.identityAsserter.jwksHandler())
+	}
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(utils.HeaderClusterProxyService) == "" && req.Header.Get(utils.HeaderClusterProxyPod) == "" {
+			local.ServeHTTP(wr, req)
+			return
+		}
+		s.ServeHTTP(wr, req)
+	})
+}
+
+// metricsHandler serves the metrics to identities the agent cluster allows to get the
+// /metrics non-resource URL, since the identity assertion Service exposes the TLS listener
+// to every workload of the cluster.
+func (s *serviceProxy) metricsHandler() http.Handler {
+	tokenAuthenticator := newTokenReviewAuthenticator(s.agentKubeClient, "agent cluster", nil)
+	metrics := legacyregistry.Handler()
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		logger := klog.FromContext(ctx)
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(wr, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		resp, authenticated, err := tokenAuthenticator.AuthenticateToken(ctx, token)
+		if err != nil && !errors.Is(err, ErrTokenNotAuthenticated) {
+			logger.Error(err, "failed to authenticate metrics request")
+			http.Error(wr, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !authenticated {
+			http.Error(wr, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		identity := resp.User
+		extra := make(map[string]authorizationv1.ExtraValue, len(identity.GetExtra()))
+		for key, values := range identity.GetExtra() {
+			extra[key] = values
+		}
+		review, err := s.agentKubeClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   identity.GetName(),
+				UID:    identity.GetUID(),
+				Groups: identity.GetGroups(),
+				Extra:  extra,
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+					Path: req.URL.Path,
+					Verb: "get",
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			logger.Error(err, "failed to authorize metrics request")
+			http.Error(wr, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !review.Status.Allowed {
+			logger.V(4).Info("metrics request denied", "user", identity.GetName(), "reason", review.Status.Reason)
+			http.Error(wr, fmt.Sprintf("user %q cannot get path %q", identity.GetName(), req.URL.Path), http.StatusForbidden)
+			return
+		}
+		metrics.ServeHTTP(wr, req)
+	})
+}
+
 // loadRootCAs builds the root CA pool from the apiserver CA and, when
 // configured and present, the additional service CA. The returned bool
 // reports whether the additional service CA was loaded.
@@ -266,29 +426,34 @@ func (s *serviceProxy) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 		klog.V(4).Infof("request:\n %s", string(dump))
 	}
 
-	url, err := utils.GetTargetServiceURLFromRequest(req)
+	target, err := s.resolveTarget(ctx, req)
 	if err != nil {
-		http.Error(wr, err.Error(), http.StatusBadRequest)
-		logger.Error(err, "failed to get target service url from request")
+		http.Error(wr, err.Error(), targetErrorStatus(err))
+		logger.Error(err, "failed to get target url from request")
+		return
+	}
+
+	if target.url.Scheme == utils.ProtoTCP {
+		s.serveTCPTunnel(klog.NewContext(ctx, logger.WithValues("targetHost", target.url.Host)), wr, req, target.url)
 		return
 	}
 
 	// Enrich logger with request-scoped fields so all downstream logs
 	// are traceable by request without repeating these values.
 	logger = logger.WithValues(
-		"targetHost", url.Host,
+		"targetHost", target.url.Host,
 		"method", req.Method,
 		"path", req.URL.Path,
 	)
 	ctx = klog.NewContext(ctx, logger)
 
 	logger.V(4).Info("service proxy received request",
-		"targetScheme", url.Scheme,
+		"targetScheme", target.url.Scheme,
 		"authProviders", s.activeAuthProviderIDs(),
-		"isKubeAPIServer", url.Host == utils.KubeAPIServerHost,
+		"isKubeAPIServer", target.kubeAPIServer,
 	)
 
-	if url.Host == utils.KubeAPIServerHost {
+	if target.kubeAPIServer {
 		clientImpersonationRequested := hasClientImpersonationHeaders(req.Header)
 		// Delegate client impersonation unchanged to the target API server, which authenticates
 		// the original token and authorizes the requested impersonation through its own RBAC.
@@ -303,25 +468,67 @@ func (s *serviceProxy) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 				"clientImpersonationRequested", clientImpersonationRequested,
 			)
 		}
+	} else if s.identityAsserter != nil {
+		// Backends other than the kube-apiserver cannot validate the caller's
+		// credential, so they receive an assertion of the authenticated identity.
+		provider, info, err := s.authenticateRequest(ctx, req)
+		if err != nil {
+			logger.Error(err, "authentication failed")
+			http.Error(wr, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err := s.identityAsserter.apply(req, target.audience, effectiveIdentity(provider, info)); err != nil {
+			logger.Error(err, "failed to assert identity")
+			http.Error(wr, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		logger.V(4).Info("identity assertion applied", "provider", provider.Metadata().id)
 	}
 
+	// the client identity is only meant for the service-proxy
+	req.Header.Del(util.ClientIdentityHeader)
+
 	logger.V(6).Info("forwarding request to reverse proxy",
-		"targetURL", url.String(),
+		"targetURL", target.url.String(),
 	)
 
-	if s.proxyTransport == nil {
+	transport := s.proxyTransport
+	if target.kubeAPIServer {
+		if s.kubeAPIServerTransport != nil {
+			transport = s.kubeAPIServerTransport
+		}
+	} else if policy, ok := s.targetTransports.get(target.url.Hostname()); ok {
+		if policy.err != nil {
+			logger.Error(policy.err, "target TLS policy is unavailable")
+			http.Error(wr, "target TLS configuration is unavailable", http.StatusBadGateway)
+			return
+		}
+		transport = policy.transport
+	}
+
+	if transport == nil {
 		err := errors.New("service proxy transport is not initialized")
 		logger.Error(err, "cannot forward request")
 		http.Error(wr, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(url)
-	proxy.Transport = s.proxyTransport
+	proxy := httputil.NewSingleHostReverseProxy(target.url)
+	proxy.Transport = transport
 	proxy.ServeHTTP(wr, req)
 }
 
-func (s *serviceProxy) newProxyTransport() *http.Transport {
+// newProxyTransport returns the default transport, which sends upgrade
+// requests over HTTP/1.1 and other requests over HTTP/2 where possible.
+func (s *serviceProxy) newProxyTransport() *utils.UpgradeAwareTransport {
+	transport := utils.NewUpgradeAwareTransport(s.newHTTPTransport())
+	transport.StreamIdleTimeout = s.streams.IdleTimeout
+	return transport
+}
+
+// newHTTPTransport returns the connection settings shared by every outbound
+// transport.
+func (s *serviceProxy) newHTTPTransport() *http.Transport {
 	return &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
@@ -336,32 +543,61 @@ func (s *serviceProxy) newProxyTransport() *http.Transport {
 			RootCAs:    s.rootCAs,
 			MinVersion: tls.VersionTLS12,
 		},
-		// golang http pkg automatically upgrade http connection to http2 connection, but http2 can not upgrade to SPDY which used in "kubectl exec".
-		// set ForceAttemptHTTP2 = false to prevent auto http2 upgration
-		ForceAttemptHTTP2: false,
 	}
 }
 
+// newTargetTransport returns a transport with the default settings and the
+// TLS client configuration of a target policy.
+func (s *serviceProxy) newTargetTransport(tlsConfig *tls.Config) closeIdleRoundTripper {
+	base := s.newHTTPTransport()
+	base.TLSClientConfig = tlsConfig
+	transport := utils.NewUpgradeAwareTransport(base)
+	transport.StreamIdleTimeout = s.streams.IdleTimeout
+	return transport
+}
+
 func (s *serviceProxy) closeIdleConnections() {
 	if s.proxyTransport != nil {
 		s.proxyTransport.CloseIdleConnections()
 	}
+	if s.kubeAPIServerTransport != nil {
+		s.kubeAPIServerTransport.CloseIdleConnections()
+	}
+	s.targetTransports.closeIdleConnections()
 }
 
 func (s *serviceProxy) validate() error {
 	if err := s.drain.Validate(); err != nil {
 		return err
 	}
+	if err := s.streams.Validate(); err != nil {
+		return err
+	}
 	if s.cert == "" {
 		return fmt.Errorf("cert is required")
 	}
 	if s.key == "" {
 		return fmt.Errorf("key is required")
 	}
+	if err := s.tokenCache.validate(); err != nil {
+		return err
+	}
 	for _, factory := range s.authProviderFactories {
 		if err := factory.validate(); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := s.authProviderChain.validate(); err != nil {
+		return err
+	}
+	if err := s.impersonator.validate(); err != nil {
+		return err
+	}
+	if err := s.tcpTunnel.validate(); err != nil {
+		return err
+	}
+	if err := s.kubeAPIServer.validate(); err != nil {
+		return err
+	}
+	return s.identityAssertion.validate()
 }
@@ -16,8 +16,8 @@ import (
 func TestNewServiceProxy_DefaultValues(t *testing.T) {
 	s := newServiceProxy()
 
-	if s.tokenReviewCacheTTL != defaultTokenReviewCacheTTL {
-		t.Fatalf("expected default TTL %v, got %v", defaultTokenReviewCacheTTL, s.tokenReviewCacheTTL)
+	if s.tokenCache != newTokenCacheOptions() {
+		t.Fatalf("expected default token cache options, got %+v", s.tokenCache)
 	}
 	if s.kubeClientQPS != defaultKubeClientQPS {
 		t.Fatalf("expected default QPS %v, got %v", defaultKubeClientQPS, s.kubeClientQPS)
@@ -1,9 +1,13 @@
 package serviceproxy
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,6 +15,14 @@ import (
 	"testing"
 	"time"
 
+	gwebsocket "github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/client-go/transport/websocket"
+	"k8s.io/streaming/pkg/httpstream"
+
 	"open-cluster-management.io/cluster-proxy/pkg/utils"
 )
 
@@ -57,7 +69,7 @@ func TestNewProxyTransportPreservesConfiguration(t *testing.T) {
 		expectContinueTimeout: 31 * time.Second,
 	}
 
-	transport := s.newProxyTransport()
+	transport := s.newHTTPTransport()
 
 	if transport.DialContext == nil {
 		t.Fatal("DialContext is not configured")
@@ -87,9 +99,6 @@ func TestNewProxyTransportPreservesConfiguration(t *testing.T) {
 	if transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
 		t.Fatalf("unexpected minimum TLS version: %d", transport.TLSClientConfig.MinVersion)
 	}
-	if transport.ForceAttemptHTTP2 {
-		t.Fatal("ForceAttemptHTTP2 must remain disabled for SPDY upgrades")
-	}
 }
 
 func TestServeHTTPReusesSharedTransport(t *testing.T) {
@@ -167,4 +176,308 @@ func TestCloseIdleConnectionsUsesSharedTransport(t *testing.T) {
 	(&serviceProxy{}).closeIdleConnections()
 }
 
+// startProtocolTestProxy serves s over TLS with HTTP/2 enabled and forwards
+// every target to backendAddr.
+func startProtocolTestProxy(t *testing.T, s *serviceProxy, backendAddr string, backendCAs *x509.CertPool) *httptest.Server {
+	t.Helper()
+	s.proxyTransport = utils.NewUpgradeAwareTransport(&http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, backendAddr)
+		},
+		TLSClientConfig: &tls.Config{RootCAs: backendCAs, ServerName: "example.com"},
+	})
+	t.Cleanup(s.closeIdleConnections)
+
+	server := httptest.NewUnstartedServer(s)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func setTargetHeaders(header http.Header, proto string) {
+	header.Set(utils.HeaderClusterProxyProto, proto)
+	header.Set(utils.HeaderClusterProxyNamespace, "default")
+	header.Set(utils.HeaderClusterProxyService, "backend")
+	header.Set(utils.HeaderClusterProxyPort, "8443")
+}
+
+func startTLSBackend(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewUnstartedServer(handler)
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestServeHTTPForwardsExecUpgradeOverHTTP1(t *testing.T) {
+	backend := startTLSBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !httpstream.IsUpgradeRequest(r) || r.ProtoMajor != 1 {
+			http.Error(w, fmt.Sprintf("unexpected %s request", r.Proto), http.StatusBadRequest)
+			return
+		}
+		conn, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n")
+		_ = buffered.Flush()
+		// echo the stream like an attached shell
+		line, _ := buffered.ReadString('\n')
+		_, _ = conn.Write([]byte(line))
+	}))
+	proxy := startProtocolTestProxy(t, &serviceProxy{}, backend.Listener.Addr().String(), backend.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs)
+
+	// kubectl exec sends the SPDY upgrade over HTTP/1.1
+	conn, err := tls.Dial("tcp", proxy.Listener.Addr().String(), &tls.Config{
+		RootCAs:    proxy.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs,
+		NextProtos: []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatalf("failed to dial service proxy: %v", err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "https://service-proxy/api/v1/namespaces/default/pods/shell/exec", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "SPDY/3.1")
+	setTargetHeaders(req.Header, "https")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("failed to write upgrade request: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatalf("failed to read upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	if _, err := conn.Write([]byte("echo hello\n")); err != nil {
+		t.Fatalf("failed to write to the upgraded stream: %v", err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read from the upgraded stream: %v", err)
+	}
+	if line != "echo hello\n" {
+		t.Fatalf("unexpected stream data %q", line)
+	}
+}
+
+func TestServeHTTPStreamsWatchOverHTTP2(t *testing.T) {
+	secondEvent := make(chan struct{})
+	backend := startTLSBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, fmt.Sprintf("unexpected %s request", r.Proto), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"type":"ADDED"}`)
+		http.NewResponseController(w).Flush()
+		select {
+		case <-secondEvent:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"type":"MODIFIED"}`)
+	}))
+	proxy := startProtocolTestProxy(t, &serviceProxy{}, backend.Listener.Addr().String(), backend.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs)
+
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, proxy.URL+"/api/v1/pods?watch=true", nil)
+	setTargetHeaders(req.Header, "https")
+	resp, err := proxy.Client().Do(req)
+	if err != nil {
+		t.Fatalf("watch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("unexpected %s response %d: %s", resp.Proto, resp.StatusCode, body)
+	}
+
+	// the first event must arrive before the backend writes the second one
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{`{"type":"ADDED"}`, `{"type":"MODIFIED"}`} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read watch event: %v", err)
+		}
+		if strings.TrimSpace(line) != want {
+			t.Fatalf("unexpected watch event %q, want %q", line, want)
+		}
+		if want == `{"type":"ADDED"}` {
+			close(secondEvent)
+		}
+	}
+}
+
+// newWebSocketExecBackend echoes one message over the kubectl v5 streaming
+// protocol after checking the forwarded upgrade headers.
+func newWebSocketExecBackend(t *testing.T) http.Handler {
+	upgrader := gwebsocket.Upgrader{Subprotocols: []string{"v5.channel.k8s.io"}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 1 || !gwebsocket.IsWebSocketUpgrade(r) {
+			http.Error(w, fmt.Sprintf("unexpected %s request with Connection %q and Upgrade %q",
+				r.Proto, r.Header.Get("Connection"), r.Header.Get("Upgrade")), http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(messageType, message)
+	})
+}
+
+// dialWebSocketExec upgrades like kubectl exec with the v5 WebSocket protocol.
+func dialWebSocketExec(t *testing.T, proxy *httptest.Server, header http.Header) (*websocket.RoundTripper, error) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, proxy.URL+"/api/v1/namespaces/default/pods/shell/exec?command=sh", nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Add("Sec-WebSocket-Protocol", "v5.channel.k8s.io")
+	req.Header.Add("Sec-WebSocket-Protocol", "v4.channel.k8s.io")
+	rt := &websocket.RoundTripper{TLSConfig: proxy.Client().Transport.(*http.Transport).TLSClientConfig}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return rt, nil
+}
+
+func TestServeHTTPForwardsWebSocketExec(t *testing.T) {
+	backend := startTLSBackend(t, newWebSocketExecBackend(t))
+	proxy := startProtocolTestProxy(t, &serviceProxy{}, backend.Listener.Addr().String(), backend.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs)
+
+	header := http.Header{}
+	setTargetHeaders(header, "https")
+	rt, err := dialWebSocketExec(t, proxy, header)
+	if err != nil {
+		t.Fatalf("WebSocket upgrade through service proxy failed: %v", err)
+	}
+	conn := rt.Connection()
+	defer conn.Close()
+
+	if conn.Subprotocol() != "v5.channel.k8s.io" {
+		t.Fatalf("negotiated %q, want v5.channel.k8s.io", conn.Subprotocol())
+	}
+	if err := conn.WriteMessage(gwebsocket.BinaryMessage, []byte("\x00echo hello")); err != nil {
+		t.Fatalf("failed to write stdin: %v", err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read stdout: %v", err)
+	}
+	if string(message) != "\x00echo hello" {
+		t.Fatalf("unexpected message %q", message)
+	}
+}
+
+func TestServeHTTPForwardsWebSocketRejectionForSPDYFallback(t *testing.T) {
+	// a backend without WebSocket support that still serves SPDY
+	backend := startTLSBackend(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gwebsocket.IsWebSocketUpgrade(r) {
+			http.Error(w, "WebSocket is not supported", http.StatusBadRequest)
+			return
+		}
+		conn, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n")
+		_ = buffered.Flush()
+	}))
+	proxy := startProtocolTestProxy(t, &serviceProxy{}, backend.Listener.Addr().String(), backend.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs)
+
+	header := http.Header{}
+	setTargetHeaders(header, "https")
+	_, err := dialWebSocketExec(t, proxy, header)
+	// kubectl retries with SPDY on upgrade failures
+	if !httpstream.IsUpgradeFailure(err) {
+		t.Fatalf("expected an upgrade failure, got %v", err)
+	}
+
+	conn, err := tls.Dial("tcp", proxy.Listener.Addr().String(), &tls.Config{
+		RootCAs:    proxy.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs,
+		NextProtos: []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatalf("failed to dial service proxy: %v", err)
+	}
+	defer conn.Close()
+	req, _ := http.NewRequest(http.MethodPost, "https://service-proxy/api/v1/namespaces/default/pods/shell/exec", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "SPDY/3.1")
+	setTargetHeaders(req.Header, "https")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("failed to write upgrade request: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("failed to read upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("SPDY retry status %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+}
+
+type testHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (testHealthServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestServeHTTPForwardsGRPCToPlaintextBackend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, testHealthServer{})
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	proxy := startProtocolTestProxy(t, &serviceProxy{}, listener.Addr().String(), nil)
+
+	conn, err := grpc.NewClient("passthrough:///"+proxy.Listener.Addr().String(), grpc.WithTransportCredentials(
+		credentials.NewTLS(&tls.Config{RootCAs: proxy.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs, ServerName: "example.com"}),
+	))
+	if err != nil {
+		t.Fatalf("failed to create gRPC client: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(),
+		utils.HeaderClusterProxyProto, "http",
+		utils.HeaderClusterProxyNamespace, "default",
+		utils.HeaderClusterProxyService, "backend",
+		utils.HeaderClusterProxyPort, "8443",
+	)
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("gRPC health check through service proxy failed: %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("unexpected health status %v", resp.Status)
+	}
+}
+
 var _ closeIdleRoundTripper = (*recordingRoundTripper)(nil)
@@ -0,0 +1,352 @@
+package serviceproxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+)
+
+const (
+	// targetTLSPoliciesKey is the ConfigMap entry holding the policy list.
+	targetTLSPoliciesKey = "policies.yaml"
+	// targetTLSCAKey is the entry of a policy's CA ConfigMap holding the bundle.
+	targetTLSCAKey = "ca.crt"
+
+	targetTLSInformerResyncPeriod = 10 * time.Minute
+)
+
+// targetTLSPolicy configures the outbound TLS client of one Service. CA
+// ConfigMaps and client certificate Secrets are read from the service-proxy
+// namespace so that the policy owner controls what is trusted.
+type targetTLSPolicy struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	// CAConfigMap replaces the default trust roots with the ca.crt entry of the
+	// named ConfigMap.
+	CAConfigMap string `json:"caConfigMap,omitempty"`
+	// ClientCertSecret names a kubernetes.io/tls Secret presented to backends
+	// requiring mutual TLS.
+	ClientCertSecret string `json:"clientCertSecret,omitempty"`
+	// ServerName overrides the name used for SNI and certificate verification.
+	ServerName string `json:"serverName,omitempty"`
+	// InsecureSkipVerify disables server certificate verification. It must be
+	// set explicitly and cannot be combined with a CA bundle.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+type targetTLSPolicyList struct {
+	Targets []targetTLSPolicy `json:"targets"`
+}
+
+func parseTargetTLSPolicies(data []byte) ([]targetTLSPolicy, error) {
+	list := targetTLSPolicyList{}
+	if err := yaml.UnmarshalStrict(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse target TLS policies: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, policy := range list.Targets {
+		if policy.Namespace == "" || policy.Service == "" {
+			return nil, fmt.Errorf("target TLS policy requires namespace and service")
+		}
+		if policy.InsecureSkipVerify && policy.CAConfigMap != "" {
+			return nil, fmt.Errorf("target TLS policy for %s/%s must not set both caConfigMap and insecureSkipVerify",
+				policy.Namespace, policy.Service)
+		}
+		host := backendAudience(policy.Namespace, policy.Service)
+		if host == utils.KubeAPIServerHost {
+			return nil, fmt.Errorf("target TLS policies must not apply to the kube-apiserver")
+		}
+		if seen[host] {
+			return nil, fmt.Errorf("duplicate target TLS policy for %s/%s", policy.Namespace, policy.Service)
+		}
+		seen[host] = true
+	}
+	return list.Targets, nil
+}
+
+// targetTransport is the cached outcome of building a policy. A policy whose
+// referenced objects are missing or invalid fails closed with err.
+type targetTransport struct {
+	transport closeIdleRoundTripper
+	err       error
+}
+
+// targetTransports holds one transport per Service with a TLS policy. Other
+// targets share the default proxy transport.
+type targetTransports struct {
+	mu         sync.RWMutex
+	observed   string
+	transports map[string]targetTransport
+}
+
+func (t *targetTransports) get(host string) (targetTransport, bool) {
+	if t == nil {
+		return targetTransport{}, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	transport, ok := t.transports[host]
+	return transport, ok
+}
+
+// applied reports whether a policy configuration has been applied.
+func (t *targetTransports) applied() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.observed != ""
+}
+
+// replace swaps the transport set unless the configuration is unchanged and
+// releases idle connections of the previous transports.
+func (t *targetTransports) replace(configurationKey string, transports map[string]targetTransport) bool {
+	t.mu.Lock()
+	if t.observed == configurationKey {
+		t.mu.Unlock()
+		return false
+	}
+	old := t.transports
+	t.observed = configurationKey
+	t.transports = transports
+	t.mu.Unlock()
+
+	for _, transport := range old {
+		if transport.transport != nil {
+			transport.transport.CloseIdleConnections()
+		}
+	}
+	return true
+}
+
+func (t *targetTransports) closeIdleConnections() {
+	if t == nil {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, transport := range t.transports {
+		if transport.transport != nil {
+			transport.transport.CloseIdleConnections()
+		}
+	}
+}
+
+type targetTLSController struct {
+	namespace       string
+	name            string
+	configMapLister listerscorev1.ConfigMapLister
+	// secretListers holds one lister per client certificate Secret that may
+	// be referenced, keyed by name.
+	secretListers  map[string]listerscorev1.SecretLister
+	newTransport   func(*tls.Config) closeIdleRoundTripper
+	defaultRootCAs *x509.CertPool
+	transports     *targetTransports
+}
+
+// startTargetTLSController watches the policy ConfigMap, every ConfigMap in the
+// service-proxy namespace and the named client certificate Secrets, rebuilding
+// the per-target transports whenever one of their inputs changes. Each Secret
+// is watched by name so that read access can be limited to those Secrets. The
+// initial reconciliation completes before the function returns and fails on
+// invalid policies.
+func startTargetTLSController(
+	ctx context.Context,
+	client kubernetes.Interface,
+	namespace, name string,
+	clientCertSecrets []string,
+	defaultRootCAs *x509.CertPool,
+	newTransport func(*tls.Config) closeIdleRoundTripper,
+	transports *targetTransports,
+) error {
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		targetTLSInformerResyncPeriod,
+		informers.WithNamespace(namespace),
+	)
+	configMapInformer := informerFactory.Core().V1().ConfigMaps()
+	informerFactories := []informers.SharedInformerFactory{informerFactory}
+	sharedInformers := []factory.Informer{configMapInformer.Informer()}
+	hasSynced := []cache.InformerSynced{configMapInformer.Informer().HasSynced}
+
+	secretListers := make(map[string]listerscorev1.SecretLister, len(clientCertSecrets))
+	for _, secretName := range clientCertSecrets {
+		secretInformerFactory := informers.NewSharedInformerFactoryWithOptions(
+			client,
+			targetTLSInformerResyncPeriod,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", secretName).String()
+			}),
+		)
+		secretInformer := secretInformerFactory.Core().V1().Secrets()
+		secretListers[secretName] = secretInformer.Lister()
+		informerFactories = append(informerFactories, secretInformerFactory)
+		sharedInformers = append(sharedInformers, secretInformer.Informer())
+		hasSynced = append(hasSynced, secretInformer.Informer().HasSynced)
+	}
+
+	reconciler := &targetTLSController{
+		namespace:       namespace,
+		name:            name,
+		configMapLister: configMapInformer.Lister(),
+		secretListers:   secretListers,
+		newTransport:    newTransport,
+		defaultRootCAs:  defaultRootCAs,
+		transports:      transports,
+	}
+	controller := factory.New().
+		WithInformersQueueKeysFunc(factory.DefaultQueueKeysFunc, sharedInformers...).
+		WithSync(func(ctx context.Context, _ factory.SyncContext, _ string) error {
+			return reconciler.reconcile(ctx)
+		}).
+		ToController("target-tls-controller")
+
+	for _, f := range informerFactories {
+		f.Start(ctx.Done())
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), hasSynced...) {
+		return fmt.Errorf("failed to sync target TLS informers: %w", ctx.Err())
+	}
+	if err := reconciler.reconcile(ctx); err != nil {
+		return err
+	}
+
+	go controller.Run(ctx, 1)
+	return nil
+}
+
+func (c *targetTLSController) reconcile(ctx context.Context) error {
+	logger := klog.FromContext(ctx)
+
+	var policies []targetTLSPolicy
+	configMap, err := c.configMapLister.ConfigMaps(c.namespace).Get(c.name)
+	switch {
+	case apierrors.IsNotFound(err):
+		// without policies every target uses the default transport
+	case err != nil:
+		return fmt.Errorf("failed to get target TLS ConfigMap %s/%s from informer cache: %w", c.namespace, c.name, err)
+	default:
+		policies, err = parseTargetTLSPolicies([]byte(configMap.Data[targetTLSPoliciesKey]))
+		if err != nil {
+			if !c.transports.applied() {
+				// without applied policies every target would silently use the
+				// default transport
+				return fmt.Errorf("invalid target TLS policies in ConfigMap %s/%s: %w", c.namespace, c.name, err)
+			}
+			// keep the previous transports rather than silently dropping the
+			// TLS requirements of every target
+			logger.Error(err, "invalid target TLS policies", "namespace", c.namespace, "name", c.name)
+			return nil
+		}
+	}
+
+	hash := sha256.New()
+	configs := make(map[string]*tls.Config, len(policies))
+	errs := make(map[string]error)
+	for _, policy := range policies {
+		host := backendAudience(policy.Namespace, policy.Service)
+		config, inputs, err := c.tlsConfig(policy)
+		fmt.Fprintf(hash, "%s\x00%+v\x00", host, policy)
+		if err != nil {
+			fmt.Fprintf(hash, "error:%v\x00", err)
+			errs[host] = err
+			continue
+		}
+		hash.Write(inputs)
+		configs[host] = config
+	}
+
+	transports := make(map[string]targetTransport, len(policies))
+	for host, err := range errs {
+		transports[host] = targetTransport{err: err}
+	}
+	for host, config := range configs {
+		transports[host] = targetTransport{transport: c.newTransport(config)}
+	}
+
+	if !c.transports.replace(fmt.Sprintf("%x", hash.Sum(nil)), transports) {
+		// the unused transports have no connections yet
+		return nil
+	}
+
+	hosts := make([]string, 0, len(transports))
+	for host, transport := range transports {
+		hosts = append(hosts, host)
+		if transport.err != nil {
+			logger.Error(transport.err, "target TLS policy is unavailable; requests to the target fail", "target", host)
+		}
+	}
+	sort.Strings(hosts)
+	logger.Info("target TLS policies reconciled", "targets", hosts)
+	return nil
+}
+
+// tlsConfig builds the client configuration of a policy and returns the raw
+// referenced data so that changes to it can be detected.
+func (c *targetTLSController) tlsConfig(policy targetTLSPolicy) (*tls.Config, []byte, error) {
+	var inputs []byte
+	config := &tls.Config{
+		RootCAs:            c.defaultRootCAs,
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         policy.ServerName,
+		InsecureSkipVerify: policy.InsecureSkipVerify, //nolint:gosec // explicit per-target opt-in
+	}
+
+	if policy.CAConfigMap != "" {
+		configMap, err := c.configMapLister.ConfigMaps(c.namespace).Get(policy.CAConfigMap)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get CA ConfigMap %s/%s: %w", c.namespace, policy.CAConfigMap, err)
+		}
+		caBundle, ok := configMap.Data[targetTLSCAKey]
+		if !ok {
+			return nil, nil, fmt.Errorf("CA ConfigMap %s/%s does not contain %s", c.namespace, policy.CAConfigMap, targetTLSCAKey)
+		}
+		pool, err := certutil.NewPoolFromBytes([]byte(caBundle))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load CA ConfigMap %s/%s: %w", c.namespace, policy.CAConfigMap, err)
+		}
+		config.RootCAs = pool
+		inputs = append(inputs, caBundle...)
+	}
+
+	if policy.ClientCertSecret != "" {
+		secretLister, ok := c.secretListers[policy.ClientCertSecret]
+		if !ok {
+			return nil, nil, fmt.Errorf("client certificate Secret %s/%s is not listed in --target-tls-client-cert-secrets",
+				c.namespace, policy.ClientCertSecret)
+		}
+		secret, err := secretLister.Secrets(c.namespace).Get(policy.ClientCertSecret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get client certificate Secret %s/%s: %w", c.namespace, policy.ClientCertSecret, err)
+		}
+		certificate, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load client certificate Secret %s/%s: %w", c.namespace, policy.ClientCertSecret, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+		inputs = append(inputs, secret.Data[corev1.TLSCertKey]...)
+		inputs = append(inputs, secret.Data[corev1.TLSPrivateKeyKey]...)
+	}
+
+	return config, inputs, nil
+}
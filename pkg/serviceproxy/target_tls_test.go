@@ -0,0 +1,254 @@
+package serviceproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+func TestParseTargetTLSPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			data: `
+targets:
+- namespace: vault
+  service: vault
+  caConfigMap: vault-ca
+  clientCertSecret: vault-client
+  serverName: vault.example.com
+- namespace: lab
+  service: legacy
+  insecureSkipVerify: true
+`,
+		},
+		{name: "empty", data: ""},
+		{name: "unknown field", data: "targets:\n- namespace: a\n  service: b\n  ca: x\n", wantErr: "unknown field"},
+		{name: "missing service", data: "targets:\n- namespace: a\n", wantErr: "requires namespace and service"},
+		{
+			name:    "insecure with CA",
+			data:    "targets:\n- namespace: a\n  service: b\n  caConfigMap: ca\n  insecureSkipVerify: true\n",
+			wantErr: "must not set both",
+		},
+		{name: "duplicate", data: "targets:\n- namespace: a\n  service: b\n- namespace: a\n  service: b\n", wantErr: "duplicate"},
+		{name: "kube-apiserver", data: "targets:\n- namespace: default\n  service: kubernetes\n", wantErr: "kube-apiserver"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTargetTLSPolicies([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTargetTLSControllerMutualTLS(t *testing.T) {
+	const namespace = "addon"
+
+	clientCertPEM, clientKeyPEM := newTestClientCertificate(t, "backup-operator")
+	clientCAs, err := certutil.NewPoolFromBytes(clientCertPEM)
+	if err != nil {
+		t.Fatalf("failed to load client CA: %v", err)
+	}
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	backend.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	backend.StartTLS()
+	defer backend.Close()
+	serverCAPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "target-tls"},
+			Data: map[string]string{targetTLSPoliciesKey: `
+targets:
+- namespace: etcd
+  service: etcd
+  caConfigMap: etcd-ca
+  clientCertSecret: etcd-client
+  serverName: example.com
+- namespace: vault
+  service: vault
+  clientCertSecret: missing
+- namespace: backup
+  service: backup
+  clientCertSecret: unlisted
+`},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "etcd-ca"},
+			Data:       map[string]string{targetTLSCAKey: string(serverCAPEM)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "etcd-client"},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       clientCertPEM,
+				corev1.TLSPrivateKeyKey: clientKeyPEM,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "unlisted"},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       clientCertPEM,
+				corev1.TLSPrivateKeyKey: clientKeyPEM,
+			},
+		},
+	)
+
+	s := &serviceProxy{rootCAs: x509.NewCertPool()}
+	transports := &targetTransports{}
+	if err := startTargetTLSController(t.Context(), client, namespace, "target-tls", []string{"etcd-client", "missing"}, s.rootCAs, s.newTargetTransport, transports); err != nil {
+		t.Fatalf("failed to start controller: %v", err)
+	}
+
+	etcd, ok := transports.get("etcd.etcd.svc")
+	if !ok || etcd.err != nil {
+		t.Fatalf("etcd transport = %+v, %t", etcd, ok)
+	}
+	resp, err := (&http.Client{Transport: etcd.transport, Timeout: 5 * time.Second}).Get(backend.URL)
+	if err != nil {
+		t.Fatalf("mTLS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	vault, ok := transports.get("vault.vault.svc")
+	if !ok || vault.err == nil {
+		t.Fatalf("expected the vault policy to fail closed, got %+v, %t", vault, ok)
+	}
+	backup, ok := transports.get("backup.backup.svc")
+	if !ok || backup.err == nil || !strings.Contains(backup.err.Error(), "not listed") {
+		t.Fatalf("expected the policy referencing an unlisted Secret to fail closed, got %+v, %t", backup, ok)
+	}
+	if _, ok := transports.get("other.default.svc"); ok {
+		t.Fatal("targets without a policy must use the default transport")
+	}
+}
+
+func TestTargetTLSControllerInvalidPolicies(t *testing.T) {
+	const namespace = "addon"
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "target-tls"},
+		Data:       map[string]string{targetTLSPoliciesKey: "targets:\n- namespace: etcd\n"},
+	}
+	s := &serviceProxy{rootCAs: x509.NewCertPool()}
+	err := startTargetTLSController(t.Context(), fake.NewSimpleClientset(configMap), namespace, "target-tls", nil,
+		s.rootCAs, s.newTargetTransport, &targetTransports{})
+	if err == nil || !strings.Contains(err.Error(), "invalid target TLS policies") {
+		t.Fatalf("expected invalid initial policies to fail the start, got %v", err)
+	}
+
+	// an invalid update keeps the applied policies
+	configMap = configMap.DeepCopy()
+	configMap.Data[targetTLSPoliciesKey] = "targets:\n- namespace: etcd\n  service: etcd\n  insecureSkipVerify: true\n"
+	client := fake.NewSimpleClientset(configMap)
+	transports := &targetTransports{}
+	if err := startTargetTLSController(t.Context(), client, namespace, "target-tls", nil,
+		s.rootCAs, s.newTargetTransport, transports); err != nil {
+		t.Fatalf("failed to start controller: %v", err)
+	}
+	configMap.Data[targetTLSPoliciesKey] = "targets: ["
+	if _, err := client.CoreV1().ConfigMaps(namespace).Update(t.Context(), configMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update policies: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if etcd, ok := transports.get("etcd.etcd.svc"); !ok || etcd.err != nil {
+		t.Fatalf("expected the applied etcd policy to be kept, got %+v, %t", etcd, ok)
+	}
+}
+
+func TestServeHTTPRejectsUnavailableTargetTLSPolicy(t *testing.T) {
+	defaultTransport := &recordingRoundTripper{}
+	transports := &targetTransports{}
+	transports.replace("test", map[string]targetTransport{
+		"vault.vault.svc": {err: errors.New("missing client certificate")},
+	})
+	s := &serviceProxy{proxyTransport: defaultTransport, targetTransports: transports}
+
+	req := httptest.NewRequest(http.MethodGet, "https://service-proxy.example/v1/sys/health", nil)
+	req.Header.Set(utils.HeaderClusterProxyProto, "https")
+	req.Header.Set(utils.HeaderClusterProxyNamespace, "vault")
+	req.Header.Set(utils.HeaderClusterProxyService, "vault")
+	req.Header.Set(utils.HeaderClusterProxyPort, "8200")
+	recorder := httptest.NewRecorder()
+
+	s.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("unexpected status: got %d, want 502", recorder.Code)
+	}
+	if roundTrips, _ := defaultTransport.counts(); roundTrips != 0 {
+		t.Fatal("a target with an unavailable policy must not fall back to the default transport")
+	}
+}
+
+// newTestClientCertificate returns a self-signed certificate usable for TLS
+// client authentication and its key.
+func newTestClientCertificate(t *testing.T, commonName string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM
+}
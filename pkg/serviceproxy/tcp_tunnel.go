@@ -0,0 +1,133 @@
+package serviceproxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/pflag"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+const defaultTCPTunnelDialTimeout = 10 * time.Second
+
+type tcpTunnelOptions struct {
+	enabled     bool
+	dialTimeout time.Duration
+
+	// dial connects to the target Service; tests override it.
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func newTCPTunnelOptions() tcpTunnelOptions {
+	return tcpTunnelOptions{
+		dialTimeout: defaultTCPTunnelDialTimeout,
+	}
+}
+
+func (o *tcpTunnelOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.enabled, "enable-tcp-tunnel", o.enabled, "Accept raw TCP tunnels to Services. Tunnels are authenticated like other requests and require create permission on the services/proxy subresource of the target Service.")
+	flags.DurationVar(&o.dialTimeout, "tcp-tunnel-dial-timeout", o.dialTimeout, "The maximum amount of time to wait for a TCP tunnel target to accept the connection.")
+}
+
+func (o tcpTunnelOptions) validate() error {
+	if o.enabled && o.dialTimeout <= 0 {
+		return fmt.Errorf("--tcp-tunnel-dial-timeout must be positive")
+	}
+	return nil
+}
+
+func (o tcpTunnelOptions) dialContext(ctx context.Context, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.dialTimeout)
+	defer cancel()
+	if o.dial != nil {
+		return o.dial(ctx, "tcp", addr)
+	}
+	return (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext(ctx, "tcp", addr)
+}
+
+// serveTCPTunnel authenticates the caller, authorizes the tunnel with a
+// SubjectAccessReview on the managed cluster, and then streams bytes to the
+// target Service. Raw TCP backends cannot validate the caller's credential, so
+// the tunnel is only opened for identities allowed to proxy to the Service.
+func (s *serviceProxy) serveTCPTunnel(ctx context.Context, wr http.ResponseWriter, req *http.Request, target *url.URL) {
+	logger := klog.FromContext(ctx)
+
+	if !s.tcpTunnel.enabled {
+		http.Error(wr, "TCP tunnels are not enabled on this managed cluster", http.StatusForbidden)
+		return
+	}
+	if !utils.IsTCPTunnelRequest(req) {
+		http.Error(wr, fmt.Sprintf("TCP tunnels require an upgrade to %s", utils.TCPTunnelProtocol), http.StatusBadRequest)
+		return
+	}
+
+	provider, info, err := s.authenticateRequest(ctx, req)
+	if err != nil {
+		logger.Error(err, "authentication failed")
+		http.Error(wr, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	identity := effectiveIdentity(provider, info)
+
+	namespace := req.Header.Get(utils.HeaderClusterProxyNamespace)
+	service := req.Header.Get(utils.HeaderClusterProxyService)
+	allowed, reason, err := s.authorizeTCPTunnel(ctx, identity, namespace, service)
+	if err != nil {
+		logger.Error(err, "failed to authorize TCP tunnel")
+		http.Error(wr, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		logger.V(4).Info("TCP tunnel denied", "user", identity.GetName(), "reason", reason)
+		http.Error(wr, fmt.Sprintf("user %q cannot create services/proxy for %s/%s", identity.GetName(), namespace, service),
+			http.StatusForbidden)
+		return
+	}
+
+	backend, err := s.tcpTunnel.dialContext(ctx, target.Host)
+	if err != nil {
+		logger.Error(err, "failed to dial TCP tunnel target")
+		http.Error(wr, fmt.Sprintf("failed to connect to %s", target.Host), http.StatusBadGateway)
+		return
+	}
+
+	logger.V(4).Info("TCP tunnel opened", "user", identity.GetName())
+	if err := utils.ServeTCPTunnel(wr, backend, s.streams.IdleTimeout); err != nil {
+		logger.V(4).Info("TCP tunnel closed", "err", err)
+	}
+}
+
+func (s *serviceProxy) authorizeTCPTunnel(ctx context.Context, identity user.Info, namespace, service string) (bool, string, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(identity.GetExtra()))
+	for key, values := range identity.GetExtra() {
+		extra[key] = values
+	}
+	review, err := s.managedClusterKubeClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   identity.GetName(),
+			UID:    identity.GetUID(),
+			Groups: identity.GetGroups(),
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        "create",
+				Resource:    "services",
+				Subresource: "proxy",
+				Name:        service,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", err
+	}
+	return review.Status.Allowed, review.Status.Reason, nil
+}
@@ -0,0 +1,163 @@
+package serviceproxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+// startEchoBackend serves a TCP echo server standing in for a database.
+func startEchoBackend(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+// openTCPTunnel sends a tunnel upgrade for postgres.db.svc:5432 to the
+// service-proxy and returns the response and, on success, the connection.
+func openTCPTunnel(t *testing.T, address, token string) (*http.Response, net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("failed to dial service-proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+address+"/", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", utils.TCPTunnelProtocol)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(utils.HeaderClusterProxyProto, utils.ProtoTCP)
+	req.Header.Set(utils.HeaderClusterProxyNamespace, "db")
+	req.Header.Set(utils.HeaderClusterProxyService, "postgres")
+	req.Header.Set(utils.HeaderClusterProxyPort, "5432")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("failed to write upgrade request: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatalf("failed to read upgrade response: %v", err)
+	}
+	return resp, conn, reader
+}
+
+func TestServeHTTPTunnelsTCP(t *testing.T) {
+	backend := startEchoBackend(t)
+
+	var reviews []authorizationv1.SubjectAccessReviewSpec
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviews = append(reviews, review.Spec)
+		review.Status.Allowed = review.Spec.User == "dba"
+		return true, review, nil
+	})
+
+	var dialed string
+	s := &serviceProxy{
+		managedClusterKubeClient: client,
+		tcpTunnel: tcpTunnelOptions{
+			enabled:     true,
+			dialTimeout: defaultTCPTunnelDialTimeout,
+			dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialed = addr
+				return (&net.Dialer{}).DialContext(ctx, network, backend.Addr().String())
+			},
+		},
+	}
+	s.authProviders = []authProvider{
+		&hubAuthProvider{
+			Token: authenticator.TokenFunc(func(_ context.Context, token string) (*authenticator.Response, bool, error) {
+				switch token {
+				case "dba-token":
+					return &authenticator.Response{User: &user.DefaultInfo{Name: "dba"}}, true, nil
+				case "dev-token":
+					return &authenticator.Response{User: &user.DefaultInfo{Name: "dev"}}, true, nil
+				}
+				return nil, false, nil
+			}),
+		},
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: utils.NewConnectionTracker().Handler(s)}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	resp, _, _ := openTCPTunnel(t, listener.Addr().String(), "unknown")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated tunnel status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	resp, _, _ = openTCPTunnel(t, listener.Addr().String(), "dev-token")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("unauthorized tunnel status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	resp, conn, reader := openTCPTunnel(t, listener.Addr().String(), "dba-token")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("tunnel status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	if _, err := conn.Write([]byte("SELECT 1;\n")); err != nil {
+		t.Fatalf("failed to write to tunnel: %v", err)
+	}
+	if line, err := reader.ReadString('\n'); err != nil || line != "SELECT 1;\n" {
+		t.Fatalf("unexpected echo %q: %v", line, err)
+	}
+
+	if dialed != "postgres.db.svc:5432" {
+		t.Fatalf("dialed %q, want postgres.db.svc:5432", dialed)
+	}
+	last := reviews[len(reviews)-1].ResourceAttributes
+	if last.Verb != "create" || last.Resource != "services" || last.Subresource != "proxy" ||
+		last.Namespace != "db" || last.Name != "postgres" {
+		t.Fatalf("unexpected access review %+v", last)
+	}
+}
+
+func TestServeHTTPRejectsDisabledTCPTunnel(t *testing.T) {
+	s := &serviceProxy{}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: s}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	resp, _, _ := openTCPTunnel(t, listener.Addr().String(), "dba-token")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("disabled tunnel status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
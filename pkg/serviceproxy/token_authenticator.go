@@ -4,13 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
-	"time"
 
 	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
-	"k8s.io/apiserver/pkg/authentication/token/cache"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
@@ -32,17 +31,9 @@ var authRejectionPatterns = []string{
 func newTokenReviewAuthenticator(
 	client kubernetes.Interface,
 	providerName string,
-	cacheTTL time.Duration,
+	audiences []string,
 ) authenticator.Token {
-	delegate := &tokenReviewAuthenticator{client: client, name: providerName}
-	if cacheTTL <= 0 {
-		return delegate
-	}
-
-	// cacheErrs=false avoids caching infrastructure failures. Caching both
-	// successful and unauthenticated results prevents repeated fallback
-	// TokenReviews during bursts of requests for the same token.
-	return cache.New(delegate, false, cacheTTL, cacheTTL)
+	return &tokenReviewAuthenticator{client: client, name: providerName, audiences: audiences}
 }
 
 // isAuthRejection reports whether a TokenReview Status.Error indicates an
@@ -58,10 +49,12 @@ func isAuthRejection(statusError string) bool {
 }
 
 // tokenReviewAuthenticator implements authenticator.Token by calling the
-// Kubernetes TokenReview API against a specific cluster.
+// Kubernetes TokenReview API against a specific cluster. When audiences are
+// set, only tokens issued for at least one of them are accepted.
 type tokenReviewAuthenticator struct {
-	client kubernetes.Interface
-	name   string // cluster name for logging (e.g., "managed cluster", "hub")
+	client    kubernetes.Interface
+	name      string // cluster name for logging (e.g., "managed cluster", "hub")
+	audiences []string
 }
 
 // AuthenticateToken calls the TokenReview API and returns the result.
@@ -71,7 +64,8 @@ func (a *tokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token
 
 	tokenReview, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
 		Spec: authenticationv1.TokenReviewSpec{
-			Token: token,
+			Token:     token,
+			Audiences: a.audiences,
 		},
 	}, metav1.CreateOptions{})
 	if err != nil {
@@ -83,6 +77,7 @@ func (a *tokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token
 		"authenticated", tokenReview.Status.Authenticated,
 		"username", tokenReview.Status.User.Username,
 		"groups", tokenReview.Status.User.Groups,
+		"audiences", tokenReview.Status.Audiences,
 	)
 
 	if tokenReview.Status.Error != "" {
@@ -96,7 +91,18 @@ func (a *tokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token
 		return nil, false, nil
 	}
 
+	// The apiserver reports the requested audiences the token is valid for.
+	// Authenticators that do not support audiences report none, so an empty
+	// intersection must not be mistaken for an unrestricted token.
+	if len(a.audiences) > 0 && !slices.ContainsFunc(tokenReview.Status.Audiences, func(audience string) bool {
+		return slices.Contains(a.audiences, audience)
+	}) {
+		return nil, false, fmt.Errorf("%s TokenReview: token audiences %v do not include any of %v: %w",
+			a.name, tokenReview.Status.Audiences, a.audiences, ErrTokenNotAuthenticated)
+	}
+
 	return &authenticator.Response{
+		Audiences: tokenReview.Status.Audiences,
 		User: &user.DefaultInfo{
 			Name:   tokenReview.Status.User.Username,
 			UID:    tokenReview.Status.User.UID,
@@ -4,9 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
-	"time"
 
 	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -69,6 +69,53 @@ func TestTokenReviewAuthenticator_Unauthenticated(t *testing.T) {
 	}
 }
 
+func TestTokenReviewAuthenticator_Audiences(t *testing.T) {
+	tests := []struct {
+		name            string
+		statusAudiences []string
+		wantAuth        bool
+	}{
+		{name: "token issued for the expected audience", statusAudiences: []string{"cluster-proxy.open-cluster-management.io"}, wantAuth: true},
+		{name: "token issued for another audience", statusAudiences: nil},
+		{name: "unexpected audience reported", statusAudiences: []string{"vault"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestedAudiences []string
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				requestedAudiences = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).Spec.Audiences
+				return true, &authenticationv1.TokenReview{
+					Status: authenticationv1.TokenReviewStatus{
+						Authenticated: true,
+						User:          authenticationv1.UserInfo{Username: "system:serviceaccount:ns:sa"},
+						Audiences:     tt.statusAudiences,
+					},
+				}, nil
+			})
+
+			authn := &tokenReviewAuthenticator{client: client, name: "test", audiences: []string{"cluster-proxy.open-cluster-management.io"}}
+			resp, ok, err := authn.AuthenticateToken(context.Background(), "token")
+			if !slices.Equal(requestedAudiences, []string{"cluster-proxy.open-cluster-management.io"}) {
+				t.Fatalf("TokenReview audiences = %v", requestedAudiences)
+			}
+			if !tt.wantAuth {
+				if ok || !errors.Is(err, ErrTokenNotAuthenticated) {
+					t.Fatalf("expected rejection, got authenticated=%t err=%v", ok, err)
+				}
+				return
+			}
+			if err != nil || !ok {
+				t.Fatalf("unexpected result: authenticated=%t err=%v", ok, err)
+			}
+			if !slices.Equal(resp.Audiences, tt.statusAudiences) {
+				t.Fatalf("response audiences = %v", resp.Audiences)
+			}
+		})
+	}
+}
+
 func TestConvertExtra(t *testing.T) {
 	extra := map[string]authenticationv1.ExtraValue{
 		"example.org/scope": {"read", "write"},
@@ -205,17 +252,3 @@ func TestTokenReviewAuthenticator_StatusError_UnknownError(t *testing.T) {
 		t.Fatalf("expected Status.Error in error message, got: %v", err)
 	}
 }
-
-func TestNewTokenReviewAuthenticatorCache(t *testing.T) {
-	client := fake.NewSimpleClientset()
-
-	uncached := newTokenReviewAuthenticator(client, managedClusterAuthProviderMetadata.displayName, 0)
-	if _, ok := uncached.(*tokenReviewAuthenticator); !ok {
-		t.Fatalf("uncached authenticator type = %T, want *tokenReviewAuthenticator", uncached)
-	}
-
-	cached := newTokenReviewAuthenticator(client, managedClusterAuthProviderMetadata.displayName, time.Second)
-	if _, ok := cached.(*tokenReviewAuthenticator); ok {
-		t.Fatalf("cached authenticator was not wrapped: %T", cached)
-	}
-}
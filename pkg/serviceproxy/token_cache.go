@@ -0,0 +1,252 @@
+package serviceproxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// defaultTokenCacheSize bounds the memory of cached authentication results
+	// shared by all providers.
+	defaultTokenCacheSize = 10000
+	// defaultTokenCacheSuccessTTL and defaultTokenCacheFailureTTL keep the
+	// previous single TokenReview cache TTL of 10s.
+	defaultTokenCacheSuccessTTL = 10 * time.Second
+	defaultTokenCacheFailureTTL = 10 * time.Second
+)
+
+var (
+	tokenCacheRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      "open_cluster_management",
+			Subsystem:      "cluster_proxy_service_proxy",
+			Name:           "token_cache_requests_total",
+			Help:           "Number of token authentication cache lookups, partitioned by auth provider and result (hit or miss).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"provider", "result"},
+	)
+	tokenCacheEvictions = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Namespace:      "open_cluster_management",
+			Subsystem:      "cluster_proxy_service_proxy",
+			Name:           "token_cache_evictions_total",
+			Help:           "Number of token authentication cache entries evicted to stay within the cache size.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(tokenCacheRequests, tokenCacheEvictions)
+}
+
+type tokenCacheOptions struct {
+	size       int
+	successTTL time.Duration
+	failureTTL time.Duration
+}
+
+func newTokenCacheOptions() tokenCacheOptions {
+	return tokenCacheOptions{
+		size:       defaultTokenCacheSize,
+		successTTL: defaultTokenCacheSuccessTTL,
+		failureTTL: defaultTokenCacheFailureTTL,
+	}
+}
+
+func (o *tokenCacheOptions) addFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&o.size, "token-cache-size", o.size, "The maximum number of authentication results cached across all auth providers. The least recently used entries are evicted first. Set to 0 to disable caching.")
+	flags.DurationVar(&o.successTTL, "token-cache-success-ttl", o.successTTL, "TTL for cached successful authentications. Entries never outlive the exp claim of the token. Set to 0 to disable caching successes.")
+	flags.DurationVar(&o.failureTTL, "token-cache-failure-ttl", o.failureTTL, "TTL for cached rejected tokens. Infrastructure errors are never cached. Set to 0 to disable caching rejections.")
+
+	flags.Var(&legacyTokenReviewCacheTTL{options: o}, "token-review-cache-ttl", "TTL for cached authentication results, applied to both successes and rejections.")
+	_ = flags.MarkDeprecated("token-review-cache-ttl", "use --token-cache-success-ttl and --token-cache-failure-ttl instead")
+}
+
+func (o tokenCacheOptions) validate() error {
+	if o.size < 0 {
+		return fmt.Errorf("--token-cache-size must not be negative")
+	}
+	if o.successTTL < 0 || o.failureTTL < 0 {
+		return fmt.Errorf("--token-cache-success-ttl and --token-cache-failure-ttl must not be negative")
+	}
+	return nil
+}
+
+func (o tokenCacheOptions) enabled() bool {
+	return o.size > 0 && (o.successTTL > 0 || o.failureTTL > 0)
+}
+
+// legacyTokenReviewCacheTTL keeps --token-review-cache-ttl working by setting
+// both TTLs.
+type legacyTokenReviewCacheTTL struct {
+	options *tokenCacheOptions
+}
+
+func (v *legacyTokenReviewCacheTTL) String() string {
+	if v.options == nil {
+		return ""
+	}
+	return v.options.successTTL.String()
+}
+
+func (v *legacyTokenReviewCacheTTL) Set(value string) error {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	v.options.successTTL = ttl
+	v.options.failureTTL = ttl
+	return nil
+}
+
+func (*legacyTokenReviewCacheTTL) Type() string {
+	return "duration"
+}
+
+type tokenCacheKey struct {
+	provider  authProviderID
+	tokenHash [sha256.Size]byte
+}
+
+type tokenCacheEntry struct {
+	response      *authenticator.Response
+	authenticated bool
+	err           error
+	expiresAt     time.Time
+}
+
+// tokenCache is a size-bounded LRU of authentication results shared by all
+// auth providers. Keys hold token hashes, never the tokens themselves.
+type tokenCache struct {
+	options tokenCacheOptions
+	cache   *lru.Cache
+	group   singleflight.Group
+	now     func() time.Time
+}
+
+// newTokenCache returns nil when caching is disabled.
+func newTokenCache(options tokenCacheOptions) *tokenCache {
+	if !options.enabled() {
+		return nil
+	}
+	return &tokenCache{
+		options: options,
+		cache: lru.NewWithEvictionFunc(options.size, func(lru.Key, interface{}) {
+			tokenCacheEvictions.Inc()
+		}),
+		now: time.Now,
+	}
+}
+
+// wrap caches the results of authn under the provider's ID. A nil cache
+// returns authn unchanged.
+func (c *tokenCache) wrap(provider authProviderID, authn authenticator.Token) authenticator.Token {
+	if c == nil {
+		return authn
+	}
+	return &cachedTokenAuthenticator{cache: c, provider: provider, delegate: authn}
+}
+
+type cachedTokenAuthenticator struct {
+	cache    *tokenCache
+	provider authProviderID
+	delegate authenticator.Token
+}
+
+func (a *cachedTokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	c := a.cache
+	key := tokenCacheKey{provider: a.provider, tokenHash: sha256.Sum256([]byte(token))}
+
+	if value, ok := c.cache.Get(key); ok {
+		entry := value.(*tokenCacheEntry)
+		if c.now().Before(entry.expiresAt) {
+			tokenCacheRequests.WithLabelValues(string(a.provider), "hit").Inc()
+			return entry.response, entry.authenticated, entry.err
+		}
+	}
+	tokenCacheRequests.WithLabelValues(string(a.provider), "miss").Inc()
+
+	// concurrent misses for the same token share one delegate call. The call
+	// is detached from the first caller's cancellation so that it does not
+	// fail the other callers; each caller still stops waiting on its own.
+	result := c.group.DoChan(fmt.Sprintf("%s/%x", key.provider, key.tokenHash), func() (interface{}, error) {
+		resp, authenticated, err := a.delegate.AuthenticateToken(context.WithoutCancel(ctx), token)
+		entry := &tokenCacheEntry{response: resp, authenticated: authenticated, err: err}
+		c.add(key, entry, token)
+		return entry, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case shared := <-result:
+		entry := shared.Val.(*tokenCacheEntry)
+		return entry.response, entry.authenticated, entry.err
+	}
+}
+
+func (c *tokenCache) add(key tokenCacheKey, entry *tokenCacheEntry, token string) {
+	now := c.now()
+	switch {
+	case entry.err == nil && entry.authenticated:
+		if c.options.successTTL <= 0 {
+			return
+		}
+		entry.expiresAt = now.Add(c.options.successTTL)
+		if exp, ok := tokenExpiry(token); ok && exp.Before(entry.expiresAt) {
+			entry.expiresAt = exp
+		}
+	case entry.err == nil || errors.Is(entry.err, ErrTokenNotAuthenticated):
+		if c.options.failureTTL <= 0 {
+			return
+		}
+		entry.expiresAt = now.Add(c.options.failureTTL)
+	default:
+		// infrastructure errors are retried by the next request
+		return
+	}
+	if !entry.expiresAt.After(now) {
+		return
+	}
+	c.cache.Add(key, entry)
+}
+
+// tokenExpiry returns the exp claim of a JWT without verifying it. The value
+// only shortens how long an already authenticated result is cached.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	claims := struct {
+		Expiry *json.Number `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Expiry == nil {
+		return time.Time{}, false
+	}
+	exp, err := claims.Expiry.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+var _ authenticator.Token = (*cachedTokenAuthenticator)(nil)
@@ -0,0 +1,210 @@
+package serviceproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+// scriptedAuthenticator counts delegate calls and returns the result for the
+// token.
+type scriptedAuthenticator struct {
+	calls   atomic.Int32
+	results map[string]error
+	block   chan struct{}
+}
+
+func (a *scriptedAuthenticator) AuthenticateToken(_ context.Context, token string) (*authenticator.Response, bool, error) {
+	a.calls.Add(1)
+	if a.block != nil {
+		<-a.block
+	}
+	err, known := a.results[token]
+	switch {
+	case !known:
+		return &authenticator.Response{User: &user.DefaultInfo{Name: token}}, true, nil
+	case err == nil:
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+func newTestTokenCache(t *testing.T, options tokenCacheOptions) (*tokenCache, *time.Time) {
+	t.Helper()
+	cache := newTokenCache(options)
+	if cache == nil {
+		t.Fatal("expected an enabled cache")
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+func testJWTWithExpiry(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "e30." + payload + ".sig"
+}
+
+func TestTokenCacheTTLs(t *testing.T) {
+	cache, now := newTestTokenCache(t, tokenCacheOptions{size: 10, successTTL: time.Minute, failureTTL: 5 * time.Second})
+	delegate := &scriptedAuthenticator{results: map[string]error{
+		"unauthenticated": nil,
+		"rejected":        fmt.Errorf("invalid: %w", ErrTokenNotAuthenticated),
+		"unavailable":     errors.New("connection refused"),
+	}}
+	authn := cache.wrap(hubAuthProviderID, delegate)
+
+	authenticate := func(token string) {
+		t.Helper()
+		_, _, _ = authn.AuthenticateToken(t.Context(), token)
+	}
+	expectCalls := func(want int32) {
+		t.Helper()
+		if got := delegate.calls.Swap(0); got != want {
+			t.Fatalf("delegate calls = %d, want %d", got, want)
+		}
+	}
+
+	for _, token := range []string{"valid", "unauthenticated", "rejected", "unavailable"} {
+		authenticate(token)
+		authenticate(token)
+	}
+	// infrastructure errors are never cached
+	expectCalls(5)
+
+	*now = now.Add(10 * time.Second)
+	for _, token := range []string{"valid", "unauthenticated", "rejected"} {
+		authenticate(token)
+	}
+	// only the negative entries expired
+	expectCalls(2)
+
+	*now = now.Add(time.Minute)
+	authenticate("valid")
+	expectCalls(1)
+}
+
+func TestTokenCacheHonorsTokenExpiry(t *testing.T) {
+	cache, now := newTestTokenCache(t, tokenCacheOptions{size: 10, successTTL: time.Hour})
+	delegate := &scriptedAuthenticator{}
+	authn := cache.wrap(oidcAuthProviderID, delegate)
+	token := testJWTWithExpiry(now.Add(30 * time.Second))
+
+	_, _, _ = authn.AuthenticateToken(t.Context(), token)
+	_, _, _ = authn.AuthenticateToken(t.Context(), token)
+	if got := delegate.calls.Load(); got != 1 {
+		t.Fatalf("delegate calls = %d, want 1", got)
+	}
+
+	*now = now.Add(30 * time.Second)
+	_, _, _ = authn.AuthenticateToken(t.Context(), token)
+	if got := delegate.calls.Load(); got != 2 {
+		t.Fatal("a cached result must not outlive the token expiry")
+	}
+}
+
+func TestTokenCacheSharedAcrossProviders(t *testing.T) {
+	cache, _ := newTestTokenCache(t, tokenCacheOptions{size: 2, successTTL: time.Minute})
+	managed := &scriptedAuthenticator{results: map[string]error{"hub-token": nil}}
+	hub := &scriptedAuthenticator{}
+	managedAuthn := cache.wrap(managedClusterAuthProviderID, managed)
+	hubAuthn := cache.wrap(hubAuthProviderID, hub)
+
+	evictionsBefore, err := testutil.GetCounterMetricValue(tokenCacheEvictions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hitsBefore, err := testutil.GetCounterMetricValue(tokenCacheRequests.WithLabelValues(string(hubAuthProviderID), "hit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same token has independent results per provider
+	_, managedOK, _ := managedAuthn.AuthenticateToken(t.Context(), "hub-token")
+	resp, hubOK, _ := hubAuthn.AuthenticateToken(t.Context(), "hub-token")
+	if managedOK || !hubOK || resp.User.GetName() != "hub-token" {
+		t.Fatalf("unexpected results: managed=%t hub=%t", managedOK, hubOK)
+	}
+	_, _, _ = hubAuthn.AuthenticateToken(t.Context(), "hub-token")
+	if hub.calls.Load() != 1 {
+		t.Fatalf("hub delegate calls = %d, want 1", hub.calls.Load())
+	}
+
+	// unauthenticated results are not cached without a failure TTL, so the
+	// third hub entry exceeds the shared size
+	_, _, _ = hubAuthn.AuthenticateToken(t.Context(), "second-token")
+	_, _, _ = hubAuthn.AuthenticateToken(t.Context(), "third-token")
+
+	evictions, _ := testutil.GetCounterMetricValue(tokenCacheEvictions)
+	if evictions-evictionsBefore != 1 {
+		t.Fatalf("evictions = %v, want 1", evictions-evictionsBefore)
+	}
+	hits, _ := testutil.GetCounterMetricValue(tokenCacheRequests.WithLabelValues(string(hubAuthProviderID), "hit"))
+	if hits-hitsBefore != 1 {
+		t.Fatalf("hits = %v, want 1", hits-hitsBefore)
+	}
+}
+
+func TestTokenCacheDeduplicatesConcurrentMisses(t *testing.T) {
+	cache, _ := newTestTokenCache(t, tokenCacheOptions{size: 10, successTTL: time.Minute})
+	delegate := &scriptedAuthenticator{block: make(chan struct{})}
+	authn := cache.wrap(hubAuthProviderID, delegate)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := authn.AuthenticateToken(context.Background(), "token"); !ok || err != nil {
+				t.Errorf("unexpected result: %t, %v", ok, err)
+			}
+		}()
+	}
+	for delegate.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(delegate.block)
+	wg.Wait()
+
+	if got := delegate.calls.Load(); got != 1 {
+		t.Fatalf("delegate calls = %d, want 1", got)
+	}
+}
+
+func TestTokenCacheOptions(t *testing.T) {
+	if newTokenCache(tokenCacheOptions{size: 0, successTTL: time.Minute}) != nil {
+		t.Fatal("a zero size must disable the cache")
+	}
+	if newTokenCache(tokenCacheOptions{size: 10}) != nil {
+		t.Fatal("zero TTLs must disable the cache")
+	}
+	var disabled *tokenCache
+	delegate := &scriptedAuthenticator{}
+	if disabled.wrap(hubAuthProviderID, delegate) != delegate {
+		t.Fatal("a disabled cache must not wrap the authenticator")
+	}
+
+	options := newTokenCacheOptions()
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	options.addFlags(flags)
+	if err := flags.Parse([]string{"--token-review-cache-ttl=1m"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if options.successTTL != time.Minute || options.failureTTL != time.Minute {
+		t.Fatalf("deprecated flag did not set both TTLs: %+v", options)
+	}
+	if err := (tokenCacheOptions{size: -1}).validate(); err == nil {
+		t.Fatal("expected an error for a negative size")
+	}
+}
@@ -0,0 +1,171 @@
+package serviceproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/pflag"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	webhookutil "k8s.io/apiserver/pkg/util/webhook"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+const (
+	webhookAuthProviderID authProviderID = "webhook"
+
+	// webhookInitialRetryDelay matches kube-apiserver's token webhook backoff.
+	webhookInitialRetryDelay = 500 * time.Millisecond
+)
+
+var webhookAuthProviderMetadata = authProviderMetadata{
+	id:                   webhookAuthProviderID,
+	displayName:          "authentication webhook",
+	authenticationTarget: "the authentication webhook",
+}
+
+type webhookAuthProvider struct {
+	authenticator.Token
+	impersonateUser impersonateUserFunc
+}
+
+func (*webhookAuthProvider) Metadata() authProviderMetadata {
+	return webhookAuthProviderMetadata
+}
+
+func (p *webhookAuthProvider) ApplyIdentity(ctx context.Context, req *http.Request, info user.Info) error {
+	return applyExternalIdentity(ctx, req, info, p.impersonateUser)
+}
+
+func (*webhookAuthProvider) MapIdentity(info user.Info) user.Info {
+	return externalIdentity(info)
+}
+
+// webhookTokenAuthenticator sends tokens as authentication.k8s.io/v1
+// TokenReviews to the webhook described by a kubeconfig file, like
+// kube-apiserver's --authentication-token-webhook-config-file. Unlike the
+// upstream authenticator it separates rejections from unreachable webhooks.
+type webhookTokenAuthenticator struct {
+	webhook *webhookutil.GenericWebhook
+}
+
+func newWebhookTokenAuthenticator(config *rest.Config) (*webhookTokenAuthenticator, error) {
+	localScheme := runtime.NewScheme()
+	if err := authenticationv1.AddToScheme(localScheme); err != nil {
+		return nil, err
+	}
+	groupVersions := []schema.GroupVersion{authenticationv1.SchemeGroupVersion}
+	if err := localScheme.SetVersionPriority(groupVersions...); err != nil {
+		return nil, err
+	}
+	webhook, err := webhookutil.NewGenericWebhook(
+		localScheme,
+		serializer.NewCodecFactory(localScheme),
+		config,
+		groupVersions,
+		webhookutil.DefaultRetryBackoffWithInitialDelay(webhookInitialRetryDelay),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authentication webhook client: %w", err)
+	}
+	return &webhookTokenAuthenticator{webhook: webhook}, nil
+}
+
+// AuthenticateToken returns an error wrapping ErrTokenNotAuthenticated when
+// the webhook answers with an error, and an infrastructure error when it
+// cannot be reached or answers with a non-2xx status.
+func (w *webhookTokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	request := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	result := w.webhook.WithExponentialBackoff(ctx, func() rest.Result {
+		return w.webhook.RestClient.Post().Body(request).Do(ctx)
+	})
+
+	review := &authenticationv1.TokenReview{}
+	if err := result.Into(review); err != nil {
+		return nil, false, fmt.Errorf("authentication webhook request failed: %w", err)
+	}
+
+	klog.FromContext(ctx).V(6).Info("authentication webhook completed",
+		"authenticated", review.Status.Authenticated,
+		"username", review.Status.User.Username,
+		"groups", review.Status.User.Groups,
+	)
+
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, false, fmt.Errorf("authentication webhook: %s: %w", review.Status.Error, ErrTokenNotAuthenticated)
+		}
+		return nil, false, nil
+	}
+
+	return &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   review.Status.User.Username,
+			UID:    review.Status.User.UID,
+			Groups: review.Status.User.Groups,
+			Extra:  convertExtra(review.Status.User.Extra),
+		},
+	}, true, nil
+}
+
+type webhookAuthProviderFactory struct {
+	configFile string
+}
+
+func newWebhookAuthProviderFactory() *webhookAuthProviderFactory {
+	return &webhookAuthProviderFactory{}
+}
+
+func (f *webhookAuthProviderFactory) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.configFile, "authentication-token-webhook-config-file", f.configFile, "The path to a kubeconfig file describing a TokenReview-compatible webhook, in the format of kube-apiserver's flag of the same name. Setting this enables webhook token authentication; the webhook must accept authentication.k8s.io/v1 TokenReviews.")
+}
+
+func (*webhookAuthProviderFactory) validate() error {
+	return nil
+}
+
+func (f *webhookAuthProviderFactory) enabled() bool {
+	return f.configFile != ""
+}
+
+func (f *webhookAuthProviderFactory) configFiles() []string {
+	if !f.enabled() {
+		return nil
+	}
+	return []string{f.configFile}
+}
+
+func (f *webhookAuthProviderFactory) build(_ context.Context, dependencies authProviderDependencies) (authProvider, error) {
+	config, err := webhookutil.LoadKubeconfig(f.configFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authentication webhook config: %w", err)
+	}
+	authn, err := newWebhookTokenAuthenticator(config)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookAuthProvider{
+		Token:           dependencies.tokenCache.wrap(webhookAuthProviderID, authn),
+		impersonateUser: dependencies.impersonateUser,
+	}, nil
+}
+
+func (f *webhookAuthProviderFactory) logConfiguration() {
+	klog.Infof("webhook token authentication enabled: configFile=%s", f.configFile)
+}
+
+var (
+	_ authProvider              = (*webhookAuthProvider)(nil)
+	_ identityMapper            = (*webhookAuthProvider)(nil)
+	_ authProviderFactory       = (*webhookAuthProviderFactory)(nil)
+	_ authProviderFactoryLogger = (*webhookAuthProviderFactory)(nil)
+)
@@ -0,0 +1,112 @@
+package serviceproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func writeTestWebhookKubeConfig(t *testing.T, server string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "webhook-kubeconfig")
+	kubeConfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: webhook
+  cluster:
+    server: %s
+users:
+- name: service-proxy
+contexts:
+- name: webhook
+  context:
+    cluster: webhook
+    user: service-proxy
+current-context: webhook
+`, server)
+	if err := os.WriteFile(path, []byte(kubeConfig), 0600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	return path
+}
+
+func TestWebhookAuthProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &authenticationv1.TokenReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch review.Spec.Token {
+		case "valid":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "alice",
+					Groups:   []string{"platform"},
+					Extra:    map[string]authenticationv1.ExtraValue{"team": {"a"}},
+				},
+			}
+		case "revoked":
+			review.Status = authenticationv1.TokenReviewStatus{Error: "token revoked"}
+		case "broken":
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	defer server.Close()
+
+	factory := newWebhookAuthProviderFactory()
+	if factory.enabled() || factory.configFiles() != nil {
+		t.Fatal("webhook authentication must be disabled by default")
+	}
+	factory.configFile = writeTestWebhookKubeConfig(t, server.URL)
+	if !slices.Equal(factory.configFiles(), []string{factory.configFile}) {
+		t.Fatalf("unexpected config files %v", factory.configFiles())
+	}
+	provider, err := factory.build(t.Context(), authProviderDependencies{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, authenticated, err := provider.AuthenticateToken(t.Context(), "valid")
+	if err != nil || !authenticated {
+		t.Fatalf("expected authentication, got authenticated=%t err=%v", authenticated, err)
+	}
+	if resp.User.GetName() != "alice" || !slices.Equal(resp.User.GetGroups(), []string{"platform"}) || !slices.Equal(resp.User.GetExtra()["team"], []string{"a"}) {
+		t.Fatalf("unexpected user %+v", resp.User)
+	}
+
+	_, authenticated, err = provider.AuthenticateToken(t.Context(), "revoked")
+	if authenticated || !errors.Is(err, ErrTokenNotAuthenticated) {
+		t.Fatalf("expected rejection, got authenticated=%t err=%v", authenticated, err)
+	}
+
+	_, authenticated, err = provider.AuthenticateToken(t.Context(), "unknown")
+	if authenticated || err != nil {
+		t.Fatalf("expected an unauthenticated result, got authenticated=%t err=%v", authenticated, err)
+	}
+
+	_, authenticated, err = provider.AuthenticateToken(t.Context(), "broken")
+	if authenticated || err == nil || errors.Is(err, ErrTokenNotAuthenticated) {
+		t.Fatalf("expected an infrastructure error, got authenticated=%t err=%v", authenticated, err)
+	}
+}
+
+func TestWebhookAuthProviderFactoryMissingConfig(t *testing.T) {
+	factory := newWebhookAuthProviderFactory()
+	factory.configFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := factory.build(t.Context(), authProviderDependencies{}); err == nil {
+		t.Fatal("expected an error for a missing config file")
+	}
+}
@@ -0,0 +1,193 @@
+package serviceproxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/spf13/pflag"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/cluster-proxy/pkg/util"
+)
+
+const x509AuthProviderID authProviderID = "x509"
+
+var x509AuthProviderMetadata = authProviderMetadata{
+	id:                   x509AuthProviderID,
+	displayName:          "client certificate",
+	authenticationTarget: "a client certificate verified by the user-server",
+}
+
+// x509AuthProvider authenticates the client certificate identity the
+// user-server forwards in util.ClientIdentityHeader. The header is signed by
+// the hub key whose public key is distributed to the agent, so it cannot be
+// forged by anything between the user-server and the service-proxy.
+type x509AuthProvider struct {
+	publicKey            *ecdsa.PublicKey
+	clusterName          string
+	reservedNamePrefixes []string
+	impersonateUser      impersonateUserFunc
+	now                  func() time.Time
+}
+
+func (*x509AuthProvider) Metadata() authProviderMetadata {
+	return x509AuthProviderMetadata
+}
+
+// AuthenticateToken never authenticates bearer tokens; requests forwarded
+// with a client certificate identity carry no token.
+func (*x509AuthProvider) AuthenticateToken(context.Context, string) (*authenticator.Response, bool, error) {
+	return nil, false, nil
+}
+
+// hasCredential reports whether the user-server forwarded a client
+// certificate identity.
+func (*x509AuthProvider) hasCredential(req *http.Request) bool {
+	return req.Header.Get(util.ClientIdentityHeader) != ""
+}
+
+// AuthenticateRequest verifies the client identity header. A missing header
+// is unauthenticated; an invalid one is rejected.
+func (p *x509AuthProvider) AuthenticateRequest(req *http.Request) (*authenticator.Response, bool, error) {
+	token := req.Header.Get(util.ClientIdentityHeader)
+	if token == "" {
+		return nil, false, nil
+	}
+
+	parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{util.ClientIdentitySigningAlgorithm})
+	if err != nil {
+		return nil, false, fmt.Errorf("malformed client identity: %w", ErrTokenNotAuthenticated)
+	}
+	claims := util.ClientIdentityClaims{}
+	if err := parsed.Claims(p.publicKey, &claims); err != nil {
+		return nil, false, fmt.Errorf("client identity signature is invalid: %w", ErrTokenNotAuthenticated)
+	}
+	if claims.Expiry == nil {
+		return nil, false, fmt.Errorf("client identity has no expiry: %w", ErrTokenNotAuthenticated)
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      util.ClientIdentityIssuer,
+		AnyAudience: jwt.Audience{p.clusterName},
+		Time:        p.now(),
+	}, jwt.DefaultLeeway); err != nil {
+		return nil, false, fmt.Errorf("client identity is not valid for cluster %s: %v: %w", p.clusterName, err, ErrTokenNotAuthenticated)
+	}
+
+	for _, name := range append([]string{claims.Subject}, claims.Groups...) {
+		for _, prefix := range p.reservedNamePrefixes {
+			if strings.HasPrefix(name, prefix) {
+				return nil, false, fmt.Errorf("client certificate name %q uses the reserved %s prefix: %w", name, prefix, ErrTokenNotAuthenticated)
+			}
+		}
+	}
+
+	return &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   claims.Subject,
+			Groups: claims.Groups,
+		},
+	}, true, nil
+}
+
+func (p *x509AuthProvider) ApplyIdentity(ctx context.Context, req *http.Request, info user.Info) error {
+	return applyExternalIdentity(ctx, req, info, p.impersonateUser)
+}
+
+func (*x509AuthProvider) MapIdentity(info user.Info) user.Info {
+	return externalIdentity(info)
+}
+
+type x509AuthProviderFactory struct {
+	publicKeyFile        string
+	reservedNamePrefixes []string
+}
+
+func newX509AuthProviderFactory() *x509AuthProviderFactory {
+	return &x509AuthProviderFactory{
+		reservedNamePrefixes: []string{"system:"},
+	}
+}
+
+func (f *x509AuthProviderFactory) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.publicKeyFile, "client-certificate-identity-public-key", f.publicKeyFile, "The path to the PEM encoded public key verifying client certificate identities forwarded by the user-server. Setting this enables client certificate authentication; it requires --cluster-name.")
+	flags.StringSliceVar(&f.reservedNamePrefixes, "client-certificate-reserved-name-prefixes", f.reservedNamePrefixes, "Comma-separated list of prefixes that client certificate common names and organizations must not use. The list replaces the default; set an empty value to disable the check.")
+}
+
+func (f *x509AuthProviderFactory) validate() error {
+	if !f.enabled() {
+		return nil
+	}
+	// an empty prefix matches every name and would silently reject all certificates
+	if slices.Contains(f.reservedNamePrefixes, "") {
+		return fmt.Errorf("--client-certificate-reserved-name-prefixes must not contain an empty prefix")
+	}
+	return nil
+}
+
+func (f *x509AuthProviderFactory) enabled() bool {
+	return f.publicKeyFile != ""
+}
+
+func (f *x509AuthProviderFactory) configFiles() []string {
+	if !f.enabled() {
+		return nil
+	}
+	return []string{f.publicKeyFile}
+}
+
+func (f *x509AuthProviderFactory) build(_ context.Context, dependencies authProviderDependencies) (authProvider, error) {
+	if dependencies.clusterName == "" {
+		return nil, fmt.Errorf("--cluster-name is required for client certificate authentication")
+	}
+	publicKey, err := loadClientIdentityPublicKey(f.publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &x509AuthProvider{
+		publicKey:            publicKey,
+		clusterName:          dependencies.clusterName,
+		reservedNamePrefixes: f.reservedNamePrefixes,
+		impersonateUser:      dependencies.impersonateUser,
+		now:                  time.Now,
+	}, nil
+}
+
+func (f *x509AuthProviderFactory) logConfiguration() {
+	klog.Infof("client certificate authentication enabled: publicKey=%s, reservedNamePrefixes=%v", f.publicKeyFile, f.reservedNamePrefixes)
+}
+
+func loadClientIdentityPublicKey(path string) (*ecdsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client identity public key: %w", err)
+	}
+	keys, err := keyutil.ParsePublicKeysPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client identity public key %s: %w", path, err)
+	}
+	publicKey, ok := keys[0].(*ecdsa.PublicKey)
+	if len(keys) != 1 || !ok {
+		return nil, fmt.Errorf("client identity public key %s must contain a single ECDSA key", path)
+	}
+	return publicKey, nil
+}
+
+var (
+	_ authProvider              = (*x509AuthProvider)(nil)
+	_ authenticator.Request     = (*x509AuthProvider)(nil)
+	_ requestCredentialDetector = (*x509AuthProvider)(nil)
+	_ identityMapper            = (*x509AuthProvider)(nil)
+	_ authProviderFactory       = (*x509AuthProviderFactory)(nil)
+	_ authProviderFactoryLogger = (*x509AuthProviderFactory)(nil)
+)
@@ -0,0 +1,225 @@
+package serviceproxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	"open-cluster-management.io/cluster-proxy/pkg/util"
+)
+
+func newTestClientIdentityKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func signTestClientIdentity(t *testing.T, key *ecdsa.PrivateKey, claims util.ClientIdentityClaims) string {
+	t.Helper()
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: util.ClientIdentitySigningAlgorithm, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("failed to sign client identity: %v", err)
+	}
+	return token
+}
+
+func testClientIdentityClaims(now time.Time) util.ClientIdentityClaims {
+	return util.ClientIdentityClaims{
+		Claims: jwt.Claims{
+			Issuer:   util.ClientIdentityIssuer,
+			Subject:  "alice",
+			Audience: jwt.Audience{"cluster1"},
+			Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		Groups: []string{"platform"},
+	}
+}
+
+func TestX509AuthProvider(t *testing.T) {
+	key := newTestClientIdentityKey(t)
+	otherKey := newTestClientIdentityKey(t)
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		header        func(t *testing.T) string
+		wantUser      string
+		wantRejection bool
+	}{
+		{
+			name:     "no identity header",
+			header:   func(*testing.T) string { return "" },
+			wantUser: "",
+		},
+		{
+			name:     "verified identity",
+			header:   func(t *testing.T) string { return signTestClientIdentity(t, key, testClientIdentityClaims(now)) },
+			wantUser: "alice",
+		},
+		{
+			name: "identity for another cluster",
+			header: func(t *testing.T) string {
+				claims := testClientIdentityClaims(now)
+				claims.Audience = jwt.Audience{"cluster2"}
+				return signTestClientIdentity(t, key, claims)
+			},
+			wantRejection: true,
+		},
+		{
+			name: "expired identity",
+			header: func(t *testing.T) string {
+				claims := testClientIdentityClaims(now.Add(-time.Hour))
+				return signTestClientIdentity(t, key, claims)
+			},
+			wantRejection: true,
+		},
+		{
+			name:          "forged identity",
+			header:        func(t *testing.T) string { return signTestClientIdentity(t, otherKey, testClientIdentityClaims(now)) },
+			wantRejection: true,
+		},
+		{
+			name: "reserved group",
+			header: func(t *testing.T) string {
+				claims := testClientIdentityClaims(now)
+				claims.Groups = []string{"system:masters"}
+				return signTestClientIdentity(t, key, claims)
+			},
+			wantRejection: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &x509AuthProvider{
+				publicKey:            &key.PublicKey,
+				clusterName:          "cluster1",
+				reservedNamePrefixes: []string{"system:"},
+				now:                  func() time.Time { return now },
+			}
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://example.com/api", nil)
+			if header := tt.header(t); header != "" {
+				req.Header.Set(util.ClientIdentityHeader, header)
+			}
+
+			resp, authenticated, err := provider.AuthenticateRequest(req)
+			if tt.wantRejection {
+				if !errors.Is(err, ErrTokenNotAuthenticated) || authenticated {
+					t.Fatalf("expected rejection, got authenticated=%t err=%v", authenticated, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantUser == "" {
+				if authenticated {
+					t.Fatal("expected an unauthenticated result")
+				}
+				return
+			}
+			if !authenticated || resp.User.GetName() != tt.wantUser || !slices.Equal(resp.User.GetGroups(), []string{"platform"}) {
+				t.Fatalf("unexpected result: authenticated=%t user=%v", authenticated, resp)
+			}
+		})
+	}
+}
+
+func TestProcessAuthentication_ClientCertificateIdentity(t *testing.T) {
+	key := newTestClientIdentityKey(t)
+	s := &serviceProxy{
+		getImpersonateTokenFunc: func() (string, error) {
+			return "fake-sa-token", nil
+		},
+	}
+	setTestAuthProviders(s, testAuthenticators{
+		managedCluster: authenticator.TokenFunc(func(context.Context, string) (*authenticator.Response, bool, error) {
+			t.Fatal("token providers must not be called without a bearer token")
+			return nil, false, nil
+		}),
+	})
+	s.authProviders = append(s.authProviders, &x509AuthProvider{
+		publicKey:       &key.PublicKey,
+		clusterName:     "cluster1",
+		impersonateUser: s.impersonateUser,
+		now:             time.Now,
+	})
+
+	ctx := t.Context()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/api", nil)
+	req.Header.Set(util.ClientIdentityHeader, signTestClientIdentity(t, key, testClientIdentityClaims(time.Now())))
+
+	if err := s.processAuthentication(ctx, req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := req.Header.Get(authenticationv1.ImpersonateUserHeader); got != "alice" {
+		t.Fatalf("impersonate user = %q, want alice", got)
+	}
+	wantGroups := []string{"platform", user.AllAuthenticated}
+	if got := req.Header.Values(authenticationv1.ImpersonateGroupHeader); !slices.Equal(got, wantGroups) {
+		t.Fatalf("impersonate groups = %v, want %v", got, wantGroups)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer fake-sa-token" {
+		t.Fatalf("expected the impersonation token, got %q", got)
+	}
+}
+
+func TestX509AuthProviderFactory(t *testing.T) {
+	key := newTestClientIdentityKey(t)
+	publicKeyPEM, err := util.EncodePublicKeyPEM(key)
+	if err != nil {
+		t.Fatalf("failed to encode public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(path, publicKeyPEM, 0600); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+
+	factory := newX509AuthProviderFactory()
+	if factory.enabled() || factory.configFiles() != nil {
+		t.Fatal("client certificate authentication must be disabled by default")
+	}
+	factory.publicKeyFile = path
+	if !slices.Equal(factory.configFiles(), []string{path}) {
+		t.Fatalf("unexpected config files %v", factory.configFiles())
+	}
+	if _, err := factory.build(t.Context(), authProviderDependencies{}); err == nil {
+		t.Fatal("expected an error without a cluster name")
+	}
+	provider, err := factory.build(t.Context(), authProviderDependencies{clusterName: "cluster1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !provider.(*x509AuthProvider).publicKey.Equal(&key.PublicKey) {
+		t.Fatal("the configured public key was not loaded")
+	}
+
+	factory.reservedNamePrefixes = []string{"system:", ""}
+	if err := factory.validate(); err == nil {
+		t.Fatal("expected an error for an empty reserved prefix")
+	}
+}
@@ -0,0 +1,244 @@
+package tcptunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+func NewTunnelCommand() *cobra.Command {
+	tunnel := newTunnel()
+
+	cmd := &cobra.Command{
+		Use:   "tunnel",
+		Short: "tunnel",
+		Long:  `Listens on a local port and forwards each connection as a raw TCP tunnel through the user-server to a Service on a managed cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tunnel.Run(cmd.Context())
+		},
+	}
+
+	tunnel.AddFlags(cmd.Flags())
+	return cmd
+}
+
+type tunnel struct {
+	server                string
+	cluster               string
+	namespace             string
+	service               string
+	remotePort            int
+	address               string
+	localPort             int
+	token, tokenFile      string
+	certificateAuthority  string
+	clientCert, clientKey string
+	insecureSkipTLSVerify bool
+
+	// transport performs the upgrade requests; Run builds it from the flags
+	// and tests override it.
+	transport http.RoundTripper
+	// ready receives the listener address once the tunnel accepts connections.
+	ready func(net.Addr)
+}
+
+func newTunnel() *tunnel {
+	return &tunnel{
+		address: "127.0.0.1",
+	}
+}
+
+func (t *tunnel) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&t.server, "server", t.server, "The base URL of the user-server, such as https://cluster-proxy.example.com.")
+	flags.StringVar(&t.cluster, "cluster", t.cluster, "The name of the managed cluster.")
+	flags.StringVar(&t.namespace, "namespace", t.namespace, "The namespace of the target Service.")
+	flags.StringVar(&t.service, "service", t.service, "The name of the target Service.")
+	flags.IntVar(&t.remotePort, "remote-port", t.remotePort, "The port of the target Service.")
+	flags.StringVar(&t.address, "address", t.address, "The local address to listen on.")
+	flags.IntVar(&t.localPort, "local-port", t.localPort, "The local port to listen on. 0 picks a free port, which is logged.")
+	flags.StringVar(&t.token, "token", t.token, "The bearer token presented to the user-server.")
+	flags.StringVar(&t.tokenFile, "token-file", t.tokenFile, "A file holding the bearer token presented to the user-server. It is read for each connection, so rotated tokens are picked up.")
+	flags.StringVar(&t.certificateAuthority, "certificate-authority", t.certificateAuthority, "The CA bundle verifying the user-server certificate. The system roots are used when empty.")
+	flags.StringVar(&t.clientCert, "client-certificate", t.clientCert, "The client certificate presented to the user-server.")
+	flags.StringVar(&t.clientKey, "client-key", t.clientKey, "The key of the client certificate.")
+	flags.BoolVar(&t.insecureSkipTLSVerify, "insecure-skip-tls-verify", t.insecureSkipTLSVerify, "Skip verifying the user-server certificate. Use only for testing.")
+}
+
+func (t *tunnel) Validate() error {
+	serverURL, err := url.Parse(t.server)
+	if err != nil || serverURL.Host == "" || (serverURL.Scheme != "https" && serverURL.Scheme != "http") {
+		return fmt.Errorf("--server must be an http or https URL")
+	}
+	if t.cluster == "" || t.namespace == "" || t.service == "" {
+		return fmt.Errorf("--cluster, --namespace and --service are required")
+	}
+	if t.remotePort <= 0 || t.remotePort > 65535 {
+		return fmt.Errorf("--remote-port must be between 1 and 65535")
+	}
+	if t.localPort < 0 || t.localPort > 65535 {
+		return fmt.Errorf("--local-port must be between 0 and 65535")
+	}
+	if t.token != "" && t.tokenFile != "" {
+		return fmt.Errorf("--token and --token-file are mutually exclusive")
+	}
+	if (t.clientCert == "") != (t.clientKey == "") {
+		return fmt.Errorf("--client-certificate and --client-key must be set together")
+	}
+	return nil
+}
+
+func (t *tunnel) Run(ctx context.Context) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if t.transport == nil {
+		transport, err := t.newTransport()
+		if err != nil {
+			return err
+		}
+		t.transport = transport
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(t.address, fmt.Sprint(t.localPort)))
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	klog.Infof("forwarding %s to %s/%s:%d on cluster %s", listener.Addr(), t.namespace, t.service, t.remotePort, t.cluster)
+	if t.ready != nil {
+		t.ready(listener.Addr())
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go t.forward(ctx, conn)
+	}
+}
+
+func (t *tunnel) newTransport() (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.insecureSkipTLSVerify, //nolint:gosec // explicitly requested for testing
+		// upgrades are only defined for HTTP/1.1
+		NextProtos: []string{"http/1.1"},
+	}
+	if t.certificateAuthority != "" {
+		rootCAs, err := certutil.NewPool(t.certificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the certificate authority: %w", err)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if t.clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.clientCert, t.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}, nil
+}
+
+// forward opens a tunnel for one local connection and copies bytes until
+// either side closes it.
+func (t *tunnel) forward(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	stream, err := t.open(ctx)
+	if err != nil {
+		klog.Errorf("failed to open a tunnel for %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer stream.Close()
+
+	klog.V(4).Infof("tunnel opened for %s", conn.RemoteAddr())
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(stream, conn)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, stream)
+		errc <- err
+	}()
+	if err := <-errc; err != nil && !errors.Is(err, net.ErrClosed) {
+		klog.V(4).Infof("tunnel for %s closed: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// open sends the upgrade request and returns the upgraded connection.
+func (t *tunnel) open(ctx context.Context) (io.ReadWriteCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.tunnelURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", utils.TCPTunnelProtocol)
+	token, err := t.bearerToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("the user-server answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	stream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("the upgraded connection is not writable")
+	}
+	return stream, nil
+}
+
+func (t *tunnel) tunnelURL() string {
+	return fmt.Sprintf("%s/%s/api/v1/namespaces/%s/services/%s:%d/proxy-tcp",
+		strings.TrimSuffix(t.server, "/"), t.cluster, t.namespace, t.service, t.remotePort)
+}
+
+func (t *tunnel) bearerToken() (string, error) {
+	if t.tokenFile == "" {
+		return t.token, nil
+	}
+	token, err := os.ReadFile(t.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token file: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
@@ -0,0 +1,169 @@
+package tcptunnel
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+// startUserServer serves a user-server stand-in that tunnels authorized
+// requests for cluster1/db/postgres:5432 to an echo backend.
+func startUserServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = backend.Close() })
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cluster1/api/v1/namespaces/db/services/postgres:5432/proxy-tcp" || !utils.IsTCPTunnelRequest(r) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer dba-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := net.Dial("tcp", backend.Addr().String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		_ = utils.ServeTCPTunnel(w, conn, 0)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func runTunnel(t *testing.T, server *httptest.Server, tokenFile string) net.Addr {
+	t.Helper()
+	ready := make(chan net.Addr, 1)
+	tun := newTunnel()
+	tun.server = server.URL + "/"
+	tun.cluster = "cluster1"
+	tun.namespace = "db"
+	tun.service = "postgres"
+	tun.remotePort = 5432
+	tun.tokenFile = tokenFile
+	tun.transport = server.Client().Transport
+	tun.ready = func(addr net.Addr) { ready <- addr }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- tun.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-result; err != nil {
+			t.Errorf("tunnel returned an error: %v", err)
+		}
+	})
+
+	select {
+	case addr := <-ready:
+		return addr
+	case err := <-result:
+		t.Fatalf("tunnel failed to start: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("tunnel did not start")
+	}
+	return nil
+}
+
+func TestTunnelForwardsLocalConnections(t *testing.T) {
+	server := startUserServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("dba-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+	addr := runTunnel(t, server, tokenFile)
+
+	// every local connection opens its own tunnel
+	for range 2 {
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatalf("failed to dial tunnel: %v", err)
+		}
+		if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || line != "PING\r\n" {
+			t.Fatalf("unexpected echo %q: %v", line, err)
+		}
+		_ = conn.Close()
+	}
+}
+
+func TestTunnelClosesRejectedConnections(t *testing.T) {
+	server := startUserServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("other-token"), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+	addr := runTunnel(t, server, tokenFile)
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("failed to dial tunnel: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the rejected connection to be closed, got %v", err)
+	}
+}
+
+func TestTunnelValidate(t *testing.T) {
+	valid := func() *tunnel {
+		tun := newTunnel()
+		tun.server = "https://cluster-proxy.example.com"
+		tun.cluster = "cluster1"
+		tun.namespace = "db"
+		tun.service = "postgres"
+		tun.remotePort = 5432
+		return tun
+	}
+	tests := []struct {
+		name    string
+		mutate  func(*tunnel)
+		wantErr bool
+	}{
+		{name: "valid", mutate: func(*tunnel) {}},
+		{name: "missing server", mutate: func(tun *tunnel) { tun.server = "" }, wantErr: true},
+		{name: "missing service", mutate: func(tun *tunnel) { tun.service = "" }, wantErr: true},
+		{name: "missing remote port", mutate: func(tun *tunnel) { tun.remotePort = 0 }, wantErr: true},
+		{name: "token and token file", mutate: func(tun *tunnel) { tun.token, tun.tokenFile = "a", "b" }, wantErr: true},
+		{name: "certificate without key", mutate: func(tun *tunnel) { tun.clientCert = "tls.crt" }, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tun := valid()
+			test.mutate(tun)
+			if err := tun.Validate(); (err != nil) != test.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %t", err, test.wantErr)
+			}
+		})
+	}
+}
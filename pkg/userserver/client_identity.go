@@ -0,0 +1,79 @@
+package userserver
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"k8s.io/klog/v2"
+
+	clusterproxyutil "open-cluster-management.io/cluster-proxy/pkg/util"
+)
+
+const (
+	// clientIdentityTokenTTL only has to cover the hop to the service-proxy.
+	clientIdentityTokenTTL       = time.Minute
+	clientIdentityTokenClockSkew = 30 * time.Second
+)
+
+// clientIdentitySigner forwards the identity of verified client certificates
+// to the service-proxy as a short-lived token signed by the hub key.
+type clientIdentitySigner struct {
+	signer jose.Signer
+	now    func() time.Time
+}
+
+func newClientIdentitySigner(key *ecdsa.PrivateKey) (*clientIdentitySigner, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: clusterproxyutil.ClientIdentitySigningAlgorithm, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client identity signer: %w", err)
+	}
+	return &clientIdentitySigner{signer: signer, now: time.Now}, nil
+}
+
+// apply drops any client-supplied identity header and, for requests that
+// presented a verified client certificate instead of a bearer token, sets the
+// header to the certificate identity scoped to the target cluster. A nil
+// signer only drops the header.
+func (s *clientIdentitySigner) apply(req *http.Request, cluster string) error {
+	req.Header.Del(clusterproxyutil.ClientIdentityHeader)
+	if s == nil || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	if req.Header.Get("Authorization") != "" {
+		// the bearer token is authenticated by the service-proxy as before
+		return nil
+	}
+
+	cert := req.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return errors.New("client certificate has no common name")
+	}
+
+	now := s.now()
+	token, err := jwt.Signed(s.signer).Claims(clusterproxyutil.ClientIdentityClaims{
+		Claims: jwt.Claims{
+			Issuer:    clusterproxyutil.ClientIdentityIssuer,
+			Subject:   cert.Subject.CommonName,
+			Audience:  jwt.Audience{cluster},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now.Add(-clientIdentityTokenClockSkew)),
+			Expiry:    jwt.NewNumericDate(now.Add(clientIdentityTokenTTL)),
+		},
+		Groups: cert.Subject.Organization,
+	}).Serialize()
+	if err != nil {
+		return fmt.Errorf("failed to sign client identity: %w", err)
+	}
+
+	req.Header.Set(clusterproxyutil.ClientIdentityHeader, token)
+	klog.V(4).Infof("forwarding client certificate identity %q to cluster %s", cert.Subject.CommonName, cluster)
+	return nil
+}
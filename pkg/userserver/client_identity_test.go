@@ -0,0 +1,83 @@
+package userserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+
+	clusterproxyutil "open-cluster-management.io/cluster-proxy/pkg/util"
+)
+
+func newTestClientCertificateRequest(t *testing.T, subject pkix.Name) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://user-server/cluster1/api/v1/pods", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: subject}}},
+	}
+	return req
+}
+
+func TestClientIdentitySigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := newClientIdentitySigner(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := newTestClientCertificateRequest(t, pkix.Name{CommonName: "alice", Organization: []string{"platform"}})
+	req.Header.Set(clusterproxyutil.ClientIdentityHeader, "forged")
+	if err := signer.apply(req, "cluster1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parsed, err := jwt.ParseSigned(req.Header.Get(clusterproxyutil.ClientIdentityHeader),
+		[]jose.SignatureAlgorithm{clusterproxyutil.ClientIdentitySigningAlgorithm})
+	if err != nil {
+		t.Fatalf("failed to parse client identity: %v", err)
+	}
+	claims := clusterproxyutil.ClientIdentityClaims{}
+	if err := parsed.Claims(&key.PublicKey, &claims); err != nil {
+		t.Fatalf("failed to verify client identity: %v", err)
+	}
+	if claims.Subject != "alice" || !slices.Equal(claims.Groups, []string{"platform"}) || !slices.Equal([]string(claims.Audience), []string{"cluster1"}) {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	// bearer tokens are authenticated by the service-proxy instead
+	req = newTestClientCertificateRequest(t, pkix.Name{CommonName: "alice"})
+	req.Header.Set("Authorization", "Bearer token")
+	if err := signer.apply(req, "cluster1"); err != nil || req.Header.Get(clusterproxyutil.ClientIdentityHeader) != "" {
+		t.Fatalf("expected no client identity for bearer token requests, err=%v", err)
+	}
+
+	req = newTestClientCertificateRequest(t, pkix.Name{Organization: []string{"platform"}})
+	if err := signer.apply(req, "cluster1"); err == nil {
+		t.Fatal("expected an error for a certificate without a common name")
+	}
+}
+
+func TestClientIdentitySignerDisabled(t *testing.T) {
+	var signer *clientIdentitySigner
+	req := newTestClientCertificateRequest(t, pkix.Name{CommonName: "alice"})
+	req.Header.Set(clusterproxyutil.ClientIdentityHeader, "forged")
+	if err := signer.apply(req, "cluster1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Header.Get(clusterproxyutil.ClientIdentityHeader) != "" {
+		t.Fatal("client-supplied identity headers must be dropped")
+	}
+}
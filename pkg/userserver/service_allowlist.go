@@ -4,15 +4,18 @@ import (
 	"fmt"
 	"sync"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
 	"open-cluster-management.io/cluster-proxy/pkg/utils"
 )
 
 // ExposedService describes a single service that is permitted to be reached
-// via the service proxy path. Namespace and Service are required. Port and
-// Protocol are optional: when omitted (empty string) they act as wildcards
-// and any value in the incoming request will match.
+// via the service proxy path. Namespace and exactly one of Service and
+// PodSelector are required. Port and Protocol are optional: when omitted
+// (empty string) they act as wildcards and any value in the incoming request
+// will match, except that TCP tunnels are only permitted by entries with the
+// protocol "tcp".
 //
 // Future extension: an optional Clusters []string field can be added here to
 // scope an entry to specific managed clusters. tsc.Cluster is already
@@ -20,9 +23,15 @@ import (
 type ExposedService struct {
 	Namespace string `json:"namespace" yaml:"namespace"`
 	Service   string `json:"service"   yaml:"service"`
-	// Port is optional. When empty, any port is permitted.
+	// PodSelector exposes the pods of Namespace whose labels match, for
+	// requests addressing a single pod. The user-server only knows the pod
+	// name, so the selector is forwarded and checked by the service-proxy.
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty" yaml:"podSelector,omitempty"`
+	// Port is optional for Services; when empty, any port is permitted.
+	// PodSelector entries require it because a pod IP may be the node IP.
 	Port string `json:"port,omitempty" yaml:"port,omitempty"`
-	// Protocol is optional. When empty, any protocol is permitted.
+	// Protocol is optional. When empty, any HTTP protocol is permitted; raw
+	// TCP tunnels require the protocol "tcp".
 	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
 }
 
@@ -31,13 +40,21 @@ func (e ExposedService) matches(tsc utils.TargetServiceConfig) bool {
 	if e.Namespace != tsc.Namespace {
 		return false
 	}
-	if e.Service != tsc.Service {
+	if tsc.Pod != "" {
+		if e.PodSelector == nil {
+			return false
+		}
+	} else if e.PodSelector != nil || e.Service != tsc.Service {
 		return false
 	}
 	if e.Port != "" && e.Port != tsc.Port {
 		return false
 	}
-	if e.Protocol != "" && e.Protocol != tsc.Proto {
+	switch {
+	case e.Protocol == "":
+		// raw TCP tunnels skip the HTTP layer and must be exposed explicitly
+		return tsc.Proto != utils.ProtoTCP
+	case e.Protocol != tsc.Proto:
 		return false
 	}
 	return true
@@ -62,7 +79,8 @@ func parseAllExposedServices(data map[string]string) ([]ExposedService, error) {
 
 // parseExposedServices strictly unmarshals the YAML value stored under any key
 // of the ConfigMap data into a slice of ExposedService entries. It validates
-// that every entry has non-empty Namespace and Service fields.
+// that every entry has a non-empty Namespace and either a Service or a valid
+// PodSelector with a Port.
 func parseExposedServices(data string) ([]ExposedService, error) {
 	if data == "" {
 		return nil, nil
@@ -75,8 +93,23 @@ func parseExposedServices(data string) ([]ExposedService, error) {
 		if svc.Namespace == "" {
 			return nil, fmt.Errorf("entry[%d]: namespace is required", i)
 		}
+		if svc.PodSelector != nil {
+			if svc.Service != "" {
+				return nil, fmt.Errorf("entry[%d]: service and podSelector are mutually exclusive", i)
+			}
+			if svc.Port == "" {
+				return nil, fmt.Errorf("entry[%d]: podSelector requires a port", i)
+			}
+			if svc.Protocol == utils.ProtoTCP {
+				return nil, fmt.Errorf("entry[%d]: podSelector does not support the protocol %q", i, utils.ProtoTCP)
+			}
+			if _, err := metav1.LabelSelectorAsSelector(svc.PodSelector); err != nil {
+				return nil, fmt.Errorf("entry[%d]: invalid podSelector: %w", i, err)
+			}
+			continue
+		}
 		if svc.Service == "" {
-			return nil, fmt.Errorf("entry[%d]: service is required", i)
+			return nil, fmt.Errorf("entry[%d]: service or podSelector is required", i)
 		}
 	}
 	return services, nil
@@ -114,6 +147,27 @@ func (a *ServiceAllowlist) IsAllowed(tsc utils.TargetServiceConfig) bool {
 	return false
 }
 
+// PodSelectors returns the label selectors of the entries permitting the pod
+// targeted by tsc. An empty result means the pod is not exposed; a pod is
+// reachable when its labels match any of the returned selectors.
+func (a *ServiceAllowlist) PodSelectors(tsc utils.TargetServiceConfig) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var selectors []string
+	for _, svc := range a.services {
+		if tsc.Pod == "" || !svc.matches(tsc) {
+			continue
+		}
+		// entries are validated when parsed
+		selector, err := metav1.LabelSelectorAsSelector(svc.PodSelector)
+		if err != nil {
+			continue
+		}
+		selectors = append(selectors, selector.String())
+	}
+	return selectors
+}
+
 // Len returns the number of entries currently in the allowlist.
 // Primarily useful for logging/diagnostics.
 func (a *ServiceAllowlist) Len() int {
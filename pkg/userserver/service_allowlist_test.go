@@ -5,6 +5,8 @@ import (
 	"sync"
 	"testing"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"open-cluster-management.io/cluster-proxy/pkg/utils"
 )
 
@@ -220,6 +222,57 @@ func TestParseExposedServices_MissingService(t *testing.T) {
 	}
 }
 
+func TestParseExposedServices_PodSelector(t *testing.T) {
+	yaml := `
+- namespace: monitoring
+  podSelector:
+    matchLabels:
+      app: prometheus
+  port: metrics
+`
+	services, err := parseExposedServices(yaml)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(services) != 1 || services[0].PodSelector == nil || services[0].PodSelector.MatchLabels["app"] != "prometheus" {
+		t.Fatalf("unexpected entries: %+v", services)
+	}
+}
+
+func TestParseExposedServices_InvalidPodSelectorEntries(t *testing.T) {
+	cases := map[string]string{
+		"service and podSelector": `
+- namespace: monitoring
+  service: prometheus
+  podSelector: {}
+`,
+		"podSelector without port": `
+- namespace: monitoring
+  podSelector:
+    matchLabels:
+      app: prometheus
+`,
+		"tcp protocol": `
+- namespace: db
+  podSelector: {}
+  port: "5432"
+  protocol: tcp
+`,
+		"invalid selector": `
+- namespace: monitoring
+  podSelector:
+    matchExpressions:
+    - key: app
+      operator: Near
+`,
+	}
+	for name, yaml := range cases {
+		if _, err := parseExposedServices(yaml); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
 func TestParseExposedServices_MalformedYAML(t *testing.T) {
 	_, err := parseExposedServices("this: is: not: valid: yaml: [[[")
 	if err == nil {
@@ -287,6 +340,30 @@ func TestMatches_WrongProtocol(t *testing.T) {
 	}
 }
 
+func TestMatches_TCPRequiresExplicitProtocol(t *testing.T) {
+	tsc := utils.TargetServiceConfig{Namespace: "db", Service: "postgres", Port: "5432", Proto: utils.ProtoTCP}
+	if (ExposedService{Namespace: "db", Service: "postgres"}).matches(tsc) {
+		t.Error("expected no match for a TCP tunnel when protocol is omitted")
+	}
+	if !(ExposedService{Namespace: "db", Service: "postgres", Protocol: "tcp"}).matches(tsc) {
+		t.Error("expected match for a TCP tunnel with protocol tcp")
+	}
+}
+
+func TestMatches_PodTargetsRequirePodSelector(t *testing.T) {
+	tsc := utils.TargetServiceConfig{Namespace: "monitoring", Pod: "prometheus-0", Port: "9090", Proto: "http"}
+	if (ExposedService{Namespace: "monitoring", Service: "prometheus-0"}).matches(tsc) {
+		t.Error("expected no match for a pod target by a service entry")
+	}
+	if !(ExposedService{Namespace: "monitoring", PodSelector: &metav1.LabelSelector{}}).matches(tsc) {
+		t.Error("expected match for a pod target by a pod selector entry")
+	}
+	service := utils.TargetServiceConfig{Namespace: "monitoring", Service: "prometheus", Port: "9090", Proto: "http"}
+	if (ExposedService{Namespace: "monitoring", PodSelector: &metav1.LabelSelector{}}).matches(service) {
+		t.Error("expected no match for a service target by a pod selector entry")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // ServiceAllowlist.IsAllowed
 // ---------------------------------------------------------------------------
@@ -349,6 +426,32 @@ func TestIsAllowed_MultipleEntries_SecondMatches(t *testing.T) {
 	}
 }
 
+func TestPodSelectors_ReturnsSelectorsOfMatchingEntries(t *testing.T) {
+	a := &ServiceAllowlist{
+		services: []ExposedService{
+			{Namespace: "monitoring", Service: "prometheus"},
+			{Namespace: "monitoring", PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}}},
+			{Namespace: "monitoring", PodSelector: &metav1.LabelSelector{}, Port: "8080"},
+			{Namespace: "default", PodSelector: &metav1.LabelSelector{}},
+		},
+	}
+	tsc := utils.TargetServiceConfig{Namespace: "monitoring", Pod: "prometheus-0", Port: "9090", Proto: "http"}
+	selectors := a.PodSelectors(tsc)
+	if len(selectors) != 1 || selectors[0] != "app=prometheus" {
+		t.Errorf("expected [app=prometheus], got %q", selectors)
+	}
+
+	tsc.Port = "8080"
+	if selectors := a.PodSelectors(tsc); len(selectors) != 2 || selectors[1] != "" {
+		t.Errorf("expected the empty selector to match every pod, got %q", selectors)
+	}
+
+	tsc.Namespace = "other"
+	if selectors := a.PodSelectors(tsc); len(selectors) != 0 {
+		t.Errorf("expected no selectors for an unexposed namespace, got %q", selectors)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // ServiceAllowlist thread-safety
 // ---------------------------------------------------------------------------
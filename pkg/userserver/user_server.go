@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -57,8 +58,8 @@ var (
 )
 
 type userServer struct {
-	// TODO: make it a controller and reuse tunnel for each cluster to improve performance.
-	getTunnel       func(context.Context) (konnectivity.Tunnel, error)
+	// getTunnel opens a single-use tunnel; ctx only bounds its creation.
+	getTunnel       func(ctx context.Context) (konnectivity.Tunnel, error)
 	proxyServerHost string
 	proxyServerPort int
 
@@ -69,7 +70,12 @@ type userServer struct {
 
 	serviceProxyCACertPath string
 	agentInstallNamespace  string
-	drain                  utils.DrainConfig
+	// clientCAFile enables client certificate authentication against the CA
+	// bundle in the file.
+	clientCAFile   string
+	clientIdentity *clientIdentitySigner
+	drain          utils.DrainConfig
+	streams        utils.StreamConfig
 
 	addonLister addonlisterv1beta1.ManagedClusterAddOnLister
 
@@ -80,6 +86,11 @@ type userServer struct {
 	// serviceAllowlist is populated at startup from the ConfigMap and kept
 	// up to date by an informer.
 	serviceAllowlist *ServiceAllowlist
+
+	// transport is shared by all requests so that connections to the
+	// service-proxy of each cluster are pooled instead of dialed per request.
+	transportOnce sync.Once
+	transport     *utils.UpgradeAwareTransport
 }
 
 func (k *userServer) AddFlags(cmd *cobra.Command) {
@@ -98,8 +109,11 @@ func (k *userServer) AddFlags(cmd *cobra.Command) {
 
 	flags.StringVar(&k.serviceProxyCACertPath, "service-proxy-ca-cert", k.serviceProxyCACertPath, "The path to the CA certificate of the service proxy server")
 
+	flags.StringVar(&k.clientCAFile, "client-ca-file", k.clientCAFile, "The path to a CA bundle verifying client certificates. When set, requests presenting a verified client certificate and no bearer token are forwarded with the certificate's common name and organizations as the identity to impersonate on the managed cluster.")
+
 	flags.StringVar(&k.agentInstallNamespace, "agent-install-namespace", k.agentInstallNamespace, "The namespace of the agent install")
 	k.drain.AddFlags(flags)
+	k.streams.AddFlags(flags)
 
 	flags.StringVar(&k.exposedServicesConfigMap, "exposed-services-configmap", constant.ExposedServicesConfigMapName,
 		"Name of the ConfigMap (in the pod's namespace) that lists which services are reachable via the service proxy path")
@@ -109,6 +123,9 @@ func (k *userServer) Validate() error {
 	if err := k.drain.Validate(); err != nil {
 		return err
 	}
+	if err := k.streams.Validate(); err != nil {
+		return err
+	}
 
 	if k.serverCert == "" {
 		return fmt.Errorf("the server-cert is required")
@@ -145,11 +162,13 @@ func (k *userServer) init(ctx context.Context, kubeClient kubernetes.Interface,
 		return fmt.Errorf("failed to load service proxy ca cert: %w", err)
 	}
 
-	k.getTunnel = func(tunnelCtx context.Context) (konnectivity.Tunnel, error) {
-		// instantiate a gprc proxy dialer
+	k.getTunnel = func(createCtx context.Context) (konnectivity.Tunnel, error) {
+		// instantiate a gprc proxy dialer. Pooled connections outlive the
+		// request that dialed them, so the tunnel lives until the server stops
+		// or the connection is closed.
 		tunnel, err := konnectivity.CreateSingleUseGrpcTunnelWithContext(
+			createCtx,
 			ctx,
-			tunnelCtx,
 			net.JoinHostPort(k.proxyServerHost, strconv.Itoa(k.proxyServerPort)),
 			grpc.WithTransportCredentials(grpccredentials.NewTLS(proxyTLSCfg)),
 			grpc.WithKeepaliveParams(keepalive.ClientParameters{
@@ -184,6 +203,18 @@ func (k *userServer) init(ctx context.Context, kubeClient kubernetes.Interface,
 	klog.Infof("service allowlist active: %d entries loaded from ConfigMap %s/%s",
 		k.serviceAllowlist.Len(), podNamespace, k.exposedServicesConfigMap)
 
+	if k.clientCAFile != "" {
+		key, err := clusterproxyutil.LoadOrCreateSigningKey(ctx, kubeClient, podNamespace, clusterproxyutil.ClientIdentitySigningSecretName)
+		if err != nil {
+			return err
+		}
+		k.clientIdentity, err = newClientIdentitySigner(key)
+		if err != nil {
+			return err
+		}
+		klog.Infof("client certificate authentication enabled: clientCAFile=%s", k.clientCAFile)
+	}
+
 	return nil
 }
 
@@ -198,6 +229,7 @@ func (k *userServer) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 	}
 
 	var tsc utils.TargetServiceConfig
+	var podSelectors []string
 	var err error
 
 	switch utils.GetProxyType(req.RequestURI) {
@@ -207,7 +239,17 @@ func (k *userServer) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 			http.Error(wr, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if !k.serviceAllowlist.IsAllowed(tsc) {
+		if tsc.Pod != "" {
+			podSelectors = k.serviceAllowlist.PodSelectors(tsc)
+			if len(podSelectors) == 0 {
+				klog.V(4).Infof("service proxy request denied: pod %s/%s is not exposed by a podSelector of the exposed services allowlist",
+					tsc.Namespace, tsc.Pod)
+				http.Error(wr,
+					fmt.Sprintf("pod %s/%s is not in the exposed services allowlist", tsc.Namespace, tsc.Pod),
+					http.StatusForbidden)
+				return
+			}
+		} else if !k.serviceAllowlist.IsAllowed(tsc) {
 			klog.V(4).Infof("service proxy request denied: %s/%s is not in the exposed services allowlist",
 				tsc.Namespace, tsc.Service)
 			http.Error(wr,
@@ -215,6 +257,24 @@ func (k *userServer) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 				http.StatusForbidden)
 			return
 		}
+	case utils.ProxyTypeTCP:
+		tsc, err = utils.GetTargetServiceConfigForTCPTunnel(req.RequestURI)
+		if err != nil {
+			http.Error(wr, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !utils.IsTCPTunnelRequest(req) {
+			http.Error(wr, fmt.Sprintf("TCP tunnels require an upgrade to %s", utils.TCPTunnelProtocol), http.StatusBadRequest)
+			return
+		}
+		if !k.serviceAllowlist.IsAllowed(tsc) {
+			klog.V(4).Infof("TCP tunnel request denied: %s/%s:%s is not in the exposed services allowlist",
+				tsc.Namespace, tsc.Service, tsc.Port)
+			http.Error(wr,
+				fmt.Sprintf("service %s/%s is not exposed for TCP tunnels", tsc.Namespace, tsc.Service),
+				http.StatusForbidden)
+			return
+		}
 	case utils.ProxyTypeKubeAPIServer:
 		tsc, err = utils.GetTargetServiceConfigForKubeAPIServer(req.RequestURI)
 		if err != nil {
@@ -223,20 +283,57 @@ func (k *userServer) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	targetURL, err := url.Parse(serviceProxyURL(tsc.Cluster))
-	if err != nil {
-		http.Error(wr, err.Error(), http.StatusBadRequest)
+	if err := k.clientIdentity.apply(req, tsc.Cluster); err != nil {
+		http.Error(wr, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	tunnel, err := k.getTunnel(req.Context())
+	targetURL, err := url.Parse(serviceProxyURL(tsc.Cluster))
 	if err != nil {
 		http.Error(wr, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-	proxy.Transport = &http.Transport{
+	proxy.Transport = k.serviceProxyTransport()
+
+	proxy.ErrorHandler = func(rw http.ResponseWriter, r *http.Request, e error) {
+		http.Error(rw, fmt.Sprintf("proxy to anp-proxy-server failed because %v", e), http.StatusBadGateway)
+		klog.Errorf("proxy to anp-proxy-server failed because %v", e)
+	}
+
+	klog.V(4).Infof("request scheme:%s; rawQuery:%s; path:%s", req.URL.Scheme, req.URL.RawQuery, req.URL.Path)
+
+	req = utils.UpdateRequest(tsc, req)
+	for _, selector := range podSelectors {
+		req.Header.Add(utils.HeaderClusterProxyPodSelector, utils.EncodePodSelector(selector))
+	}
+	proxy.ServeHTTP(wr, req)
+}
+
+// serviceProxyTransport returns the transport shared by all requests. Each
+// connection it dials opens its own tunnel.
+func (k *userServer) serviceProxyTransport() *utils.UpgradeAwareTransport {
+	k.transportOnce.Do(func() {
+		k.transport = newServiceProxyTransport(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			tunnel, err := k.getTunnel(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return tunnel.DialContext(ctx, network, addr)
+		}, k.streams.IdleTimeout)
+	})
+	return k.transport
+}
+
+// newServiceProxyTransport returns the transport to the service-proxy of a
+// managed cluster. Upgrade requests use HTTP/1.1 and all other requests share
+// HTTP/2 connections.
+func newServiceProxyTransport(
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	streamIdleTimeout time.Duration,
+) *utils.UpgradeAwareTransport {
+	transport := utils.NewUpgradeAwareTransport(&http.Transport{
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
@@ -246,24 +343,13 @@ func (k *userServer) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 			RootCAs:    serviceProxyRootCA,
 			MinVersion: tls.VersionTLS12,
 		},
-		// golang http pkg automatically upgrade http connection to http2 connection, but http2 can not upgrade to SPDY which used in "kubectl exec".
-		// set ForceAttemptHTTP2 = false to prevent auto http2 upgration
-		ForceAttemptHTTP2: false,
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			klog.V(4).Infof("proxy dial to %s", addr)
-			// TODO: may find a way to cache the proxyConn.
-			return tunnel.DialContext(ctx, network, addr)
+			return dial(ctx, network, addr)
 		},
-	}
-
-	proxy.ErrorHandler = func(rw http.ResponseWriter, r *http.Request, e error) {
-		http.Error(rw, fmt.Sprintf("proxy to anp-proxy-server failed because %v", e), http.StatusBadGateway)
-		klog.Errorf("proxy to anp-proxy-server failed because %v", e)
-	}
-
-	klog.V(4).Infof("request scheme:%s; rawQuery:%s; path:%s", req.URL.Scheme, req.URL.RawQuery, req.URL.Path)
-
-	proxy.ServeHTTP(wr, utils.UpdateRequest(tsc, req))
+	})
+	transport.StreamIdleTimeout = streamIdleTimeout
+	return transport
 }
 
 func (k *userServer) Run(ctx context.Context) error {
@@ -308,7 +394,11 @@ func (k *userServer) Run(ctx context.Context) error {
 	klog.Infof("TLS config loaded: minVersion=%s, ciphersuites=%s", sdktls.VersionToString(sdkTLSConfig.MinVersion),
 		sdktls.CipherSuitesToString(sdkTLSConfig.CipherSuites))
 
-	cc, err := addonutils.NewConfigChecker("user-server", k.proxyCACertPath, k.proxyCertPath, k.proxyKeyPath, k.serverCert, k.serverKey, k.serviceProxyCACertPath)
+	configFiles := []string{k.proxyCACertPath, k.proxyCertPath, k.proxyKeyPath, k.serverCert, k.serverKey, k.serviceProxyCACertPath}
+	if k.clientCAFile != "" {
+		configFiles = append(configFiles, k.clientCAFile)
+	}
+	cc, err := addonutils.NewConfigChecker("user-server", configFiles...)
 	if err != nil {
 		return fmt.Errorf("failed to create config checker: %w", err)
 	}
@@ -317,15 +407,26 @@ func (k *userServer) Run(ctx context.Context) error {
 		MinVersion:   sdkTLSConfig.MinVersion,
 		CipherSuites: sdkTLSConfig.CipherSuites,
 	}
+	if k.clientCAFile != "" {
+		clientCAs, err := certutil.NewPool(k.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client CA bundle: %w", err)
+		}
+		// bearer token clients keep working without a certificate
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		tlsConfig.ClientCAs = clientCAs
+	}
 
 	healthServer := utils.NewHealthProbeServer(":8000", cc.Check)
-	publicServer := utils.NewProxyHTTPServer(fmt.Sprintf(":%d", k.serverPort), tlsConfig, k)
+	connections := utils.NewConnectionTracker()
+	publicServer := utils.NewProxyHTTPServer(fmt.Sprintf(":%d", k.serverPort), tlsConfig, connections.Handler(k))
 
 	klog.Infof("starting user HTTPS server on %d and health server on 8000", k.serverPort)
 	return utils.RunHTTPServers(
 		runCtx,
 		k.drain,
 		publicServer,
+		connections,
 		k.serverCert,
 		k.serverKey,
 		healthServer,
@@ -0,0 +1,356 @@
+package userserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	gwebsocket "github.com/gorilla/websocket"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/transport/websocket"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/streaming/pkg/httpstream"
+	konnectivity "sigs.k8s.io/apiserver-network-proxy/konnectivity-client/pkg/client"
+
+	clusterproxyutil "open-cluster-management.io/cluster-proxy/pkg/util"
+	"open-cluster-management.io/cluster-proxy/pkg/utils"
+)
+
+func TestServiceProxyTransportProtocols(t *testing.T) {
+	serviceProxy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Service-Proxy-Proto", r.Proto)
+	}))
+	serviceProxy.EnableHTTP2 = true
+	serviceProxy.StartTLS()
+	defer serviceProxy.Close()
+
+	previousRootCA := serviceProxyRootCA
+	serviceProxyRootCA = serviceProxy.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	defer func() { serviceProxyRootCA = previousRootCA }()
+
+	// the tunnel resolves the service-proxy of the managed cluster
+	transport := newServiceProxyTransport(func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, serviceProxy.Listener.Addr().String())
+	}, 0)
+	defer transport.CloseIdleConnections()
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantProto string
+	}{
+		{
+			name:      "list and watch requests",
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "exec upgrade requests",
+			header:    http.Header{"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}},
+			wantProto: "HTTP/1.1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "https://example.com/api/v1/pods", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			for key, values := range test.header {
+				req.Header[key] = values
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("round trip failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Service-Proxy-Proto"); got != test.wantProto {
+				t.Fatalf("service-proxy received %s, want %s", got, test.wantProto)
+			}
+		})
+	}
+}
+
+// directTunnel stands in for the konnectivity tunnel and dials one address.
+type directTunnel struct {
+	addr string
+}
+
+func (d directTunnel) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	return (&net.Dialer{}).DialContext(ctx, network, d.addr)
+}
+
+func (directTunnel) Done() <-chan struct{} {
+	return nil
+}
+
+// startServiceProxy serves handler as the service-proxy of cluster1 with a
+// certificate the user-server trusts.
+func startServiceProxy(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey(clusterproxyutil.GenerateServiceProxyHost("cluster1"), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to generate service-proxy certificate: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load service-proxy certificate: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AppendCertsFromPEM(certPEM)
+	previousRootCA := serviceProxyRootCA
+	serviceProxyRootCA = rootCAs
+	t.Cleanup(func() { serviceProxyRootCA = previousRootCA })
+
+	serviceProxy := httptest.NewUnstartedServer(handler)
+	serviceProxy.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	serviceProxy.EnableHTTP2 = true
+	serviceProxy.StartTLS()
+	t.Cleanup(serviceProxy.Close)
+	return serviceProxy
+}
+
+// startWebSocketServiceProxy serves a service-proxy stand-in for cluster1 that
+// echoes one message over the kubectl v5 streaming protocol.
+func startWebSocketServiceProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	upgrader := gwebsocket.Upgrader{Subprotocols: []string{"v5.channel.k8s.io"}}
+	return startServiceProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods/shell/exec" || !gwebsocket.IsWebSocketUpgrade(r) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(messageType, message)
+	}))
+}
+
+func dialWebSocketExec(t *testing.T, userServerURL string) (*websocket.RoundTripper, error) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet,
+		userServerURL+"/cluster1/api/v1/namespaces/default/pods/shell/exec?command=sh", nil)
+	req.Header.Add("Sec-WebSocket-Protocol", "v5.channel.k8s.io")
+	req.Header.Add("Sec-WebSocket-Protocol", "v4.channel.k8s.io")
+	rt := &websocket.RoundTripper{}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return rt, nil
+}
+
+func TestServeHTTPForwardsWebSocketExec(t *testing.T) {
+	serviceProxy := startWebSocketServiceProxy(t)
+	k := &userServer{
+		getTunnel: func(context.Context) (konnectivity.Tunnel, error) {
+			return directTunnel{addr: serviceProxy.Listener.Addr().String()}, nil
+		},
+	}
+	userServer := httptest.NewServer(k)
+	defer userServer.Close()
+
+	rt, err := dialWebSocketExec(t, userServer.URL)
+	if err != nil {
+		t.Fatalf("WebSocket upgrade through user-server failed: %v", err)
+	}
+	conn := rt.Connection()
+	defer conn.Close()
+
+	if conn.Subprotocol() != "v5.channel.k8s.io" {
+		t.Fatalf("negotiated %q, want v5.channel.k8s.io", conn.Subprotocol())
+	}
+	if err := conn.WriteMessage(gwebsocket.BinaryMessage, []byte("\x00echo hello")); err != nil {
+		t.Fatalf("failed to write stdin: %v", err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read stdout: %v", err)
+	}
+	if string(message) != "\x00echo hello" {
+		t.Fatalf("unexpected message %q", message)
+	}
+}
+
+func TestServeHTTPForwardsWebSocketRejectionForSPDYFallback(t *testing.T) {
+	serviceProxy := startServiceProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "WebSocket is not supported", http.StatusBadRequest)
+	}))
+	k := &userServer{
+		getTunnel: func(context.Context) (konnectivity.Tunnel, error) {
+			return directTunnel{addr: serviceProxy.Listener.Addr().String()}, nil
+		},
+	}
+	userServer := httptest.NewServer(k)
+	defer userServer.Close()
+
+	_, err := dialWebSocketExec(t, userServer.URL)
+	// kubectl retries with SPDY on upgrade failures
+	if !httpstream.IsUpgradeFailure(err) {
+		t.Fatalf("expected an upgrade failure, got %v", err)
+	}
+}
+
+func TestServeHTTPForwardsTCPTunnel(t *testing.T) {
+	serviceProxy := startServiceProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(utils.HeaderClusterProxyProto) != utils.ProtoTCP ||
+			r.Header.Get(utils.HeaderClusterProxyService) != "postgres" || !utils.IsTCPTunnelRequest(r) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		client, backend := net.Pipe()
+		go func() {
+			defer backend.Close()
+			_, _ = io.Copy(backend, backend)
+		}()
+		_ = utils.ServeTCPTunnel(w, client, 0)
+	}))
+	allowlist := &ServiceAllowlist{}
+	allowlist.update([]ExposedService{
+		{Namespace: "db", Service: "postgres", Port: "5432", Protocol: utils.ProtoTCP},
+		{Namespace: "db", Service: "redis"},
+	})
+	k := &userServer{
+		serviceAllowlist: allowlist,
+		getTunnel: func(context.Context) (konnectivity.Tunnel, error) {
+			return directTunnel{addr: serviceProxy.Listener.Addr().String()}, nil
+		},
+	}
+	userServer := httptest.NewServer(k)
+	defer userServer.Close()
+
+	openTunnel := func(service string) *http.Response {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet,
+			userServer.URL+"/cluster1/api/v1/namespaces/db/services/"+service+"/proxy-tcp", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", utils.TCPTunnelProtocol)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("tunnel request failed: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	// an entry without a protocol does not expose raw TCP
+	if resp := openTunnel("redis:6379"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("tunnel to a service not exposed for TCP: status %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	resp := openTunnel("postgres:5432")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("tunnel status %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	stream := resp.Body.(io.ReadWriteCloser)
+	if _, err := stream.Write([]byte("SELECT 1;\n")); err != nil {
+		t.Fatalf("failed to write to tunnel: %v", err)
+	}
+	if line, err := bufio.NewReader(stream).ReadString('\n'); err != nil || line != "SELECT 1;\n" {
+		t.Fatalf("unexpected echo %q: %v", line, err)
+	}
+}
+
+func TestServeHTTPForwardsPodSelectors(t *testing.T) {
+	received := make(chan http.Header, 1)
+	serviceProxy := startServiceProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	allowlist := &ServiceAllowlist{}
+	allowlist.update([]ExposedService{
+		{Namespace: "monitoring", Service: "prometheus"},
+		{Namespace: "monitoring", PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}}},
+		{Namespace: "monitoring", PodSelector: &metav1.LabelSelector{}, Port: "9090"},
+	})
+	k := &userServer{
+		serviceAllowlist: allowlist,
+		getTunnel: func(context.Context) (konnectivity.Tunnel, error) {
+			return directTunnel{addr: serviceProxy.Listener.Addr().String()}, nil
+		},
+	}
+	userServer := httptest.NewServer(k)
+	defer userServer.Close()
+
+	get := func(path string) int {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, userServer.URL+path, nil)
+		// selectors supplied by the client are never forwarded
+		req.Header.Set(utils.HeaderClusterProxyPodSelector, "injected=true")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get("/cluster1/api/v1/namespaces/default/pods/web-0/proxy-service/"); status != http.StatusForbidden {
+		t.Fatalf("request to an unexposed pod: status %d, want %d", status, http.StatusForbidden)
+	}
+
+	if status := get("/cluster1/api/v1/namespaces/monitoring/pods/http:prometheus-0:9090/proxy-service/metrics"); status != http.StatusOK {
+		t.Fatalf("request to an exposed pod: status %d, want %d", status, http.StatusOK)
+	}
+	header := <-received
+	if header.Get(utils.HeaderClusterProxyPod) != "prometheus-0" || header.Get(utils.HeaderClusterProxyService) != "" {
+		t.Errorf("unexpected target headers %v", header)
+	}
+	if selectors := header.Values(utils.HeaderClusterProxyPodSelector); len(selectors) != 2 ||
+		selectors[0] != utils.EncodePodSelector("app=prometheus") || selectors[1] != utils.EncodePodSelector("") {
+		t.Errorf("unexpected pod selectors %q", selectors)
+	}
+
+	if status := get("/cluster1/api/v1/namespaces/monitoring/services/http:prometheus:9090/proxy-service/metrics"); status != http.StatusOK {
+		t.Fatalf("request to an exposed service: status %d, want %d", status, http.StatusOK)
+	}
+	header = <-received
+	if selectors := header.Values(utils.HeaderClusterProxyPodSelector); len(selectors) != 0 {
+		t.Errorf("expected no pod selectors for a service target, got %q", selectors)
+	}
+}
+
+func TestServeHTTPReusesServiceProxyConnections(t *testing.T) {
+	serviceProxy := startServiceProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var tunnels atomic.Int32
+	k := &userServer{
+		serviceAllowlist: &ServiceAllowlist{},
+		getTunnel: func(context.Context) (konnectivity.Tunnel, error) {
+			tunnels.Add(1)
+			return directTunnel{addr: serviceProxy.Listener.Addr().String()}, nil
+		},
+	}
+	k.serviceAllowlist.update([]ExposedService{{Namespace: "monitoring", Service: "prometheus"}})
+	userServer := httptest.NewServer(k)
+	defer userServer.Close()
+
+	for range 3 {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet,
+			userServer.URL+"/cluster1/api/v1/namespaces/monitoring/services/http:prometheus:9090/proxy-service/metrics", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if got := tunnels.Load(); got != 1 {
+		t.Fatalf("opened %d tunnels for 3 requests, want 1", got)
+	}
+}
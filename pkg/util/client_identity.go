@@ -0,0 +1,33 @@
+package util
+
+import (
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// ClientIdentityHeader carries the identity of a client certificate
+	// verified by the user-server to the service-proxy of the target cluster.
+	// The user-server drops the header from every incoming request.
+	ClientIdentityHeader = "X-Cluster-Proxy-Client-Identity"
+
+	// ClientIdentityIssuer is the iss claim of client identity tokens.
+	ClientIdentityIssuer = "cluster-proxy-user-server"
+
+	// ClientIdentitySigningSecretName is the Secret in the hub addon namespace
+	// holding the key that signs client identity tokens. The user-server
+	// creates it and the addon-manager publishes its public key to agents.
+	ClientIdentitySigningSecretName = "cluster-proxy-client-identity-signing-key"
+
+	// ClientIdentitySigningAlgorithm signs client identity tokens with the
+	// ECDSA P-256 key of ClientIdentitySigningSecretName.
+	ClientIdentitySigningAlgorithm = jose.ES256
+)
+
+// ClientIdentityClaims is the payload of a client identity token. The
+// subject is the common name and the groups are the organizations of the
+// verified client certificate; the audience is the target cluster name.
+type ClientIdentityClaims struct {
+	jwt.Claims
+	Groups []string `json:"groups,omitempty"`
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"net"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+// NewEntrypointStatus sorts the addresses resolved for an entrypoint into hostnames
+// and IPs, keeping their order and dropping empty or duplicated ones.
+func NewEntrypointStatus(addresses ...string) *proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus {
+	status := &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{}
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		if len(address) == 0 || seen[address] {
+			continue
+		}
+		seen[address] = true
+		if net.ParseIP(address) != nil {
+			status.IPs = append(status.IPs, address)
+		} else {
+			status.Hostnames = append(status.Hostnames, address)
+		}
+	}
+	return status
+}
+
+// EntrypointAddress returns the address the proxy agents dial from an entrypoint
+// status. Hostnames are preferred since the passthrough entrypoints route on SNI.
+func EntrypointAddress(status *proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus) string {
+	if status == nil {
+		return ""
+	}
+	if len(status.Hostnames) > 0 {
+		return status.Hostnames[0]
+	}
+	if len(status.IPs) > 0 {
+		return status.IPs[0]
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	proxyv1alpha1 "open-cluster-management.io/cluster-proxy/pkg/apis/proxy/v1alpha1"
+)
+
+func TestNewEntrypointStatus(t *testing.T) {
+	testcases := []struct {
+		name      string
+		addresses []string
+		expected  *proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus
+	}{
+		{
+			name:     "no addresses",
+			expected: &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{},
+		},
+		{
+			name:      "hostnames and ips",
+			addresses: []string{"proxy.example.com", "1.2.3.4", "", "fd00::1", "elb.amazonaws.com", "1.2.3.4"},
+			expected: &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+				Hostnames: []string{"proxy.example.com", "elb.amazonaws.com"},
+				IPs:       []string{"1.2.3.4", "fd00::1"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := NewEntrypointStatus(tc.addresses...)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEntrypointAddress(t *testing.T) {
+	testcases := []struct {
+		name     string
+		status   *proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus
+		expected string
+	}{
+		{
+			name: "nil status",
+		},
+		{
+			name: "prefers hostnames",
+			status: &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+				Hostnames: []string{"proxy.example.com"},
+				IPs:       []string{"1.2.3.4"},
+			},
+			expected: "proxy.example.com",
+		},
+		{
+			name: "falls back to ips",
+			status: &proxyv1alpha1.ManagedProxyConfigurationEntrypointStatus{
+				IPs: []string{"fd00::1"},
+			},
+			expected: "fd00::1",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := EntrypointAddress(tc.status); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
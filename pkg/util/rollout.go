@@ -0,0 +1,98 @@
+package util
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	addonclient "open-cluster-management.io/api/client/addon/clientset/versioned"
+
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+)
+
+// IsDeploymentRolledOut checks if every replica of the deployment runs its latest pod template
+// and is available.
+func IsDeploymentRolledOut(deploy *appsv1.Deployment) bool {
+	replicas := ptr.Deref(deploy.Spec.Replicas, 1)
+	return deploy.Status.ObservedGeneration >= deploy.Generation &&
+		deploy.Status.UpdatedReplicas == replicas &&
+		deploy.Status.Replicas == replicas &&
+		deploy.Status.AvailableReplicas == replicas
+}
+
+// AgentRolloutReporter reports the rollout of the proxy agent deployment on the
+// ManagedClusterAddOn in the hub, so that the hub learns which CA bundle and service-proxy
+// certificate the running agents are loaded with.
+type AgentRolloutReporter struct {
+	spokeClient    kubernetes.Interface
+	addonClient    addonclient.Interface
+	clusterName    string
+	namespace      string
+	deploymentName string
+}
+
+func NewAgentRolloutReporter(
+	spokeClient kubernetes.Interface,
+	addonClient addonclient.Interface,
+	clusterName, namespace, deploymentName string) *AgentRolloutReporter {
+	return &AgentRolloutReporter{
+		spokeClient:    spokeClient,
+		addonClient:    addonClient,
+		clusterName:    clusterName,
+		namespace:      namespace,
+		deploymentName: deploymentName,
+	}
+}
+
+// Start reports the rollout periodically until the context is done.
+func (r *AgentRolloutReporter) Start(ctx context.Context, period time.Duration) {
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.Report(ctx); err != nil {
+			klog.Errorf("Failed to report the rollout of the proxy agents: %v", err)
+		}
+	}, period)
+}
+
+// Report sets the AddonConditionTypeProxyAgentRolledOut condition from the proxy agent
+// deployment, which is only true once every replica runs the latest pod template.
+func (r *AgentRolloutReporter) Report(ctx context.Context) error {
+	deploy, err := r.spokeClient.AppsV1().Deployments(r.namespace).Get(ctx, r.deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get deployment %s/%s", r.namespace, r.deploymentName)
+	}
+	condition := metav1.Condition{
+		Type:    common.AddonConditionTypeProxyAgentRolledOut,
+		Status:  metav1.ConditionFalse,
+		Reason:  "DeploymentRollingOut",
+		Message: common.FormatRolloutHashes(deploy.Spec.Template.Annotations),
+	}
+	if IsDeploymentRolledOut(deploy) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "DeploymentRolledOut"
+	}
+
+	addons := r.addonClient.AddonV1beta1().ManagedClusterAddOns(r.clusterName)
+	addon, err := addons.Get(ctx, common.AddonName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get managed cluster addon %s/%s", r.clusterName, common.AddonName)
+	}
+	existing := meta.FindStatusCondition(addon.Status.Conditions, condition.Type)
+	if existing != nil && existing.Status == condition.Status &&
+		existing.Reason == condition.Reason && existing.Message == condition.Message {
+		return nil
+	}
+	addon = addon.DeepCopy()
+	meta.SetStatusCondition(&addon.Status.Conditions, condition)
+	if _, err := addons.UpdateStatus(ctx, addon, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to update managed cluster addon %s/%s", r.clusterName, common.AddonName)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+
+	"open-cluster-management.io/cluster-proxy/pkg/common"
+)
+
+func TestAgentRolloutReporter(t *testing.T) {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "agent", Name: "proxy-agent", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           3,
+			UpdatedReplicas:    1,
+			AvailableReplicas:  2,
+		},
+	}
+	deploy.Spec.Template.Annotations = map[string]string{
+		common.AnnotationKeyCABundleHash:            "new-ca",
+		common.AnnotationKeyServiceProxyCertHash:    "new-cert",
+		common.AnnotationKeyConfigurationGeneration: "1",
+	}
+	spokeClient := fake.NewSimpleClientset(deploy)
+	addonClient := fakeaddon.NewSimpleClientset(&addonv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cluster1", Name: common.AddonName},
+	})
+	reporter := NewAgentRolloutReporter(spokeClient, addonClient, "cluster1", "agent", "proxy-agent")
+	reportedCondition := func() *metav1.Condition {
+		if err := reporter.Report(t.Context()); err != nil {
+			t.Fatalf("unexpected error reporting the rollout: %v", err)
+		}
+		addon, err := addonClient.AddonV1beta1().ManagedClusterAddOns("cluster1").
+			Get(t.Context(), common.AddonName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get addon: %v", err)
+		}
+		condition := meta.FindStatusCondition(addon.Status.Conditions, common.AddonConditionTypeProxyAgentRolledOut)
+		if condition == nil {
+			t.Fatal("the rollout is not reported")
+		}
+		return condition
+	}
+
+	// the old replicas are still running
+	if condition := reportedCondition(); condition.Status != metav1.ConditionFalse {
+		t.Fatalf("expected the rollout in progress, got %v", condition)
+	}
+
+	deploy.Status.Replicas = 2
+	deploy.Status.UpdatedReplicas = 2
+	if _, err := spokeClient.AppsV1().Deployments("agent").UpdateStatus(t.Context(), deploy, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update deployment: %v", err)
+	}
+	condition := reportedCondition()
+	if condition.Status != metav1.ConditionTrue {
+		t.Fatalf("expected the rollout completed, got %v", condition)
+	}
+	hashes := common.ParseRolloutHashes(condition.Message)
+	expected := map[string]string{
+		common.AnnotationKeyCABundleHash:         "new-ca",
+		common.AnnotationKeyServiceProxyCertHash: "new-cert",
+	}
+	if len(hashes) != len(expected) {
+		t.Fatalf("expected hashes %v, got %v", expected, hashes)
+	}
+	for key, value := range expected {
+		if hashes[key] != value {
+			t.Fatalf("expected hashes %v, got %v", expected, hashes)
+		}
+	}
+}
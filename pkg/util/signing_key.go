@@ -0,0 +1,98 @@
+package util
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+)
+
+// SigningKeySecretKey is the Secret entry holding a PEM encoded ECDSA P-256
+// signing key.
+const SigningKeySecretKey = "signing.key"
+
+// LoadOrCreateSigningKey returns the signing key stored in the named Secret,
+// generating it on first use. Replicas racing on creation converge on
+// whichever key was stored first.
+func LoadOrCreateSigningKey(
+	ctx context.Context,
+	client kubernetes.Interface,
+	namespace, name string,
+) (*ecdsa.PrivateKey, error) {
+	secrets := client.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret, err = createSigningKeySecret(ctx, client, namespace, name)
+		if apierrors.IsAlreadyExists(err) {
+			secret, err = secrets.Get(ctx, name, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get signing secret %s/%s", namespace, name)
+	}
+	return SigningKeyFromSecret(secret)
+}
+
+// SigningKeyFromSecret parses the signing key stored in the Secret.
+func SigningKeyFromSecret(secret *corev1.Secret) (*ecdsa.PrivateKey, error) {
+	keyPEM, ok := secret.Data[SigningKeySecretKey]
+	if !ok {
+		return nil, errors.Errorf("signing secret %s/%s does not contain %s", secret.Namespace, secret.Name, SigningKeySecretKey)
+	}
+	key, err := keyutil.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse signing key of secret %s/%s", secret.Namespace, secret.Name)
+	}
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecdsaKey.Curve != elliptic.P256() {
+		return nil, errors.Errorf("signing key of secret %s/%s must be an ECDSA P-256 key", secret.Namespace, secret.Name)
+	}
+	return ecdsaKey, nil
+}
+
+// EncodePublicKeyPEM returns the PKIX "PUBLIC KEY" PEM block of the signing
+// key, which keyutil.ParsePublicKeysPEM reads back.
+func EncodePublicKeyPEM(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal public key")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func createSigningKeySecret(
+	ctx context.Context,
+	client kubernetes.Interface,
+	namespace, name string,
+) (*corev1.Secret, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("generating signing key in secret %s/%s", namespace, name)
+	return client.CoreV1().Secrets(namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			SigningKeySecretKey: keyPEM,
+		},
+	}, metav1.CreateOptions{})
+}
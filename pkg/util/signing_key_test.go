@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestLoadOrCreateSigningKey(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	created, err := LoadOrCreateSigningKey(t.Context(), client, "agent", "signing")
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	loaded, err := LoadOrCreateSigningKey(t.Context(), client, "agent", "signing")
+	if err != nil {
+		t.Fatalf("unexpected error loading key: %v", err)
+	}
+	if !created.Equal(loaded) {
+		t.Fatal("the stored signing key was not reused")
+	}
+
+	publicKeyPEM, err := EncodePublicKeyPEM(created)
+	if err != nil {
+		t.Fatalf("unexpected error encoding public key: %v", err)
+	}
+	publicKeys, err := keyutil.ParsePublicKeysPEM(publicKeyPEM)
+	if err != nil || len(publicKeys) != 1 || !created.PublicKey.Equal(publicKeys[0]) {
+		t.Fatalf("public key does not round trip: %v", err)
+	}
+
+	if _, err := client.CoreV1().Secrets("agent").Create(t.Context(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "agent"},
+		Data:       map[string][]byte{"other": []byte("data")},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create secret: %v", err)
+	}
+	if _, err := LoadOrCreateSigningKey(t.Context(), client, "agent", "invalid"); err == nil {
+		t.Fatal("expected an error for a secret without a signing key")
+	}
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterProxyTokenAudience is the audience of tokens minted for cluster-proxy
+// only. Service-proxy accepts nothing else for the hub when started with
+// --hub-token-audiences=cluster-proxy.open-cluster-management.io.
+const ClusterProxyTokenAudience = "cluster-proxy.open-cluster-management.io"
+
+// RequestServiceAccountToken mints a token for the service account through
+// the TokenRequest API. The token is only valid for the given audiences, so a
+// token minted for cluster-proxy cannot be replayed against other services and
+// tokens minted for other services are rejected by cluster-proxy. The
+// kube-apiserver may extend short expirations to its minimum of 10 minutes.
+func RequestServiceAccountToken(
+	ctx context.Context,
+	client kubernetes.Interface,
+	namespace, serviceAccount string,
+	audiences []string,
+	expiration time.Duration,
+) (string, error) {
+	if len(audiences) == 0 {
+		return "", errors.New("at least one token audience is required")
+	}
+	request := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences: audiences,
+		},
+	}
+	if expiration > 0 {
+		expirationSeconds := int64(expiration.Seconds())
+		request.Spec.ExpirationSeconds = &expirationSeconds
+	}
+
+	response, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, serviceAccount, request, metav1.CreateOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed requesting token for service account %s/%s", namespace, serviceAccount)
+	}
+	return response.Status.Token, nil
+}
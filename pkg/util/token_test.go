@@ -0,0 +1,46 @@
+package util
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRequestServiceAccountToken(t *testing.T) {
+	var request *authenticationv1.TokenRequest
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" || action.GetNamespace() != "team-a" {
+			return false, nil, nil
+		}
+		request = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		return true, &authenticationv1.TokenRequest{
+			Status: authenticationv1.TokenRequestStatus{Token: "scoped-token"},
+		}, nil
+	})
+
+	token, err := RequestServiceAccountToken(context.TODO(), client, "team-a", "deployer",
+		[]string{ClusterProxyTokenAudience}, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "scoped-token" {
+		t.Errorf("expected scoped-token, got %s", token)
+	}
+	if !slices.Equal(request.Spec.Audiences, []string{ClusterProxyTokenAudience}) {
+		t.Errorf("unexpected audiences %v", request.Spec.Audiences)
+	}
+	if request.Spec.ExpirationSeconds == nil || *request.Spec.ExpirationSeconds != 3600 {
+		t.Errorf("unexpected expiration %v", request.Spec.ExpirationSeconds)
+	}
+
+	if _, err := RequestServiceAccountToken(context.TODO(), client, "team-a", "deployer", nil, time.Hour); err == nil {
+		t.Error("expected an error without audiences")
+	}
+}
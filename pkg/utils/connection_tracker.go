@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/streaming/pkg/httpstream"
+)
+
+// closeFrameWriteTimeout bounds writing a close frame to a client that stopped
+// reading.
+const closeFrameWriteTimeout = time.Second
+
+var (
+	// websocketGoingAway is a WebSocket close frame with status 1001 (going
+	// away), which asks the client to reconnect.
+	websocketGoingAway = append([]byte{0x88, 0x02 + byte(len("proxy shutting down")), 0x03, 0xe9},
+		"proxy shutting down"...)
+	// spdyGoAway is a SPDY/3.1 GOAWAY frame with status OK, as sent by
+	// spdystream when it closes a connection.
+	spdyGoAway = []byte{0x80, 0x03, 0x00, 0x07, 0x00, 0x00, 0x00, 0x08, 0, 0, 0, 0, 0, 0, 0, 0}
+)
+
+// ConnectionTracker registers connections hijacked from net/http for protocol
+// upgrades, such as exec, attach and port-forward sessions. http.Server.Shutdown
+// neither waits for nor closes them, so RunHTTPServers drains them through the
+// tracker.
+type ConnectionTracker struct {
+	mu       sync.Mutex
+	streams  map[*trackedStream]struct{}
+	draining bool
+	// drained is closed once draining starts and no stream remains.
+	drained chan struct{}
+}
+
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{
+		streams: map[*trackedStream]struct{}{},
+		drained: make(chan struct{}),
+	}
+}
+
+// Handler tracks the upgrade requests served by next until its handler
+// returns. Once draining starts, new upgrades are answered with 503 Service
+// Unavailable so clients retry on another replica.
+func (t *ConnectionTracker) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if !httpstream.IsUpgradeRequest(req) {
+			next.ServeHTTP(wr, req)
+			return
+		}
+		stream, ok := t.add(req)
+		if !ok {
+			wr.Header().Set("Connection", "close")
+			http.Error(wr, "the proxy is shutting down, retry the request", http.StatusServiceUnavailable)
+			return
+		}
+		defer t.remove(stream)
+		next.ServeHTTP(&trackingResponseWriter{ResponseWriter: wr, stream: stream}, req)
+	})
+}
+
+// Active returns the number of tracked upgrade requests.
+func (t *ConnectionTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.streams)
+}
+
+// StartDrain stops accepting new upgrades.
+func (t *ConnectionTracker) StartDrain() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return
+	}
+	t.draining = true
+	if len(t.streams) == 0 {
+		close(t.drained)
+	}
+}
+
+// Wait blocks until draining started and every tracked stream ended, or the
+// context is done.
+func (t *ConnectionTracker) Wait(ctx context.Context) error {
+	select {
+	case <-t.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseAll sends a close frame to the clients of the remaining streams and
+// closes their connections.
+func (t *ConnectionTracker) CloseAll() {
+	t.mu.Lock()
+	streams := make([]*trackedStream, 0, len(t.streams))
+	for stream := range t.streams {
+		streams = append(streams, stream)
+	}
+	t.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.close()
+	}
+}
+
+func (t *ConnectionTracker) add(req *http.Request) (*trackedStream, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return nil, false
+	}
+	stream := &trackedStream{protocol: upgradeProtocolOf(req)}
+	t.streams[stream] = struct{}{}
+	return stream, true
+}
+
+func (t *ConnectionTracker) remove(stream *trackedStream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.streams, stream)
+	if t.draining && len(t.streams) == 0 {
+		close(t.drained)
+	}
+}
+
+// trackingResponseWriter hands out the hijacked connection wrapped in its
+// tracked stream.
+type trackingResponseWriter struct {
+	http.ResponseWriter
+	stream *trackedStream
+}
+
+func (w *trackingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.stream.attach(conn), brw, nil
+}
+
+func (w *trackingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// trackedStream is an upgrade request and, once hijacked, its client
+// connection.
+type trackedStream struct {
+	protocol *upgradeProtocol
+
+	mu     sync.Mutex
+	conn   *trackedConn
+	closed bool
+}
+
+func (s *trackedStream) attach(conn net.Conn) net.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn = &trackedConn{Conn: conn}
+	if s.protocol != nil {
+		s.conn.frames = &frameBoundary{length: s.protocol.frameLength}
+	}
+	if s.closed {
+		s.conn.closeWith(nil)
+	}
+	return s.conn
+}
+
+func (s *trackedStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return
+	}
+	var closeFrame []byte
+	if s.protocol != nil {
+		closeFrame = s.protocol.closeFrame
+	}
+	s.conn.closeWith(closeFrame)
+}
+
+// trackedConn follows the frames written to the client so that a close frame
+// is never inserted into the middle of another frame.
+type trackedConn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	frames *frameBoundary
+	closed bool
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	n, err := c.Conn.Write(p)
+	if c.frames != nil {
+		c.frames.advance(p[:n])
+	}
+	return n, err
+}
+
+func (c *trackedConn) closeWith(closeFrame []byte) {
+	// unblock a pending write to a client that stopped reading
+	_ = c.Conn.SetWriteDeadline(time.Now())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if closeFrame != nil && c.frames.atBoundary() {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(closeFrameWriteTimeout))
+		if _, err := c.Conn.Write(closeFrame); err != nil {
+			klog.V(4).Infof("failed to send close frame to %s: %v", c.Conn.RemoteAddr(), err)
+		}
+	}
+	_ = c.Conn.Close()
+}
+
+type upgradeProtocol struct {
+	closeFrame []byte
+	// frameLength returns the payload length once header holds a complete
+	// frame header.
+	frameLength func(header []byte) (int64, bool)
+}
+
+var (
+	websocketProtocol = &upgradeProtocol{closeFrame: websocketGoingAway, frameLength: websocketFrameLength}
+	spdyProtocol      = &upgradeProtocol{closeFrame: spdyGoAway, frameLength: spdyFrameLength}
+)
+
+// upgradeProtocolOf returns the framing of the requested upgrade, or nil when
+// the connection can only be closed.
+func upgradeProtocolOf(req *http.Request) *upgradeProtocol {
+	if !httpstream.IsUpgradeRequest(req) {
+		return nil
+	}
+	for _, value := range req.Header.Values("Upgrade") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocol = strings.ToLower(strings.TrimSpace(protocol))
+			switch {
+			case protocol == "websocket":
+				return websocketProtocol
+			case strings.HasPrefix(protocol, "spdy/"):
+				return spdyProtocol
+			}
+		}
+	}
+	return nil
+}
+
+func websocketFrameLength(header []byte) (int64, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	headerLen := 2
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		headerLen += 2
+	case 127:
+		headerLen += 8
+	}
+	if header[1]&0x80 != 0 {
+		// masking key
+		headerLen += 4
+	}
+	if len(header) < headerLen {
+		return 0, false
+	}
+	switch length {
+	case 126:
+		length = int64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		length = int64(binary.BigEndian.Uint64(header[2:10]) & (1<<63 - 1))
+	}
+	return length, true
+}
+
+// spdyFrameLength reads the 24-bit length shared by SPDY control and data
+// frame headers.
+func spdyFrameLength(header []byte) (int64, bool) {
+	if len(header) < 8 {
+		return 0, false
+	}
+	return int64(header[5])<<16 | int64(header[6])<<8 | int64(header[7]), true
+}
+
+type frameBoundary struct {
+	header    []byte
+	remaining int64
+	length    func(header []byte) (int64, bool)
+}
+
+func (f *frameBoundary) advance(p []byte) {
+	for len(p) > 0 {
+		if f.remaining > 0 {
+			n := min(int64(len(p)), f.remaining)
+			f.remaining -= n
+			p = p[n:]
+			continue
+		}
+		f.header = append(f.header, p[0])
+		p = p[1:]
+		if length, ok := f.length(f.header); ok {
+			f.remaining = length
+			f.header = f.header[:0]
+		}
+	}
+}
+
+func (f *frameBoundary) atBoundary() bool {
+	return f == nil || (f.remaining == 0 && len(f.header) == 0)
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpgradeProtocolOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   *upgradeProtocol
+	}{
+		{
+			name:   "websocket",
+			header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+			want:   websocketProtocol,
+		},
+		{
+			name:   "connection token list",
+			header: http.Header{"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"WebSocket"}},
+			want:   websocketProtocol,
+		},
+		{
+			name:   "spdy",
+			header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}},
+			want:   spdyProtocol,
+		},
+		{
+			name:   "upgrade header without connection upgrade",
+			header: http.Header{"Upgrade": {"websocket"}},
+		},
+		{
+			name:   "unknown protocol",
+			header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"h2c"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://proxy/api/v1/namespaces/default/pods/shell/exec", nil)
+			req.Header = test.header
+			if got := upgradeProtocolOf(req); got != test.want {
+				t.Fatalf("upgradeProtocolOf() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFrameBoundary(t *testing.T) {
+	tests := []struct {
+		name         string
+		length       func([]byte) (int64, bool)
+		writes       [][]byte
+		wantBoundary bool
+	}{
+		{
+			name:         "complete websocket frame",
+			length:       websocketFrameLength,
+			writes:       [][]byte{{0x82, 0x03, 1, 2, 3}},
+			wantBoundary: true,
+		},
+		{
+			name:   "websocket payload split across writes",
+			length: websocketFrameLength,
+			writes: [][]byte{{0x82, 0x03, 1}, {2}},
+		},
+		{
+			name:         "websocket extended length and split header",
+			length:       websocketFrameLength,
+			writes:       [][]byte{{0x82, 0x7e}, {0x00}, append([]byte{0x80}, make([]byte, 128)...)},
+			wantBoundary: true,
+		},
+		{
+			name:   "masked websocket header",
+			length: websocketFrameLength,
+			writes: [][]byte{{0x81, 0x81, 0, 0, 0}},
+		},
+		{
+			name:         "spdy data frames",
+			length:       spdyFrameLength,
+			writes:       [][]byte{{0, 0, 0, 1, 0, 0, 0, 2, 'o', 'k', 0, 0, 0, 3, 0, 0, 0, 0}},
+			wantBoundary: true,
+		},
+		{
+			name:   "partial spdy header",
+			length: spdyFrameLength,
+			writes: [][]byte{{0x80, 0x03, 0x00}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frames := &frameBoundary{length: test.length}
+			for _, write := range test.writes {
+				frames.advance(write)
+			}
+			if got := frames.atBoundary(); got != test.wantBoundary {
+				t.Fatalf("atBoundary() = %t, want %t", got, test.wantBoundary)
+			}
+		})
+	}
+}
+
+func TestCloseFramesAreWellFormed(t *testing.T) {
+	for name, frame := range map[string]struct {
+		data   []byte
+		length func([]byte) (int64, bool)
+	}{
+		"websocket": {data: websocketGoingAway, length: websocketFrameLength},
+		"spdy":      {data: spdyGoAway, length: spdyFrameLength},
+	} {
+		frames := &frameBoundary{length: frame.length}
+		frames.advance(frame.data)
+		if !frames.atBoundary() {
+			t.Fatalf("%s close frame length does not match its header", name)
+		}
+	}
+}
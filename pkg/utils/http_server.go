@@ -30,7 +30,8 @@ func NewProxyHTTPServer(address string, tlsConfig *tls.Config, handler http.Hand
 	}
 }
 
-// DrainConfig controls how long active HTTP requests may remain during shutdown.
+// DrainConfig controls how long active HTTP requests and upgraded connections
+// may remain during shutdown.
 // MinDuration is part of, rather than additional to, Timeout.
 type DrainConfig struct {
 	Timeout     time.Duration
@@ -53,14 +54,16 @@ func (c DrainConfig) Validate() error {
 // AddFlags registers the drain flags shared by the proxy server commands.
 func (c *DrainConfig) AddFlags(flags *pflag.FlagSet) {
 	flags.DurationVar(&c.Timeout, "drain-timeout", DefaultDrainTimeout,
-		"Maximum time to drain active HTTP requests during shutdown.")
+		"Maximum time to drain active HTTP requests and upgraded connections during shutdown.")
 	flags.DurationVar(&c.MinDuration, "min-drain-duration", DefaultMinDrainDuration,
 		"Minimum drain duration allowing time for endpoint deprogramming.")
 }
 
 type HealthProbeServer struct {
 	*http.Server
-	ready atomic.Bool
+	mux         *http.ServeMux
+	ready       atomic.Bool
+	connections atomic.Pointer[ConnectionTracker]
 }
 
 // NewHealthProbeServer creates separate liveness and readiness endpoints.
@@ -80,12 +83,18 @@ func NewHealthProbeServer(healthProbeBindAddress string, customChecks ...healthz
 
 	mux.Handle("/healthz", http.StripPrefix("/healthz", &healthz.Handler{Checks: checks}))
 	mux.HandleFunc("/readyz", func(writer http.ResponseWriter, _ *http.Request) {
+		var upgraded int
+		if connections := healthServer.connections.Load(); connections != nil {
+			upgraded = connections.Active()
+		}
 		if !healthServer.ready.Load() {
-			http.Error(writer, "draining", http.StatusServiceUnavailable)
+			http.Error(writer, fmt.Sprintf("draining, %d upgraded connections", upgraded), http.StatusServiceUnavailable)
 			return
 		}
 		writer.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(writer, "ok, %d upgraded connections\n", upgraded)
 	})
+	healthServer.mux = mux
 	healthServer.Server = &http.Server{
 		Addr:              healthProbeBindAddress,
 		Handler:           mux,
@@ -94,22 +103,38 @@ func NewHealthProbeServer(healthProbeBindAddress string, customChecks ...healthz
 	return healthServer
 }
 
+// Handle registers an additional plaintext endpoint, such as a public key
+// document, next to the probes. It must be called before the server starts.
+func (s *HealthProbeServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
 func (s *HealthProbeServer) SetReady(ready bool) {
 	s.ready.Store(ready)
 }
 
+// ReportConnections adds the number of upgraded connections to the readiness
+// response.
+func (s *HealthProbeServer) ReportConnections(connections *ConnectionTracker) {
+	s.connections.Store(connections)
+}
+
 type httpServerResult struct {
 	health bool
 	err    error
 }
 
 // RunHTTPServers serves until the context is canceled or either server stops.
-// During planned shutdown it first removes the proxy from readiness, waits for
-// endpoint deprogramming, and then drains requests managed by net/http.
+// During planned shutdown it first removes the proxy from readiness and stops
+// accepting upgrades, waits for endpoint deprogramming, and then drains
+// requests managed by net/http and the upgraded connections registered with
+// connections. Upgraded connections still open at the drain deadline receive a
+// close frame and are closed.
 func RunHTTPServers(
 	ctx context.Context,
 	drainConfig DrainConfig,
 	public *http.Server,
+	connections *ConnectionTracker,
 	certFile, keyFile string,
 	health *HealthProbeServer,
 ) error {
@@ -118,7 +143,7 @@ func RunHTTPServers(
 		return nil
 	}
 
-	return runHTTPServers(ctx, drainConfig, public, health, func() error {
+	return runHTTPServers(ctx, drainConfig, public, connections, health, func() error {
 		return public.ListenAndServeTLS(certFile, keyFile)
 	})
 }
@@ -127,9 +152,11 @@ func runHTTPServers(
 	ctx context.Context,
 	drainConfig DrainConfig,
 	public *http.Server,
+	connections *ConnectionTracker,
 	health *HealthProbeServer,
 	servePublic func() error,
 ) error {
+	health.ReportConnections(connections)
 	serverResults := make(chan httpServerResult, 2)
 	go func() {
 		serverResults <- httpServerResult{err: servePublic()}
@@ -145,6 +172,7 @@ func runHTTPServers(
 	}
 
 	health.SetReady(false)
+	connections.StartDrain()
 	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainConfig.Timeout)
 	defer cancelDrain()
 
@@ -158,16 +186,25 @@ func runHTTPServers(
 		}
 	}
 
+	// closing the remaining upgraded connections lets clients reconnect to
+	// another replica instead of waiting for a TCP timeout
+	defer connections.CloseAll()
+
 	err := public.Shutdown(drainCtx)
 	switch {
 	case err == nil:
-		return nil
 	case errors.Is(err, context.DeadlineExceeded):
 		klog.Warningf("HTTP request drain timeout %s expired; exiting with active requests", drainConfig.Timeout)
 		return nil
 	default:
 		return fmt.Errorf("drain public proxy HTTP requests: %w", err)
 	}
+
+	if err := connections.Wait(drainCtx); err != nil {
+		klog.Warningf("upgraded connection drain timeout %s expired; closing %d connections",
+			drainConfig.Timeout, connections.Active())
+	}
+	return nil
 }
 
 func unexpectedServerError(result httpServerResult) error {
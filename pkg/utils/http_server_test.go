@@ -46,6 +46,16 @@ func TestHealthProbeServerReadiness(t *testing.T) {
 	assertProbeStatus(t, server, "/healthz", http.StatusOK)
 }
 
+func TestHealthProbeServerAdditionalHandler(t *testing.T) {
+	server := NewHealthProbeServer("127.0.0.1:8000")
+	server.Handle("/.well-known/jwks.json", http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	assertProbeStatus(t, server, "/.well-known/jwks.json", http.StatusAccepted)
+	assertProbeStatus(t, server, "/healthz", http.StatusOK)
+}
+
 func TestRunHTTPServersWaitsForMinimumDrainDuration(t *testing.T) {
 	servers := newTestServers(t, http.NotFoundHandler())
 	ctx, cancel := context.WithCancel(context.Background())
@@ -156,29 +166,24 @@ func TestRunHTTPServersClosesIdleKeepAliveConnection(t *testing.T) {
 	}
 }
 
-func TestRunHTTPServersDoesNotWaitForHijackedConnection(t *testing.T) {
+func TestRunHTTPServersWaitsForHijackedConnection(t *testing.T) {
 	hijacked := make(chan struct{})
 	handlerDone := make(chan struct{})
 	servers := newTestServers(t, hijackedConnectionHandler(hijacked, handlerDone))
 	ctx, cancel := context.WithCancel(context.Background())
 	runResult := runTestHTTPServers(ctx,
 		DrainConfig{Timeout: time.Second, MinDuration: 20 * time.Millisecond}, servers, servers.serve)
-	connection := openHijackedConnection(t, servers.listener.Addr().String())
+	connection := openHijackedConnection(t, servers.listener.Addr().String(), "test")
 	<-hijacked
+	assertProbeBody(t, servers.health, "/readyz", "ok, 1 upgraded connections\n")
 
 	cancel()
+	eventuallyProbeStatus(t, servers.health, "/readyz", http.StatusServiceUnavailable)
+	assertProbeBody(t, servers.health, "/readyz", "draining, 1 upgraded connections\n")
 	select {
 	case err := <-runResult:
-		if err != nil {
-			t.Fatalf("drain returned an error: %v", err)
-		}
-	case <-time.After(250 * time.Millisecond):
-		t.Fatal("server waited for hijacked connection")
-	}
-	select {
-	case <-handlerDone:
-		t.Fatal("hijacked connection closed before process exit")
-	default:
+		t.Fatalf("server returned before hijacked connection drained: %v", err)
+	case <-time.After(100 * time.Millisecond):
 	}
 
 	if err := connection.Close(); err != nil {
@@ -189,6 +194,95 @@ func TestRunHTTPServersDoesNotWaitForHijackedConnection(t *testing.T) {
 	case <-time.After(time.Second):
 		t.Fatal("hijacked handler did not finish after client close")
 	}
+	select {
+	case err := <-runResult:
+		if err != nil {
+			t.Fatalf("drain returned an error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop after hijacked connection drained")
+	}
+}
+
+func TestRunHTTPServersRejectsUpgradesWhileDraining(t *testing.T) {
+	servers := newTestServers(t, http.NotFoundHandler())
+	ctx, cancel := context.WithCancel(context.Background())
+	runResult := runTestHTTPServers(ctx,
+		DrainConfig{Timeout: time.Second, MinDuration: 500 * time.Millisecond}, servers, servers.serve)
+	waitForPublicServer(t, http.DefaultClient, servers.url())
+
+	cancel()
+	eventuallyProbeStatus(t, servers.health, "/readyz", http.StatusServiceUnavailable)
+
+	request, err := http.NewRequest(http.MethodGet, servers.url(), nil)
+	if err != nil {
+		t.Fatalf("create upgrade request: %v", err)
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "test")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("upgrade request during drain: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("upgrade status during drain = %d, want %d", response.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	if result := <-requestBody(http.DefaultClient, servers.url()); result.err != nil {
+		t.Fatalf("plain request failed during minimum drain duration: %v", result.err)
+	}
+	if err := <-runResult; err != nil {
+		t.Fatalf("drain returned an error: %v", err)
+	}
+}
+
+func TestRunHTTPServersClosesHijackedConnectionsAtDrainTimeout(t *testing.T) {
+	hijacked := make(chan struct{})
+	servers := newTestServers(t, http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		connection, buffered, err := http.NewResponseController(writer).Hijack()
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+		_, _ = buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		_ = buffered.Flush()
+		// answer the first client message with a complete text frame, as a
+		// proxied stream would write it
+		if _, err := connection.Read(make([]byte, 1)); err != nil {
+			return
+		}
+		_, _ = connection.Write([]byte{0x81, 0x02, 'h', 'i'})
+		close(hijacked)
+		_, _ = io.Copy(io.Discard, connection)
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	runResult := runTestHTTPServers(ctx,
+		DrainConfig{Timeout: 200 * time.Millisecond, MinDuration: 20 * time.Millisecond}, servers, servers.serve)
+	connection := openHijackedConnection(t, servers.listener.Addr().String(), "websocket")
+	defer connection.Close()
+	if _, err := connection.Write([]byte{0}); err != nil {
+		t.Fatalf("write to hijacked connection: %v", err)
+	}
+	<-hijacked
+
+	startedDrain := time.Now()
+	cancel()
+	if err := <-runResult; err != nil {
+		t.Fatalf("drain returned an error: %v", err)
+	}
+	if elapsed := time.Since(startedDrain); elapsed < 180*time.Millisecond {
+		t.Fatalf("hijacked connection closed before drain deadline: %v", elapsed)
+	}
+
+	_ = connection.SetReadDeadline(time.Now().Add(time.Second))
+	received, err := io.ReadAll(connection)
+	if err != nil {
+		t.Fatalf("read hijacked connection: %v", err)
+	}
+	if want := append([]byte{0x81, 0x02, 'h', 'i'}, websocketGoingAway...); string(received) != string(want) {
+		t.Fatalf("received %x, want the text frame followed by a going away close frame", received)
+	}
 }
 
 func TestRunHTTPServersBoundsActiveRequestDrain(t *testing.T) {
@@ -240,9 +334,10 @@ func TestRunHTTPServersPropagatesHealthServerFailure(t *testing.T) {
 }
 
 type testServers struct {
-	public   *http.Server
-	listener net.Listener
-	health   *HealthProbeServer
+	public      *http.Server
+	listener    net.Listener
+	connections *ConnectionTracker
+	health      *HealthProbeServer
 }
 
 func newTestServers(t *testing.T, handler http.Handler) *testServers {
@@ -252,10 +347,12 @@ func newTestServers(t *testing.T, handler http.Handler) *testServers {
 		t.Fatalf("listen for test proxy server: %v", err)
 	}
 
+	connections := NewConnectionTracker()
 	servers := &testServers{
-		public:   &http.Server{Addr: listener.Addr().String(), Handler: handler},
-		listener: listener,
-		health:   NewHealthProbeServer("127.0.0.1:0"),
+		public:      &http.Server{Addr: listener.Addr().String(), Handler: connections.Handler(handler)},
+		listener:    listener,
+		connections: connections,
+		health:      NewHealthProbeServer("127.0.0.1:0"),
 	}
 	t.Cleanup(func() {
 		_ = servers.public.Close()
@@ -281,7 +378,7 @@ func runTestHTTPServers(
 ) <-chan error {
 	result := make(chan error, 1)
 	go func() {
-		result <- runHTTPServers(ctx, config, servers.public, servers.health, servePublic)
+		result <- runHTTPServers(ctx, config, servers.public, servers.connections, servers.health, servePublic)
 	}()
 	return result
 }
@@ -322,6 +419,15 @@ func assertProbeStatus(t *testing.T, server *HealthProbeServer, path string, sta
 	}
 }
 
+func assertProbeBody(t *testing.T, server *HealthProbeServer, path, body string) {
+	t.Helper()
+	response := httptest.NewRecorder()
+	server.Handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+	if response.Body.String() != body {
+		t.Fatalf("%s body = %q, want %q", path, response.Body.String(), body)
+	}
+}
+
 func eventuallyProbeStatus(t *testing.T, server *HealthProbeServer, path string, status int) {
 	t.Helper()
 	deadline := time.Now().Add(time.Second)
@@ -369,14 +475,14 @@ func hijackedConnectionHandler(hijacked, done chan<- struct{}) http.Handler {
 	})
 }
 
-func openHijackedConnection(t *testing.T, address string) net.Conn {
+func openHijackedConnection(t *testing.T, address, protocol string) net.Conn {
 	t.Helper()
 	connection, err := net.DialTimeout("tcp", address, time.Second)
 	if err != nil {
 		t.Fatalf("dial proxy server: %v", err)
 	}
 	if _, err := io.WriteString(connection,
-		"GET / HTTP/1.1\r\nHost: "+address+"\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"); err != nil {
+		"GET / HTTP/1.1\r\nHost: "+address+"\r\nConnection: Upgrade\r\nUpgrade: "+protocol+"\r\n\r\n"); err != nil {
 		_ = connection.Close()
 		t.Fatalf("write upgrade request: %v", err)
 	}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+// DefaultStreamIdleTimeout matches the kubelet default of
+// --streaming-connection-idle-timeout.
+const DefaultStreamIdleTimeout = 4 * time.Hour
+
+// StreamConfig controls upgraded streams such as exec, attach and
+// port-forward sessions. WebSocket and SPDY upgrades are negotiated per
+// request: an upstream rejecting a WebSocket upgrade answers with a non-101
+// status, which is forwarded unchanged and makes kubectl retry with SPDY.
+type StreamConfig struct {
+	// IdleTimeout closes a stream after no data flowed in either direction
+	// for this long. Zero disables it.
+	IdleTimeout time.Duration
+}
+
+func (c StreamConfig) Validate() error {
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("streaming-connection-idle-timeout must not be negative")
+	}
+	return nil
+}
+
+// AddFlags registers the stream flags shared by the proxy server commands.
+func (c *StreamConfig) AddFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&c.IdleTimeout, "streaming-connection-idle-timeout", DefaultStreamIdleTimeout,
+		"Maximum time an exec, attach or port-forward stream may be idle before it is closed. 0 disables the timeout.")
+}
+
+// idleTimeoutStream closes an upgraded backend stream once no data flowed in
+// either direction for the timeout. Closing the backend side also ends the
+// copy to the client in httputil.ReverseProxy.
+type idleTimeoutStream struct {
+	io.ReadWriteCloser
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleTimeoutStream(stream io.ReadWriteCloser, timeout time.Duration) *idleTimeoutStream {
+	return &idleTimeoutStream{
+		ReadWriteCloser: stream,
+		timeout:         timeout,
+		timer: time.AfterFunc(timeout, func() {
+			klog.V(2).Infof("closing upgraded stream idle for %v", timeout)
+			_ = stream.Close()
+		}),
+	}
+}
+
+func (s *idleTimeoutStream) Read(p []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+func (s *idleTimeoutStream) Write(p []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Write(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+func (s *idleTimeoutStream) Close() error {
+	s.timer.Stop()
+	return s.ReadWriteCloser.Close()
+}
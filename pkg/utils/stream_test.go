@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamConfigValidate(t *testing.T) {
+	if err := (StreamConfig{IdleTimeout: -time.Second}).Validate(); err == nil {
+		t.Fatal("expected an error for a negative idle timeout")
+	}
+}
+
+func TestUpgradeAwareTransportClosesIdleStreams(t *testing.T) {
+	closed := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		_ = buffered.Flush()
+		// echo until the proxy side closes the idle stream
+		_, _ = io.Copy(conn, buffered)
+		close(closed)
+	}))
+	defer backend.Close()
+
+	transport := NewUpgradeAwareTransport(&http.Transport{})
+	transport.StreamIdleTimeout = 200 * time.Millisecond
+
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, backend.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("round trip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	stream := resp.Body.(io.ReadWriteCloser)
+	reader := bufio.NewReader(stream)
+
+	// traffic keeps the stream open beyond the idle timeout
+	for range 3 {
+		time.Sleep(100 * time.Millisecond)
+		if _, err := stream.Write([]byte("ping\n")); err != nil {
+			t.Fatalf("stream closed while active: %v", err)
+		}
+		if line, err := reader.ReadString('\n'); err != nil || line != "ping\n" {
+			t.Fatalf("unexpected echo %q: %v", line, err)
+		}
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle stream was not closed")
+	}
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Fatal("expected the idle stream to be closed")
+	}
+}
+
+var _ io.ReadWriteCloser = (*idleTimeoutStream)(nil)
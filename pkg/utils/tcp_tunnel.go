@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServeTCPTunnel answers a TCP tunnel upgrade on wr and copies bytes between
+// the client and backend until either side closes the connection, or no data
+// flowed for idleTimeout when it is positive. It closes backend.
+func ServeTCPTunnel(wr http.ResponseWriter, backend net.Conn, idleTimeout time.Duration) error {
+	defer backend.Close()
+
+	client, brw, err := http.NewResponseController(wr).Hijack()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if _, err := brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: " +
+		TCPTunnelProtocol + "\r\n\r\n"); err != nil {
+		return err
+	}
+	if err := brw.Flush(); err != nil {
+		return err
+	}
+
+	var stream io.ReadWriteCloser = backend
+	if idleTimeout > 0 {
+		stream = newIdleTimeoutStream(backend, idleTimeout)
+	}
+	errc := make(chan error, 2)
+	go func() {
+		// brw holds any bytes the client sent right after the request
+		_, err := io.Copy(stream, brw)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(client, stream)
+		errc <- err
+	}()
+	// the deferred closes end the other copy
+	return <-errc
+}
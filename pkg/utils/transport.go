@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/streaming/pkg/httpstream"
+)
+
+// UpgradeAwareTransport routes requests by the protocol they need. Upgrade
+// requests, such as SPDY and WebSocket exec, attach and port-forward, cannot
+// ride HTTP/2 and use a dedicated HTTP/1.1 transport. Other requests use HTTP/2
+// when the TLS backend negotiates it, so list, watch and gRPC traffic is
+// multiplexed. gRPC requests to plaintext backends use HTTP/2 with prior
+// knowledge (h2c) because gRPC requires HTTP/2.
+type UpgradeAwareTransport struct {
+	upgrade *http.Transport
+	http2   *http.Transport
+	h2c     *http.Transport
+
+	// StreamIdleTimeout closes upgraded streams after no data flowed in
+	// either direction for this long. Zero disables it.
+	StreamIdleTimeout time.Duration
+}
+
+// NewUpgradeAwareTransport derives the per-protocol transports from base,
+// which is not used directly.
+func NewUpgradeAwareTransport(base *http.Transport) *UpgradeAwareTransport {
+	upgrade := base.Clone()
+	upgrade.ForceAttemptHTTP2 = false
+	upgrade.Protocols = &http.Protocols{}
+	upgrade.Protocols.SetHTTP1(true)
+	if upgrade.TLSClientConfig != nil {
+		// the backend must not select h2 through ALPN for an upgrade
+		upgrade.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	http2 := base.Clone()
+	http2.ForceAttemptHTTP2 = true
+	http2.Protocols = &http.Protocols{}
+	http2.Protocols.SetHTTP1(true)
+	http2.Protocols.SetHTTP2(true)
+
+	h2c := base.Clone()
+	h2c.Protocols = &http.Protocols{}
+	h2c.Protocols.SetUnencryptedHTTP2(true)
+
+	return &UpgradeAwareTransport{
+		upgrade: upgrade,
+		http2:   http2,
+		h2c:     h2c,
+	}
+}
+
+func (t *UpgradeAwareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case httpstream.IsUpgradeRequest(req):
+		return t.roundTripUpgrade(req)
+	case req.URL.Scheme == "http" && IsGRPCRequest(req):
+		return t.h2c.RoundTrip(req)
+	default:
+		return t.http2.RoundTrip(req)
+	}
+}
+
+func (t *UpgradeAwareTransport) roundTripUpgrade(req *http.Request) (*http.Response, error) {
+	resp, err := t.upgrade.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols || t.StreamIdleTimeout <= 0 {
+		return resp, err
+	}
+	// httputil.ReverseProxy copies between the client and this stream
+	if stream, ok := resp.Body.(io.ReadWriteCloser); ok {
+		resp.Body = newIdleTimeoutStream(stream, t.StreamIdleTimeout)
+	}
+	return resp, nil
+}
+
+func (t *UpgradeAwareTransport) CloseIdleConnections() {
+	t.upgrade.CloseIdleConnections()
+	t.http2.CloseIdleConnections()
+	t.h2c.CloseIdleConnections()
+}
+
+// IsGRPCRequest reports whether the request carries a gRPC content type, such
+// as application/grpc or application/grpc+proto.
+func IsGRPCRequest(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpgradeAwareTransport(t *testing.T) {
+	protoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Backend-Proto", r.Proto)
+	})
+
+	tlsBackend := httptest.NewUnstartedServer(protoHandler)
+	tlsBackend.EnableHTTP2 = true
+	tlsBackend.StartTLS()
+	defer tlsBackend.Close()
+
+	plainBackend := httptest.NewUnstartedServer(protoHandler)
+	plainBackend.Config.Protocols = &http.Protocols{}
+	plainBackend.Config.Protocols.SetHTTP1(true)
+	plainBackend.Config.Protocols.SetUnencryptedHTTP2(true)
+	plainBackend.Start()
+	defer plainBackend.Close()
+
+	transport := NewUpgradeAwareTransport(tlsBackend.Client().Transport.(*http.Transport))
+	defer transport.CloseIdleConnections()
+
+	tests := []struct {
+		name      string
+		url       string
+		header    http.Header
+		wantProto string
+	}{
+		{
+			name:      "tls request",
+			url:       tlsBackend.URL,
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "tls gRPC request",
+			url:       tlsBackend.URL,
+			header:    http.Header{"Content-Type": {"application/grpc"}},
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "tls upgrade request",
+			url:       tlsBackend.URL,
+			header:    http.Header{"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}},
+			wantProto: "HTTP/1.1",
+		},
+		{
+			name:      "plaintext request",
+			url:       plainBackend.URL,
+			wantProto: "HTTP/1.1",
+		},
+		{
+			name:      "plaintext gRPC request",
+			url:       plainBackend.URL,
+			header:    http.Header{"Content-Type": {"application/grpc+proto"}},
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "plaintext upgrade request",
+			url:       plainBackend.URL,
+			header:    http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Content-Type": {"application/grpc"}},
+			wantProto: "HTTP/1.1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			for key, values := range test.header {
+				req.Header[key] = values
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("round trip failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Backend-Proto"); got != test.wantProto {
+				t.Fatalf("backend received %s, want %s", got, test.wantProto)
+			}
+		})
+	}
+}
+
+func TestIsGRPCRequest(t *testing.T) {
+	tests := map[string]bool{
+		"application/grpc":              true,
+		"application/grpc+proto":        true,
+		"application/grpc; charset=foo": true,
+		"application/grpc-web":          false,
+		"application/json":              false,
+		"":                              false,
+	}
+	for contentType, want := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://backend", nil)
+		req.Header.Set("Content-Type", contentType)
+		if got := IsGRPCRequest(req); got != want {
+			t.Errorf("IsGRPCRequest(%q) = %t, want %t", contentType, got, want)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package utils
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 
 	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/streaming/pkg/httpstream"
 )
 
 const (
@@ -24,16 +26,50 @@ const (
 	HeaderClusterProxyNamespace = "Cluster-Proxy-Namespace"
 	HeaderClusterProxyService   = "Cluster-Proxy-Service"
 	HeaderClusterProxyPort      = "Cluster-Proxy-Port"
+	HeaderClusterProxyPod       = "Cluster-Proxy-Pod"
+	// HeaderClusterProxyPodSelector carries one base64-encoded label selector
+	// per allowlist entry exposing the target pod; the service-proxy checks the
+	// pod labels. Selectors contain commas, so they are encoded to survive
+	// intermediaries folding repeated headers into one comma-separated value.
+	HeaderClusterProxyPodSelector = "Cluster-Proxy-Pod-Selector"
+
+	// ProtoTCP is the protocol of raw TCP tunnel targets.
+	ProtoTCP = "tcp"
+	// TCPTunnelProtocol is the Upgrade protocol of raw TCP tunnels.
+	TCPTunnelProtocol = "cluster-proxy-tcp"
 )
 
+// EncodePodSelector encodes a label selector for HeaderClusterProxyPodSelector.
+func EncodePodSelector(selector string) string {
+	return base64.StdEncoding.EncodeToString([]byte(selector))
+}
+
+// DecodePodSelectors decodes the HeaderClusterProxyPodSelector values, whether
+// sent as repeated headers or folded into comma-separated values.
+func DecodePodSelectors(values []string) ([]string, error) {
+	var selectors []string
+	for _, value := range values {
+		for _, encoded := range strings.Split(value, ",") {
+			selector, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pod selector %q: %w", encoded, err)
+			}
+			selectors = append(selectors, string(selector))
+		}
+	}
+	return selectors, nil
+}
+
 // TargetServiceConfig is a collection of data extrict from the request URL description the target service we can to access on the managed cluster.
 // There are 2 usages of it:
 // 1. used in function `ServiceProxyURL` to construct the target service URL.
 // 2. used in function `UpdateRequest` to update the request object.
 type TargetServiceConfig struct {
-	Cluster   string
-	Proto     string
-	Service   string
+	Cluster string
+	Proto   string
+	Service string
+	// Pod is set instead of Service when the target is a single pod.
+	Pod       string
 	Namespace string
 	Port      string
 	Path      string
@@ -48,6 +84,9 @@ func UpdateRequest(t TargetServiceConfig, req *http.Request) *http.Request {
 	req.Header.Set(HeaderClusterProxyNamespace, t.Namespace)
 	req.Header.Set(HeaderClusterProxyService, t.Service)
 	req.Header.Set(HeaderClusterProxyPort, t.Port)
+	req.Header.Set(HeaderClusterProxyPod, t.Pod)
+	// pod selectors are only set by the user-server after the allowlist check
+	req.Header.Del(HeaderClusterProxyPodSelector)
 
 	return req
 }
@@ -55,6 +94,7 @@ func UpdateRequest(t TargetServiceConfig, req *http.Request) *http.Request {
 // GetTargetServiceConfig extrict the target service config from requestURL
 // input: https://<route location cluster-proxy>/cluster1/api/v1/namespaces/default/services/<https:helloworld:8080>/proxy-service/ping?time-out=32s
 // output: TargetServiceConfig{Cluster: cluster1, Proto: https, Service: helloworld, Namespace: default, Port: 8080, Path: /ping}
+// A pod is targeted with pods instead of services, such as .../namespaces/default/pods/<http:web-0:8080>/proxy-service/metrics.
 func GetTargetServiceConfig(requestURL string) (ts TargetServiceConfig, err error) {
 	urlparams := strings.Split(requestURL, "/")
 	if len(urlparams) < 9 {
@@ -63,10 +103,14 @@ func GetTargetServiceConfig(requestURL string) (ts TargetServiceConfig, err erro
 	}
 
 	namespace := urlparams[5]
+	resource := urlparams[6]
+	if resource != "services" && resource != "pods" {
+		return TargetServiceConfig{}, fmt.Errorf("requestURL format not correct, expected services or pods: %s", requestURL)
+	}
 
-	proto, service, port, valid := utilnet.SplitSchemeNamePort(urlparams[7])
+	proto, name, port, valid := utilnet.SplitSchemeNamePort(urlparams[7])
 	if !valid {
-		return TargetServiceConfig{}, fmt.Errorf("invalid service name %q", urlparams[7])
+		return TargetServiceConfig{}, fmt.Errorf("invalid %s name %q", strings.TrimSuffix(resource, "s"), urlparams[7])
 	}
 	if proto == "" {
 		proto = "https" // set a default to https
@@ -75,14 +119,19 @@ func GetTargetServiceConfig(requestURL string) (ts TargetServiceConfig, err erro
 	servicePath := strings.Join(urlparams[9:], "/")
 	servicePath = strings.Split(servicePath, "?")[0] //we only need path here, the proxy pkg would add params back
 
-	return TargetServiceConfig{
+	ts = TargetServiceConfig{
 		Cluster:   urlparams[1],
 		Proto:     proto,
-		Service:   service,
 		Namespace: namespace,
 		Port:      port,
 		Path:      servicePath,
-	}, nil
+	}
+	if resource == "pods" {
+		ts.Pod = name
+	} else {
+		ts.Service = name
+	}
+	return ts, nil
 }
 
 // GetTargetServiceConfigForKubeAPIServer extrict the kube apiserver config from requestURL
@@ -109,6 +158,55 @@ func GetTargetServiceConfigForKubeAPIServer(requestURL string) (ts TargetService
 	return ts, nil
 }
 
+// GetTargetServiceConfigForTCPTunnel extrict the target service config of a raw TCP tunnel from requestURL
+// input: https://<route location cluster-proxy>/cluster1/api/v1/namespaces/db/services/postgres:5432/proxy-tcp
+// output: TargetServiceConfig{Cluster: cluster1, Proto: tcp, Service: postgres, Namespace: db, Port: 5432}
+func GetTargetServiceConfigForTCPTunnel(requestURL string) (TargetServiceConfig, error) {
+	urlparams := strings.Split(strings.Split(requestURL, "?")[0], "/")
+	if len(urlparams) != 9 || urlparams[6] != "services" {
+		return TargetServiceConfig{}, fmt.Errorf("requestURL format not correct, expected .../services/<service>:<port>/proxy-tcp: %s", requestURL)
+	}
+
+	// SplitSchemeNamePort only knows the http and https schemes
+	scheme, service, port, valid := utilnet.SplitSchemeNamePort(strings.TrimPrefix(urlparams[7], ProtoTCP+":"))
+	if !valid || scheme != "" || port == "" {
+		return TargetServiceConfig{}, fmt.Errorf("invalid TCP service %q, expected <service>:<port>", urlparams[7])
+	}
+
+	return TargetServiceConfig{
+		Cluster:   urlparams[1],
+		Proto:     ProtoTCP,
+		Service:   service,
+		Namespace: urlparams[5],
+		Port:      port,
+	}, nil
+}
+
+// IsTCPTunnelRequest reports whether the request asks to upgrade to a raw TCP
+// tunnel.
+func IsTCPTunnelRequest(req *http.Request) bool {
+	return httpstream.IsUpgradeRequest(req) && strings.EqualFold(req.Header.Get("Upgrade"), TCPTunnelProtocol)
+}
+
+// GetTargetPodFromRequest is used on the agent side to read the pod a request
+// targets. ok is false when the request targets a Service.
+func GetTargetPodFromRequest(req *http.Request) (ts TargetServiceConfig, ok bool, err error) {
+	pod := req.Header.Get(HeaderClusterProxyPod)
+	if pod == "" {
+		return TargetServiceConfig{}, false, nil
+	}
+	ts = TargetServiceConfig{
+		Proto:     req.Header.Get(HeaderClusterProxyProto),
+		Pod:       pod,
+		Namespace: req.Header.Get(HeaderClusterProxyNamespace),
+		Port:      req.Header.Get(HeaderClusterProxyPort),
+	}
+	if ts.Namespace == "" || (ts.Proto != "http" && ts.Proto != "https") {
+		return TargetServiceConfig{}, true, fmt.Errorf("invalid request headers")
+	}
+	return ts, true, nil
+}
+
 // GetTargetServiceURLFromRequest is used on the agent side, the service-proxy agent received a request from the proxy-agent, and need to know the target service URL to do further proxy.
 func GetTargetServiceURLFromRequest(req *http.Request) (*url.URL, error) {
 	// get proto, namespace, service, and port from request headers
@@ -141,15 +239,21 @@ func GetTargetServiceURLFromRequest(req *http.Request) (*url.URL, error) {
 const (
 	ProxyTypeService = iota
 	ProxyTypeKubeAPIServer
+	ProxyTypeTCP
 )
 
-// GetProxyType determines whether a request meant to proxy to a regular service or the kube-apiserver of the managed cluster.
+// GetProxyType determines whether a request meant to proxy to a regular service, tunnel TCP to a service, or proxy to the kube-apiserver of the managed cluster.
 // An example of service: https://<route location cluster-proxy>/<managed_cluster_name>/api/v1/namespaces/<namespace_name>/services/<[https:]service_name[:port_name]>/proxy-service/<service_path>
+// An example of pod: https://<route location cluster-proxy>/<managed_cluster_name>/api/v1/namespaces/<namespace_name>/pods/<[https:]pod_name[:port_name]>/proxy-service/<service_path>
+// An example of TCP tunnel: https://<route location cluster-proxy>/<managed_cluster_name>/api/v1/namespaces/<namespace_name>/services/<service_name:port>/proxy-tcp
 // An example of kube-apiserver: https://<route location cluster-proxy>/<managed_cluster_name>/api/pods?timeout=32s
 func GetProxyType(reqURI string) int {
 	urlparams := strings.Split(reqURI, "/")
 	if len(urlparams) > 9 && urlparams[8] == "proxy-service" {
 		return ProxyTypeService
 	}
+	if len(urlparams) == 9 && strings.Split(urlparams[8], "?")[0] == "proxy-tcp" {
+		return ProxyTypeTCP
+	}
 	return ProxyTypeKubeAPIServer
 }
@@ -86,6 +86,22 @@ func TestGetProxyType(t *testing.T) {
 			requestURL: "route-domain/cluster1/api/v1/namespaces/default/services/https:nginx:80/proxy-service/hello",
 			proxyType:  ProxyTypeService,
 		},
+		{
+			requestURL: "route-domain/cluster1/api/v1/namespaces/default/pods/http:web-0:8080/proxy-service/hello",
+			proxyType:  ProxyTypeService,
+		},
+		{
+			requestURL: "route-domain/cluster1/api/v1/namespaces/default/pods/web-0/proxy/hello",
+			proxyType:  ProxyTypeKubeAPIServer,
+		},
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/services/postgres:5432/proxy-tcp",
+			proxyType:  ProxyTypeTCP,
+		},
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/services/postgres:5432/proxy-tcp/extra",
+			proxyType:  ProxyTypeKubeAPIServer,
+		},
 	}
 
 	for _, tc := range testcases {
@@ -125,6 +141,22 @@ func TestParseServiceRequestURL(t *testing.T) {
 				Path:      "",
 			},
 		},
+		{
+			requestURL: "route-domain/cluster1/api/v1/namespaces/monitoring/pods/http:prometheus-0:metrics/proxy-service/metrics",
+			expect: TargetServiceConfig{
+				Cluster:   "cluster1",
+				Proto:     "http",
+				Pod:       "prometheus-0",
+				Namespace: "monitoring",
+				Port:      "metrics",
+				Path:      "metrics",
+			},
+		},
+		{
+			requestURL: "route-domain/cluster1/api/v1/namespaces/default/endpoints/nginx/proxy-service/hello",
+			expect:     TargetServiceConfig{},
+			err:        fmt.Errorf("requestURL format not correct, expected services or pods"),
+		},
 		{
 			requestURL: "route-domain/cluster1/proxy-service/hello?timeout=32s",
 			expect:     TargetServiceConfig{},
@@ -140,6 +172,9 @@ func TestParseServiceRequestURL(t *testing.T) {
 			}
 			continue
 		}
+		if tc.err != nil {
+			t.Fatalf("expected err %v, but got none", tc.err)
+		}
 
 		// compare every field in targetServiceConfig
 		if actual.Cluster != tc.expect.Cluster {
@@ -151,6 +186,9 @@ func TestParseServiceRequestURL(t *testing.T) {
 		if actual.Service != tc.expect.Service {
 			t.Errorf("expected service: %v, got: %v", tc.expect.Service, actual.Service)
 		}
+		if actual.Pod != tc.expect.Pod {
+			t.Errorf("expected pod: %v, got: %v", tc.expect.Pod, actual.Pod)
+		}
 		if actual.Namespace != tc.expect.Namespace {
 			t.Errorf("expected namespace: %v, got: %v", tc.expect.Namespace, actual.Namespace)
 		}
@@ -163,6 +201,45 @@ func TestParseServiceRequestURL(t *testing.T) {
 	}
 }
 
+func TestGetTargetServiceConfigForTCPTunnel(t *testing.T) {
+	testcases := []struct {
+		requestURL string
+		expect     TargetServiceConfig
+		wantErr    bool
+	}{
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/services/postgres:5432/proxy-tcp",
+			expect:     TargetServiceConfig{Cluster: "cluster1", Proto: ProtoTCP, Service: "postgres", Namespace: "db", Port: "5432"},
+		},
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/services/tcp:redis:6379/proxy-tcp?timeout=32s",
+			expect:     TargetServiceConfig{Cluster: "cluster1", Proto: ProtoTCP, Service: "redis", Namespace: "db", Port: "6379"},
+		},
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/services/postgres/proxy-tcp",
+			wantErr:    true,
+		},
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/services/https:postgres:5432/proxy-tcp",
+			wantErr:    true,
+		},
+		{
+			requestURL: "/cluster1/api/v1/namespaces/db/pods/postgres-0:5432/proxy-tcp",
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range testcases {
+		actual, err := GetTargetServiceConfigForTCPTunnel(tc.requestURL)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %t", tc.requestURL, err, tc.wantErr)
+		}
+		if actual != tc.expect {
+			t.Errorf("%s: expected %+v, got %+v", tc.requestURL, tc.expect, actual)
+		}
+	}
+}
+
 func TestUpdateRequest(t *testing.T) {
 	tsc := TargetServiceConfig{
 		Cluster:   "cluster1",
@@ -217,6 +294,93 @@ func TestUpdateRequest(t *testing.T) {
 	}
 }
 
+func TestUpdateRequestReplacesPodHeaders(t *testing.T) {
+	req := &http.Request{
+		Header: map[string][]string{
+			HeaderClusterProxyPod:         {"injected"},
+			HeaderClusterProxyPodSelector: {""},
+		},
+		URL: &url.URL{},
+	}
+	UpdateRequest(TargetServiceConfig{Proto: "https", Service: "hello-world", Namespace: "default", Port: "9091"}, req)
+	if pod := req.Header.Get(HeaderClusterProxyPod); pod != "" {
+		t.Errorf("expected no pod for a Service target, got %q", pod)
+	}
+	if _, ok := req.Header[HeaderClusterProxyPodSelector]; ok {
+		t.Errorf("expected client pod selectors to be removed")
+	}
+
+	UpdateRequest(TargetServiceConfig{Proto: "http", Pod: "web-0", Namespace: "default", Port: "8080"}, req)
+	if pod := req.Header.Get(HeaderClusterProxyPod); pod != "web-0" {
+		t.Errorf("expected pod web-0, got %q", pod)
+	}
+}
+
+func TestGetTargetPodFromRequest(t *testing.T) {
+	testcases := []struct {
+		name    string
+		header  http.Header
+		expect  TargetServiceConfig
+		ok      bool
+		wantErr bool
+	}{
+		{
+			name: "service target",
+			header: http.Header{
+				HeaderClusterProxyProto:     {"https"},
+				HeaderClusterProxyService:   {"hello-world"},
+				HeaderClusterProxyNamespace: {"default"},
+				HeaderClusterProxyPort:      {"9091"},
+				HeaderClusterProxyPod:       {""},
+			},
+		},
+		{
+			name: "pod target",
+			header: http.Header{
+				HeaderClusterProxyProto:     {"http"},
+				HeaderClusterProxyNamespace: {"default"},
+				HeaderClusterProxyPort:      {"metrics"},
+				HeaderClusterProxyPod:       {"web-0"},
+			},
+			expect: TargetServiceConfig{Proto: "http", Pod: "web-0", Namespace: "default", Port: "metrics"},
+			ok:     true,
+		},
+		{
+			name: "pod target without port",
+			header: http.Header{
+				HeaderClusterProxyProto:     {"https"},
+				HeaderClusterProxyNamespace: {"default"},
+				HeaderClusterProxyPod:       {"web-0"},
+			},
+			expect: TargetServiceConfig{Proto: "https", Pod: "web-0", Namespace: "default"},
+			ok:     true,
+		},
+		{
+			name: "pod target over TCP",
+			header: http.Header{
+				HeaderClusterProxyProto:     {ProtoTCP},
+				HeaderClusterProxyNamespace: {"default"},
+				HeaderClusterProxyPort:      {"5432"},
+				HeaderClusterProxyPod:       {"postgres-0"},
+			},
+			ok:      true,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok, err := GetTargetPodFromRequest(&http.Request{Header: tc.header})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if ok != tc.ok || actual != tc.expect {
+				t.Errorf("expected %+v, %t, got %+v, %t", tc.expect, tc.ok, actual, ok)
+			}
+		})
+	}
+}
+
 func TestGetTargetServiceURLFromRequest(t *testing.T) {
 	testcases := []struct {
 		name   string
@@ -345,3 +509,22 @@ func TestGetTargetServiceURLFromRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodePodSelectors(t *testing.T) {
+	header := http.Header{}
+	header.Add(HeaderClusterProxyPodSelector, EncodePodSelector("app=a,tier=b"))
+	// a proxy may fold repeated headers into one comma-separated value
+	header.Add(HeaderClusterProxyPodSelector, EncodePodSelector("app=c")+", "+EncodePodSelector(""))
+
+	selectors, err := DecodePodSelectors(header.Values(HeaderClusterProxyPodSelector))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(selectors) != 3 || selectors[0] != "app=a,tier=b" || selectors[1] != "app=c" || selectors[2] != "" {
+		t.Errorf("unexpected selectors %q", selectors)
+	}
+
+	if _, err := DecodePodSelectors([]string{"app=a"}); err == nil {
+		t.Errorf("expected an error for a selector that is not base64-encoded")
+	}
+}